@@ -0,0 +1,197 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package cache response cache for the forwarded queries
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"dns-server/util"
+)
+
+// Stats cache statistics.
+type Stats struct {
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+	Size     int    `json:"size"`
+	Capacity int    `json:"capacity"`
+}
+
+type key struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+}
+
+type entry struct {
+	key    key
+	msg    *dns.Msg
+	stored time.Time
+	expiry time.Time
+}
+
+// Cache TTL respecting response cache with LRU eviction, caching both positive and negative(RFC 2308) responses.
+type Cache struct {
+	mutex    sync.Mutex
+	capacity int
+	items    map[key]*list.Element
+	lru      *list.List
+	hits     uint64
+	misses   uint64
+}
+
+// New create a cache holding up to capacity responses.
+func New(capacity int) *Cache {
+	return &Cache{capacity: capacity, items: make(map[key]*list.Element), lru: list.New()}
+}
+
+func newKey(q *dns.Question) key {
+	return key{name: strings.ToLower(q.Name), qtype: q.Qtype, qclass: q.Qclass}
+}
+
+// Get get the cached response for the question with the remaining TTL, nil if not cached.
+func (c *Cache) Get(q *dns.Question) *dns.Msg {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	k := newKey(q)
+	elem, ok := c.items[k]
+	if !ok {
+		c.misses++
+		return nil
+	}
+	e := elem.Value.(*entry)
+	now := time.Now()
+	if !now.Before(e.expiry) {
+		c.removeElement(elem)
+		c.misses++
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	c.hits++
+
+	msg := e.msg.Copy()
+	elapsed := uint32(now.Sub(e.stored) / time.Second)
+	for _, section := range [][]dns.RR{msg.Answer, msg.Ns, msg.Extra} {
+		for _, rr := range section {
+			if rr.Header().Rrtype == dns.TypeOPT {
+				continue
+			}
+			if rr.Header().Ttl > elapsed {
+				rr.Header().Ttl -= elapsed
+			} else {
+				rr.Header().Ttl = 0
+			}
+		}
+	}
+
+	return msg
+}
+
+// Set cache the response, responses which are not cacheable are ignored.
+func (c *Cache) Set(msg *dns.Msg) {
+	if len(msg.Question) != 1 || msg.Truncated {
+		return
+	}
+	ttl, ok := cacheTTL(msg)
+	if !ok || ttl == 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.capacity <= 0 {
+		return
+	}
+	k := newKey(&msg.Question[0])
+	now := time.Now()
+	e := &entry{key: k, msg: msg.Copy(), stored: now, expiry: now.Add(time.Duration(ttl) * time.Second)}
+	if elem, ok := c.items[k]; ok {
+		elem.Value = e
+		c.lru.MoveToFront(elem)
+		return
+	}
+	for c.lru.Len() >= c.capacity {
+		c.removeElement(c.lru.Back())
+	}
+	c.items[k] = c.lru.PushFront(e)
+}
+
+// Flush remove all the cached responses.
+func (c *Cache) Flush() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.items = make(map[key]*list.Element)
+	c.lru.Init()
+}
+
+// Stats get the cache statistics.
+func (c *Cache) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return Stats{Hits: c.hits, Misses: c.misses, Size: c.lru.Len(), Capacity: c.capacity}
+}
+
+func (c *Cache) removeElement(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.items, elem.Value.(*entry).key)
+}
+
+// cacheTTL get the TTL to cache the response, minimum TTL of the answer for positive responses and the SOA minimum
+// for negative responses(RFC 2308).
+func cacheTTL(msg *dns.Msg) (uint32, bool) {
+	switch {
+	case msg.Rcode == dns.RcodeSuccess && len(msg.Answer) != 0:
+		return minTTL(msg.Answer, util.MaxCacheTTL), true
+	case msg.Rcode == dns.RcodeNameError || (msg.Rcode == dns.RcodeSuccess && len(msg.Answer) == 0):
+		for _, rr := range msg.Ns {
+			if soa, ok := rr.(*dns.SOA); ok {
+				ttl := soa.Minttl
+				if soa.Hdr.Ttl < ttl {
+					ttl = soa.Hdr.Ttl
+				}
+				if ttl > util.MaxNegativeCacheTTL {
+					ttl = util.MaxNegativeCacheTTL
+				}
+				return ttl, true
+			}
+		}
+		// Negative responses without SOA are not cached
+		return 0, false
+	default:
+		return 0, false
+	}
+}
+
+func minTTL(rrs []dns.RR, max uint32) uint32 {
+	ttl := max
+	for _, rr := range rrs {
+		if rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+
+	return ttl
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cache
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	exampleDomain  = "www.example.com."
+	example1Domain = "www.example1.com."
+	example2Domain = "www.example2.com."
+	errorInCache   = "Error in cache"
+)
+
+func newResponse(name string, ttl uint32) *dns.Msg {
+	req := new(dns.Msg)
+	req.SetQuestion(name, dns.TypeA)
+	rsp := new(dns.Msg)
+	rsp.SetReply(req)
+	rsp.Answer = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl},
+		A: net.ParseIP("192.168.1.100")}}
+	return rsp
+}
+
+func newNegativeResponse(name string, rcode int, soaTTL uint32, minTTL uint32) *dns.Msg {
+	req := new(dns.Msg)
+	req.SetQuestion(name, dns.TypeA)
+	rsp := new(dns.Msg)
+	rsp.SetRcode(req, rcode)
+	rsp.Ns = []dns.RR{&dns.SOA{Hdr: dns.RR_Header{Name: "example.com.", Rrtype: dns.TypeSOA,
+		Class: dns.ClassINET, Ttl: soaTTL}, Ns: "ns1.example.com.", Mbox: "admin.example.com.", Minttl: minTTL}}
+	return rsp
+}
+
+func TestCache(t *testing.T) {
+	t.Run("PositiveResponse", func(t *testing.T) {
+		c := New(10)
+		c.Set(newResponse(exampleDomain, 30))
+		cached := c.Get(&dns.Question{Name: "WWW.Example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.NotEqual(t, (*dns.Msg)(nil), cached, errorInCache)
+		assert.Equal(t, uint32(30), cached.Answer[0].Header().Ttl, errorInCache)
+
+		assert.Equal(t, (*dns.Msg)(nil), c.Get(&dns.Question{Name: exampleDomain, Qtype: dns.TypeAAAA,
+			Qclass: dns.ClassINET}), errorInCache)
+		assert.Equal(t, Stats{Hits: 1, Misses: 1, Size: 1, Capacity: 10}, c.Stats(), errorInCache)
+	})
+
+	t.Run("RemainingTTL", func(t *testing.T) {
+		c := New(10)
+		c.Set(newResponse(exampleDomain, 30))
+		c.items[newKey(&dns.Question{Name: exampleDomain, Qtype: dns.TypeA, Qclass: dns.ClassINET})].Value.(*entry).
+			stored = time.Now().Add(-10 * time.Second)
+		cached := c.Get(&dns.Question{Name: exampleDomain, Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.Equal(t, uint32(20), cached.Answer[0].Header().Ttl, errorInCache)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		c := New(10)
+		c.Set(newResponse(exampleDomain, 30))
+		c.items[newKey(&dns.Question{Name: exampleDomain, Qtype: dns.TypeA, Qclass: dns.ClassINET})].Value.(*entry).
+			expiry = time.Now().Add(-time.Second)
+		assert.Equal(t, (*dns.Msg)(nil), c.Get(&dns.Question{Name: exampleDomain, Qtype: dns.TypeA,
+			Qclass: dns.ClassINET}), errorInCache)
+		assert.Equal(t, 0, c.Stats().Size, errorInCache)
+	})
+
+	t.Run("NegativeResponse", func(t *testing.T) {
+		c := New(10)
+		c.Set(newNegativeResponse(exampleDomain, dns.RcodeNameError, 60, 10))
+		cached := c.Get(&dns.Question{Name: exampleDomain, Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.Equal(t, dns.RcodeNameError, cached.Rcode, errorInCache)
+		entryTTL := c.items[newKey(&dns.Question{Name: exampleDomain, Qtype: dns.TypeA,
+			Qclass: dns.ClassINET})].Value.(*entry)
+		assert.Equal(t, 10*time.Second, entryTTL.expiry.Sub(entryTTL.stored), errorInCache)
+
+		// NODATA
+		c.Set(newNegativeResponse(example1Domain, dns.RcodeSuccess, 60, 120))
+		assert.NotEqual(t, (*dns.Msg)(nil), c.Get(&dns.Question{Name: example1Domain, Qtype: dns.TypeA,
+			Qclass: dns.ClassINET}), errorInCache)
+
+		// Without SOA negative responses are not cached
+		noSOA := newNegativeResponse(example2Domain, dns.RcodeNameError, 60, 10)
+		noSOA.Ns = nil
+		c.Set(noSOA)
+		assert.Equal(t, (*dns.Msg)(nil), c.Get(&dns.Question{Name: example2Domain, Qtype: dns.TypeA,
+			Qclass: dns.ClassINET}), errorInCache)
+	})
+
+	t.Run("NotCacheable", func(t *testing.T) {
+		c := New(10)
+		failure := newNegativeResponse(exampleDomain, dns.RcodeServerFailure, 60, 10)
+		c.Set(failure)
+		truncated := newResponse(example1Domain, 30)
+		truncated.Truncated = true
+		c.Set(truncated)
+		c.Set(newResponse(example2Domain, 0))
+		assert.Equal(t, 0, c.Stats().Size, errorInCache)
+
+		disabled := New(0)
+		disabled.Set(newResponse(exampleDomain, 30))
+		assert.Equal(t, 0, disabled.Stats().Size, errorInCache)
+	})
+
+	t.Run("LRUEviction", func(t *testing.T) {
+		c := New(2)
+		c.Set(newResponse(exampleDomain, 30))
+		c.Set(newResponse(example1Domain, 30))
+		// Access makes the entry recently used
+		_ = c.Get(&dns.Question{Name: exampleDomain, Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		c.Set(newResponse(example2Domain, 30))
+
+		assert.Equal(t, 2, c.Stats().Size, errorInCache)
+		assert.NotEqual(t, (*dns.Msg)(nil), c.Get(&dns.Question{Name: exampleDomain, Qtype: dns.TypeA,
+			Qclass: dns.ClassINET}), errorInCache)
+		assert.Equal(t, (*dns.Msg)(nil), c.Get(&dns.Question{Name: example1Domain, Qtype: dns.TypeA,
+			Qclass: dns.ClassINET}), errorInCache)
+	})
+
+	t.Run("Flush", func(t *testing.T) {
+		c := New(10)
+		c.Set(newResponse(exampleDomain, 30))
+		c.Flush()
+		assert.Equal(t, 0, c.Stats().Size, errorInCache)
+		assert.Equal(t, (*dns.Msg)(nil), c.Get(&dns.Question{Name: exampleDomain, Qtype: dns.TypeA,
+			Qclass: dns.ClassINET}), errorInCache)
+	})
+}
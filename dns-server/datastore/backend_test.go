@@ -0,0 +1,269 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"dns-server/health"
+	"dns-server/util"
+)
+
+const errorInBackend = "Error in data store backend"
+
+// etcdTestEndpoints comma separated endpoints of the etcd to test the etcd backend against, skipped if not set.
+var etcdTestEndpoints = os.Getenv("DNS_SERVER_TEST_ETCD")
+
+type newStoreFunc func(name string, monitor *health.Monitor) DataStore
+
+// forEachBackend run the test against each data store backend, creating an empty store of the backend.
+func forEachBackend(t *testing.T, test func(t *testing.T, newStore newStoreFunc)) {
+	t.Run("BoltDB", func(t *testing.T) {
+		test(t, func(name string, monitor *health.Monitor) DataStore {
+			return &BoltDB{FileName: name, TTL: 30, Health: monitor}
+		})
+	})
+	t.Run("Memory", func(t *testing.T) {
+		test(t, func(name string, monitor *health.Monitor) DataStore {
+			return &MemoryStore{Health: monitor}
+		})
+	})
+	t.Run("Etcd", func(t *testing.T) {
+		if len(etcdTestEndpoints) == 0 {
+			t.Skip("etcd endpoints not set in DNS_SERVER_TEST_ETCD")
+		}
+		test(t, func(name string, monitor *health.Monitor) DataStore {
+			prefix := "/dns-server-test/" + name + "/"
+			clearEtcdPrefix(t, prefix)
+			return &EtcdStore{Endpoints: strings.Split(etcdTestEndpoints, ","), Prefix: prefix, Health: monitor}
+		})
+	})
+}
+
+func clearEtcdPrefix(t *testing.T, prefix string) {
+	client, err := clientv3.New(clientv3.Config{Endpoints: strings.Split(etcdTestEndpoints, ","),
+		DialTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Failed to connect to etcd. %s", err.Error())
+	}
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err = client.Delete(ctx, prefix, clientv3.WithPrefix()); err != nil {
+		t.Fatalf("Failed to clear etcd. %s", err.Error())
+	}
+}
+
+func TestFlatKey(t *testing.T) {
+	key := "{\"host\":\"a/b.example.com.\",\"rrType\":1}"
+	flat := flatKey([]string{ZoneConfig, "example.com."}, key)
+	assert.Equal(t, "zone/example.com./%7B%22host%22:%22a%2Fb.example.com.%22%2C%22rrType%22:1%7D", flat,
+		errorInBackend)
+	path, k, err := splitFlatKey(flat)
+	assert.Equal(t, nil, err, errorInBackend)
+	assert.Equal(t, []string{ZoneConfig, "example.com."}, path, errorInBackend)
+	assert.Equal(t, key, k, errorInBackend)
+
+	path, k, _ = splitFlatKey(flatKey([]string{JournalConfig, "example.com."}, ""))
+	assert.Equal(t, []string{JournalConfig, "example.com."}, path, errorInBackend)
+	assert.Equal(t, "", k, errorInBackend)
+}
+
+func TestMemoryBackendRollback(t *testing.T) {
+	backend := &memBackend{root: newMemNode()}
+	err := backend.Update(func(tx kvTx) error {
+		bkt, err := tx.CreateBucketIfNotExists([]byte(ZoneConfig))
+		assert.Equal(t, nil, err, errorInBackend)
+		return bkt.Put([]byte("a"), []byte("1"))
+	})
+	assert.Equal(t, nil, err, errorInBackend)
+
+	err = backend.Update(func(tx kvTx) error {
+		bkt := tx.Bucket([]byte(ZoneConfig))
+		_ = bkt.Put([]byte("a"), []byte("2"))
+		_ = bkt.Put([]byte("b"), []byte("2"))
+		_, _ = bkt.CreateBucket([]byte("c"))
+		_, _ = bkt.NextSequence()
+		_, err := tx.CreateBucketIfNotExists([]byte(ViewConfig))
+		assert.Equal(t, nil, err, errorInBackend)
+		return errBucketNotFound
+	})
+	assert.Equal(t, errBucketNotFound, err, errorInBackend)
+
+	_ = backend.View(func(tx kvTx) error {
+		assert.Equal(t, nil, tx.Bucket([]byte(ViewConfig)), errorInBackend)
+		var keys []string
+		_ = tx.Bucket([]byte(ZoneConfig)).ForEach(func(k, v []byte) error {
+			keys = append(keys, string(k)+"="+string(v))
+			return nil
+		})
+		assert.Equal(t, []string{"a=1"}, keys, errorInBackend)
+		assert.Equal(t, errTxNotWritable, tx.Bucket([]byte(ZoneConfig)).Put([]byte("a"), nil), errorInBackend)
+		return nil
+	})
+}
+
+func TestMemoryBackendCursor(t *testing.T) {
+	backend := &memBackend{root: newMemNode()}
+	err := backend.Update(func(tx kvTx) error {
+		bkt, err := tx.CreateBucketIfNotExists([]byte(ZoneConfig))
+		assert.Equal(t, nil, err, errorInBackend)
+		for _, k := range []string{"d", "b", "e", "a"} {
+			assert.Equal(t, nil, bkt.Put([]byte(k), []byte(k)), errorInBackend)
+		}
+		_, err = bkt.CreateBucket([]byte("c"))
+		assert.Equal(t, nil, err, errorInBackend)
+		assert.Equal(t, nil, bkt.Delete([]byte("e")), errorInBackend)
+
+		// Keys deleted while iterating are skipped
+		var keys []string
+		c := bkt.Cursor()
+		for k, _ := c.Seek([]byte("b")); k != nil; k, _ = c.Next() {
+			keys = append(keys, string(k))
+			if string(k) == "b" {
+				assert.Equal(t, nil, c.Delete(), errorInBackend)
+				assert.Equal(t, nil, bkt.Delete([]byte("d")), errorInBackend)
+			}
+		}
+		assert.Equal(t, []string{"b", "c"}, keys, errorInBackend)
+		return errBucketNotFound
+	})
+	assert.Equal(t, errBucketNotFound, err, errorInBackend)
+
+	// Sorted keys are restored on rollback
+	assert.Equal(t, 0, len(backend.root.keys), errorInBackend)
+}
+
+func TestNameIndexBuiltOnOpen(t *testing.T) {
+	// Zone stored without the name index
+	backend := &memBackend{root: newMemNode()}
+	err := backend.Update(func(tx kvTx) error {
+		zoneCfgBkt, _ := tx.CreateBucketIfNotExists([]byte(ZoneConfig))
+		zoneBkt, _ := zoneCfgBkt.CreateBucketIfNotExists([]byte("example.com."))
+		key, _ := newRecordKey("x.ent.example.com.", "A")
+		return zoneBkt.Put(key, []byte("{}"))
+	})
+	assert.Equal(t, nil, err, errorInBackend)
+
+	store := &bucketStore{backend: backend, loadBalance: newSwitchFlag(false)}
+	assert.Equal(t, nil, store.Open(), errorInBackend)
+	_ = backend.View(func(tx kvTx) error {
+		zoneBkt := tx.Bucket([]byte(ZoneConfig)).Bucket([]byte("example.com."))
+		assert.Equal(t, true, store.hasDescendants(zoneBkt, "ent.example.com."), errorInBackend)
+		assert.Equal(t, true, store.hasDescendants(zoneBkt, DefaultZone), errorInBackend)
+		assert.Equal(t, false, store.hasDescendants(zoneBkt, "x.ent.example.com."), errorInBackend)
+		assert.Equal(t, false, store.hasDescendants(zoneBkt, "nt.example.com."), errorInBackend)
+		return nil
+	})
+}
+
+func TestReverseName(t *testing.T) {
+	assert.Equal(t, "com.example.www.", reverseName("WWW.example.com."), errorInBackend)
+	assert.Equal(t, "com.a\\.b.", reverseName("a\\.b.com."), errorInBackend)
+	assert.Equal(t, ".", reverseName("."), errorInBackend)
+}
+
+func TestEtcdStoreReplicas(t *testing.T) {
+	if len(etcdTestEndpoints) == 0 {
+		t.Skip("etcd endpoints not set in DNS_SERVER_TEST_ETCD")
+	}
+	prefix := "/dns-server-test/testreplicas/"
+	clearEtcdPrefix(t, prefix)
+	endpoints := strings.Split(etcdTestEndpoints, ",")
+	store1 := &EtcdStore{Endpoints: endpoints, Prefix: prefix}
+	store2 := &EtcdStore{Endpoints: endpoints, Prefix: prefix}
+	assert.Equal(t, nil, store1.Open(), errorInBackend)
+	defer store1.Close()
+	assert.Equal(t, nil, store2.Open(), errorInBackend)
+	defer store2.Close()
+
+	question := &dns.Question{Name: exampleDomain, Qtype: dns.TypeA, Qclass: dns.ClassINET}
+	waitForRecords := func(store DataStore, count int) {
+		for i := 0; i < 50; i++ {
+			rrs, _ := store.GetResourceRecord(question)
+			if (rrs == nil && count == 0) || (rrs != nil && len(*rrs) == count) {
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Errorf("Records not replicated")
+	}
+
+	t.Run("ChangesReplicated", func(t *testing.T) {
+		err := store1.SetResourceRecord(DefaultZone, &ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN",
+			TTL: 30, RData: []string{dnsConfigTestIP1}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		waitForRecords(store2, 1)
+
+		_, err = store2.DelResourceRecord(DefaultZone, exampleDomain, "A")
+		assert.Equal(t, nil, err, errorDeleteMessage)
+		waitForRecords(store1, 0)
+	})
+
+	t.Run("ViewsReplicated", func(t *testing.T) {
+		err := store1.SetView(&View{Name: "internal", CIDRs: []string{"10.0.0.0/8"}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		for i := 0; i < 50 && store2.SelectView(net.ParseIP("10.1.1.1")) == ""; i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		assert.Equal(t, "internal", store2.SelectView(net.ParseIP("10.1.1.1")), errorInBackend)
+	})
+
+	t.Run("ConflictingChangesRetried", func(t *testing.T) {
+		// Commit of store2 is conflicting as store1 changed the records since its local copy
+		store2.bucketStore.backend.(*etcdBackend).cancel()
+		err := store1.SetResourceRecord(DefaultZone, &ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN",
+			TTL: 30, RData: []string{dnsConfigTestIP1}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		err = store2.SetResourceRecord(DefaultZone, &ResourceRecord{Name: exampleAbcDomain, Type: "A", Class: "IN",
+			TTL: 30, RData: []string{dnsConfigTestIP2}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+
+		records, err := store2.ListResourceRecords(&RecordFilter{})
+		assert.Equal(t, nil, err, errorInBackend)
+		assert.Equal(t, 2, len(records), errorInBackend)
+	})
+
+	t.Run("ImportLargerThanTransaction", func(t *testing.T) {
+		// More keys than the max-txn-ops of etcd, committed in several transactions
+		count := 3 * util.EtcdMaxTxnOps
+		records := make([]ResourceRecord, 0, count)
+		for i := 0; i < count; i++ {
+			records = append(records, ResourceRecord{Name: fmt.Sprintf("host%d.example.com.", i), Type: "A",
+				Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP1}})
+		}
+		err := store1.ImportZone("example.com.", records)
+		assert.Equal(t, nil, err, "Error in importing the zone")
+
+		reopened := &EtcdStore{Endpoints: endpoints, Prefix: prefix}
+		assert.Equal(t, nil, reopened.Open(), errorInBackend)
+		defer reopened.Close()
+		imported, err := reopened.ListResourceRecords(&RecordFilter{Zone: "example.com."})
+		assert.Equal(t, nil, err, errorInBackend)
+		assert.Equal(t, count, len(imported), "Error in importing the zone")
+	})
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package datastore
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	"dns-server/health"
+	"dns-server/util"
+)
+
+// DefaultWeight weight of the rData of a record without weights.
+const DefaultWeight = 1
+
+// answer candidate record of an answer along with its weighted selection key, lowest key is answered first.
+type answer struct {
+	rr  dns.RR
+	key float64
+}
+
+// Validate check the health check is valid for the record type.
+func (h *HealthCheck) Validate(rrType string) error {
+	if rrType != "A" && rrType != "AAAA" {
+		return fmt.Errorf("health check not supported for rrtype(%s) entry", rrType)
+	}
+	switch h.Protocol {
+	case health.ProtocolTCP:
+		if len(h.Path) != 0 {
+			return fmt.Errorf("health check path not supported for tcp")
+		}
+	case health.ProtocolHTTP:
+		if len(h.Path) > util.MaxHealthCheckPathLength || (len(h.Path) != 0 && !strings.HasPrefix(h.Path, "/")) {
+			return fmt.Errorf("invalid health check path(%s)", h.Path)
+		}
+	default:
+		return fmt.Errorf("unsupported health check protocol(%s)", h.Protocol)
+	}
+	if h.Port == 0 {
+		return fmt.Errorf("health check port number not in valid range")
+	}
+	if h.Interval > util.MaxHealthCheckInterval {
+		return fmt.Errorf("health check interval not in valid range")
+	}
+	if h.Timeout > h.interval() {
+		return fmt.Errorf("health check timeout exceeds the interval")
+	}
+
+	return nil
+}
+
+// interval get the interval between the probes in seconds.
+func (h *HealthCheck) interval() uint32 {
+	if h.Interval == 0 {
+		return util.DefaultHealthCheckInterval
+	}
+	return h.Interval
+}
+
+// target get the probe target of the address.
+func (h *HealthCheck) target(ip net.IP) health.Target {
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = util.DefaultHealthCheckTimeout
+	}
+	if timeout > h.interval() {
+		timeout = h.interval()
+	}
+
+	return health.Target{
+		Protocol: h.Protocol,
+		Address:  net.JoinHostPort(ip.String(), strconv.Itoa(int(h.Port))),
+		Path:     h.Path,
+		Interval: time.Duration(h.interval()) * time.Second,
+		Timeout:  time.Duration(timeout) * time.Second,
+	}
+}
+
+// selectAnswers generate the answers from the record config. The unhealthy addresses are dropped, the answers are
+// ordered by weighted random selection when the record has weights, else shuffled if load balancing, and only the
+// max answers are kept.
+func (b *bucketStore) selectAnswers(dnsCfg *DNSConfigRRValue, name string, rrType uint16) []dns.RR {
+	weighted := len(dnsCfg.Weights) == len(dnsCfg.PointTo) && len(dnsCfg.Weights) != 0
+	answers := make([]answer, 0, len(dnsCfg.PointTo))
+	for i, pointTo := range dnsCfg.PointTo {
+		rr, err := newRR(name, rrType, dnsCfg.RRClass, dnsCfg.TTL, pointTo)
+		if err != nil {
+			log.Errorf("Invalid data store entry for %s.", name)
+			continue
+		}
+		a := answer{rr: rr}
+		if weighted {
+			a.key = selectionKey(dnsCfg.Weights[i])
+		}
+		answers = append(answers, a)
+	}
+
+	answers = b.healthyAnswers(dnsCfg.HealthCheck, answers)
+	if weighted {
+		sort.SliceStable(answers, func(i, j int) bool {
+			return answers[i].key < answers[j].key
+		})
+	} else if b.loadBalance.isSet() {
+		rand.Shuffle(len(answers), func(i, j int) {
+			answers[i], answers[j] = answers[j], answers[i]
+		})
+	}
+	if dnsCfg.MaxAnswers != 0 && len(answers) > int(dnsCfg.MaxAnswers) {
+		answers = answers[:dnsCfg.MaxAnswers]
+	}
+
+	records := make([]dns.RR, 0, len(answers))
+	for _, a := range answers {
+		records = append(records, a.rr)
+	}
+
+	return records
+}
+
+// selectionKey random key of the weighted selection(Efraimidis-Spirakis), exponentially distributed with the weight
+// as rate, so an answer comes first with a probability proportional to its weight. Zero weight answers come last.
+func selectionKey(weight uint32) float64 {
+	if weight == 0 {
+		return math.Inf(1)
+	}
+	return rand.ExpFloat64() / float64(weight)
+}
+
+// healthyAnswers drop the answers with an unhealthy address. All the answers are kept when none is healthy, as
+// answering a possibly unhealthy address is better than none.
+func (b *bucketStore) healthyAnswers(check *HealthCheck, answers []answer) []answer {
+	if check == nil || b.health == nil {
+		return answers
+	}
+	healthy := make([]answer, 0, len(answers))
+	for _, a := range answers {
+		var ip net.IP
+		switch rr := a.rr.(type) {
+		case *dns.A:
+			ip = rr.A
+		case *dns.AAAA:
+			ip = rr.AAAA
+		}
+		if ip == nil || b.health.IsHealthy(check.target(ip)) {
+			healthy = append(healthy, a)
+		}
+	}
+	if len(healthy) == 0 {
+		return answers
+	}
+
+	return healthy
+}
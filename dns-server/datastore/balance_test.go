@@ -0,0 +1,164 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package datastore
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+
+	"dns-server/health"
+)
+
+const (
+	errorInBalance = "Error in answer selection"
+	balanceIP1     = "127.0.0.1"
+	balanceIP2     = "127.0.0.2"
+	balanceIP3     = "127.0.0.3"
+)
+
+func getAnswerIPs(t *testing.T, store DataStore, name string) []string {
+	rrs, err := store.GetResourceRecord(&dns.Question{Name: name, Qtype: dns.TypeA, Qclass: dns.ClassINET})
+	assert.Equal(t, nil, err, errorInBalance)
+	if err != nil {
+		return nil
+	}
+	var ips []string
+	for _, rr := range *rrs {
+		ips = append(ips, rr.(*dns.A).A.String())
+	}
+	return ips
+}
+
+func TestHealthCheckValidate(t *testing.T) {
+	check := &HealthCheck{Protocol: "http", Port: 8080, Path: "/health", Interval: 5, Timeout: 2}
+	assert.Equal(t, nil, check.Validate("A"), errorInBalance)
+	assert.EqualError(t, check.Validate("CNAME"), "health check not supported for rrtype(CNAME) entry",
+		errorInBalance)
+
+	check = &HealthCheck{Protocol: "tcp", Port: 8080, Path: "/health"}
+	assert.EqualError(t, check.Validate("AAAA"), "health check path not supported for tcp", errorInBalance)
+	check = &HealthCheck{Protocol: "http", Port: 8080, Path: "health"}
+	assert.EqualError(t, check.Validate("A"), "invalid health check path(health)", errorInBalance)
+	check = &HealthCheck{Protocol: "icmp", Port: 8080}
+	assert.EqualError(t, check.Validate("A"), "unsupported health check protocol(icmp)", errorInBalance)
+	check = &HealthCheck{Protocol: "tcp"}
+	assert.EqualError(t, check.Validate("A"), "health check port number not in valid range", errorInBalance)
+	check = &HealthCheck{Protocol: "tcp", Port: 8080, Interval: 7200}
+	assert.EqualError(t, check.Validate("A"), "health check interval not in valid range", errorInBalance)
+	check = &HealthCheck{Protocol: "tcp", Port: 8080, Timeout: 20}
+	assert.EqualError(t, check.Validate("A"), "health check timeout exceeds the interval", errorInBalance)
+}
+
+func TestAnswerSelection(t *testing.T) {
+	forEachBackend(t, testAnswerSelection)
+}
+
+func testAnswerSelection(t *testing.T, newStore newStoreFunc) {
+	defer func() {
+		_ = os.RemoveAll(DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	monitor := health.New()
+	defer monitor.Stop()
+	store := newStore("testbalancedb", monitor)
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+
+	t.Run("StoredOrder", func(t *testing.T) {
+		err := store.SetResourceRecord(exampleZone, &ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN",
+			TTL: 30, RData: []string{balanceIP1, balanceIP2, balanceIP3}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		assert.Equal(t, []string{balanceIP1, balanceIP2, balanceIP3}, getAnswerIPs(t, store, exampleDomain),
+			errorInBalance)
+	})
+
+	t.Run("Weights", func(t *testing.T) {
+		err := store.SetResourceRecord(exampleZone, &ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN",
+			TTL: 30, RData: []string{balanceIP1, balanceIP2, balanceIP3}, Weights: []uint32{0, 90, 10}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		first := make(map[string]int)
+		for i := 0; i < 1000; i++ {
+			ips := getAnswerIPs(t, store, exampleDomain)
+			assert.Equal(t, 3, len(ips), errorInBalance)
+			// Zero weight is answered last
+			assert.Equal(t, balanceIP1, ips[2], errorInBalance)
+			first[ips[0]]++
+		}
+		assert.Equal(t, true, first[balanceIP2] > 800 && first[balanceIP3] > 30, errorInBalance)
+
+		err = store.SetResourceRecord(exampleZone, &ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN",
+			TTL: 30, RData: []string{balanceIP1, balanceIP2}, Weights: []uint32{1, 2, 3}})
+		assert.EqualError(t, err, "weights do not match the rdata of the entry", errorInBalance)
+	})
+
+	t.Run("MaxAnswers", func(t *testing.T) {
+		err := store.SetResourceRecord(exampleZone, &ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN",
+			TTL: 30, RData: []string{balanceIP1, balanceIP2, balanceIP3}, Weights: []uint32{0, 0, 1}, MaxAnswers: 1})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		assert.Equal(t, []string{balanceIP3}, getAnswerIPs(t, store, exampleDomain), errorInBalance)
+
+		records, err := store.ListResourceRecords(&RecordFilter{Name: exampleDomain})
+		assert.Equal(t, nil, err, errorInBalance)
+		assert.Equal(t, uint32(1), records[0].MaxAnswers, errorInBalance)
+		assert.Equal(t, []uint32{0, 0, 1}, records[0].Weights, errorInBalance)
+	})
+
+	t.Run("UnhealthyDropped", func(t *testing.T) {
+		listener, err := net.Listen("tcp", net.JoinHostPort(balanceIP2, "0"))
+		if err != nil {
+			t.Fatalf("Failed to listen on tcp port. %s", err.Error())
+		}
+		defer listener.Close()
+		port := listener.Addr().(*net.TCPAddr).Port
+
+		err = store.SetResourceRecord(exampleZone, &ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN",
+			TTL: 30, RData: []string{balanceIP1, balanceIP2},
+			HealthCheck: &HealthCheck{Protocol: "tcp", Port: uint16(port), Interval: 1, Timeout: 1}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		// Healthy until probed
+		assert.Equal(t, []string{balanceIP1, balanceIP2}, getAnswerIPs(t, store, exampleDomain), errorInBalance)
+		var ips []string
+		for i := 0; i < 50; i++ {
+			if ips = getAnswerIPs(t, store, exampleDomain); len(ips) == 1 {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		assert.Equal(t, []string{balanceIP2}, ips, errorInBalance)
+
+		// All the addresses are answered when none is healthy
+		_ = listener.Close()
+		for i := 0; i < 50; i++ {
+			if len(monitor.Status()) == 2 && !monitor.Status()[1].Healthy {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		assert.Equal(t, []string{balanceIP1, balanceIP2}, getAnswerIPs(t, store, exampleDomain), errorInBalance)
+	})
+
+	err = store.Close()
+	assert.Equal(t, nil, err, "Error in closing the db")
+}
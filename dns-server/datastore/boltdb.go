@@ -18,13 +18,14 @@
 package datastore
 
 import (
+	"bytes"
+	"dns-server/health"
 	"dns-server/util"
 	"encoding/json"
 	"fmt"
 	"github.com/miekg/dns"
 	log "github.com/sirupsen/logrus"
 	bolt "go.etcd.io/bbolt"
-	"net"
 	"os"
 	"path"
 	"strings"
@@ -38,6 +39,12 @@ const (
 	DefaultZone = "."
 	// DBPath DataBase Path.
 	DBPath = "data"
+	// ViewConfig View constant.
+	ViewConfig = "view"
+	// viewCIDRsKey key of the client CIDRs in the view bucket.
+	viewCIDRsKey = "cidrs"
+	// nameIndexBucket bucket nested in the zone bucket holding the owner names by their reversed name.
+	nameIndexBucket = "names"
 )
 
 // DNSConfigRRKey RR Config key.
@@ -48,22 +55,29 @@ type DNSConfigRRKey struct {
 
 // DNSConfigRRValue RR config value.
 type DNSConfigRRValue struct {
-	RRClass uint16   `json:"rrClass"`
-	PointTo []string `json:"pointTo"`
-	TTL     uint32   `json:"ttl"`
+	RRClass     uint16       `json:"rrClass"`
+	PointTo     []string     `json:"pointTo"`
+	TTL         uint32       `json:"ttl"`
+	Weights     []uint32     `json:"weights,omitempty"`
+	MaxAnswers  uint32       `json:"maxAnswers,omitempty"`
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
 }
 
 // rrTypeMap rr Type Map.
-var rrTypeMap = map[string]uint16{"A": dns.TypeA, "AAAA": dns.TypeAAAA}
+var rrTypeMap = map[string]uint16{"A": dns.TypeA, "AAAA": dns.TypeAAAA, "CNAME": dns.TypeCNAME, "SRV": dns.TypeSRV,
+	"TXT": dns.TypeTXT, "PTR": dns.TypePTR, "MX": dns.TypeMX, "NS": dns.TypeNS, "SOA": dns.TypeSOA}
 
 // rrClassMap rr Class Map.
 var rrClassMap = map[string]uint16{"IN": dns.ClassINET, "CS": dns.ClassCSNET, "CH": dns.ClassCHAOS,
 	"HS": dns.ClassHESIOD, "*": dns.ClassANY}
 
+// BoltDB data store backed by a bolt db file under the data path.
 type BoltDB struct {
-	FileName string
-	TTL      uint32
-	db       *bolt.DB
+	FileName    string
+	TTL         uint32
+	LoadBalance bool            // shuffle the answers of the records without weights
+	Health      *health.Monitor // health of the addresses with a health check, all healthy if nil
+	*bucketStore
 }
 
 func (b *BoltDB) Open() error {
@@ -77,35 +91,24 @@ func (b *BoltDB) Open() error {
 		}
 	}
 
-	b.db, err = bolt.Open(path.Join(DBPath, b.FileName), 0600, &bolt.Options{Timeout: 2 * time.Second})
+	db, err := bolt.Open(path.Join(DBPath, b.FileName), 0600, &bolt.Options{Timeout: 2 * time.Second})
 	if err != nil {
 		return err
 	}
-
-	// Create the default zone if not exists one
-	err = b.db.Update(func(tx *bolt.Tx) error {
-		bZone, err := tx.CreateBucketIfNotExists([]byte(ZoneConfig))
-		if err != nil {
-			log.Error("Failed to create the zone bucket.", nil)
-			return fmt.Errorf("error creating zone bucket: %s", err)
-		}
-		_, err = bZone.CreateBucketIfNotExists([]byte(DefaultZone))
-		if err != nil {
-			log.Error("Failed to create the default(.) zone bucket.", nil)
-			return fmt.Errorf("error creating default zone(.) bucket: %s", err)
-		}
-
-		return nil
-	})
+	b.bucketStore = &bucketStore{backend: &boltBackend{db: db}, loadBalance: newSwitchFlag(b.LoadBalance),
+		health: b.Health}
+	if err = b.bucketStore.Open(); err != nil {
+		return err
+	}
 
 	log.Debugf("Initialize bolt db(%s) success.", b.FileName)
 
-	return err
+	return nil
 }
 
 func (b *BoltDB) Close() error {
-	if b.db != nil {
-		err := b.db.Close()
+	if b.bucketStore != nil {
+		err := b.bucketStore.Close()
 		if err != nil {
 			log.Errorf("Failed to close the bolt db(%s).", b.FileName)
 
@@ -117,7 +120,103 @@ func (b *BoltDB) Close() error {
 	return nil
 }
 
-func (b *BoltDB) setOrCreateDBEntryGeneration(confValueBytes []byte, rr *ResourceRecord) ([]byte, error) {
+// boltBackend bolt db as the key value backend of the data store.
+type boltBackend struct {
+	db *bolt.DB
+}
+
+func (k *boltBackend) View(fn func(tx kvTx) error) error {
+	return k.db.View(func(tx *bolt.Tx) error {
+		return fn(boltTx{tx: tx})
+	})
+}
+
+func (k *boltBackend) Update(fn func(tx kvTx) error) error {
+	return k.db.Update(func(tx *bolt.Tx) error {
+		return fn(boltTx{tx: tx})
+	})
+}
+
+func (k *boltBackend) Close() error {
+	return k.db.Close()
+}
+
+type boltTx struct {
+	tx *bolt.Tx
+}
+
+func (t boltTx) Bucket(name []byte) kvBucket {
+	return wrapBoltBucket(t.tx.Bucket(name))
+}
+
+func (t boltTx) CreateBucketIfNotExists(name []byte) (kvBucket, error) {
+	bkt, err := t.tx.CreateBucketIfNotExists(name)
+	return wrapBoltBucket(bkt), err
+}
+
+type boltBucket struct {
+	bkt *bolt.Bucket
+}
+
+// wrapBoltBucket wrap the bolt bucket, nil if the bucket is not available.
+func wrapBoltBucket(bkt *bolt.Bucket) kvBucket {
+	if bkt == nil {
+		return nil
+	}
+
+	return boltBucket{bkt: bkt}
+}
+
+func (b boltBucket) Get(key []byte) []byte {
+	return b.bkt.Get(key)
+}
+
+func (b boltBucket) Put(key []byte, value []byte) error {
+	return b.bkt.Put(key, value)
+}
+
+func (b boltBucket) Delete(key []byte) error {
+	return b.bkt.Delete(key)
+}
+
+func (b boltBucket) Bucket(name []byte) kvBucket {
+	return wrapBoltBucket(b.bkt.Bucket(name))
+}
+
+func (b boltBucket) CreateBucket(name []byte) (kvBucket, error) {
+	bkt, err := b.bkt.CreateBucket(name)
+	if err == bolt.ErrBucketExists {
+		return nil, errBucketExists
+	}
+	return wrapBoltBucket(bkt), err
+}
+
+func (b boltBucket) CreateBucketIfNotExists(name []byte) (kvBucket, error) {
+	bkt, err := b.bkt.CreateBucketIfNotExists(name)
+	return wrapBoltBucket(bkt), err
+}
+
+func (b boltBucket) DeleteBucket(name []byte) error {
+	err := b.bkt.DeleteBucket(name)
+	if err == bolt.ErrBucketNotFound {
+		return errBucketNotFound
+	}
+	return err
+}
+
+func (b boltBucket) ForEach(fn func(k, v []byte) error) error {
+	return b.bkt.ForEach(fn)
+}
+
+func (b boltBucket) Cursor() kvCursor {
+	return b.bkt.Cursor()
+}
+
+func (b boltBucket) NextSequence() (uint64, error) {
+	return b.bkt.NextSequence()
+}
+
+func (b *bucketStore) setOrCreateDBEntryGeneration(confValueBytes []byte, rr *ResourceRecord) ([]byte, error) {
 	var err error
 	dnsCfgValue := &DNSConfigRRValue{}
 
@@ -143,8 +242,24 @@ func (b *BoltDB) setOrCreateDBEntryGeneration(confValueBytes []byte, rr *Resourc
 	}
 	dnsCfgValue.TTL = rr.TTL
 	if len(rr.RData) != 0 {
+		if rr.Type == "CNAME" && len(rr.RData) != 1 {
+			return nil, fmt.Errorf("only one rdata allowed for CNAME entry")
+		}
 		dnsCfgValue.PointTo = rr.RData
+		dnsCfgValue.Weights = rr.Weights
+	} else if len(rr.Weights) != 0 {
+		dnsCfgValue.Weights = rr.Weights
+	}
+	if len(dnsCfgValue.Weights) != 0 && len(dnsCfgValue.Weights) != len(dnsCfgValue.PointTo) {
+		return nil, fmt.Errorf("weights do not match the rdata of the entry")
+	}
+	if rr.HealthCheck != nil {
+		if err = rr.HealthCheck.Validate(rr.Type); err != nil {
+			return nil, err
+		}
 	}
+	dnsCfgValue.MaxAnswers = rr.MaxAnswers
+	dnsCfgValue.HealthCheck = rr.HealthCheck
 	updatedConfValueBytes, err := json.Marshal(dnsCfgValue)
 	if err != nil {
 		return nil, fmt.Errorf("data store could not marshal dns config json")
@@ -152,7 +267,15 @@ func (b *BoltDB) setOrCreateDBEntryGeneration(confValueBytes []byte, rr *Resourc
 	return updatedConfValueBytes, nil
 }
 
-func (b *BoltDB) SetResourceRecord(zone string, rr *ResourceRecord) error {
+func (b *bucketStore) SetResourceRecord(zone string, rr *ResourceRecord) error {
+	// Add new entry to the db
+	return b.backend.Update(func(tx kvTx) error {
+		return b.putResourceRecord(tx, zone, rr)
+	})
+}
+
+// putResourceRecord add or modify the record in the zone bucket within the transaction.
+func (b *bucketStore) putResourceRecord(tx kvTx, zone string, rr *ResourceRecord) error {
 	rrType, ok := rrTypeMap[rr.Type]
 	if !ok {
 		return fmt.Errorf("unsupported rrtype(%s) entry", rr.Type)
@@ -163,6 +286,9 @@ func (b *BoltDB) SetResourceRecord(zone string, rr *ResourceRecord) error {
 	}
 
 	host := strings.ToLower(rr.Name)
+	if rrType == dns.TypeSOA && (host != strings.ToLower(zone) || len(rr.RData) > 1) {
+		return fmt.Errorf("only one SOA entry allowed at the zone(%s) apex", zone)
+	}
 
 	dnsCfgKey := DNSConfigRRKey{Host: host, RRType: rrType}
 	confKeyBytes, err := json.Marshal(dnsCfgKey)
@@ -170,89 +296,399 @@ func (b *BoltDB) SetResourceRecord(zone string, rr *ResourceRecord) error {
 		return fmt.Errorf("internal error, could not parse dns config json")
 	}
 
-	// Add new entry to the db
-	return b.db.Update(func(tx *bolt.Tx) error {
-		zoneBkt, err := tx.Bucket([]byte(ZoneConfig)).CreateBucketIfNotExists([]byte(zone))
-		if err != nil {
-			return fmt.Errorf("zone(%s) retrieval failed", zone)
+	zoneCfgBkt, err := b.zoneConfigBucket(tx)
+	if err != nil {
+		return err
+	}
+	zoneBkt, err := zoneCfgBkt.CreateBucketIfNotExists([]byte(zone))
+	if err != nil {
+		return fmt.Errorf("zone(%s) retrieval failed", zone)
+	}
+	if err = b.checkCNAMEConflict(zoneBkt, host, rrType); err != nil {
+		return err
+	}
+	confValueBytes := zoneBkt.Get(confKeyBytes)
+	updatedConfValueBytes, err := b.setOrCreateDBEntryGeneration(confValueBytes, rr)
+	if err != nil {
+		return err
+	}
+	oldRRs := b.getRRSet(zoneBkt, confKeyBytes)
+	oldCfg := b.getDNSConfig(zoneBkt, host, rrType)
+	if err = zoneBkt.Put(confKeyBytes, updatedConfValueBytes); err != nil {
+		return fmt.Errorf("saving dns entry to data store failed")
+	}
+	if err = b.indexName(zoneBkt, host); err != nil {
+		return err
+	}
+	if err = b.recordZoneChange(tx, zoneBkt, zone, oldRRs, b.getRRSet(zoneBkt, confKeyBytes)); err != nil {
+		return err
+	}
+
+	if (rrType == dns.TypeA || rrType == dns.TypeAAAA) && b.getZoneOptions(tx, zone).AutoPTR {
+		var oldAddrs []string
+		if oldCfg != nil {
+			oldAddrs = oldCfg.PointTo
+		}
+		newCfg := b.getDNSConfig(zoneBkt, host, rrType)
+		return b.updateReversePointers(tx, host, oldAddrs, newCfg.PointTo, newCfg.TTL)
+	}
+
+	return nil
+}
+
+// checkCNAMEConflict a CNAME cannot coexist with any other data for the same host.
+func (b *bucketStore) checkCNAMEConflict(zoneBkt kvBucket, host string, rrType uint16) error {
+	for _, existing := range b.getHostRRTypes(zoneBkt, host) {
+		if existing == rrType {
+			continue
+		}
+		if existing == dns.TypeCNAME || rrType == dns.TypeCNAME {
+			return fmt.Errorf("CNAME entry cannot coexist with other entries for %s", host)
+		}
+	}
+
+	return nil
+}
+
+// getHostRRTypes get all the rr types available for the host in the zone bucket.
+func (b *bucketStore) getHostRRTypes(zoneBkt kvBucket, host string) []uint16 {
+	var rrTypes []uint16
+	hostBytes, err := json.Marshal(host)
+	if err != nil {
+		return rrTypes
+	}
+	// Keys are marshaled DNSConfigRRKey, so the all the entries of a host share the same prefix
+	prefix := []byte(fmt.Sprintf("{\"host\":%s,", hostBytes))
+	c := zoneBkt.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		dnsCfgKey := &DNSConfigRRKey{}
+		if json.Unmarshal(k, dnsCfgKey) == nil {
+			rrTypes = append(rrTypes, dnsCfgKey.RRType)
 		}
-		confValueBytes := zoneBkt.Get(confKeyBytes)
-		updatedConfValueBytes, err := b.setOrCreateDBEntryGeneration(confValueBytes, rr)
+	}
+
+	return rrTypes
+}
+
+// hasDescendants check any entry exists below the name in the zone bucket, i.e. name is an empty non-terminal.
+func (b *bucketStore) hasDescendants(zoneBkt kvBucket, name string) bool {
+	indexBkt := zoneBkt.Bucket([]byte(nameIndexBucket))
+	if indexBkt == nil {
+		return false
+	}
+	// Reversed names of the descendants start with the reversed name, which comes first
+	key := reverseName(name)
+	prefix := []byte(key)
+	if key == DefaultZone {
+		prefix = nil
+	}
+	c := indexBkt.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		if string(k) != key {
+			return true
+		}
+	}
+
+	return false
+}
+
+// indexName add the host to the name index of the zone bucket, or remove it once the host has no entry left.
+func (b *bucketStore) indexName(zoneBkt kvBucket, host string) error {
+	indexBkt, err := zoneBkt.CreateBucketIfNotExists([]byte(nameIndexBucket))
+	if err != nil {
+		return fmt.Errorf("name index retrieval failed")
+	}
+	key := []byte(reverseName(host))
+	if len(b.getHostRRTypes(zoneBkt, host)) == 0 {
+		err = indexBkt.Delete(key)
+	} else {
+		err = indexBkt.Put(key, []byte(host))
+	}
+	if err != nil {
+		return fmt.Errorf("saving name index to data store failed")
+	}
+
+	return nil
+}
+
+// indexAllZoneNames build the name index of the zones of all the views stored without it, once on open.
+func (b *bucketStore) indexAllZoneNames(tx kvTx) error {
+	zoneCfgBkts := []kvBucket{tx.Bucket([]byte(ZoneConfig))}
+	viewCfgBkt := tx.Bucket([]byte(ViewConfig))
+	err := viewCfgBkt.ForEach(func(name, v []byte) error {
+		if viewBkt := viewCfgBkt.Bucket(name); v == nil && viewBkt != nil && viewBkt.Bucket([]byte(ZoneConfig)) != nil {
+			zoneCfgBkts = append(zoneCfgBkts, viewBkt.Bucket([]byte(ZoneConfig)))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, zoneCfgBkt := range zoneCfgBkts {
+		if err = b.indexZoneNames(zoneCfgBkt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexZoneNames build the name index of the zone buckets stored without it.
+func (b *bucketStore) indexZoneNames(zoneCfgBkt kvBucket) error {
+	return zoneCfgBkt.ForEach(func(zone, v []byte) error {
+		zoneBkt := zoneCfgBkt.Bucket(zone)
+		if v != nil || zoneBkt == nil || zoneBkt.Bucket([]byte(nameIndexBucket)) != nil {
+			return nil
+		}
+		var hosts []string
+		err := zoneBkt.ForEach(func(k, v []byte) error {
+			dnsCfgKey := &DNSConfigRRKey{}
+			if v != nil && json.Unmarshal(k, dnsCfgKey) == nil {
+				hosts = append(hosts, dnsCfgKey.Host)
+			}
+			return nil
+		})
 		if err != nil {
 			return err
 		}
-		if err = zoneBkt.Put(confKeyBytes, updatedConfValueBytes); err != nil {
-			return fmt.Errorf("saving dns entry to data store failed")
+		if _, err = zoneBkt.CreateBucket([]byte(nameIndexBucket)); err != nil {
+			return fmt.Errorf("creating name index of zone(%s) failed", zone)
+		}
+		for _, host := range hosts {
+			if err = b.indexName(zoneBkt, host); err != nil {
+				return err
+			}
 		}
-
 		return nil
 	})
 }
 
-func (b *BoltDB) getRRFromZoneBucket(zoneBkt *bolt.Bucket, dnsCfgKeyBytes []byte, question *dns.Question) []dns.RR {
-	var records []dns.RR
+// reverseName the labels of the name in reverse order each followed by a dot, "." for the root. Reversed names of
+// the names below a name start with its reversed name.
+func reverseName(name string) string {
+	labels := dns.SplitDomainName(strings.ToLower(name))
+	if len(labels) == 0 {
+		return DefaultZone
+	}
+	var sb strings.Builder
+	for i := len(labels) - 1; i >= 0; i-- {
+		sb.WriteString(labels[i])
+		sb.WriteString(".")
+	}
+
+	return sb.String()
+}
+
+// findWildcard find the wildcard owner of the closest encloser applicable for the host in the zone bucket(RFC 4592),
+// empty if none applies.
+func (b *bucketStore) findWildcard(zoneBkt kvBucket, zone string, host string) string {
+	child := host
+	off, end := dns.NextLabel(host, 0)
+	for !end {
+		ancestor := host[off:]
+		wildcard := "*." + ancestor
+		if len(b.getHostRRTypes(zoneBkt, wildcard)) != 0 {
+			// Wildcard does not apply if the name below the closest encloser exists as empty non-terminal
+			if b.hasDescendants(zoneBkt, child) {
+				return ""
+			}
+			return wildcard
+		}
+		// Closest encloser found without any wildcard
+		if len(b.getHostRRTypes(zoneBkt, ancestor)) != 0 || ancestor == zone {
+			return ""
+		}
+		child = ancestor
+		off, end = dns.NextLabel(host, off)
+	}
+
+	return ""
+}
+
+// lookupWildcard find the records from the wildcard of the closest encloser of the name in the zone bucket,
+// synthesized with the queried name as owner.
+func (b *bucketStore) lookupWildcard(zoneBkt kvBucket, zone string, name string, rrType uint16,
+	rrClass uint16) []dns.RR {
+	wildcard := b.findWildcard(zoneBkt, zone, strings.ToLower(name))
+	if len(wildcard) == 0 {
+		return nil
+	}
+
+	return b.getAnswersFromZoneBucket(zoneBkt, wildcard, name, rrType, rrClass)
+}
+
+// getDNSConfig get the config stored for the host and rrType in the zone bucket, nil if not available.
+func (b *bucketStore) getDNSConfig(zoneBkt kvBucket, host string, rrType uint16) *DNSConfigRRValue {
+	dnsCfgKeyBytes, err := json.Marshal(DNSConfigRRKey{Host: strings.ToLower(host), RRType: rrType})
+	if err != nil {
+		return nil
+	}
 	dnsCfgBytes := zoneBkt.Get(dnsCfgKeyBytes)
 	if dnsCfgBytes == nil {
-		return records
+		return nil
 	}
 	dnsCfg := &DNSConfigRRValue{}
 	if err := json.Unmarshal(dnsCfgBytes, dnsCfg); err != nil {
-		return records
+		return nil
 	}
+
+	return dnsCfg
+}
+
+// getRRFromZoneBucket get the records stored for the host, generated with the given owner name.
+func (b *bucketStore) getRRFromZoneBucket(zoneBkt kvBucket, host string, name string, rrType uint16,
+	rrClass uint16) []dns.RR {
+	var records []dns.RR
+	dnsCfg := b.getDNSConfig(zoneBkt, host, rrType)
 	// rrClass filtering
-	if dnsCfg.RRClass != question.Qclass {
+	if dnsCfg == nil || dnsCfg.RRClass != rrClass {
 		return records
 	}
-	for _, pointToIP := range dnsCfg.PointTo {
-		if question.Qtype == dns.TypeA {
-			records = append(records, &dns.A{Hdr: dns.RR_Header{Name: question.Name, Rrtype: question.Qtype,
-				Class: dns.ClassINET, Ttl: dnsCfg.TTL}, A: net.ParseIP(pointToIP)})
-		} else if question.Qtype == dns.TypeAAAA {
-			records = append(records, &dns.AAAA{Hdr: dns.RR_Header{Name: question.Name, Rrtype: question.Qtype,
-				Class: dns.ClassINET, Ttl: dnsCfg.TTL}, AAAA: net.ParseIP(pointToIP)})
+	for _, pointTo := range dnsCfg.PointTo {
+		rr, err := newRR(name, rrType, rrClass, dnsCfg.TTL, pointTo)
+		if err != nil {
+			log.Errorf("Invalid data store entry for %s.", name)
+			continue
 		}
+		records = append(records, rr)
 	}
 
 	return records
 }
 
-func (b *BoltDB) GetResourceRecord(question *dns.Question) (*[]dns.RR, error) {
-	q := strings.ToLower(question.Name)
-	var (
-		off int
-		end bool
-	)
-
-	dnsCfgKey := DNSConfigRRKey{Host: q, RRType: question.Qtype}
-	dnsCfgKeyBytes, err := json.Marshal(dnsCfgKey)
-	if err != nil {
-		return nil, fmt.Errorf("parsing dns query failed")
+// getAnswersFromZoneBucket get the records stored for the host as answers, selected as per the answer policy of the
+// records.
+func (b *bucketStore) getAnswersFromZoneBucket(zoneBkt kvBucket, host string, name string, rrType uint16,
+	rrClass uint16) []dns.RR {
+	dnsCfg := b.getDNSConfig(zoneBkt, host, rrType)
+	if dnsCfg == nil || dnsCfg.RRClass != rrClass {
+		return nil
 	}
 
-	zones := make(map[string]bool)
-	// Get a  zone entries from the input question
+	return b.selectAnswers(dnsCfg, name, rrType)
+}
+
+// getZoneCandidates get the possible zones of a name, from the most specific zone to the default zone.
+func getZoneCandidates(name string) []string {
+	var (
+		off   int
+		end   bool
+		zones []string
+	)
+	q := strings.ToLower(name)
 	for {
-		zones[q[off:]] = true
+		zones = append(zones, q[off:])
 		off, end = dns.NextLabel(q, off)
 		if end {
 			break
 		}
 	}
-	zones["."] = true // Add the default zone at end to process
+	if zones[len(zones)-1] != DefaultZone {
+		zones = append(zones, DefaultZone) // Add the default zone at end to process
+	}
 
-	var records []dns.RR
+	return zones
+}
 
-	err = b.db.View(func(tx *bolt.Tx) error {
-		var zoneBkt *bolt.Bucket
-		for zone := range zones {
-			zoneBkt = tx.Bucket([]byte(ZoneConfig)).Bucket([]byte(zone))
+// lookup find the records of the name from the most specific zone available in the db, falling back to the
+// wildcard records when the name does not exist in the zone. Zone config buckets are looked up in order.
+func (b *bucketStore) lookup(zoneCfgBkts []kvBucket, name string, rrType uint16, rrClass uint16) []dns.RR {
+	for _, zoneCfgBkt := range zoneCfgBkts {
+		for _, zone := range getZoneCandidates(name) {
+			zoneBkt := zoneCfgBkt.Bucket([]byte(zone))
 			if zoneBkt == nil {
 				// Zone not available in the db
 				continue
 			}
-			records = b.getRRFromZoneBucket(zoneBkt, dnsCfgKeyBytes, question)
+			records := b.getAnswersFromZoneBucket(zoneBkt, name, name, rrType, rrClass)
+			if len(records) == 0 && len(b.getHostRRTypes(zoneBkt, strings.ToLower(name))) == 0 {
+				records = b.lookupWildcard(zoneBkt, zone, name, rrType, rrClass)
+			}
 			if len(records) != 0 {
-				break
+				return records
+			}
+		}
+	}
+
+	return nil
+}
+
+// lookupWithCNAME find the records of the name, chasing the CNAME chain within the local zones.
+func (b *bucketStore) lookupWithCNAME(zoneCfgBkts []kvBucket, name string, rrType uint16, rrClass uint16) []dns.RR {
+	var records []dns.RR
+	visited := make(map[string]bool)
+	for i := 0; i < util.MaxCNAMEChainLength; i++ {
+		rrs := b.lookup(zoneCfgBkts, name, rrType, rrClass)
+		if len(rrs) != 0 || rrType == dns.TypeCNAME {
+			return append(records, rrs...)
+		}
+		cnames := b.lookup(zoneCfgBkts, name, dns.TypeCNAME, rrClass)
+		if len(cnames) == 0 {
+			break
+		}
+		records = append(records, cnames[0])
+		visited[strings.ToLower(name)] = true
+		name = cnameTarget(cnames[0])
+		if visited[strings.ToLower(name)] {
+			log.Errorf("CNAME loop detected for %s.", name)
+			break
+		}
+	}
+
+	return records
+}
+
+// getAuthority get the SOA of the closest local zone the name belongs to, nil if not authoritative for the name.
+func (b *bucketStore) getAuthority(zoneCfgBkts []kvBucket, name string, rrClass uint16) dns.RR {
+	for _, zoneCfgBkt := range zoneCfgBkts {
+		for _, zone := range getZoneCandidates(name) {
+			zoneBkt := zoneCfgBkt.Bucket([]byte(zone))
+			if zoneBkt == nil {
+				continue
+			}
+			soa := b.getRRFromZoneBucket(zoneBkt, zone, zone, dns.TypeSOA, rrClass)
+			if len(soa) != 0 {
+				return soa[0]
+			}
+		}
+	}
+
+	return nil
+}
+
+// isNameExists check the name exists in the local zones with any record type, as an empty non-terminal or through a
+// wildcard.
+func (b *bucketStore) isNameExists(zoneCfgBkts []kvBucket, name string) bool {
+	host := strings.ToLower(name)
+	for _, zoneCfgBkt := range zoneCfgBkts {
+		for _, zone := range getZoneCandidates(host) {
+			zoneBkt := zoneCfgBkt.Bucket([]byte(zone))
+			if zoneBkt == nil {
+				continue
+			}
+			if len(b.getHostRRTypes(zoneBkt, host)) != 0 || b.hasDescendants(zoneBkt, host) ||
+				len(b.findWildcard(zoneBkt, zone, host)) != 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (b *bucketStore) GetResourceRecord(question *dns.Question) (*[]dns.RR, error) {
+	var (
+		records  []dns.RR
+		notFound *NotFoundError
+	)
+
+	err := b.backend.View(func(tx kvTx) error {
+		zoneCfgBkts := b.lookupBuckets(tx)
+		records = b.lookupWithCNAME(zoneCfgBkts, question.Name, question.Qtype, question.Qclass)
+		if len(records) == 0 {
+			notFound = &NotFoundError{Authority: b.getAuthority(zoneCfgBkts, question.Name, question.Qclass)}
+			if notFound.Authority != nil {
+				notFound.NameExists = b.isNameExists(zoneCfgBkts, question.Name)
 			}
 		}
 
@@ -262,102 +698,367 @@ func (b *BoltDB) GetResourceRecord(question *dns.Question) (*[]dns.RR, error) {
 		return nil, fmt.Errorf("reading dns entry from data store failed")
 	}
 	if len(records) == 0 {
-		return nil, fmt.Errorf("could not process/retrieve the query")
+		return nil, notFound
 	}
 
 	return &records, nil
 }
 
-func (b *BoltDB) DelResourceRecord(zone string, host string, rrtypestr string) error {
-	// panic("implement me")
+// DelResourceRecord delete the record from the zone holding it, which is returned.
+func (b *bucketStore) DelResourceRecord(zone string, host string, rrtypestr string) (string, error) {
+	var deletedZone string
+	err := b.backend.Update(func(tx kvTx) error {
+		var err error
+		deletedZone, err = b.deleteResourceRecord(tx, host, rrtypestr)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(deletedZone) == 0 {
+		return "", fmt.Errorf("not found for the zone %v", zone)
+	}
+
+	return deletedZone, nil
+}
+
+// deleteResourceRecord delete the record from the zone holding it within the transaction, empty zone if not found.
+func (b *bucketStore) deleteResourceRecord(tx kvTx, host string, rrtypestr string) (string, error) {
+	dnsCfgKeyBytes, err := newRecordKey(host, rrtypestr)
+	if err != nil {
+		return "", err
+	}
+
+	zone, zoneBkt := b.findRecordZoneBucket(tx, dnsCfgKeyBytes)
+	if zoneBkt == nil {
+		return "", nil
+	}
+	oldRRs := b.getRRSet(zoneBkt, dnsCfgKeyBytes)
+	dnsCfgKey := &DNSConfigRRKey{}
+	_ = json.Unmarshal(dnsCfgKeyBytes, dnsCfgKey)
+	oldCfg := b.getDNSConfig(zoneBkt, dnsCfgKey.Host, dnsCfgKey.RRType)
+	if err = zoneBkt.Delete(dnsCfgKeyBytes); err != nil {
+		return "", fmt.Errorf("failed to delete dns entry")
+	}
+	if err = b.indexName(zoneBkt, dnsCfgKey.Host); err != nil {
+		return "", err
+	}
+	if err = b.recordZoneChange(tx, zoneBkt, zone, oldRRs, nil); err != nil {
+		return "", err
+	}
+
+	if (dnsCfgKey.RRType == dns.TypeA || dnsCfgKey.RRType == dns.TypeAAAA) && oldCfg != nil &&
+		b.getZoneOptions(tx, zone).AutoPTR {
+		return zone, b.updateReversePointers(tx, dnsCfgKey.Host, oldCfg.PointTo, nil, oldCfg.TTL)
+	}
+
+	return zone, nil
+}
+
+func (b *bucketStore) IsResourceRecordExists(zone string, rr *ResourceRecord) bool {
 	var found bool
+	if rr.TTL == 0 {
+		log.Error("DNS TTL value 0 is not supported.", nil)
+		return false
+	}
+
+	confKeyBytes, err := newRecordKey(rr.Name, rr.Type)
+	if err != nil {
+		log.Error(err.Error())
+		return false
+	}
+
+	// Check bucket exists or not
+	_ = b.backend.View(func(tx kvTx) error {
+		_, zoneBkt := b.findRecordZoneBucket(tx, confKeyBytes)
+		found = zoneBkt != nil
+		return nil
+	})
+
+	if !found {
+		log.Infof("Record not found for the zone %s", zone)
+		return false
+	}
+
+	return true
+}
+
+// newRecordKey generate the data store key of the record.
+func newRecordKey(host string, rrtypestr string) ([]byte, error) {
 	rrType, ok := rrTypeMap[rrtypestr]
 	if !ok {
-		return fmt.Errorf("unsupported rrtype(%s) entry", rrtypestr)
+		return nil, fmt.Errorf("unsupported rrtype(%s) entry", rrtypestr)
 	}
 
-	dnsCfgKey := &DNSConfigRRKey{Host: strings.ToLower(host), RRType: rrType}
-	dnsCfgKeyBytes, err := json.Marshal(dnsCfgKey)
+	dnsCfgKeyBytes, err := json.Marshal(&DNSConfigRRKey{Host: strings.ToLower(host), RRType: rrType})
 	if err != nil {
-		return fmt.Errorf("failed to parse input request")
+		return nil, fmt.Errorf("failed to parse input request")
+	}
+
+	return dnsCfgKeyBytes, nil
+}
+
+// findRecordZoneBucket get the zone and the zone bucket holding the record, nil if none of the zones has it.
+func (b *bucketStore) findRecordZoneBucket(tx kvTx, dnsCfgKeyBytes []byte) (string, kvBucket) {
+	var foundZone string
+	var found kvBucket
+	zoneCfgBkt, err := b.zoneConfigBucket(tx)
+	if err != nil {
+		return "", nil
+	}
+	_ = zoneCfgBkt.ForEach(func(zone, v []byte) error {
+		if found != nil || v != nil {
+			return nil
+		}
+		zoneBkt := zoneCfgBkt.Bucket(zone)
+		if zoneBkt != nil && zoneBkt.Get(dnsCfgKeyBytes) != nil {
+			foundZone, found = string(zone), zoneBkt
+		}
+		return nil
+	})
+
+	return foundZone, found
+}
+
+// ListZones get all the local zones.
+func (b *bucketStore) ListZones() ([]string, error) {
+	var zones []string
+	err := b.backend.View(func(tx kvTx) error {
+		zoneCfgBkt, err := b.zoneConfigBucket(tx)
+		if err != nil {
+			return err
+		}
+		return zoneCfgBkt.ForEach(func(zone, v []byte) error {
+			// Nested buckets only have the key
+			if v == nil {
+				zones = append(zones, string(zone))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading zones from data store failed")
+	}
+
+	return zones, nil
+}
+
+// ListResourceRecords get the records matching the filter, ordered by zone and name.
+func (b *bucketStore) ListResourceRecords(filter *RecordFilter) ([]ZoneResourceRecord, error) {
+	var rrType uint16
+	if len(filter.Type) != 0 {
+		var ok bool
+		if rrType, ok = rrTypeMap[filter.Type]; !ok {
+			return nil, fmt.Errorf("unsupported rrtype(%s) entry", filter.Type)
+		}
 	}
+	host := strings.ToLower(filter.Name)
 
-	err = b.db.Update(func(tx *bolt.Tx) error {
-		var zoneBkt *bolt.Bucket
-		err := tx.Bucket([]byte(ZoneConfig)).ForEach(func(zone, _ []byte) error {
-			if found {
+	records := make([]ZoneResourceRecord, 0)
+	err := b.backend.View(func(tx kvTx) error {
+		zoneCfgBkt, err := b.zoneConfigBucket(tx)
+		if err != nil {
+			return err
+		}
+		return zoneCfgBkt.ForEach(func(zone, v []byte) error {
+			if v != nil || (len(filter.Zone) != 0 && !strings.EqualFold(filter.Zone, string(zone))) {
 				return nil
 			}
-			zoneBkt = tx.Bucket([]byte(ZoneConfig)).Bucket(zone)
+			zoneBkt := zoneCfgBkt.Bucket(zone)
 			if zoneBkt == nil {
-				// Zone not available in the db
 				return fmt.Errorf("failed to read the zone entry")
 			}
-			if zoneBkt.Get(dnsCfgKeyBytes) != nil {
-				found = true
-
-				return zoneBkt.Delete(dnsCfgKeyBytes)
+			for _, rr := range b.listZoneBucket(zoneBkt, host, rrType) {
+				records = append(records, ZoneResourceRecord{Zone: string(zone), ResourceRecord: rr})
 			}
 			return nil
 		})
-		return err
 	})
 	if err != nil {
-		return fmt.Errorf("failed to delete dns entry")
-	}
-	if !found {
-		return fmt.Errorf("not found for the zone %v", zone)
+		return nil, fmt.Errorf("reading dns entries from data store failed")
 	}
 
-	return nil
+	return records, nil
 }
 
-func (b *BoltDB) IsResourceRecordExists(zone string, rr *ResourceRecord) bool {
-	var found bool
-	rrType, ok := rrTypeMap[rr.Type]
-	if !ok {
-		log.Error("Unsupported rrtype entry", nil)
-		return false
+// listZoneBucket get the records in the zone bucket, host and rrType filters the records when not empty.
+func (b *bucketStore) listZoneBucket(zoneBkt kvBucket, host string, rrType uint16) []ResourceRecord {
+	var records []ResourceRecord
+	var prefix []byte
+	if len(host) != 0 {
+		hostBytes, err := json.Marshal(host)
+		if err != nil {
+			return records
+		}
+		prefix = []byte(fmt.Sprintf("{\"host\":%s,", hostBytes))
 	}
-	if rr.TTL == 0 {
-		log.Error("DNS TTL value 0 is not supported.", nil)
-		return false
+
+	c := zoneBkt.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		dnsCfgKey := &DNSConfigRRKey{}
+		dnsCfgValue := &DNSConfigRRValue{}
+		if v == nil || json.Unmarshal(k, dnsCfgKey) != nil || json.Unmarshal(v, dnsCfgValue) != nil {
+			continue
+		}
+		if rrType != 0 && dnsCfgKey.RRType != rrType {
+			continue
+		}
+		records = append(records, ResourceRecord{
+			Name:        dnsCfgKey.Host,
+			Type:        dns.TypeToString[dnsCfgKey.RRType],
+			Class:       dns.ClassToString[dnsCfgValue.RRClass],
+			TTL:         dnsCfgValue.TTL,
+			RData:       dnsCfgValue.PointTo,
+			Weights:     dnsCfgValue.Weights,
+			MaxAnswers:  dnsCfgValue.MaxAnswers,
+			HealthCheck: dnsCfgValue.HealthCheck,
+		})
 	}
 
-	host := strings.ToLower(rr.Name)
+	return records
+}
 
-	dnsCfgKey := DNSConfigRRKey{Host: host, RRType: rrType}
-	confKeyBytes, err := json.Marshal(dnsCfgKey)
-	if err != nil {
-		log.Error("Internal error, could not parse dns config json")
-		return false
+// CreateZone create an empty local zone.
+func (b *bucketStore) CreateZone(zone string) error {
+	return b.backend.Update(func(tx kvTx) error {
+		zoneCfgBkt, err := b.zoneConfigBucket(tx)
+		if err != nil {
+			return err
+		}
+		_, err = zoneCfgBkt.CreateBucket([]byte(zone))
+		if err == errBucketExists {
+			return fmt.Errorf("zone(%s) already exists", zone)
+		}
+		if err != nil {
+			return fmt.Errorf("creating zone(%s) failed", zone)
+		}
+		return nil
+	})
+}
+
+// DeleteZone delete the local zone along with all its records.
+func (b *bucketStore) DeleteZone(zone string) error {
+	if zone == DefaultZone && len(b.view) == 0 {
+		return fmt.Errorf("default zone(%s) cannot be deleted", DefaultZone)
 	}
 
-	// Check bucket exists or not
-	err = b.db.View(func(tx *bolt.Tx) error {
-		var zoneBkt *bolt.Bucket
-		err := tx.Bucket([]byte(ZoneConfig)).ForEach(func(zone, _ []byte) error {
-			if found {
-				return nil
+	return b.backend.Update(func(tx kvTx) error {
+		zoneCfgBkt, err := b.zoneConfigBucket(tx)
+		if err != nil {
+			return err
+		}
+		if err = b.deleteZoneOptions(tx, zone, zoneCfgBkt.Bucket([]byte(zone))); err != nil {
+			return err
+		}
+		if err = b.deleteZoneKeys(tx, zone); err != nil {
+			return err
+		}
+		err = zoneCfgBkt.DeleteBucket([]byte(zone))
+		if err == errBucketNotFound {
+			return fmt.Errorf("zone(%s) not found", zone)
+		}
+		if err != nil {
+			return fmt.Errorf("deleting zone(%s) failed", zone)
+		}
+		return b.clearJournal(tx, zone)
+	})
+}
+
+// ImportZone replace all the records of the zone with the given records in a single transaction, the zone is
+// created if not exists. The serial of the imported SOA is kept as is and the journal of the zone is dropped. Options
+// of the zone are kept.
+func (b *bucketStore) ImportZone(zone string, records []ResourceRecord) error {
+	return b.backend.Update(func(tx kvTx) error {
+		zoneCfgBkt, err := b.zoneConfigBucket(tx)
+		if err != nil {
+			return err
+		}
+		if err = b.unlinkZoneReversePointers(tx, zone, zoneCfgBkt.Bucket([]byte(zone))); err != nil {
+			return err
+		}
+		if err := zoneCfgBkt.DeleteBucket([]byte(zone)); err != nil && err != errBucketNotFound {
+			return fmt.Errorf("clearing zone(%s) failed", zone)
+		}
+		if _, err := zoneCfgBkt.CreateBucket([]byte(zone)); err != nil {
+			return fmt.Errorf("creating zone(%s) failed", zone)
+		}
+		// SOA goes last, so that adding the records does not bump the imported serial
+		for _, soaLast := range []bool{false, true} {
+			for i := range records {
+				if (records[i].Type == "SOA") != soaLast {
+					continue
+				}
+				if err := b.putResourceRecord(tx, zone, &records[i]); err != nil {
+					return fmt.Errorf("importing %s %s failed, %s", records[i].Name, records[i].Type, err.Error())
+				}
 			}
-			zoneBkt = tx.Bucket([]byte(ZoneConfig)).Bucket(zone)
-			if zoneBkt == nil {
-				// Zone not available in the db
-				return fmt.Errorf("failed to read the zone entry")
+		}
+		return b.clearJournal(tx, zone)
+	})
+}
+
+// ApplyBatch apply all the operations in a single transaction, none of them are applied if any fails.
+func (b *bucketStore) ApplyBatch(ops []BatchOperation) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ops))
+	failed := false
+	err := b.backend.Update(func(tx kvTx) error {
+		// Continue with the rest on failure, so that all the failed operations are reported at once
+		for i := range ops {
+			results[i] = BatchResult{Index: i, Status: BatchApplied}
+			if err := b.applyOperation(tx, &ops[i]); err != nil {
+				results[i] = BatchResult{Index: i, Status: BatchFailed, Error: err.Error()}
+				failed = true
 			}
-			if zoneBkt.Get(confKeyBytes) != nil {
-				found = true
+		}
+		if failed {
+			return fmt.Errorf("batch update failed, no changes applied")
+		}
+		return nil
+	})
+	if err != nil {
+		for i := range results {
+			if results[i].Status == BatchApplied {
+				results[i].Status = BatchRolledBack
 			}
-			return nil
-		})
+		}
+		return results, err
+	}
 
-		return err
-	})
+	return results, nil
+}
 
-	if !found || err != nil {
-		log.Infof("Record not found for the zone %s", zone)
-		return false
+// applyOperation apply the batch operation within the transaction.
+func (b *bucketStore) applyOperation(tx kvTx, op *BatchOperation) error {
+	zone := op.Zone
+	if len(zone) == 0 {
+		zone = DefaultZone
 	}
 
-	return true
+	switch op.Op {
+	case BatchOpAdd, BatchOpUpdate:
+		dnsCfgKeyBytes, err := newRecordKey(op.RR.Name, op.RR.Type)
+		if err != nil {
+			return err
+		}
+		_, zoneBkt := b.findRecordZoneBucket(tx, dnsCfgKeyBytes)
+		exists := zoneBkt != nil
+		if op.Op == BatchOpAdd && exists {
+			return fmt.Errorf("record already exists")
+		}
+		if op.Op == BatchOpUpdate && !exists {
+			return fmt.Errorf("record not exist, cannot update")
+		}
+		return b.putResourceRecord(tx, zone, &op.RR)
+	case BatchOpDelete:
+		deletedZone, err := b.deleteResourceRecord(tx, op.RR.Name, op.RR.Type)
+		if err != nil {
+			return err
+		}
+		if len(deletedZone) == 0 {
+			return fmt.Errorf("not found for the zone %v", zone)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported operation(%s)", op.Op)
+	}
 }
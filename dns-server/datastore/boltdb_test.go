@@ -18,6 +18,7 @@ package datastore
 import (
 	"fmt"
 	"math/rand"
+	"net"
 	"os"
 	"testing"
 
@@ -51,6 +52,10 @@ var dnsConfigTestIP5 = fmt.Sprintf(ipAddFormatter, rand.Intn(maxIPVal), rand.Int
 
 // Query dns rules request in mp1 interface
 func TestBasicDataStoreOperations(t *testing.T) {
+	forEachBackend(t, testBasicDataStoreOperations)
+}
+
+func testBasicDataStoreOperations(t *testing.T, newStore newStoreFunc) {
 	defer func() {
 		_ = os.RemoveAll(DBPath)
 		if r := recover(); r != nil {
@@ -58,7 +63,7 @@ func TestBasicDataStoreOperations(t *testing.T) {
 		}
 	}()
 
-	store := &BoltDB{FileName: "testdb", TTL: 30}
+	store := newStore("testdb", nil)
 	err := store.Open()
 	assert.Equal(t, nil, err, "Error in opening the db")
 
@@ -73,7 +78,7 @@ func TestBasicDataStoreOperations(t *testing.T) {
 	assert.Equal(t, fmt.Sprintf(exampleRspFormatter, dnsConfigTestIP1),
 		(*rrResponse)[0].String(), "Error")
 
-	err = store.DelResourceRecord("", exampleDomain, "A")
+	_, err = store.DelResourceRecord("", exampleDomain, "A")
 	assert.Equal(t, nil, err, errorDeleteMessage)
 
 	t.Run("QueryNonExistingRecord", func(t *testing.T) {
@@ -120,7 +125,7 @@ func TestBasicDataStoreOperations(t *testing.T) {
 		assert.Equal(t, fmt.Sprintf("WWW.example.COM.\t30\tIN\tA\t%s", dnsConfigTestIP1), (*rrResponse)[0].String(),
 			"Error")
 
-		err = store.DelResourceRecord("", exampleDomain, "A")
+		_, err = store.DelResourceRecord("", exampleDomain, "A")
 		assert.Equal(t, nil, err, errorDeleteMessage)
 	})
 
@@ -135,12 +140,12 @@ func TestBasicDataStoreOperations(t *testing.T) {
 		assert.Equal(t, fmt.Sprintf(exampleRspFormatter, dnsConfigTestIP2), (*rrResponse)[0].String(),
 			"Error")
 
-		err = store.DelResourceRecord("", exampleDomain, "A")
+		_, err = store.DelResourceRecord("", exampleDomain, "A")
 		assert.Equal(t, nil, err, errorDeleteMessage)
 	})
 
 	t.Run("DeleteNonExistingRecord", func(t *testing.T) {
-		err = store.DelResourceRecord("cloud", exampleDomain, "A")
+		_, err = store.DelResourceRecord("cloud", exampleDomain, "A")
 		assert.NotEqual(t, nil, err, "Error in deleting the db")
 		assert.EqualError(t, err, "not found for the zone cloud", errorSettingMessage)
 	})
@@ -149,11 +154,11 @@ func TestBasicDataStoreOperations(t *testing.T) {
 		_ = store.SetResourceRecord(".", &ResourceRecord{Name: exampleDomain, Type: "A",
 			Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP1}})
 
-		err = store.DelResourceRecord("", exampleDomain, "None")
+		_, err = store.DelResourceRecord("", exampleDomain, "None")
 		assert.NotEqual(t, nil, err, "Error in deleting the db")
 		assert.EqualError(t, err, "unsupported rrtype(None) entry", "Error in deleting record")
 
-		err = store.DelResourceRecord("", exampleDomain, "A")
+		_, err = store.DelResourceRecord("", exampleDomain, "A")
 		assert.Equal(t, nil, err, errorDeleteMessage)
 	})
 
@@ -166,7 +171,7 @@ func TestBasicDataStoreOperations(t *testing.T) {
 		assert.Equal(t, fmt.Sprintf(abcExampleRspFormatter, dnsConfigTestIP3), (*rrResponse)[0].String(),
 			"Error")
 
-		err = store.DelResourceRecord("", exampleAbcDomain, "A")
+		_, err = store.DelResourceRecord("", exampleAbcDomain, "A")
 		assert.Equal(t, nil, err, errorDeleteMessage)
 	})
 
@@ -184,10 +189,661 @@ func TestBasicDataStoreOperations(t *testing.T) {
 		assert.Equal(t, fmt.Sprintf(abcExampleRspFormatter, dnsConfigTestIP5), (*rrResponse)[2].String(),
 			"Error")
 
-		err = store.DelResourceRecord("", exampleAbcDomain, "A")
+		_, err = store.DelResourceRecord("", exampleAbcDomain, "A")
+		assert.Equal(t, nil, err, errorDeleteMessage)
+	})
+
+	err = store.Close()
+	assert.Equal(t, nil, err, "Error in closing the db")
+}
+
+func TestRecordTypesDataStoreOperations(t *testing.T) {
+	forEachBackend(t, testRecordTypesDataStoreOperations)
+}
+
+func testRecordTypesDataStoreOperations(t *testing.T, newStore newStoreFunc) {
+	defer func() {
+		_ = os.RemoveAll(DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	store := newStore("testdb", nil)
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+
+	t.Run("SRVRecord", func(t *testing.T) {
+		err = store.SetResourceRecord("example.com.", &ResourceRecord{Name: "_http._tcp.example.com.", Type: "SRV",
+			Class: "IN", TTL: 30, RData: []string{"10 5 8080 app.example.com."}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		rrResponse, err := store.GetResourceRecord(&dns.Question{Name: "_http._tcp.example.com.",
+			Qtype: dns.TypeSRV, Qclass: dns.ClassINET})
+		assert.Equal(t, nil, err, "Error in reading the record")
+		assert.Equal(t, "_http._tcp.example.com.\t30\tIN\tSRV\t10 5 8080 app.example.com.",
+			(*rrResponse)[0].String(), "Error")
+		_, err = store.DelResourceRecord("example.com.", "_http._tcp.example.com.", "SRV")
+		assert.Equal(t, nil, err, errorDeleteMessage)
+	})
+
+	t.Run("TXTRecord", func(t *testing.T) {
+		err = store.SetResourceRecord(".", &ResourceRecord{Name: exampleDomain, Type: "TXT",
+			Class: "IN", TTL: 30, RData: []string{"app=video version=1"}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		rrResponse, err := store.GetResourceRecord(&dns.Question{Name: exampleDomain,
+			Qtype: dns.TypeTXT, Qclass: dns.ClassINET})
+		assert.Equal(t, nil, err, "Error in reading the record")
+		assert.Equal(t, "www.example.com.\t30\tIN\tTXT\t\"app=video version=1\"", (*rrResponse)[0].String(),
+			"Error")
+		_, err = store.DelResourceRecord(".", exampleDomain, "TXT")
+		assert.Equal(t, nil, err, errorDeleteMessage)
+	})
+
+	t.Run("MXNSAndPTRRecords", func(t *testing.T) {
+		err = store.SetResourceRecord("example.com.", &ResourceRecord{Name: "example.com.", Type: "MX",
+			Class: "IN", TTL: 30, RData: []string{"10 mail.example.com.", "20 mail2.example.com."}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		err = store.SetResourceRecord("example.com.", &ResourceRecord{Name: "example.com.", Type: "NS",
+			Class: "IN", TTL: 30, RData: []string{"ns1.example.com."}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		err = store.SetResourceRecord("1.168.192.in-addr.arpa.", &ResourceRecord{Name: "101.1.168.192.in-addr.arpa.",
+			Type: "PTR", Class: "IN", TTL: 30, RData: []string{exampleDomain}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+
+		rrResponse, _ := store.GetResourceRecord(&dns.Question{Name: "example.com.",
+			Qtype: dns.TypeMX, Qclass: dns.ClassINET})
+		assert.Equal(t, 2, len(*rrResponse), "Not found all records")
+		assert.Equal(t, "example.com.\t30\tIN\tMX\t10 mail.example.com.", (*rrResponse)[0].String(), "Error")
+		rrResponse, _ = store.GetResourceRecord(&dns.Question{Name: "example.com.",
+			Qtype: dns.TypeNS, Qclass: dns.ClassINET})
+		assert.Equal(t, "example.com.\t30\tIN\tNS\tns1.example.com.", (*rrResponse)[0].String(), "Error")
+		rrResponse, _ = store.GetResourceRecord(&dns.Question{Name: "101.1.168.192.in-addr.arpa.",
+			Qtype: dns.TypePTR, Qclass: dns.ClassINET})
+		assert.Equal(t, "101.1.168.192.in-addr.arpa.\t30\tIN\tPTR\twww.example.com.", (*rrResponse)[0].String(),
+			"Error")
+
+		_, err = store.DelResourceRecord("example.com.", "example.com.", "MX")
+		assert.Equal(t, nil, err, errorDeleteMessage)
+		_, err = store.DelResourceRecord("example.com.", "example.com.", "NS")
 		assert.Equal(t, nil, err, errorDeleteMessage)
+		_, err = store.DelResourceRecord("1.168.192.in-addr.arpa.", "101.1.168.192.in-addr.arpa.",
+			"PTR")
+		assert.Equal(t, nil, err, errorDeleteMessage)
+	})
+
+	t.Run("CNAMEChasing", func(t *testing.T) {
+		err = store.SetResourceRecord(".", &ResourceRecord{Name: exampleDomain, Type: "CNAME",
+			Class: "IN", TTL: 30, RData: []string{exampleAbcDomain}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		err = store.SetResourceRecord("example.com.", &ResourceRecord{Name: exampleAbcDomain, Type: "A",
+			Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP1}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+
+		rrResponse, err := store.GetResourceRecord(&dns.Question{Name: exampleDomain,
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.Equal(t, nil, err, "Error in reading the record")
+		assert.Equal(t, 2, len(*rrResponse), "Not found all records")
+		assert.Equal(t, "www.example.com.\t30\tIN\tCNAME\tabc.example.com.", (*rrResponse)[0].String(), "Error")
+		assert.Equal(t, fmt.Sprintf(abcExampleRspFormatter, dnsConfigTestIP1), (*rrResponse)[1].String(), "Error")
+
+		// Query for the CNAME itself should not be chased
+		rrResponse, _ = store.GetResourceRecord(&dns.Question{Name: exampleDomain,
+			Qtype: dns.TypeCNAME, Qclass: dns.ClassINET})
+		assert.Equal(t, 1, len(*rrResponse), "Error")
+
+		_, err = store.DelResourceRecord(".", exampleDomain, "CNAME")
+		assert.Equal(t, nil, err, errorDeleteMessage)
+		_, err = store.DelResourceRecord("example.com.", exampleAbcDomain, "A")
+		assert.Equal(t, nil, err, errorDeleteMessage)
+	})
+
+	t.Run("CNAMELoop", func(t *testing.T) {
+		_ = store.SetResourceRecord(".", &ResourceRecord{Name: exampleDomain, Type: "CNAME",
+			Class: "IN", TTL: 30, RData: []string{exampleAbcDomain}})
+		_ = store.SetResourceRecord(".", &ResourceRecord{Name: exampleAbcDomain, Type: "CNAME",
+			Class: "IN", TTL: 30, RData: []string{exampleDomain}})
+
+		rrResponse, err := store.GetResourceRecord(&dns.Question{Name: exampleDomain,
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.Equal(t, nil, err, "Error in reading the record")
+		assert.Equal(t, 2, len(*rrResponse), "Error")
+
+		_, err = store.DelResourceRecord(".", exampleDomain, "CNAME")
+		assert.Equal(t, nil, err, errorDeleteMessage)
+		_, err = store.DelResourceRecord(".", exampleAbcDomain, "CNAME")
+		assert.Equal(t, nil, err, errorDeleteMessage)
+	})
+
+	t.Run("CNAMEConflict", func(t *testing.T) {
+		_ = store.SetResourceRecord(".", &ResourceRecord{Name: exampleDomain, Type: "A",
+			Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP1}})
+		err = store.SetResourceRecord(".", &ResourceRecord{Name: exampleDomain, Type: "CNAME",
+			Class: "IN", TTL: 30, RData: []string{exampleAbcDomain}})
+		assert.EqualError(t, err, "CNAME entry cannot coexist with other entries for www.example.com.",
+			errorSettingMessage)
+
+		err = store.SetResourceRecord(".", &ResourceRecord{Name: example1Domain, Type: "CNAME",
+			Class: "IN", TTL: 30, RData: []string{exampleAbcDomain, exampleDomain}})
+		assert.EqualError(t, err, "only one rdata allowed for CNAME entry", errorSettingMessage)
+
+		_, err = store.DelResourceRecord(".", exampleDomain, "A")
+		assert.Equal(t, nil, err, errorDeleteMessage)
+	})
+
+	t.Run("ValidateRData", func(t *testing.T) {
+		assert.Equal(t, nil, ValidateRData("SRV", "10 5 8080 app.example.com."), "Error")
+		assert.NotEqual(t, nil, ValidateRData("SRV", "10 app.example.com."), "Error")
+		assert.NotEqual(t, nil, ValidateRData("MX", "mail.example.com."), "Error")
+		assert.NotEqual(t, nil, ValidateRData("CNAME", "app.example.com. ; comment"), "Error")
+		assert.NotEqual(t, nil, ValidateRData("TXT", ""), "Error")
+		assert.EqualError(t, ValidateRData("AB", "1.1.1.1"), "unsupported rrtype(AB) entry", "Error")
+	})
+
+	err = store.Close()
+	assert.Equal(t, nil, err, "Error in closing the db")
+}
+
+func TestWildcardDataStoreOperations(t *testing.T) {
+	forEachBackend(t, testWildcardDataStoreOperations)
+}
+
+func testWildcardDataStoreOperations(t *testing.T, newStore newStoreFunc) {
+	defer func() {
+		_ = os.RemoveAll(DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	const (
+		videoZone     = "video.mec.local."
+		videoWildcard = "*.video.mec.local."
+	)
+
+	store := newStore("testdb", nil)
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+
+	err = store.SetResourceRecord(videoZone, &ResourceRecord{Name: videoWildcard, Type: "A",
+		Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP1}})
+	assert.Equal(t, nil, err, errorSettingMessage)
+
+	t.Run("WildcardMatch", func(t *testing.T) {
+		rrResponse, err := store.GetResourceRecord(&dns.Question{Name: "App1.video.mec.local.",
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.Equal(t, nil, err, "Error in reading the record")
+		assert.Equal(t, fmt.Sprintf("App1.video.mec.local.\t30\tIN\tA\t%s", dnsConfigTestIP1),
+			(*rrResponse)[0].String(), "Error")
+
+		rrResponse, err = store.GetResourceRecord(&dns.Question{Name: "a.b.video.mec.local.",
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.Equal(t, nil, err, "Error in reading the record")
+		assert.Equal(t, fmt.Sprintf("a.b.video.mec.local.\t30\tIN\tA\t%s", dnsConfigTestIP1),
+			(*rrResponse)[0].String(), "Error")
+	})
+
+	t.Run("WildcardOtherType", func(t *testing.T) {
+		_, err = store.GetResourceRecord(&dns.Question{Name: "app1.video.mec.local.",
+			Qtype: dns.TypeAAAA, Qclass: dns.ClassINET})
+		assert.EqualError(t, err, "could not process/retrieve the query", "Error")
+	})
+
+	t.Run("ExactMatchPreferred", func(t *testing.T) {
+		_ = store.SetResourceRecord(videoZone, &ResourceRecord{Name: "app2.video.mec.local.", Type: "A",
+			Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP2}})
+		_ = store.SetResourceRecord(videoZone, &ResourceRecord{Name: "app3.video.mec.local.", Type: "TXT",
+			Class: "IN", TTL: 30, RData: []string{"no address"}})
+
+		rrResponse, _ := store.GetResourceRecord(&dns.Question{Name: "app2.video.mec.local.",
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.Equal(t, fmt.Sprintf("app2.video.mec.local.\t30\tIN\tA\t%s", dnsConfigTestIP2),
+			(*rrResponse)[0].String(), "Error")
+
+		// Existing name with other type should not match the wildcard
+		_, err = store.GetResourceRecord(&dns.Question{Name: "app3.video.mec.local.",
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.NotEqual(t, nil, err, "Error")
+
+		_, _ = store.DelResourceRecord(videoZone, "app2.video.mec.local.", "A")
+		_, _ = store.DelResourceRecord(videoZone, "app3.video.mec.local.", "TXT")
 	})
 
+	t.Run("ClosestEncloserBlocksWildcard", func(t *testing.T) {
+		_ = store.SetResourceRecord(videoZone, &ResourceRecord{Name: "sub.video.mec.local.", Type: "A",
+			Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP2}})
+		_ = store.SetResourceRecord(videoZone, &ResourceRecord{Name: "x.ent.video.mec.local.", Type: "A",
+			Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP2}})
+
+		_, err = store.GetResourceRecord(&dns.Question{Name: "a.sub.video.mec.local.",
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.NotEqual(t, nil, err, "Error")
+		// Empty non-terminal exists, so wildcard does not apply
+		_, err = store.GetResourceRecord(&dns.Question{Name: "ent.video.mec.local.",
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.NotEqual(t, nil, err, "Error")
+		_, err = store.GetResourceRecord(&dns.Question{Name: "y.ent.video.mec.local.",
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.NotEqual(t, nil, err, "Error")
+
+		_, _ = store.DelResourceRecord(videoZone, "sub.video.mec.local.", "A")
+		_, _ = store.DelResourceRecord(videoZone, "x.ent.video.mec.local.", "A")
+	})
+
+	t.Run("WildcardCNAME", func(t *testing.T) {
+		_ = store.SetResourceRecord("mec.local.", &ResourceRecord{Name: "*.apps.mec.local.", Type: "CNAME",
+			Class: "IN", TTL: 30, RData: []string{"gw.video.mec.local."}})
+
+		rrResponse, err := store.GetResourceRecord(&dns.Question{Name: "app1.apps.mec.local.",
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.Equal(t, nil, err, "Error in reading the record")
+		assert.Equal(t, 2, len(*rrResponse), "Not found all records")
+		assert.Equal(t, "app1.apps.mec.local.\t30\tIN\tCNAME\tgw.video.mec.local.", (*rrResponse)[0].String(),
+			"Error")
+		assert.Equal(t, fmt.Sprintf("gw.video.mec.local.\t30\tIN\tA\t%s", dnsConfigTestIP1),
+			(*rrResponse)[1].String(), "Error")
+
+		_, _ = store.DelResourceRecord("mec.local.", "*.apps.mec.local.", "CNAME")
+	})
+
+	_, err = store.DelResourceRecord(videoZone, videoWildcard, "A")
+	assert.Equal(t, nil, err, errorDeleteMessage)
+
 	err = store.Close()
 	assert.Equal(t, nil, err, "Error in closing the db")
 }
+
+func TestAuthoritativeZoneDataStoreOperations(t *testing.T) {
+	forEachBackend(t, testAuthoritativeZoneDataStoreOperations)
+}
+
+func testAuthoritativeZoneDataStoreOperations(t *testing.T, newStore newStoreFunc) {
+	defer func() {
+		_ = os.RemoveAll(DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	const soaRData = "ns1.example.com. admin.example.com. 1 3600 600 86400 10"
+
+	store := newStore("testdb", nil)
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+
+	// Changes after the SOA bump the serial, so the records go first
+	err = store.SetResourceRecord("example.com.", &ResourceRecord{Name: exampleAbcDomain, Type: "A",
+		Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP1}})
+	assert.Equal(t, nil, err, errorSettingMessage)
+	err = store.SetResourceRecord("example.com.", &ResourceRecord{Name: "example.com.", Type: "SOA",
+		Class: "IN", TTL: 30, RData: []string{soaRData}})
+	assert.Equal(t, nil, err, errorSettingMessage)
+
+	t.Run("SOANotAtApex", func(t *testing.T) {
+		err = store.SetResourceRecord("example.com.", &ResourceRecord{Name: exampleDomain, Type: "SOA",
+			Class: "IN", TTL: 30, RData: []string{soaRData}})
+		assert.EqualError(t, err, "only one SOA entry allowed at the zone(example.com.) apex", errorSettingMessage)
+	})
+
+	t.Run("QuerySOA", func(t *testing.T) {
+		rrResponse, err := store.GetResourceRecord(&dns.Question{Name: "example.com.",
+			Qtype: dns.TypeSOA, Qclass: dns.ClassINET})
+		assert.Equal(t, nil, err, "Error in reading the record")
+		assert.Equal(t, "example.com.\t30\tIN\tSOA\t"+soaRData, (*rrResponse)[0].String(), "Error")
+	})
+
+	t.Run("NameNotExists", func(t *testing.T) {
+		_, err = store.GetResourceRecord(&dns.Question{Name: exampleDomain,
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		notFound, ok := err.(*NotFoundError)
+		assert.Equal(t, true, ok, "Error")
+		assert.Equal(t, "example.com.\t30\tIN\tSOA\t"+soaRData, notFound.Authority.String(), "Error")
+		assert.Equal(t, false, notFound.NameExists, "Error")
+	})
+
+	t.Run("NameExistsWithOtherType", func(t *testing.T) {
+		_, err = store.GetResourceRecord(&dns.Question{Name: exampleAbcDomain,
+			Qtype: dns.TypeAAAA, Qclass: dns.ClassINET})
+		notFound, ok := err.(*NotFoundError)
+		assert.Equal(t, true, ok, "Error")
+		assert.NotEqual(t, nil, notFound.Authority, "Error")
+		assert.Equal(t, true, notFound.NameExists, "Error")
+	})
+
+	t.Run("EmptyNonTerminalExists", func(t *testing.T) {
+		_ = store.SetResourceRecord("example.com.", &ResourceRecord{Name: "x.ent.example.com.", Type: "A",
+			Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP1}})
+		_, err = store.GetResourceRecord(&dns.Question{Name: "ent.example.com.",
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		notFound, ok := err.(*NotFoundError)
+		assert.Equal(t, true, ok, "Error")
+		assert.Equal(t, true, notFound.NameExists, "Error")
+		_, _ = store.DelResourceRecord("example.com.", "x.ent.example.com.", "A")
+
+		// Not an empty non-terminal anymore once the descendant is deleted
+		_, err = store.GetResourceRecord(&dns.Question{Name: "ent.example.com.",
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		notFound, ok = err.(*NotFoundError)
+		assert.Equal(t, true, ok, "Error")
+		assert.Equal(t, false, notFound.NameExists, "Error")
+	})
+
+	t.Run("DescendantOnLabelBoundary", func(t *testing.T) {
+		_ = store.SetResourceRecord("example.com.", &ResourceRecord{Name: "xent.example.com.", Type: "A",
+			Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP1}})
+		_, err = store.GetResourceRecord(&dns.Question{Name: "ent.example.com.",
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		notFound, ok := err.(*NotFoundError)
+		assert.Equal(t, true, ok, "Error")
+		assert.Equal(t, false, notFound.NameExists, "Error")
+		_, _ = store.DelResourceRecord("example.com.", "xent.example.com.", "A")
+	})
+
+	t.Run("NonAuthoritativeZone", func(t *testing.T) {
+		_, err = store.GetResourceRecord(&dns.Question{Name: example1Domain,
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		notFound, ok := err.(*NotFoundError)
+		assert.Equal(t, true, ok, "Error")
+		assert.Equal(t, nil, notFound.Authority, "Error")
+	})
+
+	_, err = store.DelResourceRecord("example.com.", exampleAbcDomain, "A")
+	assert.Equal(t, nil, err, errorDeleteMessage)
+	_, err = store.DelResourceRecord("example.com.", "example.com.", "SOA")
+	assert.Equal(t, nil, err, errorDeleteMessage)
+
+	err = store.Close()
+	assert.Equal(t, nil, err, "Error in closing the db")
+}
+
+func TestListDataStoreOperations(t *testing.T) {
+	forEachBackend(t, testListDataStoreOperations)
+}
+
+func testListDataStoreOperations(t *testing.T, newStore newStoreFunc) {
+	defer func() {
+		_ = os.RemoveAll(DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	store := newStore("testdb", nil)
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+	defer store.Close()
+
+	err = store.SetResourceRecord(".", &ResourceRecord{Name: exampleDomain, Type: "A",
+		Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP1, dnsConfigTestIP2}})
+	assert.Equal(t, nil, err, errorSettingMessage)
+	err = store.SetResourceRecord(".", &ResourceRecord{Name: exampleDomain, Type: "TXT",
+		Class: "IN", TTL: 60, RData: []string{"v=app1"}})
+	assert.Equal(t, nil, err, errorSettingMessage)
+	err = store.SetResourceRecord("example.com.", &ResourceRecord{Name: exampleAbcDomain, Type: "A",
+		Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP3}})
+	assert.Equal(t, nil, err, errorSettingMessage)
+
+	t.Run("ListZones", func(t *testing.T) {
+		zones, err := store.ListZones()
+		assert.Equal(t, nil, err, "Error in listing the zones")
+		assert.Equal(t, []string{".", "example.com."}, zones, "Error in listing the zones")
+	})
+
+	t.Run("ListAll", func(t *testing.T) {
+		records, err := store.ListResourceRecords(&RecordFilter{})
+		assert.Equal(t, nil, err, "Error in listing the records")
+		assert.Equal(t, 3, len(records), "Error in listing the records")
+		assert.Equal(t, ZoneResourceRecord{Zone: "example.com.", ResourceRecord: ResourceRecord{
+			Name: exampleAbcDomain, Type: "A", Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP3}}},
+			records[2], "Error in listing the records")
+	})
+
+	t.Run("ListFiltered", func(t *testing.T) {
+		records, err := store.ListResourceRecords(&RecordFilter{Zone: "."})
+		assert.Equal(t, nil, err, "Error in listing the records")
+		assert.Equal(t, 2, len(records), "Error in listing the records")
+
+		records, err = store.ListResourceRecords(&RecordFilter{Name: "WWW.example.com."})
+		assert.Equal(t, nil, err, "Error in listing the records")
+		assert.Equal(t, 2, len(records), "Error in listing the records")
+
+		records, err = store.ListResourceRecords(&RecordFilter{Name: exampleDomain, Type: "TXT"})
+		assert.Equal(t, nil, err, "Error in listing the records")
+		assert.Equal(t, 1, len(records), "Error in listing the records")
+		assert.Equal(t, []string{"v=app1"}, records[0].RData, "Error in listing the records")
+		assert.Equal(t, uint32(60), records[0].TTL, "Error in listing the records")
+
+		records, err = store.ListResourceRecords(&RecordFilter{Zone: "example.com.", Name: exampleDomain})
+		assert.Equal(t, nil, err, "Error in listing the records")
+		assert.Equal(t, 0, len(records), "Error in listing the records")
+
+		_, err = store.ListResourceRecords(&RecordFilter{Type: "AAB"})
+		assert.EqualError(t, err, "unsupported rrtype(AAB) entry", "Error in listing the records")
+	})
+}
+
+func TestZoneDataStoreOperations(t *testing.T) {
+	forEachBackend(t, testZoneDataStoreOperations)
+}
+
+func testZoneDataStoreOperations(t *testing.T, newStore newStoreFunc) {
+	defer func() {
+		_ = os.RemoveAll(DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	store := newStore("testdb", nil)
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+	defer store.Close()
+
+	t.Run("CreateZone", func(t *testing.T) {
+		err := store.CreateZone("example.com.")
+		assert.Equal(t, nil, err, "Error in creating the zone")
+		err = store.CreateZone("example.com.")
+		assert.EqualError(t, err, "zone(example.com.) already exists", "Error in creating the zone")
+		zones, _ := store.ListZones()
+		assert.Equal(t, []string{".", "example.com."}, zones, "Error in creating the zone")
+	})
+
+	t.Run("ImportZone", func(t *testing.T) {
+		err := store.SetResourceRecord("example.com.", &ResourceRecord{Name: example1Domain, Type: "A",
+			Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP1}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+
+		err = store.ImportZone("example.com.", []ResourceRecord{
+			{Name: exampleDomain, Type: "A", Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP2}},
+			{Name: exampleAbcDomain, Type: "CNAME", Class: "IN", TTL: 30, RData: []string{exampleDomain}}})
+		assert.Equal(t, nil, err, "Error in importing the zone")
+		records, _ := store.ListResourceRecords(&RecordFilter{Zone: "example.com."})
+		assert.Equal(t, 2, len(records), "Error in importing the zone")
+		// Existing records are replaced
+		records, _ = store.ListResourceRecords(&RecordFilter{Name: example1Domain})
+		assert.Equal(t, 0, len(records), "Error in importing the zone")
+	})
+
+	t.Run("ImportZoneFailureIsAtomic", func(t *testing.T) {
+		err := store.ImportZone("example.com.", []ResourceRecord{
+			{Name: exampleDomain, Type: "A", Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP3}},
+			{Name: exampleDomain, Type: "CNAME", Class: "IN", TTL: 30, RData: []string{exampleAbcDomain}}})
+		assert.EqualError(t, err, "importing www.example.com. CNAME failed, CNAME entry cannot coexist with "+
+			"other entries for www.example.com.", "Error in importing the zone")
+		records, _ := store.ListResourceRecords(&RecordFilter{Zone: "example.com.", Name: exampleDomain})
+		assert.Equal(t, []string{dnsConfigTestIP2}, records[0].RData, "Error in importing the zone")
+	})
+
+	t.Run("DeleteZone", func(t *testing.T) {
+		err := store.DeleteZone("example.com.")
+		assert.Equal(t, nil, err, "Error in deleting the zone")
+		records, _ := store.ListResourceRecords(&RecordFilter{Zone: "example.com."})
+		assert.Equal(t, 0, len(records), "Error in deleting the zone")
+		_, err = store.GetResourceRecord(&dns.Question{Name: exampleDomain, Qtype: dns.TypeA,
+			Qclass: dns.ClassINET})
+		assert.NotEqual(t, nil, err, "Error in deleting the zone")
+
+		err = store.DeleteZone("example.com.")
+		assert.EqualError(t, err, "zone(example.com.) not found", "Error in deleting the zone")
+		err = store.DeleteZone(".")
+		assert.EqualError(t, err, "default zone(.) cannot be deleted", "Error in deleting the zone")
+	})
+}
+
+func TestBatchDataStoreOperations(t *testing.T) {
+	forEachBackend(t, testBatchDataStoreOperations)
+}
+
+func testBatchDataStoreOperations(t *testing.T, newStore newStoreFunc) {
+	defer func() {
+		_ = os.RemoveAll(DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	store := newStore("testdb", nil)
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+	defer store.Close()
+
+	err = store.SetResourceRecord(".", &ResourceRecord{Name: example1Domain, Type: "A",
+		Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP1}})
+	assert.Equal(t, nil, err, errorSettingMessage)
+
+	t.Run("BatchApplied", func(t *testing.T) {
+		results, err := store.ApplyBatch([]BatchOperation{
+			{Op: BatchOpAdd, RR: ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN", TTL: 30,
+				RData: []string{dnsConfigTestIP2}}},
+			{Op: BatchOpUpdate, Zone: ".", RR: ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN", TTL: 30,
+				RData: []string{dnsConfigTestIP3}}},
+			{Op: BatchOpDelete, RR: ResourceRecord{Name: example1Domain, Type: "A"}}})
+		assert.Equal(t, nil, err, "Error in batch update")
+		assert.Equal(t, []BatchResult{{Index: 0, Status: BatchApplied}, {Index: 1, Status: BatchApplied},
+			{Index: 2, Status: BatchApplied}}, results, "Error in batch update")
+
+		records, _ := store.ListResourceRecords(&RecordFilter{})
+		assert.Equal(t, 1, len(records), "Error in batch update")
+		assert.Equal(t, []string{dnsConfigTestIP3}, records[0].RData, "Error in batch update")
+	})
+
+	t.Run("BatchRolledBack", func(t *testing.T) {
+		results, err := store.ApplyBatch([]BatchOperation{
+			{Op: BatchOpAdd, RR: ResourceRecord{Name: example1Domain, Type: "A", Class: "IN", TTL: 30,
+				RData: []string{dnsConfigTestIP1}}},
+			{Op: BatchOpAdd, RR: ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN", TTL: 30,
+				RData: []string{dnsConfigTestIP2}}},
+			{Op: BatchOpDelete, RR: ResourceRecord{Name: exampleAbcDomain, Type: "A"}},
+			{Op: "replace", RR: ResourceRecord{Name: exampleDomain, Type: "A"}}})
+		assert.EqualError(t, err, "batch update failed, no changes applied", "Error in batch update")
+		assert.Equal(t, []BatchResult{{Index: 0, Status: BatchRolledBack},
+			{Index: 1, Status: BatchFailed, Error: "record already exists"},
+			{Index: 2, Status: BatchFailed, Error: "not found for the zone ."},
+			{Index: 3, Status: BatchFailed, Error: "unsupported operation(replace)"}}, results,
+			"Error in batch update")
+
+		records, _ := store.ListResourceRecords(&RecordFilter{})
+		assert.Equal(t, 1, len(records), "Error in batch update")
+		assert.Equal(t, exampleDomain, records[0].Name, "Error in batch update")
+	})
+}
+
+func TestViewDataStoreOperations(t *testing.T) {
+	forEachBackend(t, testViewDataStoreOperations)
+}
+
+func testViewDataStoreOperations(t *testing.T, newStore newStoreFunc) {
+	defer func() {
+		_ = os.RemoveAll(DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	store := newStore("testdb", nil)
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+	defer store.Close()
+
+	err = store.SetResourceRecord(".", &ResourceRecord{Name: exampleDomain, Type: "A",
+		Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP1}})
+	assert.Equal(t, nil, err, errorSettingMessage)
+	err = store.SetResourceRecord(".", &ResourceRecord{Name: example1Domain, Type: "A",
+		Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP2}})
+	assert.Equal(t, nil, err, errorSettingMessage)
+
+	t.Run("SetView", func(t *testing.T) {
+		err := store.SetView(&View{Name: "ue", CIDRs: []string{"10.0.0.0/8"}})
+		assert.Equal(t, nil, err, "Error in setting the view")
+		err = store.SetView(&View{Name: "mgmt", CIDRs: []string{"10.10.0.0/16", "2001:db8::/64"}})
+		assert.Equal(t, nil, err, "Error in setting the view")
+
+		err = store.SetView(&View{Name: "app", CIDRs: []string{"10.10.0.0/16"}})
+		assert.EqualError(t, err, "cidr(10.10.0.0/16) already used by view(mgmt)", "Error in setting the view")
+		// The same network written with host bits
+		err = store.SetView(&View{Name: "app", CIDRs: []string{"10.10.1.1/16"}})
+		assert.EqualError(t, err, "cidr(10.10.0.0/16) already used by view(mgmt)", "Error in setting the view")
+		err = store.SetView(&View{Name: "ue", CIDRs: []string{"10.0.0.1/8"}})
+		assert.Equal(t, nil, err, "Error in setting the view")
+		err = store.SetView(&View{Name: "app", CIDRs: []string{"10.10.0.300/16"}})
+		assert.EqualError(t, err, "invalid cidr(10.10.0.300/16) for view(app)", "Error in setting the view")
+
+		views, _ := store.ListViews()
+		assert.Equal(t, []View{{Name: "mgmt", CIDRs: []string{"10.10.0.0/16", "2001:db8::/64"}},
+			{Name: "ue", CIDRs: []string{"10.0.0.0/8"}}}, views, "Error in setting the view")
+	})
+
+	t.Run("SelectView", func(t *testing.T) {
+		assert.Equal(t, "mgmt", store.SelectView(net.ParseIP("10.10.1.1")), "Error in selecting the view")
+		assert.Equal(t, "mgmt", store.SelectView(net.ParseIP("2001:db8::1")), "Error in selecting the view")
+		assert.Equal(t, "ue", store.SelectView(net.ParseIP("10.20.1.1")), "Error in selecting the view")
+		assert.Equal(t, "", store.SelectView(net.ParseIP("192.168.1.1")), "Error in selecting the view")
+	})
+
+	t.Run("ViewOverridesDefault", func(t *testing.T) {
+		err := store.WithView("ue").SetResourceRecord(".", &ResourceRecord{Name: exampleDomain, Type: "A",
+			Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP3}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+
+		rrs, err := store.WithView("ue").GetResourceRecord(&dns.Question{Name: exampleDomain, Qtype: dns.TypeA,
+			Qclass: dns.ClassINET})
+		assert.Equal(t, nil, err, "Error in retrieving the record")
+		assert.Equal(t, fmt.Sprintf(exampleRspFormatter, dnsConfigTestIP3), (*rrs)[0].String(),
+			"Error in retrieving the record")
+		// Names not in the view are answered from the default view
+		rrs, err = store.WithView("ue").GetResourceRecord(&dns.Question{Name: example1Domain, Qtype: dns.TypeA,
+			Qclass: dns.ClassINET})
+		assert.Equal(t, nil, err, "Error in retrieving the record")
+		assert.Equal(t, 1, len(*rrs), "Error in retrieving the record")
+		// Default view is not affected
+		rrs, _ = store.GetResourceRecord(&dns.Question{Name: exampleDomain, Qtype: dns.TypeA,
+			Qclass: dns.ClassINET})
+		assert.Equal(t, fmt.Sprintf(exampleRspFormatter, dnsConfigTestIP1), (*rrs)[0].String(),
+			"Error in retrieving the record")
+
+		records, _ := store.WithView("ue").ListResourceRecords(&RecordFilter{})
+		assert.Equal(t, 1, len(records), "Error in listing the records")
+		_, err = store.WithView("ue").DelResourceRecord(".", exampleDomain, "A")
+		assert.Equal(t, nil, err, errorDeleteMessage)
+		assert.Equal(t, true, store.IsResourceRecordExists(".", &ResourceRecord{Name: exampleDomain, Type: "A",
+			TTL: 30}), errorDeleteMessage)
+	})
+
+	t.Run("UnknownView", func(t *testing.T) {
+		err := store.WithView("app").SetResourceRecord(".", &ResourceRecord{Name: exampleDomain, Type: "A",
+			Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP3}})
+		assert.EqualError(t, err, "view(app) not found", errorSettingMessage)
+		// Lookup falls back to the default view
+		rrs, err := store.WithView("app").GetResourceRecord(&dns.Question{Name: exampleDomain, Qtype: dns.TypeA,
+			Qclass: dns.ClassINET})
+		assert.Equal(t, nil, err, "Error in retrieving the record")
+		assert.Equal(t, 1, len(*rrs), "Error in retrieving the record")
+	})
+
+	t.Run("DeleteView", func(t *testing.T) {
+		err := store.DeleteView("mgmt")
+		assert.Equal(t, nil, err, "Error in deleting the view")
+		assert.Equal(t, "ue", store.SelectView(net.ParseIP("10.10.1.1")), "Error in selecting the view")
+		err = store.DeleteView("mgmt")
+		assert.EqualError(t, err, "view(mgmt) not found", "Error in deleting the view")
+	})
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package datastore
+package datastore
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	"dns-server/health"
+)
+
+var (
+	errBucketExists   = errors.New("bucket already exists")
+	errBucketNotFound = errors.New("bucket not found")
+	errTxNotWritable  = errors.New("tx not writable")
+)
+
+// kvBackend key value store holding the records in nested buckets, as bolt db. Update runs the function in a
+// writable transaction, all the changes are discarded if it returns an error.
+type kvBackend interface {
+	View(fn func(tx kvTx) error) error
+	Update(fn func(tx kvTx) error) error
+	Close() error
+}
+
+// kvTx transaction of the key value backend, giving the top level buckets.
+type kvTx interface {
+	Bucket(name []byte) kvBucket
+	CreateBucketIfNotExists(name []byte) (kvBucket, error)
+}
+
+// kvBucket bucket of the key values and the nested buckets, iterated in the byte order of the keys. Nested buckets
+// have nil value on iteration.
+type kvBucket interface {
+	Get(key []byte) []byte
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+	Bucket(name []byte) kvBucket
+	CreateBucket(name []byte) (kvBucket, error)
+	CreateBucketIfNotExists(name []byte) (kvBucket, error)
+	DeleteBucket(name []byte) error
+	ForEach(fn func(k, v []byte) error) error
+	Cursor() kvCursor
+	NextSequence() (uint64, error)
+}
+
+// kvCursor cursor over the keys of a bucket.
+type kvCursor interface {
+	First() ([]byte, []byte)
+	Seek(seek []byte) ([]byte, []byte)
+	Next() ([]byte, []byte)
+	Delete() error
+}
+
+// bucketStore data store over the buckets of a key value backend, shared by all the data store backends. Zones are
+// buckets of records under the zone bucket, views hold their own zone bucket under the view bucket.
+type bucketStore struct {
+	backend     kvBackend
+	loadBalance *switchFlag     // shuffle the answers of the records without weights
+	health      *health.Monitor // health of the addresses with a health check, all healthy if nil
+	view        string
+	views       *viewTable
+}
+
+// Open create the top level buckets along with the default zone if not exists, and load the views.
+func (b *bucketStore) Open() error {
+	err := b.backend.Update(func(tx kvTx) error {
+		bZone, err := tx.CreateBucketIfNotExists([]byte(ZoneConfig))
+		if err != nil {
+			log.Error("Failed to create the zone bucket.", nil)
+			return fmt.Errorf("error creating zone bucket: %s", err)
+		}
+		_, err = bZone.CreateBucketIfNotExists([]byte(DefaultZone))
+		if err != nil {
+			log.Error("Failed to create the default(.) zone bucket.", nil)
+			return fmt.Errorf("error creating default zone(.) bucket: %s", err)
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte(ViewConfig))
+		if err != nil {
+			log.Error("Failed to create the view bucket.", nil)
+			return fmt.Errorf("error creating view bucket: %s", err)
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte(ZoneOptionConfig))
+		if err != nil {
+			log.Error("Failed to create the zone option bucket.", nil)
+			return fmt.Errorf("error creating zone option bucket: %s", err)
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte(ZoneKeyConfig))
+		if err != nil {
+			log.Error("Failed to create the zone key bucket.", nil)
+			return fmt.Errorf("error creating zone key bucket: %s", err)
+		}
+		_, err = tx.CreateBucketIfNotExists([]byte(JournalConfig))
+		if err != nil {
+			log.Error("Failed to create the journal bucket.", nil)
+			return fmt.Errorf("error creating journal bucket: %s", err)
+		}
+
+		return b.indexAllZoneNames(tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.loadViews()
+}
+
+// Close close the backend, shared by the views.
+func (b *bucketStore) Close() error {
+	return b.backend.Close()
+}
+
+// SetLoadBalance switch the shuffling of the answers of the records without weights, for all the views.
+func (b *bucketStore) SetLoadBalance(enabled bool) {
+	b.loadBalance.set(enabled)
+}
+
+// switchFlag flag switched at run time, safe for concurrent use.
+type switchFlag struct {
+	value int32
+}
+
+func newSwitchFlag(on bool) *switchFlag {
+	f := &switchFlag{}
+	f.set(on)
+
+	return f
+}
+
+func (f *switchFlag) set(on bool) {
+	var value int32
+	if on {
+		value = 1
+	}
+	atomic.StoreInt32(&f.value, value)
+}
+
+func (f *switchFlag) isSet() bool {
+	return atomic.LoadInt32(&f.value) != 0
+}
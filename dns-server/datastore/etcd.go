@@ -0,0 +1,361 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package datastore
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"dns-server/health"
+	"dns-server/util"
+)
+
+// etcdVersionKey key under the prefix changed by every commit, commits conflict when it changed since the local copy.
+const etcdVersionKey = "version"
+
+// EtcdStore data store backed by etcd, shared by the dns servers using the same prefix. Buckets keep their layout as
+// "/" separated keys under the prefix. Queries are served from a local copy kept in sync with the etcd watch, changes
+// are committed in an etcd transaction which fails on the concurrent changes of the other servers. Changes of more
+// keys than the max-txn-ops of etcd, i.e. by a zone import or delete, are committed in several transactions.
+type EtcdStore struct {
+	Endpoints   []string
+	Prefix      string          // prefix of the keys, util.DefaultEtcdPrefix if empty
+	LoadBalance bool            // shuffle the answers of the records without weights
+	Health      *health.Monitor // health of the addresses with a health check, all healthy if nil
+	*bucketStore
+}
+
+func (e *EtcdStore) Open() error {
+	prefix := e.Prefix
+	if len(prefix) == 0 {
+		prefix = util.DefaultEtcdPrefix
+	}
+	// Keys of another prefix sharing the beginning are not watched
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	client, err := clientv3.New(clientv3.Config{Endpoints: e.Endpoints,
+		DialTimeout: util.EtcdDialTimeout * time.Second})
+	if err != nil {
+		return fmt.Errorf("connecting to etcd failed, %s", err.Error())
+	}
+	backend := &etcdBackend{client: client, prefix: prefix}
+	if err = backend.reload(); err != nil {
+		_ = client.Close()
+		return err
+	}
+	store := &bucketStore{backend: backend, loadBalance: newSwitchFlag(e.LoadBalance), health: e.Health,
+		views: &viewTable{}}
+	// Views changed by the other servers
+	backend.onChange = func() {
+		if err := store.loadViews(); err != nil {
+			log.Errorf("Failed to reload the views. %s", err.Error())
+		}
+	}
+	backend.start()
+	e.bucketStore = store
+	if err = e.bucketStore.Open(); err != nil {
+		_ = backend.Close()
+		return err
+	}
+
+	log.Debugf("Initialize etcd data store(%s) success.", prefix)
+
+	return nil
+}
+
+func (e *EtcdStore) Close() error {
+	if e.bucketStore != nil {
+		if err := e.bucketStore.Close(); err != nil {
+			log.Errorf("Failed to close the etcd data store.")
+
+			return err
+		}
+	}
+	log.Debugf("Closed etcd data store as part of shutdown service.")
+
+	return nil
+}
+
+// etcdBackend key value backend replicated to etcd, over a local in-memory copy of the keys.
+type etcdBackend struct {
+	client   *clientv3.Client
+	prefix   string
+	onChange func() // called after applying the changes of the other servers
+	mutex    sync.RWMutex
+	root     *memNode
+	revision int64 // etcd revision of the local copy
+	version  int64 // mod revision of the version key in the local copy
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+func (k *etcdBackend) View(fn func(tx kvTx) error) error {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	return fn(&memTx{root: k.root})
+}
+
+// Update apply the changes to the local copy and commit the changed keys to etcd. On conflict the local copy is
+// resynced and the function is run again.
+func (k *etcdBackend) Update(fn func(tx kvTx) error) error {
+	resynced, err := k.update(fn)
+	if resynced {
+		k.onChange()
+	}
+
+	return err
+}
+
+func (k *etcdBackend) update(fn func(tx kvTx) error) (bool, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	resynced := false
+	for attempt := 1; ; attempt++ {
+		tx := &memTx{root: k.root, writable: true, changed: make(map[string]bool)}
+		if err := tx.run(fn); err != nil {
+			return resynced, err
+		}
+		if len(tx.changed) == 0 {
+			return resynced, nil
+		}
+		revision := k.revision
+		committed, err := k.commit(tx)
+		if committed {
+			return resynced, nil
+		}
+		tx.rollback()
+		if err != nil {
+			// The chunks committed before the failure are in etcd only, their watch events are skipped
+			if k.revision != revision {
+				if resyncErr := k.resync(); resyncErr != nil {
+					log.Errorf("Failed to resync with etcd. %s", resyncErr.Error())
+				}
+				resynced = true
+			}
+			return resynced, fmt.Errorf("committing the changes to etcd failed, %s", err.Error())
+		}
+		if attempt >= util.EtcdCommitRetries {
+			return resynced, fmt.Errorf("committing the changes to etcd failed, conflicting with concurrent changes")
+		}
+		log.Debugf("Changes conflicting with the concurrent changes in etcd, retrying.")
+		if err = k.resync(); err != nil {
+			return resynced, err
+		}
+		resynced = true
+	}
+}
+
+func (k *etcdBackend) Close() error {
+	if k.cancel != nil {
+		k.cancel()
+		<-k.done
+	}
+
+	return k.client.Close()
+}
+
+// commit commit the changed keys of the transaction, false without error if the version changed in etcd. The keys
+// are committed in chunks fitting in an etcd transaction, each one guarded by the version left by the previous one.
+// The chunks committed before a conflict stay in etcd, the retry commits the rest of the changes on top of them.
+func (k *etcdBackend) commit(tx *memTx) (bool, error) {
+	flatKeys := make([]string, 0, len(tx.changed))
+	for flat := range tx.changed {
+		flatKeys = append(flatKeys, flat)
+	}
+	sort.Strings(flatKeys)
+
+	// One operation of each transaction is left for the version key
+	chunkSize := util.EtcdMaxTxnOps - 1
+	for start := 0; start < len(flatKeys); start += chunkSize {
+		end := start + chunkSize
+		if end > len(flatKeys) {
+			end = len(flatKeys)
+		}
+		committed, err := k.commitKeys(flatKeys[start:end])
+		if !committed {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// commitKeys commit the flat keys along with the version key in one etcd transaction, false without error if the
+// version changed in etcd.
+func (k *etcdBackend) commitKeys(flatKeys []string) (bool, error) {
+	ops := make([]clientv3.Op, 0, len(flatKeys)+1)
+	for _, flat := range flatKeys {
+		if value, ok := k.root.lookup(flat); ok {
+			ops = append(ops, clientv3.OpPut(k.prefix+flat, string(value)))
+		} else {
+			ops = append(ops, clientv3.OpDelete(k.prefix+flat))
+		}
+	}
+	ops = append(ops, clientv3.OpPut(k.prefix+etcdVersionKey, ""))
+
+	ctx, cancel := context.WithTimeout(context.Background(), util.EtcdRequestTimeout*time.Second)
+	defer cancel()
+	rsp, err := k.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(k.prefix+etcdVersionKey), "=", k.version)).
+		Then(ops...).
+		Commit()
+	if err != nil || !rsp.Succeeded {
+		return false, err
+	}
+	k.revision, k.version = rsp.Header.Revision, rsp.Header.Revision
+
+	return true, nil
+}
+
+// reload replace the local copy with the keys in etcd.
+func (k *etcdBackend) reload() error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	return k.resync()
+}
+
+// resync replace the local copy with the keys in etcd, the lock must be held.
+func (k *etcdBackend) resync() error {
+	ctx, cancel := context.WithTimeout(context.Background(), util.EtcdRequestTimeout*time.Second)
+	defer cancel()
+	rsp, err := k.client.Get(ctx, k.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return fmt.Errorf("reading from etcd failed, %s", err.Error())
+	}
+
+	root := newMemNode()
+	var version int64
+	for _, kv := range rsp.Kvs {
+		flat := strings.TrimPrefix(string(kv.Key), k.prefix)
+		if flat == etcdVersionKey {
+			version = kv.ModRevision
+			continue
+		}
+		applyFlatKey(root, flat, kv.Value, false)
+	}
+	k.root, k.revision, k.version = root, rsp.Header.Revision, version
+
+	return nil
+}
+
+// start watch the changes of the other servers in the background.
+func (k *etcdBackend) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	k.cancel, k.done = cancel, make(chan struct{})
+	go k.watch(ctx)
+}
+
+func (k *etcdBackend) watch(ctx context.Context) {
+	defer close(k.done)
+
+	for {
+		k.mutex.RLock()
+		revision := k.revision
+		k.mutex.RUnlock()
+
+		watchCh := k.client.Watch(clientv3.WithRequireLeader(ctx), k.prefix, clientv3.WithPrefix(),
+			clientv3.WithRev(revision+1))
+		for rsp := range watchCh {
+			if err := rsp.Err(); err != nil {
+				log.Errorf("Failed to watch the changes in etcd. %s", err.Error())
+				break
+			}
+			k.apply(rsp.Events)
+		}
+
+		// Watch is broken, i.e. the revision is compacted or the leader is lost, resync the local copy
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(util.EtcdRetryInterval * time.Second):
+		}
+		if err := k.reload(); err != nil {
+			log.Errorf("Failed to resync with etcd. %s", err.Error())
+			continue
+		}
+		k.onChange()
+	}
+}
+
+// apply apply the changes in etcd newer than the local copy, the own commits are already applied.
+func (k *etcdBackend) apply(events []*clientv3.Event) {
+	k.mutex.Lock()
+	revision := k.revision
+	applied := false
+	for _, ev := range events {
+		if ev.Kv.ModRevision <= revision {
+			continue
+		}
+		deleted := ev.Type == clientv3.EventTypeDelete
+		flat := strings.TrimPrefix(string(ev.Kv.Key), k.prefix)
+		if flat == etcdVersionKey {
+			k.version = ev.Kv.ModRevision
+			if deleted {
+				k.version = 0
+			}
+		} else {
+			applyFlatKey(k.root, flat, ev.Kv.Value, deleted)
+		}
+		if ev.Kv.ModRevision > k.revision {
+			k.revision = ev.Kv.ModRevision
+		}
+		applied = true
+	}
+	k.mutex.Unlock()
+
+	if applied {
+		k.onChange()
+	}
+}
+
+// applyFlatKey put or delete the flat key in the buckets, the bucket itself for the empty key.
+func applyFlatKey(root *memNode, flat string, value []byte, deleted bool) {
+	path, key, err := splitFlatKey(flat)
+	if err != nil || len(path) == 0 {
+		log.Errorf("Invalid data store key(%s) in etcd.", flat)
+		return
+	}
+
+	switch {
+	case len(key) == 0 && deleted:
+		if parent := root.find(path[:len(path)-1]); parent != nil {
+			parent.deleteBucket(path[len(path)-1])
+		}
+	case len(key) == 0:
+		seq, _ := strconv.ParseUint(string(value), 10, 64)
+		root.ensure(path).seq = seq
+	case deleted:
+		if bucket := root.find(path); bucket != nil {
+			bucket.deleteValue(key)
+		}
+	default:
+		root.ensure(path).setValue(key, value)
+	}
+}
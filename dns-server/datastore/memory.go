@@ -0,0 +1,475 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package datastore
+package datastore
+
+import (
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"dns-server/health"
+)
+
+var (
+	errKeyRequired       = errors.New("key required")
+	errIncompatibleValue = errors.New("incompatible value")
+)
+
+// MemoryStore data store held in memory only, for the tests and the ephemeral deployments. All the records are lost
+// on close.
+type MemoryStore struct {
+	LoadBalance bool            // shuffle the answers of the records without weights
+	Health      *health.Monitor // health of the addresses with a health check, all healthy if nil
+	*bucketStore
+}
+
+func (m *MemoryStore) Open() error {
+	m.bucketStore = &bucketStore{backend: &memBackend{root: newMemNode()}, loadBalance: newSwitchFlag(m.LoadBalance),
+		health: m.Health}
+	if err := m.bucketStore.Open(); err != nil {
+		return err
+	}
+
+	log.Debugf("Initialize in-memory data store success.")
+
+	return nil
+}
+
+func (m *MemoryStore) Close() error {
+	if m.bucketStore != nil {
+		if err := m.bucketStore.Close(); err != nil {
+			return err
+		}
+	}
+	log.Debugf("Closed in-memory data store as part of shutdown service.")
+
+	return nil
+}
+
+// memBackend in-memory key value backend, a writer blocks the readers while updating the buckets in place.
+type memBackend struct {
+	mutex sync.RWMutex
+	root  *memNode
+}
+
+func (k *memBackend) View(fn func(tx kvTx) error) error {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	return fn(&memTx{root: k.root})
+}
+
+func (k *memBackend) Update(fn func(tx kvTx) error) error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	return (&memTx{root: k.root, writable: true}).run(fn)
+}
+
+func (k *memBackend) Close() error {
+	return nil
+}
+
+// memNode in-memory bucket. Values and nested buckets are changed through the set and delete methods, keeping the
+// names of both in byte order for the cursors.
+type memNode struct {
+	values  map[string][]byte
+	buckets map[string]*memNode
+	keys    []string // keys and nested bucket names in byte order
+	seq     uint64
+}
+
+func newMemNode() *memNode {
+	return &memNode{values: make(map[string][]byte), buckets: make(map[string]*memNode)}
+}
+
+// find get the nested bucket at the path, nil if not exists.
+func (n *memNode) find(path []string) *memNode {
+	for _, name := range path {
+		if n = n.buckets[name]; n == nil {
+			return nil
+		}
+	}
+
+	return n
+}
+
+// ensure get the nested bucket at the path, creating the missing ones.
+func (n *memNode) ensure(path []string) *memNode {
+	for _, name := range path {
+		child := n.buckets[name]
+		if child == nil {
+			child = newMemNode()
+			n.setBucket(name, child)
+		}
+		n = child
+	}
+
+	return n
+}
+
+// lookup get the value of the flat key, the sequence of the bucket for the bucket itself.
+func (n *memNode) lookup(flat string) ([]byte, bool) {
+	path, key, err := splitFlatKey(flat)
+	if err != nil {
+		return nil, false
+	}
+	if len(key) == 0 {
+		if bucket := n.find(path); bucket != nil && len(path) != 0 {
+			return []byte(strconv.FormatUint(bucket.seq, 10)), true
+		}
+		return nil, false
+	}
+	if bucket := n.find(path); bucket != nil {
+		value, ok := bucket.values[key]
+		return value, ok
+	}
+
+	return nil, false
+}
+
+// flatKeys get the flat keys of the bucket at the path along with all its keys and nested buckets.
+func (n *memNode) flatKeys(path []string) []string {
+	keys := []string{flatKey(path, "")}
+	for key := range n.values {
+		keys = append(keys, flatKey(path, key))
+	}
+	for name, child := range n.buckets {
+		keys = append(keys, child.flatKeys(append(append([]string{}, path...), name))...)
+	}
+
+	return keys
+}
+
+func (n *memNode) setValue(key string, value []byte) {
+	n.values[key] = value
+	n.insertKey(key)
+}
+
+func (n *memNode) deleteValue(key string) {
+	if _, ok := n.values[key]; ok {
+		delete(n.values, key)
+		n.removeKey(key)
+	}
+}
+
+func (n *memNode) setBucket(name string, child *memNode) {
+	n.buckets[name] = child
+	n.insertKey(name)
+}
+
+func (n *memNode) deleteBucket(name string) {
+	if _, ok := n.buckets[name]; ok {
+		delete(n.buckets, name)
+		n.removeKey(name)
+	}
+}
+
+// insertKey add the key to the sorted keys if not there yet.
+func (n *memNode) insertKey(key string) {
+	i := sort.SearchStrings(n.keys, key)
+	if i < len(n.keys) && n.keys[i] == key {
+		return
+	}
+	n.keys = append(n.keys, "")
+	copy(n.keys[i+1:], n.keys[i:])
+	n.keys[i] = key
+}
+
+// removeKey remove the key from the sorted keys.
+func (n *memNode) removeKey(key string) {
+	i := sort.SearchStrings(n.keys, key)
+	if i < len(n.keys) && n.keys[i] == key {
+		n.keys = append(n.keys[:i], n.keys[i+1:]...)
+	}
+}
+
+// flatKey flatten the key of the nested buckets to the escaped bucket names and key joined by "/", empty key for the
+// bucket itself.
+func flatKey(path []string, key string) string {
+	var sb strings.Builder
+	for _, name := range path {
+		sb.WriteString(url.PathEscape(name))
+		sb.WriteString("/")
+	}
+	sb.WriteString(url.PathEscape(key))
+
+	return sb.String()
+}
+
+// splitFlatKey get the bucket path and the key of the flat key.
+func splitFlatKey(flat string) ([]string, string, error) {
+	segments := strings.Split(flat, "/")
+	for i := range segments {
+		segment, err := url.PathUnescape(segments[i])
+		if err != nil {
+			return nil, "", err
+		}
+		segments[i] = segment
+	}
+
+	return segments[:len(segments)-1], segments[len(segments)-1], nil
+}
+
+// memTx transaction over the in-memory buckets. Changes are applied in place and undone on rollback, the flat keys
+// changed are collected when changed is not nil.
+type memTx struct {
+	root     *memNode
+	writable bool
+	undo     []func()
+	changed  map[string]bool
+}
+
+// run run the function in the transaction, rolling back the changes on error or panic.
+func (tx *memTx) run(fn func(tx kvTx) error) error {
+	committed := false
+	defer func() {
+		if !committed {
+			tx.rollback()
+		}
+	}()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	committed = true
+
+	return nil
+}
+
+func (tx *memTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+	tx.undo = nil
+}
+
+func (tx *memTx) change(undo func(), flatKeys ...string) {
+	tx.undo = append(tx.undo, undo)
+	if tx.changed != nil {
+		for _, flat := range flatKeys {
+			tx.changed[flat] = true
+		}
+	}
+}
+
+func (tx *memTx) Bucket(name []byte) kvBucket {
+	return (&memBucket{tx: tx, node: tx.root}).Bucket(name)
+}
+
+func (tx *memTx) CreateBucketIfNotExists(name []byte) (kvBucket, error) {
+	return (&memBucket{tx: tx, node: tx.root}).CreateBucketIfNotExists(name)
+}
+
+// memBucket bucket at the path within the transaction.
+type memBucket struct {
+	tx   *memTx
+	node *memNode
+	path []string
+}
+
+func (b *memBucket) childPath(name string) []string {
+	return append(append(make([]string, 0, len(b.path)+1), b.path...), name)
+}
+
+func (b *memBucket) Get(key []byte) []byte {
+	return b.node.values[string(key)]
+}
+
+func (b *memBucket) Put(key []byte, value []byte) error {
+	if !b.tx.writable {
+		return errTxNotWritable
+	}
+	if len(key) == 0 {
+		return errKeyRequired
+	}
+	k := string(key)
+	if _, ok := b.node.buckets[k]; ok {
+		return errIncompatibleValue
+	}
+	old, existed := b.node.values[k]
+	b.node.setValue(k, append(make([]byte, 0, len(value)), value...))
+	b.tx.change(func() {
+		if existed {
+			b.node.setValue(k, old)
+		} else {
+			b.node.deleteValue(k)
+		}
+	}, flatKey(b.path, k))
+
+	return nil
+}
+
+func (b *memBucket) Delete(key []byte) error {
+	if !b.tx.writable {
+		return errTxNotWritable
+	}
+	k := string(key)
+	if _, ok := b.node.buckets[k]; ok {
+		return errIncompatibleValue
+	}
+	old, existed := b.node.values[k]
+	if !existed {
+		return nil
+	}
+	b.node.deleteValue(k)
+	b.tx.change(func() {
+		b.node.setValue(k, old)
+	}, flatKey(b.path, k))
+
+	return nil
+}
+
+func (b *memBucket) Bucket(name []byte) kvBucket {
+	child := b.node.buckets[string(name)]
+	if child == nil {
+		return nil
+	}
+
+	return &memBucket{tx: b.tx, node: child, path: b.childPath(string(name))}
+}
+
+func (b *memBucket) CreateBucket(name []byte) (kvBucket, error) {
+	if !b.tx.writable {
+		return nil, errTxNotWritable
+	}
+	if len(name) == 0 {
+		return nil, errKeyRequired
+	}
+	k := string(name)
+	if _, ok := b.node.buckets[k]; ok {
+		return nil, errBucketExists
+	}
+	if _, ok := b.node.values[k]; ok {
+		return nil, errIncompatibleValue
+	}
+	child := newMemNode()
+	b.node.setBucket(k, child)
+	path := b.childPath(k)
+	b.tx.change(func() {
+		b.node.deleteBucket(k)
+	}, flatKey(path, ""))
+
+	return &memBucket{tx: b.tx, node: child, path: path}, nil
+}
+
+func (b *memBucket) CreateBucketIfNotExists(name []byte) (kvBucket, error) {
+	if bucket := b.Bucket(name); bucket != nil {
+		return bucket, nil
+	}
+
+	return b.CreateBucket(name)
+}
+
+func (b *memBucket) DeleteBucket(name []byte) error {
+	if !b.tx.writable {
+		return errTxNotWritable
+	}
+	k := string(name)
+	child := b.node.buckets[k]
+	if child == nil {
+		return errBucketNotFound
+	}
+	b.node.deleteBucket(k)
+	b.tx.change(func() {
+		b.node.setBucket(k, child)
+	}, child.flatKeys(b.childPath(k))...)
+
+	return nil
+}
+
+func (b *memBucket) ForEach(fn func(k, v []byte) error) error {
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *memBucket) Cursor() kvCursor {
+	return &memCursor{bucket: b}
+}
+
+func (b *memBucket) NextSequence() (uint64, error) {
+	if !b.tx.writable {
+		return 0, errTxNotWritable
+	}
+	b.node.seq++
+	b.tx.change(func() {
+		b.node.seq--
+	}, flatKey(b.path, ""))
+
+	return b.node.seq, nil
+}
+
+// memCursor cursor over the keys of the bucket, positioned by the current key so that the keys changed while iterating
+// are seen as in bolt db.
+type memCursor struct {
+	bucket *memBucket
+	key    string
+	valid  bool
+}
+
+func (c *memCursor) First() ([]byte, []byte) {
+	return c.at(0)
+}
+
+func (c *memCursor) Seek(seek []byte) ([]byte, []byte) {
+	return c.at(sort.SearchStrings(c.bucket.node.keys, string(seek)))
+}
+
+func (c *memCursor) Next() ([]byte, []byte) {
+	if !c.valid {
+		return nil, nil
+	}
+	keys := c.bucket.node.keys
+
+	return c.at(sort.Search(len(keys), func(i int) bool {
+		return keys[i] > c.key
+	}))
+}
+
+func (c *memCursor) Delete() error {
+	if !c.valid {
+		return nil
+	}
+
+	return c.bucket.Delete([]byte(c.key))
+}
+
+// at position the cursor at the i-th key of the bucket.
+func (c *memCursor) at(i int) ([]byte, []byte) {
+	keys := c.bucket.node.keys
+	if i >= len(keys) {
+		c.valid = false
+		return nil, nil
+	}
+	c.key, c.valid = keys[i], true
+	if _, ok := c.bucket.node.buckets[c.key]; ok {
+		return []byte(c.key), nil
+	}
+
+	return []byte(c.key), c.bucket.node.values[c.key]
+}
@@ -0,0 +1,335 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// ZoneOptionConfig Zone option constant.
+	ZoneOptionConfig = "zoneoption"
+	// reverseZoneIPv4 reverse zone of the IPv4 PTR records, used when no more specific local reverse zone exists.
+	reverseZoneIPv4 = "in-addr.arpa."
+	// reverseZoneIPv6 reverse zone of the IPv6 PTR records, used when no more specific local reverse zone exists.
+	reverseZoneIPv6 = "ip6.arpa."
+)
+
+// GetZoneOptions get the options of the zone.
+func (b *bucketStore) GetZoneOptions(zone string) (*ZoneOptions, error) {
+	var options *ZoneOptions
+	err := b.backend.View(func(tx kvTx) error {
+		zoneCfgBkt, err := b.zoneConfigBucket(tx)
+		if err != nil {
+			return err
+		}
+		if zoneCfgBkt.Bucket([]byte(zone)) == nil {
+			return fmt.Errorf("zone(%s) not found", zone)
+		}
+		options = b.getZoneOptions(tx, zone)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
+// SetZoneOptions set the options of the zone. Enabling the automatic PTR records generates them for the existing
+// A/AAAA records of the zone, disabling keeps the generated ones as is.
+func (b *bucketStore) SetZoneOptions(zone string, options *ZoneOptions) error {
+	optionBytes, err := json.Marshal(options)
+	if err != nil {
+		return fmt.Errorf("data store could not marshal zone options json")
+	}
+
+	return b.backend.Update(func(tx kvTx) error {
+		zoneCfgBkt, err := b.zoneConfigBucket(tx)
+		if err != nil {
+			return err
+		}
+		zoneBkt := zoneCfgBkt.Bucket([]byte(zone))
+		if zoneBkt == nil {
+			return fmt.Errorf("zone(%s) not found", zone)
+		}
+		enabled := options.AutoPTR && !b.getZoneOptions(tx, zone).AutoPTR
+
+		optionBkt, err := b.zoneOptionBucket(tx, true)
+		if err != nil {
+			return err
+		}
+		if err = optionBkt.Put([]byte(zone), optionBytes); err != nil {
+			return fmt.Errorf("saving zone options to data store failed")
+		}
+		if !enabled {
+			return nil
+		}
+		for _, rr := range b.listAddressRecords(zoneBkt) {
+			if err = b.updateReversePointers(tx, rr.Name, nil, rr.RData, rr.TTL); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// zoneOptionBucket get the bucket holding the options of the zones of the view, nil if not exists and not created.
+func (b *bucketStore) zoneOptionBucket(tx kvTx, create bool) (kvBucket, error) {
+	if len(b.view) == 0 {
+		return tx.Bucket([]byte(ZoneOptionConfig)), nil
+	}
+	viewBkt := tx.Bucket([]byte(ViewConfig)).Bucket([]byte(b.view))
+	if viewBkt == nil {
+		return nil, fmt.Errorf("view(%s) not found", b.view)
+	}
+	if !create {
+		return viewBkt.Bucket([]byte(ZoneOptionConfig)), nil
+	}
+	optionBkt, err := viewBkt.CreateBucketIfNotExists([]byte(ZoneOptionConfig))
+	if err != nil {
+		return nil, fmt.Errorf("zone options of view(%s) retrieval failed", b.view)
+	}
+
+	return optionBkt, nil
+}
+
+// getZoneOptions get the options of the zone within the transaction, defaults if not set.
+func (b *bucketStore) getZoneOptions(tx kvTx, zone string) *ZoneOptions {
+	options := &ZoneOptions{}
+	optionBkt, err := b.zoneOptionBucket(tx, false)
+	if err != nil || optionBkt == nil {
+		return options
+	}
+	if value := optionBkt.Get([]byte(zone)); value != nil {
+		_ = json.Unmarshal(value, options)
+	}
+
+	return options
+}
+
+// deleteZoneOptions drop the options of the zone, unlinking the PTR records generated for its A/AAAA records.
+func (b *bucketStore) deleteZoneOptions(tx kvTx, zone string, zoneBkt kvBucket) error {
+	if err := b.unlinkZoneReversePointers(tx, zone, zoneBkt); err != nil {
+		return err
+	}
+	optionBkt, err := b.zoneOptionBucket(tx, false)
+	if err != nil || optionBkt == nil {
+		return nil
+	}
+	if err = optionBkt.Delete([]byte(zone)); err != nil {
+		return fmt.Errorf("deleting zone(%s) options failed", zone)
+	}
+
+	return nil
+}
+
+// unlinkZoneReversePointers remove the PTR records generated for the A/AAAA records of the zone, if enabled.
+func (b *bucketStore) unlinkZoneReversePointers(tx kvTx, zone string, zoneBkt kvBucket) error {
+	if zoneBkt == nil || !b.getZoneOptions(tx, zone).AutoPTR {
+		return nil
+	}
+	for _, rr := range b.listAddressRecords(zoneBkt) {
+		if err := b.updateReversePointers(tx, rr.Name, rr.RData, nil, rr.TTL); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// listAddressRecords get the A/AAAA records of the zone bucket.
+func (b *bucketStore) listAddressRecords(zoneBkt kvBucket) []ResourceRecord {
+	return append(b.listZoneBucket(zoneBkt, "", dns.TypeA), b.listZoneBucket(zoneBkt, "", dns.TypeAAAA)...)
+}
+
+// updateReversePointers maintain the PTR records pointing to the host as its addresses change from the old to the
+// new ones. PTR records pointing to other hosts for the same address are kept.
+func (b *bucketStore) updateReversePointers(tx kvTx, host string, oldAddrs []string, newAddrs []string,
+	ttl uint32) error {
+	for _, addr := range oldAddrs {
+		if !containsAddress(newAddrs, addr) {
+			if err := b.unlinkReversePointer(tx, addr, host); err != nil {
+				return err
+			}
+		}
+	}
+	for _, addr := range newAddrs {
+		if err := b.linkReversePointer(tx, addr, host, ttl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// linkReversePointer add the host to the PTR record of the address, creating the record in the most specific local
+// reverse zone if not exists.
+func (b *bucketStore) linkReversePointer(tx kvTx, addr string, host string, ttl uint32) error {
+	reverse, err := dns.ReverseAddr(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address(%s) for the reverse entry", addr)
+	}
+	dnsCfgKeyBytes, err := newRecordKey(reverse, "PTR")
+	if err != nil {
+		return err
+	}
+	zone, zoneBkt := b.findRecordZoneBucket(tx, dnsCfgKeyBytes)
+	if zoneBkt == nil {
+		if zone, zoneBkt, err = b.reverseZoneBucket(tx, reverse); err != nil {
+			return err
+		}
+		if err = b.checkCNAMEConflict(zoneBkt, reverse, dns.TypePTR); err != nil {
+			return err
+		}
+	}
+
+	dnsCfgValue := &DNSConfigRRValue{RRClass: dns.ClassINET, TTL: ttl}
+	if value := zoneBkt.Get(dnsCfgKeyBytes); value != nil {
+		if err = json.Unmarshal(value, dnsCfgValue); err != nil {
+			return fmt.Errorf("parsing failed on data retrieval")
+		}
+	}
+	for _, target := range dnsCfgValue.PointTo {
+		if strings.EqualFold(target, host) {
+			return nil
+		}
+	}
+	dnsCfgValue.PointTo = append(dnsCfgValue.PointTo, host)
+	if len(dnsCfgValue.Weights) != 0 {
+		dnsCfgValue.Weights = append(dnsCfgValue.Weights, 1)
+	}
+
+	return b.putReversePointer(tx, zone, zoneBkt, dnsCfgKeyBytes, dnsCfgValue)
+}
+
+// unlinkReversePointer remove the host from the PTR record of the address, deleting the record when no other host
+// is left.
+func (b *bucketStore) unlinkReversePointer(tx kvTx, addr string, host string) error {
+	reverse, err := dns.ReverseAddr(addr)
+	if err != nil {
+		return nil
+	}
+	dnsCfgKeyBytes, err := newRecordKey(reverse, "PTR")
+	if err != nil {
+		return err
+	}
+	zone, zoneBkt := b.findRecordZoneBucket(tx, dnsCfgKeyBytes)
+	if zoneBkt == nil {
+		return nil
+	}
+	dnsCfgValue := &DNSConfigRRValue{}
+	if err = json.Unmarshal(zoneBkt.Get(dnsCfgKeyBytes), dnsCfgValue); err != nil {
+		return fmt.Errorf("parsing failed on data retrieval")
+	}
+
+	targets := make([]string, 0, len(dnsCfgValue.PointTo))
+	weights := make([]uint32, 0, len(dnsCfgValue.Weights))
+	for i, target := range dnsCfgValue.PointTo {
+		if strings.EqualFold(target, host) {
+			continue
+		}
+		targets = append(targets, target)
+		if len(dnsCfgValue.Weights) != 0 {
+			weights = append(weights, dnsCfgValue.Weights[i])
+		}
+	}
+	if len(targets) == len(dnsCfgValue.PointTo) {
+		return nil
+	}
+	if len(targets) == 0 {
+		oldRRs := b.getRRSet(zoneBkt, dnsCfgKeyBytes)
+		if err = zoneBkt.Delete(dnsCfgKeyBytes); err != nil {
+			return fmt.Errorf("failed to delete dns entry")
+		}
+		if err = b.indexName(zoneBkt, reverse); err != nil {
+			return err
+		}
+		return b.recordZoneChange(tx, zoneBkt, zone, oldRRs, nil)
+	}
+	dnsCfgValue.PointTo = targets
+	if len(dnsCfgValue.Weights) != 0 {
+		dnsCfgValue.Weights = weights
+	}
+
+	return b.putReversePointer(tx, zone, zoneBkt, dnsCfgKeyBytes, dnsCfgValue)
+}
+
+func (b *bucketStore) putReversePointer(tx kvTx, zone string, zoneBkt kvBucket, dnsCfgKeyBytes []byte,
+	dnsCfgValue *DNSConfigRRValue) error {
+	valueBytes, err := json.Marshal(dnsCfgValue)
+	if err != nil {
+		return fmt.Errorf("data store could not marshal dns config json")
+	}
+	oldRRs := b.getRRSet(zoneBkt, dnsCfgKeyBytes)
+	if err = zoneBkt.Put(dnsCfgKeyBytes, valueBytes); err != nil {
+		return fmt.Errorf("saving dns entry to data store failed")
+	}
+	dnsCfgKey := &DNSConfigRRKey{}
+	if err = json.Unmarshal(dnsCfgKeyBytes, dnsCfgKey); err != nil {
+		return fmt.Errorf("parsing failed on data retrieval")
+	}
+	if err = b.indexName(zoneBkt, dnsCfgKey.Host); err != nil {
+		return err
+	}
+
+	return b.recordZoneChange(tx, zoneBkt, zone, oldRRs, b.getRRSet(zoneBkt, dnsCfgKeyBytes))
+}
+
+// reverseZoneBucket get the most specific local reverse zone of the PTR name, in-addr.arpa. or ip6.arpa. zone is
+// created if none exists.
+func (b *bucketStore) reverseZoneBucket(tx kvTx, reverse string) (string, kvBucket, error) {
+	zoneCfgBkt, err := b.zoneConfigBucket(tx)
+	if err != nil {
+		return "", nil, err
+	}
+	reverseZone := reverseZoneIPv4
+	if strings.HasSuffix(reverse, reverseZoneIPv6) {
+		reverseZone = reverseZoneIPv6
+	}
+	for _, zone := range getZoneCandidates(reverse) {
+		if !strings.HasSuffix(zone, reverseZone) {
+			break
+		}
+		if zoneBkt := zoneCfgBkt.Bucket([]byte(zone)); zoneBkt != nil {
+			return zone, zoneBkt, nil
+		}
+	}
+	zoneBkt, err := zoneCfgBkt.CreateBucketIfNotExists([]byte(reverseZone))
+	if err != nil {
+		return "", nil, fmt.Errorf("zone(%s) retrieval failed", reverseZone)
+	}
+
+	return reverseZone, zoneBkt, nil
+}
+
+// containsAddress check the address is in the list, comparing the parsed IPs.
+func containsAddress(addrs []string, addr string) bool {
+	ip := net.ParseIP(addr)
+	for _, a := range addrs {
+		if a == addr || (ip != nil && ip.Equal(net.ParseIP(a))) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package datastore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReversePointers(t *testing.T) {
+	forEachBackend(t, testReversePointers)
+}
+
+func testReversePointers(t *testing.T, newStore newStoreFunc) {
+	defer func() {
+		_ = os.RemoveAll(DBPath)
+	}()
+
+	store := newStore("testdb", nil)
+	assert.Nil(t, store.Open(), "Error in opening the db")
+	defer store.Close()
+
+	lookupPTR := func(addr string) []string {
+		reverse, _ := dns.ReverseAddr(addr)
+		rrs, err := store.GetResourceRecord(&dns.Question{Name: reverse, Qtype: dns.TypePTR, Qclass: dns.ClassINET})
+		if err != nil {
+			return nil
+		}
+		var targets []string
+		for _, rr := range *rrs {
+			targets = append(targets, rr.(*dns.PTR).Ptr)
+		}
+		return targets
+	}
+	setA := func(name string, addrs ...string) {
+		err := store.SetResourceRecord(".", &ResourceRecord{Name: name, Type: "A", Class: "IN", TTL: 30,
+			RData: addrs})
+		assert.Nil(t, err, errorSettingMessage)
+	}
+
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		setA("app1.mec.", "10.1.1.1")
+		assert.Nil(t, lookupPTR("10.1.1.1"))
+		options, err := store.GetZoneOptions(".")
+		assert.Nil(t, err)
+		assert.False(t, options.AutoPTR)
+	})
+
+	t.Run("EnableGeneratesExisting", func(t *testing.T) {
+		assert.Nil(t, store.SetZoneOptions(".", &ZoneOptions{AutoPTR: true}))
+		options, _ := store.GetZoneOptions(".")
+		assert.True(t, options.AutoPTR)
+		assert.Equal(t, []string{"app1.mec."}, lookupPTR("10.1.1.1"))
+		zones, _ := store.ListZones()
+		assert.Contains(t, zones, "in-addr.arpa.")
+	})
+
+	t.Run("MostSpecificReverseZone", func(t *testing.T) {
+		assert.Nil(t, store.CreateZone("2.10.in-addr.arpa."))
+		setA("app2.mec.", "10.2.0.1")
+		records, _ := store.ListResourceRecords(&RecordFilter{Zone: "2.10.in-addr.arpa.", Type: "PTR"})
+		assert.Equal(t, 1, len(records))
+		assert.Equal(t, "1.0.2.10.in-addr.arpa.", records[0].Name)
+		assert.Equal(t, []string{"app2.mec."}, records[0].RData)
+	})
+
+	t.Run("IPv6", func(t *testing.T) {
+		err := store.SetResourceRecord(".", &ResourceRecord{Name: "app6.mec.", Type: "AAAA", Class: "IN",
+			TTL: 30, RData: []string{"2001:db8::1"}})
+		assert.Nil(t, err, errorSettingMessage)
+		assert.Equal(t, []string{"app6.mec."}, lookupPTR("2001:db8::1"))
+		_, err = store.DelResourceRecord(".", "app6.mec.", "AAAA")
+		assert.Nil(t, err)
+		assert.Nil(t, lookupPTR("2001:db8::1"))
+	})
+
+	t.Run("AddressChanged", func(t *testing.T) {
+		setA("app1.mec.", "10.1.1.2")
+		assert.Nil(t, lookupPTR("10.1.1.1"))
+		assert.Equal(t, []string{"app1.mec."}, lookupPTR("10.1.1.2"))
+	})
+
+	t.Run("SharedAddress", func(t *testing.T) {
+		setA("app3.mec.", "10.1.1.2")
+		assert.ElementsMatch(t, []string{"app1.mec.", "app3.mec."}, lookupPTR("10.1.1.2"))
+		_, err := store.DelResourceRecord(".", "app3.mec.", "A")
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"app1.mec."}, lookupPTR("10.1.1.2"))
+	})
+
+	t.Run("FailedChangeRolledBack", func(t *testing.T) {
+		err := store.SetResourceRecord("in-addr.arpa.", &ResourceRecord{Name: "4.1.1.10.in-addr.arpa.",
+			Type: "CNAME", Class: "IN", TTL: 30, RData: []string{"other.mec."}})
+		assert.Nil(t, err, errorSettingMessage)
+		err = store.SetResourceRecord(".", &ResourceRecord{Name: "app4.mec.", Type: "A", Class: "IN", TTL: 30,
+			RData: []string{"10.1.1.4"}})
+		assert.NotNil(t, err)
+		_, err = store.GetResourceRecord(&dns.Question{Name: "app4.mec.", Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.NotNil(t, err, "A record kept on failure")
+	})
+
+	t.Run("DeleteZone", func(t *testing.T) {
+		assert.Nil(t, store.CreateZone("edge.mec."))
+		assert.Nil(t, store.SetZoneOptions("edge.mec.", &ZoneOptions{AutoPTR: true}))
+		err := store.SetResourceRecord("edge.mec.", &ResourceRecord{Name: "app5.edge.mec.", Type: "A", Class: "IN",
+			TTL: 30, RData: []string{"10.1.1.5"}})
+		assert.Nil(t, err, errorSettingMessage)
+		assert.Equal(t, []string{"app5.edge.mec."}, lookupPTR("10.1.1.5"))
+		assert.Nil(t, store.DeleteZone("edge.mec."))
+		assert.Nil(t, lookupPTR("10.1.1.5"))
+
+		// Options go with the zone
+		assert.Nil(t, store.CreateZone("edge.mec."))
+		options, _ := store.GetZoneOptions("edge.mec.")
+		assert.False(t, options.AutoPTR)
+	})
+
+	t.Run("ZoneNotFound", func(t *testing.T) {
+		assert.EqualError(t, store.SetZoneOptions("none.mec.", &ZoneOptions{AutoPTR: true}),
+			"zone(none.mec.) not found")
+		_, err := store.GetZoneOptions("none.mec.")
+		assert.EqualError(t, err, "zone(none.mec.) not found")
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		assert.Nil(t, store.SetZoneOptions(".", &ZoneOptions{}))
+		setA("app1.mec.", "10.1.1.6")
+		assert.Nil(t, lookupPTR("10.1.1.6"))
+		assert.Equal(t, []string{"app1.mec."}, lookupPTR("10.1.1.2"), "generated records are kept")
+	})
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package datastore
+package datastore
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxTXTStringLength max length of a single TXT character-string.
+const maxTXTStringLength = 255
+
+// ValidateRData check the rData is in valid presentation format for the rr type.
+func ValidateRData(rrTypeStr string, rData string) error {
+	rrType, ok := rrTypeMap[rrTypeStr]
+	if !ok {
+		return fmt.Errorf("unsupported rrtype(%s) entry", rrTypeStr)
+	}
+	_, err := newRR(DefaultZone, rrType, dns.ClassINET, 0, rData)
+
+	return err
+}
+
+// DNSRecords generate the dns records from the rData of the record.
+func (rr *ResourceRecord) DNSRecords() ([]dns.RR, error) {
+	rrType, ok := rrTypeMap[rr.Type]
+	if !ok {
+		return nil, fmt.Errorf("unsupported rrtype(%s) entry", rr.Type)
+	}
+	rrClass, ok := rrClassMap[rr.Class]
+	if !ok {
+		return nil, fmt.Errorf("unsupported rrclass(%s) entry", rr.Class)
+	}
+
+	records := make([]dns.RR, 0, len(rr.RData))
+	for _, rData := range rr.RData {
+		record, err := newRR(rr.Name, rrType, rrClass, rr.TTL, rData)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// newRR generates a resource record for the given type from the rData in presentation format, e.g.
+//
+//	A/AAAA: "192.168.1.101"
+//	CNAME/PTR/NS: "gw.example.com."
+//	MX: "10 mail.example.com."
+//	SRV: "10 5 8080 app.example.com."
+//	TXT: "any text"
+//	SOA: "ns1.example.com. admin.example.com. 2021010101 3600 600 86400 30"
+func newRR(name string, rrType uint16, rrClass uint16, ttl uint32, rData string) (dns.RR, error) {
+	hdr := dns.RR_Header{Name: name, Rrtype: rrType, Class: rrClass, Ttl: ttl}
+
+	switch rrType {
+	case dns.TypeA:
+		ip := net.ParseIP(rData)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid rdata(%s) for A record", rData)
+		}
+		return &dns.A{Hdr: hdr, A: ip}, nil
+	case dns.TypeAAAA:
+		ip := net.ParseIP(rData)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid rdata(%s) for AAAA record", rData)
+		}
+		return &dns.AAAA{Hdr: hdr, AAAA: ip}, nil
+	case dns.TypeTXT:
+		if len(rData) == 0 {
+			return nil, fmt.Errorf("empty rdata for TXT record")
+		}
+		return &dns.TXT{Hdr: hdr, Txt: splitTXT(rData)}, nil
+	case dns.TypeCNAME, dns.TypePTR, dns.TypeNS, dns.TypeMX, dns.TypeSRV, dns.TypeSOA:
+		if strings.ContainsAny(rData, "\n;()\"") {
+			return nil, fmt.Errorf("invalid rdata(%s) for %s record", rData, dns.TypeToString[rrType])
+		}
+		rr, err := dns.NewRR(fmt.Sprintf("%s %d %s %s %s", DefaultZone, ttl, dns.ClassToString[rrClass],
+			dns.TypeToString[rrType], rData))
+		if err != nil || rr == nil {
+			return nil, fmt.Errorf("invalid rdata(%s) for %s record", rData, dns.TypeToString[rrType])
+		}
+		*rr.Header() = hdr
+		return rr, nil
+	default:
+		return nil, fmt.Errorf("unsupported rrtype(%d) entry", rrType)
+	}
+}
+
+// splitTXT split the text into character-strings which fits in the TXT record.
+func splitTXT(text string) []string {
+	var txt []string
+	for len(text) > maxTXTStringLength {
+		txt = append(txt, text[:maxTXTStringLength])
+		text = text[maxTXTStringLength:]
+	}
+
+	return append(txt, text)
+}
+
+// cnameTarget get the target name of a CNAME record.
+func cnameTarget(rr dns.RR) string {
+	if cname, ok := rr.(*dns.CNAME); ok {
+		return cname.Target
+	}
+
+	return ""
+}
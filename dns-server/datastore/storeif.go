@@ -17,7 +17,18 @@
 // Package Data Store
 package datastore
 
-import "github.com/miekg/dns"
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// Data store backends.
+const (
+	StoreBoltDB = "boltdb"
+	StoreMemory = "memory"
+	StoreEtcd   = "etcd"
+)
 
 type ResourceRecord struct {
 	Name  string   `json:"name"`
@@ -25,6 +36,19 @@ type ResourceRecord struct {
 	Class string   `json:"class"`
 	TTL   uint32   `json:"ttl"`
 	RData []string `json:"rData"`
+	// Answer selection, weights of the rData in order, the number of answers and the health check of the addresses
+	Weights     []uint32     `json:"weights,omitempty"`
+	MaxAnswers  uint32       `json:"maxAnswers,omitempty"`
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
+}
+
+// HealthCheck probe of the A/AAAA record addresses, the unhealthy addresses are dropped from the answers.
+type HealthCheck struct {
+	Protocol string `json:"protocol"`           // tcp or http
+	Port     uint16 `json:"port"`               // port probed on each address
+	Path     string `json:"path,omitempty"`     // path of the http GET
+	Interval uint32 `json:"interval,omitempty"` // seconds between the probes, default 10
+	Timeout  uint32 `json:"timeout,omitempty"`  // probe timeout in seconds, default 3
 }
 
 type ZoneEntry struct {
@@ -32,6 +56,79 @@ type ZoneEntry struct {
 	RR   *[]ResourceRecord `json:"rr"`
 }
 
+// ZoneOptions options of a zone.
+type ZoneOptions struct {
+	// AutoPTR maintain the PTR records of the A/AAAA records of the zone in the local in-addr.arpa./ip6.arpa. zones
+	AutoPTR bool `json:"autoPtr"`
+}
+
+// ZoneKey DNSSEC key of a zone.
+type ZoneKey struct {
+	DNSKEY     string `json:"dnskey"`     // DNSKEY record in presentation format
+	PrivateKey string `json:"privateKey"` // private key in the BIND private key format
+	Active     bool   `json:"active"`     // signing with the key, inactive keys are only published
+}
+
+// ZoneResourceRecord resource record along with the zone it belongs to.
+type ZoneResourceRecord struct {
+	Zone string `json:"zone"`
+	ResourceRecord
+}
+
+// RecordFilter filter for listing the records, empty fields match all.
+type RecordFilter struct {
+	Zone string
+	Name string
+	Type string
+}
+
+// View view and the client CIDRs selecting it.
+type View struct {
+	Name  string   `json:"name"`
+	CIDRs []string `json:"cidrs"`
+}
+
+// Batch operations.
+const (
+	BatchOpAdd    = "add"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+)
+
+// Batch operation results.
+const (
+	BatchApplied    = "applied"
+	BatchFailed     = "failed"
+	BatchRolledBack = "rolledBack"
+)
+
+// BatchOperation add/update/delete operation of a batch update, only the name and type of the record are needed for
+// delete.
+type BatchOperation struct {
+	Op   string         `json:"op"`
+	Zone string         `json:"zone"`
+	RR   ResourceRecord `json:"rr"`
+}
+
+// BatchResult result of a batch operation.
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// NotFoundError no record available for the question in the local zones.
+type NotFoundError struct {
+	// Authority SOA of the local zone the question belongs to, nil if none of the local zones are authoritative
+	Authority dns.RR
+	// NameExists name exists with other record types(NODATA), valid only in authoritative zones
+	NameExists bool
+}
+
+func (e *NotFoundError) Error() string {
+	return "could not process/retrieve the query"
+}
+
 type DataStore interface {
 	// Open - Initialize the DB by creating the database
 	Open() error
@@ -39,14 +136,75 @@ type DataStore interface {
 	// Close - Cleanup the db
 	Close() error
 
-	// SetResourceRecord - Add or modify a A type record
+	// SetResourceRecord - Add or modify a A/AAAA/CNAME/SRV/TXT/PTR/MX/NS/SOA type record
 	SetResourceRecord(zone string, rr *ResourceRecord) error
 
-	// GetResourceRecord - Get A type record
+	// GetResourceRecord - Get the records for the question, following CNAME within the local zones. Returns
+	// NotFoundError when no record exists
 	GetResourceRecord(question *dns.Question) (*[]dns.RR, error)
 
-	// DelResourceRecord - Delete A type record
-	DelResourceRecord(zone string, host string, rrtype string) error
+	// DelResourceRecord - Delete a record, returns the zone it was deleted from
+	DelResourceRecord(zone string, host string, rrtype string) (string, error)
 	// IsResourceRecordExists - check the record exists
 	IsResourceRecordExists(zone string, rr *ResourceRecord) bool
+
+	// ListZones - Get all the local zones
+	ListZones() ([]string, error)
+
+	// CreateZone - Create an empty local zone
+	CreateZone(zone string) error
+
+	// DeleteZone - Delete a local zone along with all its records
+	DeleteZone(zone string) error
+
+	// GetZoneOptions - Get the options of a local zone
+	GetZoneOptions(zone string) (*ZoneOptions, error)
+
+	// SetZoneOptions - Set the options of a local zone
+	SetZoneOptions(zone string, options *ZoneOptions) error
+
+	// GetZoneKeys - Get the DNSSEC keys of a local zone of the default view, empty if the zone is not signed
+	GetZoneKeys(zone string) ([]ZoneKey, error)
+
+	// SetZoneKeys - Set the DNSSEC keys of a local zone of the default view, empty keys disable the signing
+	SetZoneKeys(zone string, keys []ZoneKey) error
+
+	// GetZoneNames - Get the owner names within the zone along with their record types, from all the local zones
+	// looked up for the view
+	GetZoneNames(zone string) (map[string][]uint16, error)
+
+	// ImportZone - Replace all the records of a zone atomically, creating the zone if not exists
+	ImportZone(zone string, records []ResourceRecord) error
+
+	// ApplyBatch - Apply the add/update/delete operations atomically, returns the result of each operation
+	ApplyBatch(ops []BatchOperation) ([]BatchResult, error)
+
+	// ListResourceRecords - Get the records matching the filter, ordered by zone and name
+	ListResourceRecords(filter *RecordFilter) ([]ZoneResourceRecord, error)
+
+	// WithView - Get the data store scoped to the view, records of the view override the default view on lookup.
+	// Empty view is the default view
+	WithView(view string) DataStore
+
+	// SelectView - Get the view of the client, empty for the default view
+	SelectView(ip net.IP) string
+
+	// SetView - Create or update a view with the client CIDRs selecting it
+	SetView(view *View) error
+
+	// DeleteView - Delete a view along with all its zones
+	DeleteView(view string) error
+
+	// ListViews - Get all the views
+	ListViews() ([]View, error)
+
+	// GetZoneTransfer - Get all the records of an authoritative zone for AXFR, starting and ending with the SOA
+	GetZoneTransfer(zone string) ([]dns.RR, error)
+
+	// GetZoneIncrementalTransfer - Get the changes of an authoritative zone since the serial for IXFR, nil when the
+	// changes are not available
+	GetZoneIncrementalTransfer(zone string, serial uint32) ([]dns.RR, error)
+
+	// SetLoadBalance - Switch the shuffling of the answers of the records without weights, for all the views
+	SetLoadBalance(enabled bool)
 }
@@ -0,0 +1,361 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package datastore
+package datastore
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	"dns-server/util"
+)
+
+// JournalConfig Journal constant.
+const JournalConfig = "journal"
+
+// journalEntry change of the zone from one serial to the next, kept for the incremental zone transfer.
+type journalEntry struct {
+	OldSOA  string   `json:"oldSOA"`
+	NewSOA  string   `json:"newSOA"`
+	Deleted []string `json:"deleted"`
+	Added   []string `json:"added"`
+}
+
+// GetZoneTransfer get the records of the zone for AXFR, starting and ending with the SOA.
+func (b *bucketStore) GetZoneTransfer(zone string) ([]dns.RR, error) {
+	var records []dns.RR
+	err := b.backend.View(func(tx kvTx) error {
+		zoneBkt, soa, err := b.getAuthoritativeZone(tx, zone)
+		if err != nil {
+			return err
+		}
+
+		records = append(records, soa)
+		err = zoneBkt.ForEach(func(k, v []byte) error {
+			dnsCfgKey := &DNSConfigRRKey{}
+			if v == nil || json.Unmarshal(k, dnsCfgKey) != nil || dnsCfgKey.RRType == dns.TypeSOA {
+				return nil
+			}
+			records = append(records, b.getRRSet(zoneBkt, k)...)
+			return nil
+		})
+		records = append(records, soa)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// GetZoneIncrementalTransfer get the changes of the zone since the serial for IXFR(RFC 1995), only the current SOA if
+// the serial is up to date. Returns nil without error when the changes are not available in the journal.
+func (b *bucketStore) GetZoneIncrementalTransfer(zone string, serial uint32) ([]dns.RR, error) {
+	var records []dns.RR
+	err := b.backend.View(func(tx kvTx) error {
+		_, soa, err := b.getAuthoritativeZone(tx, zone)
+		if err != nil {
+			return err
+		}
+		if !SerialGreater(soa.Serial, serial) {
+			records = []dns.RR{soa}
+			return nil
+		}
+
+		journalBkt := tx.Bucket([]byte(JournalConfig)).Bucket([]byte(zone))
+		if journalBkt == nil {
+			return nil
+		}
+		records = b.getJournalChanges(journalBkt, soa, serial)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// getJournalChanges get the changes from the serial to the current SOA in IXFR format, nil if the journal does not
+// have all the changes.
+func (b *bucketStore) getJournalChanges(journalBkt kvBucket, soa *dns.SOA, serial uint32) []dns.RR {
+	records := []dns.RR{soa}
+	current := serial
+	found := false
+	c := journalBkt.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		entry := &journalEntry{}
+		if json.Unmarshal(v, entry) != nil {
+			return nil
+		}
+		oldSOA, newSOA := parseSOA(entry.OldSOA), parseSOA(entry.NewSOA)
+		if oldSOA == nil || newSOA == nil {
+			return nil
+		}
+		if !found && oldSOA.Serial != serial {
+			continue
+		}
+		// Changes must be continuous from the requested serial
+		if oldSOA.Serial != current {
+			return nil
+		}
+		found = true
+		records = append(records, oldSOA)
+		records = append(records, parseRRs(entry.Deleted)...)
+		records = append(records, newSOA)
+		records = append(records, parseRRs(entry.Added)...)
+		current = newSOA.Serial
+	}
+	if !found || current != soa.Serial {
+		return nil
+	}
+
+	return append(records, soa)
+}
+
+// getAuthoritativeZone get the zone bucket along with its SOA, error if the zone is not authoritative.
+func (b *bucketStore) getAuthoritativeZone(tx kvTx, zone string) (kvBucket, *dns.SOA, error) {
+	zoneCfgBkt, err := b.zoneConfigBucket(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	zoneBkt := zoneCfgBkt.Bucket([]byte(zone))
+	if zoneBkt == nil {
+		return nil, nil, fmt.Errorf("zone(%s) not found", zone)
+	}
+	soa := b.getZoneSOA(zoneBkt, zone)
+	if soa == nil {
+		return nil, nil, fmt.Errorf("zone(%s) is not authoritative", zone)
+	}
+
+	return zoneBkt, soa, nil
+}
+
+// getZoneSOA get the SOA of the zone, nil if not available.
+func (b *bucketStore) getZoneSOA(zoneBkt kvBucket, zone string) *dns.SOA {
+	dnsCfgKeyBytes, err := newRecordKey(zone, "SOA")
+	if err != nil {
+		return nil
+	}
+
+	return findSOA(b.getRRSet(zoneBkt, dnsCfgKeyBytes))
+}
+
+// getRRSet get the records stored under the key.
+func (b *bucketStore) getRRSet(zoneBkt kvBucket, dnsCfgKeyBytes []byte) []dns.RR {
+	dnsCfgKey := &DNSConfigRRKey{}
+	if err := json.Unmarshal(dnsCfgKeyBytes, dnsCfgKey); err != nil {
+		return nil
+	}
+	dnsCfgBytes := zoneBkt.Get(dnsCfgKeyBytes)
+	if dnsCfgBytes == nil {
+		return nil
+	}
+	dnsCfg := &DNSConfigRRValue{}
+	if err := json.Unmarshal(dnsCfgBytes, dnsCfg); err != nil {
+		return nil
+	}
+
+	return b.getRRFromZoneBucket(zoneBkt, dnsCfgKey.Host, dnsCfgKey.Host, dnsCfgKey.RRType, dnsCfg.RRClass)
+}
+
+// recordZoneChange bump the serial of the zone and journal the change for the incremental zone transfer. Only the
+// authoritative zones of the default view are tracked.
+func (b *bucketStore) recordZoneChange(tx kvTx, zoneBkt kvBucket, zone string, oldRRs []dns.RR,
+	newRRs []dns.RR) error {
+	deleted, added := diffRRs(oldRRs, newRRs), diffRRs(newRRs, oldRRs)
+	if len(b.view) != 0 || (len(deleted) == 0 && len(added) == 0) {
+		return nil
+	}
+
+	oldSOA, newSOA := findSOA(deleted), findSOA(added)
+	switch {
+	case oldSOA == nil && newSOA == nil:
+		oldSOA = b.getZoneSOA(zoneBkt, zone)
+		if oldSOA == nil {
+			return nil
+		}
+		newSOA = dns.Copy(oldSOA).(*dns.SOA)
+	case oldSOA == nil || newSOA == nil:
+		// Zone became authoritative or not anymore, history is not applicable
+		return b.clearJournal(tx, zone)
+	default:
+		deleted, added = withoutSOA(deleted), withoutSOA(added)
+	}
+
+	if !SerialGreater(newSOA.Serial, oldSOA.Serial) {
+		newSOA.Serial = oldSOA.Serial + 1
+		if err := b.putSOA(zoneBkt, zone, newSOA); err != nil {
+			return err
+		}
+	}
+
+	return b.appendJournal(tx, zone, &journalEntry{OldSOA: oldSOA.String(), NewSOA: newSOA.String(),
+		Deleted: rrStrings(deleted), Added: rrStrings(added)})
+}
+
+// putSOA update the stored SOA of the zone.
+func (b *bucketStore) putSOA(zoneBkt kvBucket, zone string, soa *dns.SOA) error {
+	dnsCfgKeyBytes, err := newRecordKey(zone, "SOA")
+	if err != nil {
+		return err
+	}
+	dnsCfg := &DNSConfigRRValue{}
+	if err = json.Unmarshal(zoneBkt.Get(dnsCfgKeyBytes), dnsCfg); err != nil {
+		return fmt.Errorf("parsing failed on data retrieval")
+	}
+	dnsCfg.PointTo = []string{RDataString(soa)}
+	dnsCfgBytes, err := json.Marshal(dnsCfg)
+	if err != nil {
+		return fmt.Errorf("data store could not marshal dns config json")
+	}
+	if err = zoneBkt.Put(dnsCfgKeyBytes, dnsCfgBytes); err != nil {
+		return fmt.Errorf("saving dns entry to data store failed")
+	}
+
+	return nil
+}
+
+// appendJournal add the change to the journal of the zone, dropping the oldest beyond the limit.
+func (b *bucketStore) appendJournal(tx kvTx, zone string, entry *journalEntry) error {
+	journalBkt, err := tx.Bucket([]byte(JournalConfig)).CreateBucketIfNotExists([]byte(zone))
+	if err != nil {
+		return fmt.Errorf("journal of zone(%s) retrieval failed", zone)
+	}
+	seq, err := journalBkt.NextSequence()
+	if err != nil {
+		return fmt.Errorf("journal of zone(%s) retrieval failed", zone)
+	}
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("data store could not marshal journal json")
+	}
+	if err = journalBkt.Put(journalKey(seq), entryBytes); err != nil {
+		return fmt.Errorf("saving journal to data store failed")
+	}
+
+	// Keys are in the sequence order
+	c := journalBkt.Cursor()
+	for k, _ := c.First(); k != nil && binary.BigEndian.Uint64(k)+util.MaxJournalEntries <= seq; k, _ = c.Next() {
+		if err = c.Delete(); err != nil {
+			log.Errorf("Failed to trim the journal of zone(%s).", zone)
+			break
+		}
+	}
+
+	return nil
+}
+
+// clearJournal drop the journal of the zone.
+func (b *bucketStore) clearJournal(tx kvTx, zone string) error {
+	if len(b.view) != 0 {
+		return nil
+	}
+	err := tx.Bucket([]byte(JournalConfig)).DeleteBucket([]byte(zone))
+	if err != nil && err != errBucketNotFound {
+		return fmt.Errorf("clearing journal of zone(%s) failed", zone)
+	}
+
+	return nil
+}
+
+func journalKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+
+	return key
+}
+
+// SerialGreater check the serial s1 is greater than s2 in the serial number arithmetic of RFC 1982.
+func SerialGreater(s1 uint32, s2 uint32) bool {
+	return int32(s1-s2) > 0
+}
+
+// diffRRs get the records of rrs1 which are not in rrs2.
+func diffRRs(rrs1 []dns.RR, rrs2 []dns.RR) []dns.RR {
+	var diff []dns.RR
+	for _, rr1 := range rrs1 {
+		found := false
+		for _, rr2 := range rrs2 {
+			if rr1.String() == rr2.String() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			diff = append(diff, rr1)
+		}
+	}
+
+	return diff
+}
+
+func findSOA(rrs []dns.RR) *dns.SOA {
+	for _, rr := range rrs {
+		if soa, ok := rr.(*dns.SOA); ok {
+			return soa
+		}
+	}
+
+	return nil
+}
+
+func withoutSOA(rrs []dns.RR) []dns.RR {
+	var records []dns.RR
+	for _, rr := range rrs {
+		if rr.Header().Rrtype != dns.TypeSOA {
+			records = append(records, rr)
+		}
+	}
+
+	return records
+}
+
+func rrStrings(rrs []dns.RR) []string {
+	texts := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		texts = append(texts, rr.String())
+	}
+
+	return texts
+}
+
+func parseRRs(texts []string) []dns.RR {
+	var rrs []dns.RR
+	for _, text := range texts {
+		if rr, err := dns.NewRR(text); err == nil && rr != nil {
+			rrs = append(rrs, rr)
+		}
+	}
+
+	return rrs
+}
+
+func parseSOA(text string) *dns.SOA {
+	rr, err := dns.NewRR(text)
+	if err != nil {
+		return nil
+	}
+	soa, _ := rr.(*dns.SOA)
+
+	return soa
+}
@@ -0,0 +1,187 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package datastore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	exampleZone         = "example.com."
+	errorInTransfer     = "Error in zone transfer"
+	transferSOASerial10 = "ns1.example.com. admin.example.com. 10 3600 600 86400 10"
+)
+
+func getSOASerial(t *testing.T, rr dns.RR) uint32 {
+	soa, ok := rr.(*dns.SOA)
+	assert.Equal(t, true, ok, errorInTransfer)
+	if !ok {
+		return 0
+	}
+	return soa.Serial
+}
+
+func TestTransferDataStoreOperations(t *testing.T) {
+	forEachBackend(t, testTransferDataStoreOperations)
+}
+
+func testTransferDataStoreOperations(t *testing.T, newStore newStoreFunc) {
+	defer func() {
+		_ = os.RemoveAll(DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	store := newStore("testtransferdb", nil)
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+
+	err = store.ImportZone(exampleZone, []ResourceRecord{
+		{Name: exampleDomain, Type: "A", Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP1}},
+		{Name: exampleZone, Type: "SOA", Class: "IN", TTL: 30, RData: []string{transferSOASerial10}},
+		{Name: exampleAbcDomain, Type: "A", Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP2}},
+	})
+	assert.Equal(t, nil, err, errorSettingMessage)
+
+	t.Run("FullTransfer", func(t *testing.T) {
+		rrs, err := store.GetZoneTransfer(exampleZone)
+		assert.Equal(t, nil, err, errorInTransfer)
+		assert.Equal(t, 4, len(rrs), errorInTransfer)
+		// Imported serial is kept as is
+		assert.Equal(t, uint32(10), getSOASerial(t, rrs[0]), errorInTransfer)
+		assert.Equal(t, rrs[0].String(), rrs[len(rrs)-1].String(), errorInTransfer)
+	})
+
+	t.Run("NotAuthoritative", func(t *testing.T) {
+		_, err := store.GetZoneTransfer(DefaultZone)
+		assert.EqualError(t, err, "zone(.) is not authoritative", errorInTransfer)
+		_, err = store.GetZoneIncrementalTransfer("example1.com.", 1)
+		assert.EqualError(t, err, "zone(example1.com.) not found", errorInTransfer)
+	})
+
+	t.Run("UpToDate", func(t *testing.T) {
+		rrs, err := store.GetZoneIncrementalTransfer(exampleZone, 10)
+		assert.Equal(t, nil, err, errorInTransfer)
+		assert.Equal(t, 1, len(rrs), errorInTransfer)
+		// Newer serial on the client as per RFC 1982
+		rrs, err = store.GetZoneIncrementalTransfer(exampleZone, 11)
+		assert.Equal(t, nil, err, errorInTransfer)
+		assert.Equal(t, 1, len(rrs), errorInTransfer)
+	})
+
+	t.Run("SerialBumpedOnChange", func(t *testing.T) {
+		err := store.SetResourceRecord(exampleZone, &ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN",
+			TTL: 30, RData: []string{dnsConfigTestIP3}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		_, err = store.DelResourceRecord(exampleZone, exampleAbcDomain, "A")
+		assert.Equal(t, nil, err, errorDeleteMessage)
+
+		rrs, err := store.GetZoneTransfer(exampleZone)
+		assert.Equal(t, nil, err, errorInTransfer)
+		assert.Equal(t, uint32(12), getSOASerial(t, rrs[0]), errorInTransfer)
+
+		// Unchanged record does not bump the serial
+		err = store.SetResourceRecord(exampleZone, &ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN",
+			TTL: 30, RData: []string{dnsConfigTestIP3}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		rrs, _ = store.GetZoneIncrementalTransfer(exampleZone, 12)
+		assert.Equal(t, 1, len(rrs), errorInTransfer)
+	})
+
+	t.Run("IncrementalTransfer", func(t *testing.T) {
+		rrs, err := store.GetZoneIncrementalTransfer(exampleZone, 10)
+		assert.Equal(t, nil, err, errorInTransfer)
+		// current SOA, (SOA 10, -www, SOA 11, +www), (SOA 11, -abc, SOA 12), current SOA
+		var texts []string
+		for _, rr := range rrs {
+			texts = append(texts, rr.String())
+		}
+		assert.Equal(t, []string{
+			"example.com.\t30\tIN\tSOA\tns1.example.com. admin.example.com. 12 3600 600 86400 10",
+			"example.com.\t30\tIN\tSOA\tns1.example.com. admin.example.com. 10 3600 600 86400 10",
+			"www.example.com.\t30\tIN\tA\t" + dnsConfigTestIP1,
+			"example.com.\t30\tIN\tSOA\tns1.example.com. admin.example.com. 11 3600 600 86400 10",
+			"www.example.com.\t30\tIN\tA\t" + dnsConfigTestIP3,
+			"example.com.\t30\tIN\tSOA\tns1.example.com. admin.example.com. 11 3600 600 86400 10",
+			"abc.example.com.\t30\tIN\tA\t" + dnsConfigTestIP2,
+			"example.com.\t30\tIN\tSOA\tns1.example.com. admin.example.com. 12 3600 600 86400 10",
+			"example.com.\t30\tIN\tSOA\tns1.example.com. admin.example.com. 12 3600 600 86400 10",
+		}, texts, errorInTransfer)
+
+		rrs, err = store.GetZoneIncrementalTransfer(exampleZone, 11)
+		assert.Equal(t, nil, err, errorInTransfer)
+		assert.Equal(t, 5, len(rrs), errorInTransfer)
+
+		// Changes are not available from unknown serial
+		rrs, err = store.GetZoneIncrementalTransfer(exampleZone, 5)
+		assert.Equal(t, nil, err, errorInTransfer)
+		assert.Equal(t, 0, len(rrs), errorInTransfer)
+	})
+
+	t.Run("SOAUpdate", func(t *testing.T) {
+		// Serial not greater than the current is increased
+		err := store.SetResourceRecord(exampleZone, &ResourceRecord{Name: exampleZone, Type: "SOA", Class: "IN",
+			TTL: 60, RData: []string{transferSOASerial10}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		rrs, _ := store.GetZoneIncrementalTransfer(exampleZone, 12)
+		assert.Equal(t, 4, len(rrs), errorInTransfer)
+		assert.Equal(t, uint32(13), getSOASerial(t, rrs[0]), errorInTransfer)
+		assert.Equal(t, uint32(60), rrs[0].Header().Ttl, errorInTransfer)
+	})
+
+	t.Run("JournalClearedOnImport", func(t *testing.T) {
+		err := store.ImportZone(exampleZone, []ResourceRecord{
+			{Name: exampleZone, Type: "SOA", Class: "IN", TTL: 30, RData: []string{
+				"ns1.example.com. admin.example.com. 20 3600 600 86400 10"}},
+		})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		rrs, err := store.GetZoneIncrementalTransfer(exampleZone, 13)
+		assert.Equal(t, nil, err, errorInTransfer)
+		assert.Equal(t, 0, len(rrs), errorInTransfer)
+	})
+
+	t.Run("ViewNotTracked", func(t *testing.T) {
+		err := store.SetView(&View{Name: "internal", CIDRs: []string{"10.0.0.0/8"}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		viewStore := store.WithView("internal")
+		err = viewStore.SetResourceRecord(exampleZone, &ResourceRecord{Name: exampleZone, Type: "SOA",
+			Class: "IN", TTL: 30, RData: []string{transferSOASerial10}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		err = viewStore.SetResourceRecord(exampleZone, &ResourceRecord{Name: exampleDomain, Type: "A",
+			Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP4}})
+		assert.Equal(t, nil, err, errorSettingMessage)
+		rrs, _ := viewStore.GetZoneTransfer(exampleZone)
+		assert.Equal(t, uint32(10), getSOASerial(t, rrs[0]), errorInTransfer)
+	})
+
+	err = store.Close()
+	assert.Equal(t, nil, err, "Error in closing the db")
+}
+
+func TestSerialGreater(t *testing.T) {
+	assert.Equal(t, true, SerialGreater(2, 1), errorInTransfer)
+	assert.Equal(t, false, SerialGreater(1, 1), errorInTransfer)
+	assert.Equal(t, false, SerialGreater(1, 2), errorInTransfer)
+	// Serials wrap around
+	assert.Equal(t, true, SerialGreater(1, 0xffffffff), errorInTransfer)
+	assert.Equal(t, false, SerialGreater(0xffffffff, 1), errorInTransfer)
+}
@@ -0,0 +1,236 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package datastore
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type viewEntry struct {
+	name string
+	nets []*net.IPNet
+}
+
+// viewTable client CIDRs of the views, used to select the view of a query.
+type viewTable struct {
+	mutex   sync.RWMutex
+	entries []viewEntry
+}
+
+func (t *viewTable) set(views []View) {
+	entries := make([]viewEntry, 0, len(views))
+	for _, view := range views {
+		entry := viewEntry{name: view.Name}
+		for _, cidr := range view.CIDRs {
+			// Stored CIDRs are already validated
+			if _, ipNet, err := net.ParseCIDR(cidr); err == nil {
+				entry.nets = append(entry.nets, ipNet)
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.entries = entries
+}
+
+// selectView get the view with the longest CIDR match of the ip, empty if none matches.
+func (t *viewTable) selectView(ip net.IP) string {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	selected, longest := "", -1
+	for _, entry := range t.entries {
+		for _, ipNet := range entry.nets {
+			if ones, _ := ipNet.Mask.Size(); ones > longest && ipNet.Contains(ip) {
+				selected, longest = entry.name, ones
+			}
+		}
+	}
+
+	return selected
+}
+
+// WithView get the data store scoped to the view, it shares the underlying backend with the default view.
+func (b *bucketStore) WithView(view string) DataStore {
+	if view == b.view {
+		return b
+	}
+
+	return &bucketStore{backend: b.backend, loadBalance: b.loadBalance, health: b.health, view: view, views: b.views}
+}
+
+// SelectView get the view of the client, empty for the default view.
+func (b *bucketStore) SelectView(ip net.IP) string {
+	if b.views == nil || ip == nil {
+		return ""
+	}
+
+	return b.views.selectView(ip)
+}
+
+// SetView create or update the view with the client CIDRs selecting it. CIDRs are stored as networks, the host bits
+// cleared.
+func (b *bucketStore) SetView(view *View) error {
+	cidrs := make([]string, 0, len(view.CIDRs))
+	for _, cidr := range view.CIDRs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid cidr(%s) for view(%s)", cidr, view.Name)
+		}
+		cidrs = append(cidrs, ipNet.String())
+	}
+	cidrBytes, err := json.Marshal(cidrs)
+	if err != nil {
+		return fmt.Errorf("data store could not marshal view json")
+	}
+
+	err = b.backend.Update(func(tx kvTx) error {
+		viewCfgBkt := tx.Bucket([]byte(ViewConfig))
+		// A client network can select only one view
+		err := viewCfgBkt.ForEach(func(name, v []byte) error {
+			if v != nil || string(name) == view.Name {
+				return nil
+			}
+			for _, existing := range b.getViewCIDRs(viewCfgBkt.Bucket(name)) {
+				for _, cidr := range cidrs {
+					if cidr == existing {
+						return fmt.Errorf("cidr(%s) already used by view(%s)", existing, name)
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		viewBkt, err := viewCfgBkt.CreateBucketIfNotExists([]byte(view.Name))
+		if err != nil {
+			return fmt.Errorf("view(%s) creation failed", view.Name)
+		}
+		if _, err = viewBkt.CreateBucketIfNotExists([]byte(ZoneConfig)); err != nil {
+			return fmt.Errorf("view(%s) creation failed", view.Name)
+		}
+		if err = viewBkt.Put([]byte(viewCIDRsKey), cidrBytes); err != nil {
+			return fmt.Errorf("saving view to data store failed")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.loadViews()
+}
+
+// DeleteView delete the view along with all its zones.
+func (b *bucketStore) DeleteView(view string) error {
+	err := b.backend.Update(func(tx kvTx) error {
+		err := tx.Bucket([]byte(ViewConfig)).DeleteBucket([]byte(view))
+		if err == errBucketNotFound {
+			return fmt.Errorf("view(%s) not found", view)
+		}
+		if err != nil {
+			return fmt.Errorf("deleting view(%s) failed", view)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.loadViews()
+}
+
+// ListViews get all the views.
+func (b *bucketStore) ListViews() ([]View, error) {
+	views := make([]View, 0)
+	err := b.backend.View(func(tx kvTx) error {
+		viewCfgBkt := tx.Bucket([]byte(ViewConfig))
+		return viewCfgBkt.ForEach(func(name, v []byte) error {
+			if v == nil {
+				views = append(views, View{Name: string(name), CIDRs: b.getViewCIDRs(viewCfgBkt.Bucket(name))})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading views from data store failed")
+	}
+
+	return views, nil
+}
+
+// loadViews reload the view selection table from the db.
+func (b *bucketStore) loadViews() error {
+	views, err := b.ListViews()
+	if err != nil {
+		return err
+	}
+	if b.views == nil {
+		b.views = &viewTable{}
+	}
+	b.views.set(views)
+	log.Debugf("Loaded %d views.", len(views))
+
+	return nil
+}
+
+func (b *bucketStore) getViewCIDRs(viewBkt kvBucket) []string {
+	cidrs := make([]string, 0)
+	if viewBkt == nil {
+		return cidrs
+	}
+	if value := viewBkt.Get([]byte(viewCIDRsKey)); value != nil {
+		_ = json.Unmarshal(value, &cidrs)
+	}
+
+	return cidrs
+}
+
+// zoneConfigBucket get the bucket holding the zone buckets of the view.
+func (b *bucketStore) zoneConfigBucket(tx kvTx) (kvBucket, error) {
+	if len(b.view) == 0 {
+		return tx.Bucket([]byte(ZoneConfig)), nil
+	}
+	viewBkt := tx.Bucket([]byte(ViewConfig)).Bucket([]byte(b.view))
+	if viewBkt == nil {
+		return nil, fmt.Errorf("view(%s) not found", b.view)
+	}
+
+	return viewBkt.Bucket([]byte(ZoneConfig)), nil
+}
+
+// lookupBuckets get the zone config buckets to lookup, records in the view override the ones in the default view.
+func (b *bucketStore) lookupBuckets(tx kvTx) []kvBucket {
+	buckets := make([]kvBucket, 0, 2)
+	if len(b.view) != 0 {
+		if zoneCfgBkt, err := b.zoneConfigBucket(tx); err == nil {
+			buckets = append(buckets, zoneCfgBkt)
+		}
+	}
+
+	return append(buckets, tx.Bucket([]byte(ZoneConfig)))
+}
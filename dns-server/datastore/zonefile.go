@@ -0,0 +1,108 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package datastore
+package datastore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ParseZoneFile parse the RFC 1035 master file of the zone into resource records, rData of the same name and type
+// are grouped into one record. $INCLUDE is not supported.
+func ParseZoneFile(zone string, r io.Reader) ([]ResourceRecord, error) {
+	var records []ResourceRecord
+	index := make(map[DNSConfigRRKey]int)
+
+	zp := dns.NewZoneParser(r, dns.Fqdn(zone), "")
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		hdr := rr.Header()
+		rrType := dns.Type(hdr.Rrtype).String()
+		if _, ok := rrTypeMap[rrType]; !ok {
+			return nil, fmt.Errorf("unsupported rrtype(%s) entry for %s", rrType, hdr.Name)
+		}
+		if !dns.IsSubDomain(zone, hdr.Name) {
+			return nil, fmt.Errorf("entry %s is out of the zone(%s)", hdr.Name, zone)
+		}
+
+		key := DNSConfigRRKey{Host: strings.ToLower(hdr.Name), RRType: hdr.Rrtype}
+		if i, exists := index[key]; exists {
+			records[i].RData = append(records[i].RData, RDataString(rr))
+			// RRSet shares the same TTL, use the lowest as per RFC 2181
+			if hdr.Ttl < records[i].TTL {
+				records[i].TTL = hdr.Ttl
+			}
+			continue
+		}
+		index[key] = len(records)
+		records = append(records, ResourceRecord{Name: key.Host, Type: rrType,
+			Class: dns.ClassToString[hdr.Class], TTL: hdr.Ttl, RData: []string{RDataString(rr)}})
+	}
+	if err := zp.Err(); err != nil {
+		return nil, fmt.Errorf("parsing zone file failed, %s", err.Error())
+	}
+
+	return records, nil
+}
+
+// WriteZoneFile write the records of the zone as a RFC 1035 master file, SOA first.
+func WriteZoneFile(w io.Writer, zone string, records []ResourceRecord) error {
+	bw := bufio.NewWriter(w)
+	if _, err := fmt.Fprintf(bw, "$ORIGIN %s\n", dns.Fqdn(zone)); err != nil {
+		return err
+	}
+
+	for _, soaFirst := range []bool{true, false} {
+		for _, rr := range records {
+			if (rr.Type == "SOA") != soaFirst {
+				continue
+			}
+			rrType, ok := rrTypeMap[rr.Type]
+			if !ok {
+				return fmt.Errorf("unsupported rrtype(%s) entry", rr.Type)
+			}
+			rrClass, ok := rrClassMap[rr.Class]
+			if !ok {
+				return fmt.Errorf("unsupported rrclass(%s) entry", rr.Class)
+			}
+			for _, rData := range rr.RData {
+				dnsRR, err := newRR(rr.Name, rrType, rrClass, rr.TTL, rData)
+				if err != nil {
+					return err
+				}
+				if _, err = fmt.Fprintln(bw, dnsRR.String()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// RDataString get the rData of the record in the presentation format used by the data store.
+func RDataString(rr dns.RR) string {
+	if txt, ok := rr.(*dns.TXT); ok {
+		return strings.Join(txt.Txt, "")
+	}
+
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package datastore
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testZoneFile = `$ORIGIN example.com.
+$TTL 60
+@       30 IN SOA ns1 admin 1 3600 600 86400 10
+@          IN NS  ns1
+ns1        IN A   192.168.1.1
+www     30 IN A   192.168.1.101
+www     20 IN A   192.168.1.102
+app        IN CNAME www
+_http._tcp IN SRV 10 5 8080 app
+@          IN MX  10 mail.example.com.
+info       IN TXT "v=app1 version=2"
+`
+
+func TestParseZoneFile(t *testing.T) {
+	records, err := ParseZoneFile("example.com.", strings.NewReader(testZoneFile))
+	assert.Equal(t, nil, err, "Error in parsing the zone file")
+	assert.Equal(t, 8, len(records), "Error in parsing the zone file")
+	assert.Equal(t, ResourceRecord{Name: "example.com.", Type: "SOA", Class: "IN", TTL: 30,
+		RData: []string{"ns1.example.com. admin.example.com. 1 3600 600 86400 10"}}, records[0],
+		"Error in parsing the zone file")
+	assert.Equal(t, ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN", TTL: 20,
+		RData: []string{"192.168.1.101", "192.168.1.102"}}, records[3], "Error in parsing the zone file")
+	assert.Equal(t, []string{"www.example.com."}, records[4].RData, "Error in parsing the zone file")
+	assert.Equal(t, []string{"10 5 8080 app.example.com."}, records[5].RData, "Error in parsing the zone file")
+	assert.Equal(t, []string{"v=app1 version=2"}, records[7].RData, "Error in parsing the zone file")
+
+	_, err = ParseZoneFile("example.com.", strings.NewReader("www.example.org. 30 IN A 192.168.1.1\n"))
+	assert.EqualError(t, err, "entry www.example.org. is out of the zone(example.com.)",
+		"Error in parsing the zone file")
+	_, err = ParseZoneFile("example.com.", strings.NewReader("www 30 IN HINFO cpu os\n"))
+	assert.EqualError(t, err, "unsupported rrtype(HINFO) entry for www.example.com.",
+		"Error in parsing the zone file")
+	_, err = ParseZoneFile("example.com.", strings.NewReader("www 30 IN A 192.168.1\n"))
+	assert.NotEqual(t, nil, err, "Error in parsing the zone file")
+}
+
+func TestWriteZoneFile(t *testing.T) {
+	records, err := ParseZoneFile("example.com.", strings.NewReader(testZoneFile))
+	assert.Equal(t, nil, err, "Error in parsing the zone file")
+
+	// SOA is always written first
+	records = append(records[1:], records[0])
+	var zoneFile bytes.Buffer
+	err = WriteZoneFile(&zoneFile, "example.com.", records)
+	assert.Equal(t, nil, err, "Error in writing the zone file")
+	lines := strings.Split(strings.TrimSpace(zoneFile.String()), "\n")
+	assert.Equal(t, "$ORIGIN example.com.", lines[0], "Error in writing the zone file")
+	assert.Equal(t, "example.com.\t30\tIN\tSOA\tns1.example.com. admin.example.com. 1 3600 600 86400 10", lines[1],
+		"Error in writing the zone file")
+	assert.Contains(t, lines, "info.example.com.\t60\tIN\tTXT\t\"v=app1 version=2\"", "Error in writing the zone file")
+
+	// Exported file can be imported back as it is
+	reparsed, err := ParseZoneFile("example.com.", &zoneFile)
+	assert.Equal(t, nil, err, "Error in parsing the zone file")
+	assert.ElementsMatch(t, records, reparsed, "Error in parsing the zone file")
+}
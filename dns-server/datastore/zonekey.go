@@ -0,0 +1,125 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// ZoneKeyConfig Zone DNSSEC key constant.
+const ZoneKeyConfig = "zonekey"
+
+// GetZoneKeys get the DNSSEC keys of the zone, empty if the zone is not signed. Keys belong to the zones of the
+// default view and are shared by the views.
+func (b *bucketStore) GetZoneKeys(zone string) ([]ZoneKey, error) {
+	keys := make([]ZoneKey, 0)
+	err := b.backend.View(func(tx kvTx) error {
+		if tx.Bucket([]byte(ZoneConfig)).Bucket([]byte(zone)) == nil {
+			return fmt.Errorf("zone(%s) not found", zone)
+		}
+		if value := tx.Bucket([]byte(ZoneKeyConfig)).Get([]byte(zone)); value != nil {
+			if err := json.Unmarshal(value, &keys); err != nil {
+				return fmt.Errorf("parsing failed on data retrieval")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// SetZoneKeys set the DNSSEC keys of the zone of the default view, empty keys disable the signing of the zone.
+func (b *bucketStore) SetZoneKeys(zone string, keys []ZoneKey) error {
+	keyBytes, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("data store could not marshal zone keys json")
+	}
+
+	return b.backend.Update(func(tx kvTx) error {
+		if tx.Bucket([]byte(ZoneConfig)).Bucket([]byte(zone)) == nil {
+			return fmt.Errorf("zone(%s) not found", zone)
+		}
+		keyBkt := tx.Bucket([]byte(ZoneKeyConfig))
+		if len(keys) == 0 {
+			err = keyBkt.Delete([]byte(zone))
+		} else {
+			err = keyBkt.Put([]byte(zone), keyBytes)
+		}
+		if err != nil {
+			return fmt.Errorf("saving zone keys to data store failed")
+		}
+		return nil
+	})
+}
+
+// deleteZoneKeys drop the DNSSEC keys of the zone, only the zones of the default view have keys.
+func (b *bucketStore) deleteZoneKeys(tx kvTx, zone string) error {
+	if len(b.view) != 0 {
+		return nil
+	}
+	if err := tx.Bucket([]byte(ZoneKeyConfig)).Delete([]byte(zone)); err != nil {
+		return fmt.Errorf("deleting zone(%s) keys failed", zone)
+	}
+
+	return nil
+}
+
+// GetZoneNames get the owner names within the zone along with their record types, from all the local zones looked
+// up for the view.
+func (b *bucketStore) GetZoneNames(zone string) (map[string][]uint16, error) {
+	names := make(map[string][]uint16)
+	zone = strings.ToLower(zone)
+	err := b.backend.View(func(tx kvTx) error {
+		for _, zoneCfgBkt := range b.lookupBuckets(tx) {
+			err := zoneCfgBkt.ForEach(func(name, v []byte) error {
+				zoneBkt := zoneCfgBkt.Bucket(name)
+				if v != nil || zoneBkt == nil {
+					return nil
+				}
+				return zoneBkt.ForEach(func(k, v []byte) error {
+					dnsCfgKey := &DNSConfigRRKey{}
+					if v == nil || json.Unmarshal(k, dnsCfgKey) != nil || !dns.IsSubDomain(zone, dnsCfgKey.Host) {
+						return nil
+					}
+					for _, rrType := range names[dnsCfgKey.Host] {
+						if rrType == dnsCfgKey.RRType {
+							return nil
+						}
+					}
+					names[dnsCfgKey.Host] = append(names[dnsCfgKey.Host], dnsCfgKey.RRType)
+					return nil
+				})
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading dns entries from data store failed")
+	}
+
+	return names, nil
+}
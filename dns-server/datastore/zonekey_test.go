@@ -0,0 +1,101 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package datastore
+
+import (
+	"os"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZoneKeys(t *testing.T) {
+	forEachBackend(t, testZoneKeys)
+}
+
+func testZoneKeys(t *testing.T, newStore newStoreFunc) {
+	defer func() {
+		_ = os.RemoveAll(DBPath)
+	}()
+
+	store := newStore("testdb", nil)
+	assert.Nil(t, store.Open(), "Error in opening the db")
+	defer store.Close()
+	assert.Nil(t, store.CreateZone("example.com."))
+	keys := []ZoneKey{{DNSKEY: "example.com. 3600 IN DNSKEY 257 3 13 key", PrivateKey: "private", Active: true}}
+
+	t.Run("SetAndGet", func(t *testing.T) {
+		stored, err := store.GetZoneKeys("example.com.")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(stored))
+		assert.Nil(t, store.SetZoneKeys("example.com.", keys))
+		stored, err = store.GetZoneKeys("example.com.")
+		assert.Nil(t, err)
+		assert.Equal(t, keys, stored)
+	})
+
+	t.Run("SharedByViews", func(t *testing.T) {
+		assert.Nil(t, store.SetView(&View{Name: "edge", CIDRs: []string{"10.10.0.0/16"}}))
+		defer store.DeleteView("edge")
+		stored, err := store.WithView("edge").GetZoneKeys("example.com.")
+		assert.Nil(t, err)
+		assert.Equal(t, keys, stored)
+	})
+
+	t.Run("ZoneNotFound", func(t *testing.T) {
+		_, err := store.GetZoneKeys("example.org.")
+		assert.EqualError(t, err, "zone(example.org.) not found")
+		assert.EqualError(t, store.SetZoneKeys("example.org.", keys), "zone(example.org.) not found")
+	})
+
+	t.Run("DeletedWithZone", func(t *testing.T) {
+		assert.Nil(t, store.DeleteZone("example.com."))
+		assert.Nil(t, store.CreateZone("example.com."))
+		stored, err := store.GetZoneKeys("example.com.")
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(stored))
+	})
+
+	t.Run("ZoneNames", func(t *testing.T) {
+		for _, rr := range []struct{ zone, name, rrType, rData string }{
+			{"example.com.", "example.com.", "SOA", "ns1.example.com. admin.example.com. 1 3600 600 86400 10"},
+			{"example.com.", "www.example.com.", "A", "192.168.1.1"},
+			{"example.com.", "www.example.com.", "AAAA", "2001:db8::1"},
+			{".", "app.example.com.", "A", "192.168.1.2"},
+			{".", "www.example.org.", "A", "192.168.1.3"},
+		} {
+			err := store.SetResourceRecord(rr.zone, &ResourceRecord{Name: rr.name, Type: rr.rrType, Class: "IN",
+				TTL: 30, RData: []string{rr.rData}})
+			assert.Nil(t, err, errorSettingMessage)
+		}
+		assert.Nil(t, store.SetView(&View{Name: "edge", CIDRs: []string{"10.10.0.0/16"}}))
+		defer store.DeleteView("edge")
+		err := store.WithView("edge").SetResourceRecord(".", &ResourceRecord{Name: "edge.example.com.",
+			Type: "TXT", Class: "IN", TTL: 30, RData: []string{"edge"}})
+		assert.Nil(t, err, errorSettingMessage)
+
+		names, err := store.GetZoneNames("example.com.")
+		assert.Nil(t, err)
+		assert.Equal(t, map[string][]uint16{"example.com.": {dns.TypeSOA},
+			"www.example.com.": {dns.TypeA, dns.TypeAAAA}, "app.example.com.": {dns.TypeA}}, names)
+
+		names, err = store.WithView("edge").GetZoneNames("example.com.")
+		assert.Nil(t, err)
+		assert.Equal(t, 4, len(names))
+		assert.Equal(t, []uint16{dns.TypeTXT}, names["edge.example.com."])
+	})
+}
@@ -18,37 +18,60 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"math/rand"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/miekg/dns"
 	log "github.com/sirupsen/logrus"
 
+	"dns-server/cache"
 	"dns-server/datastore"
+	"dns-server/dnssec"
+	"dns-server/forward"
+	"dns-server/health"
+	"dns-server/metrics"
 	"dns-server/mgmt"
+	"dns-server/notify"
+	"dns-server/querylog"
+	"dns-server/ratelimit"
 	"dns-server/util"
 )
 
 // Config DNS server configuration.
 type Config struct {
-	dbName            string // Database name, default zone
-	port              uint   // Port to listen to, default 53
-	mgmtPort          uint   // Http port to listen to, default 80
-	ipAdd             net.IP // IP address to listen to, default 0.0.0.0
-	ipMgmtAdd         net.IP // IP address to listen to, default 0.0.0.0
-	forwarder         net.IP // Forwarder dns address , default 8.8.8.8
-	connectionTimeout uint   // Connection time out value, both read, and write, default 2s
-	loadBalance       bool   // load balancing using random shuffle
+	dbName            string               // Database name, default zone
+	store             string               // Data store backend, default boltdb
+	etcdEndpoints     []string             // Etcd endpoints of the etcd data store
+	etcdPrefix        string               // Prefix of the data store keys in etcd
+	configFile        string               // Config file of the settings reloaded on SIGHUP, none if empty
+	flagSettings      reloadSettings       // Reloadable settings from the command line, overridden by the config file
+	port              uint                 // Port to listen to, default 53
+	mgmtPort          uint                 // Http port to listen to, default 80
+	ipAdd             net.IP               // IP address to listen to, default 0.0.0.0
+	ipMgmtAdd         net.IP               // IP address to listen to, default 0.0.0.0
+	forwarders        *forward.Pool        // Forwarder dns servers, default none
+	connectionTimeout uint                 // Connection time out value, both read, and write, default 2s
+	loadBalance       bool                 // load balancing of the local answers using random shuffle
+	cache             *cache.Cache         // Forwarded response cache
+	transferACL       []*net.IPNet         // Clients allowed to transfer the zones, none if empty
+	notifier          *notify.Notifier     // Notifies the secondaries of the zone changes
+	tsigKeys          map[string][]tsigKey // TSIG keys allowed to update the zones, updates are refused if none
+	monitor           *health.Monitor      // Health of the record addresses with a health check
+	queryLog          *querylog.Logger     // Logs the queries along with the responses, disabled if nil
+	rateLimiter       *ratelimit.Limiter   // Limits the udp responses per client, disabled if nil
+	signer            *dnssec.Signer       // Signs the answers of the signed local zones, disabled if nil
 }
 
 type Server struct {
-	config    *Config
-	dataStore datastore.DataStore
-	mgmtCtl   mgmt.ManagementCtrl
-	tcpServer *dns.Server
-	udpServer *dns.Server
+	config      *Config
+	dataStore   datastore.DataStore
+	mgmtCtl     mgmt.ManagementCtrl
+	tcpServer   *dns.Server
+	udpServer   *dns.Server
+	updateMutex sync.Mutex
 }
 
 func NewServer(config *Config, dataStore datastore.DataStore, mgmtCtl mgmt.ManagementCtrl) *Server {
@@ -60,12 +83,24 @@ func (s *Server) Run() error { // Set dns query handler
 
 	address := fmt.Sprintf("%s:%d", s.config.ipAdd.String(), s.config.port)
 
+	secrets := tsigSecrets(s.config.tsigKeys)
 	s.udpServer = &dns.Server{
-		Addr:         address,
-		Net:          "udp",
-		UDPSize:      util.DNSUDPPacketSize,
-		ReadTimeout:  time.Duration(s.config.connectionTimeout) * time.Second,
-		WriteTimeout: time.Duration(s.config.connectionTimeout) * time.Second,
+		Addr:          address,
+		Net:           "udp",
+		UDPSize:       util.DNSUDPPacketSize,
+		ReadTimeout:   time.Duration(s.config.connectionTimeout) * time.Second,
+		WriteTimeout:  time.Duration(s.config.connectionTimeout) * time.Second,
+		TsigSecret:    secrets,
+		MsgAcceptFunc: acceptMsg,
+	}
+
+	s.tcpServer = &dns.Server{
+		Addr:          address,
+		Net:           "tcp",
+		ReadTimeout:   time.Duration(s.config.connectionTimeout) * time.Second,
+		WriteTimeout:  time.Duration(s.config.connectionTimeout) * time.Second,
+		TsigSecret:    secrets,
+		MsgAcceptFunc: acceptMsg,
 	}
 
 	err := s.dataStore.Open()
@@ -77,100 +112,230 @@ func (s *Server) Run() error { // Set dns query handler
 
 	go s.mgmtCtl.StartController(&s.dataStore, s.config.ipMgmtAdd, s.config.mgmtPort)
 	go s.start(s.udpServer)
+	go s.start(s.tcpServer)
 
 	return nil
 }
 
 func (s *Server) start(dns *dns.Server) {
+	dns.NotifyStartedFunc = func() {
+		log.Infof("Dns %s server now running on %s.", dns.Net, dns.Addr)
+	}
 	err := dns.ListenAndServe()
 	if err != nil {
 		log.Fatalf("Failed to listen dns %s server on %s. (%s)", dns.Net, dns.Addr, err.Error())
 	}
-	log.Infof("Dns %s server now running on %s.", dns.Net, dns.Addr)
+	log.Infof("Dns %s server stopped on %s.", dns.Net, dns.Addr)
 }
 
+// Stop stop the dns server in order, the in-flight queries are drained before closing the data store.
 func (s *Server) Stop() {
-	err := s.dataStore.Close()
-	if err != nil {
-		log.Error("Failed to close the data store.", nil)
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), util.ShutdownTimeout*time.Second)
+	defer cancel()
 
 	if s.udpServer != nil {
-		err = s.udpServer.Shutdown()
+		err := s.udpServer.ShutdownContext(ctx)
 		if err != nil {
-			log.Error("Failed to stop the dns udp server.", nil)
+			log.Errorf("Failed to stop the dns udp server. %s", err.Error())
 		}
 	}
 
-	err = s.mgmtCtl.StopController()
+	if s.tcpServer != nil {
+		err := s.tcpServer.ShutdownContext(ctx)
+		if err != nil {
+			log.Errorf("Failed to stop the dns tcp server. %s", err.Error())
+		}
+	}
+
+	err := s.mgmtCtl.StopController()
 	if err != nil {
-		log.Fatal("Failed to stop the management controller", err)
+		log.Errorf("Failed to stop the management controller. %s", err.Error())
+	}
+
+	s.config.monitor.Stop()
+	s.config.queryLog.Close()
+
+	err = s.dataStore.Close()
+	if err != nil {
+		log.Error("Failed to close the data store.", nil)
 	}
 
 	log.Info("Edge-Gallery DNS-Server stopped now.")
 }
 
-// forward request to external server.
+// forward request to external server, answering from the cache when available.
 func (s *Server) forward(req *dns.Msg) (*dns.Msg, error) {
-	c := new(dns.Client)
-	forwarder := s.config.forwarder.String()
-	if forwarder == util.DefaultIP {
-		return nil, fmt.Errorf("could not resolve the request %q and no forwarder is configured",
-			req.Question[0].Name)
+	if cached := s.config.cache.Get(&req.Question[0]); cached != nil {
+		cached.Id = req.Id
+		cached.Question = req.Question
+		return cached, nil
 	}
 
-	// Retry 3 times on failure. exchange will not retry on failure.
-	for i := 0; i < util.ForwardRetryCount; i++ {
-		ret, _, err := c.Exchange(req, forwarder+":53")
-		if err != nil {
-			continue
-		}
-		if ret.Rcode == dns.RcodeSuccess {
-			return ret, nil
-		}
+	respMsg, err := s.config.forwarders.Exchange(req)
+	if err != nil {
+		return nil, err
 	}
+	s.config.cache.Set(respMsg)
+
+	return respMsg, nil
+}
 
-	return nil, fmt.Errorf("forward of request %q was not accepted", req.Question[0].Name)
+// responseRecorder records the response and the outcome of the request for the metrics and the query log.
+type responseRecorder struct {
+	dns.ResponseWriter
+	outcome  string
+	response *dns.Msg
+}
+
+// WriteMsg send the response, recording the first message.
+func (r *responseRecorder) WriteMsg(msg *dns.Msg) error {
+	if r.response == nil {
+		r.response = msg
+	}
+	return r.ResponseWriter.WriteMsg(msg)
 }
 
 // Handle DNS Query matching.
-func (s *Server) handleDNS(w dns.ResponseWriter, req *dns.Msg) {
+func (s *Server) handleDNS(writer dns.ResponseWriter, req *dns.Msg) {
+	start := time.Now()
+	w := &responseRecorder{ResponseWriter: writer, outcome: metrics.OutcomeLocal}
+	defer func() {
+		latency := time.Since(start)
+		if w.response != nil {
+			metrics.ObserveResponse(w.outcome, w.response.Rcode, latency)
+		}
+		s.config.queryLog.Log(&querylog.Entry{Time: start, Client: writer.RemoteAddr(), Query: req,
+			Response: w.response, Latency: latency, Source: w.outcome})
+	}()
+
 	if !s.validateQuestion(req) {
 		s.writeErrorResponse(w, req, dns.RcodeFormatError)
 
 		return
 	}
+	metrics.ObserveRequest(req.Question[0].Qtype)
+
+	// Only the udp clients are rate limited, tcp clients can not spoof their address
+	if addr, ok := writer.RemoteAddr().(*net.UDPAddr); ok {
+		switch s.config.rateLimiter.Check(addr.IP, req.Question[0].Name) {
+		case ratelimit.Drop:
+			w.outcome = metrics.OutcomeRateLimited
+			return
+		case ratelimit.Slip:
+			w.outcome = metrics.OutcomeRateLimited
+			s.writeTruncatedResponse(w, req)
+			return
+		}
+	}
 
 	if req.Opcode == dns.OpcodeQuery {
+		if qtype := req.Question[0].Qtype; qtype == dns.TypeAXFR || qtype == dns.TypeIXFR {
+			w.outcome = metrics.OutcomeTransfer
+			s.handleTransfer(w, req)
+			return
+		}
 		// log.Debugf("Query lookup (%s)", req.Question[0].String())
 		// Match data from db
-		rrs, err := s.dataStore.GetResourceRecord(&req.Question[0])
+		// Records of the client's view override the default view
+		view := s.dataStore.SelectView(clientIP(w))
+		store := s.dataStore.WithView(view)
+		// DNSKEY records of the signed zones are generated from the zone keys
+		if req.Question[0].Qtype == dns.TypeDNSKEY {
+			if rrs := s.config.signer.DNSKEY(req.Question[0].Name); rrs != nil {
+				s.writeSuccessResponse(view, &rrs, true, w, req)
+				return
+			}
+		}
+		lookupStart := time.Now()
+		rrs, err := store.GetResourceRecord(&req.Question[0])
+		metrics.ObserveDataStore(metrics.OperationLookup, lookupStart)
 		if err != nil {
+			// Names in the local authoritative zones are answered locally
+			if notFound, ok := err.(*datastore.NotFoundError); ok && notFound.Authority != nil {
+				s.writeNegativeResponse(view, notFound, w, req)
+				return
+			}
+			w.outcome = metrics.OutcomeForwarded
 			respMsg, err := s.forward(req)
 			if err != nil {
 				s.writeErrorResponse(w, req, dns.RcodeServerFailure)
 				// log.Debugf("Failed to find entry: %v", err)
 				return
 			}
-			err = w.WriteMsg(respMsg)
+			err = s.writeMsg(w, req, respMsg)
 			if err != nil {
 				log.Errorf("Failed to send a response for query")
 			}
 
 			return
 		}
-		// Shuffle the response if load balancing is enabled
-		if s.config.loadBalance && len(*rrs) > 1 {
-			rand.Shuffle(len(*rrs), func(i, j int) {
-				(*rrs)[i], (*rrs)[j] = (*rrs)[j], (*rrs)[i]
-			})
+		// Answers are already selected by the data store, as per the weights, health and load balancing
+		authoritative := true
+		if cnameChainLength(rrs, req.Question[0].Qtype) == len(*rrs) {
+			// Data of the forwarder is not authoritative
+			authoritative = !s.chaseCNAME(req, rrs)
 		}
-		s.writeSuccessResponse(rrs, w, req)
+		s.writeSuccessResponse(view, rrs, authoritative, w, req)
+	} else if req.Opcode == dns.OpcodeUpdate {
+		w.outcome = metrics.OutcomeUpdate
+		s.handleUpdate(w, req)
 	} else {
 		s.writeErrorResponse(w, req, dns.RcodeRefused)
 	}
 }
 
+// clientIP get the address of the client sending the request.
+func clientIP(w dns.ResponseWriter) net.IP {
+	switch addr := w.RemoteAddr().(type) {
+	case *net.UDPAddr:
+		return addr.IP
+	case *net.TCPAddr:
+		return addr.IP
+	default:
+		return nil
+	}
+}
+
+// cnameChainLength count the CNAME records at the beginning of the answer.
+func cnameChainLength(rrs *[]dns.RR, qtype uint16) int {
+	if qtype == dns.TypeCNAME {
+		return 0
+	}
+	count := 0
+	for _, rr := range *rrs {
+		if rr.Header().Rrtype != dns.TypeCNAME {
+			break
+		}
+		count++
+	}
+
+	return count
+}
+
+// chaseCNAME complete the answer through the forwarder when the local CNAME chain points outside the local zones,
+// true if any forwarded record is added.
+func (s *Server) chaseCNAME(req *dns.Msg, rrs *[]dns.RR) bool {
+	if len(*rrs) == 0 {
+		return false
+	}
+	cname, ok := (*rrs)[len(*rrs)-1].(*dns.CNAME)
+	if !ok {
+		return false
+	}
+
+	fwdReq := new(dns.Msg)
+	fwdReq.SetQuestion(cname.Target, req.Question[0].Qtype)
+	fwdReq.Question[0].Qclass = req.Question[0].Qclass
+	respMsg, err := s.forward(fwdReq)
+	if err != nil {
+		log.Debugf("Could not resolve the CNAME target %s.", cname.Target)
+		return false
+	}
+	*rrs = append(*rrs, respMsg.Answer...)
+
+	return len(respMsg.Answer) != 0
+}
+
 // Validate the input question.
 func (s *Server) validateQuestion(req *dns.Msg) bool {
 	if len(req.Question) != 1 {
@@ -194,14 +359,68 @@ func (s *Server) writeErrorResponse(w dns.ResponseWriter, req *dns.Msg, rc int)
 	}
 }
 
-func (s *Server) writeSuccessResponse(answer *[]dns.RR, w dns.ResponseWriter, req *dns.Msg) {
+// writeTruncatedResponse answer an empty response with TC bit set, so the client retries over tcp.
+func (s *Server) writeTruncatedResponse(w dns.ResponseWriter, req *dns.Msg) {
 	response := new(dns.Msg)
-	response.Answer = *answer
-	response.Authoritative = true
 	response.SetReply(req)
+	response.Truncated = true
 
 	err := w.WriteMsg(response)
+	if err != nil {
+		log.Errorf("Failed to send truncated response for query")
+	}
+}
+
+// writeSuccessResponse answer the records of the view, signed when the client sets the DO bit. Authoritative is
+// false when the answer holds data of the forwarder.
+func (s *Server) writeSuccessResponse(view string, answer *[]dns.RR, authoritative bool, w dns.ResponseWriter,
+	req *dns.Msg) {
+	response := new(dns.Msg)
+	response.Answer = *answer
+	response.Authoritative = authoritative
+	response.SetReply(req)
+	s.config.signer.Sign(view, req, response)
+
+	err := s.writeMsg(w, req, response)
 	if err != nil {
 		log.Errorf("Failed to send success response for query")
 	}
 }
+
+// writeNegativeResponse answer NXDOMAIN or NODATA with the zone SOA in authority section(RFC 2308), along with the
+// denial of existence when the client sets the DO bit.
+func (s *Server) writeNegativeResponse(view string, notFound *datastore.NotFoundError, w dns.ResponseWriter,
+	req *dns.Msg) {
+	response := new(dns.Msg)
+	response.SetReply(req)
+	response.Authoritative = true
+	if !notFound.NameExists {
+		response.Rcode = dns.RcodeNameError
+	}
+
+	// Negative answer TTL is the minimum of the SOA TTL and SOA minimum field
+	soa := dns.Copy(notFound.Authority)
+	if soaRR, ok := soa.(*dns.SOA); ok && soaRR.Minttl < soaRR.Hdr.Ttl {
+		soaRR.Hdr.Ttl = soaRR.Minttl
+	}
+	response.Ns = []dns.RR{soa}
+	s.config.signer.Sign(view, req, response)
+
+	err := s.writeMsg(w, req, response)
+	if err != nil {
+		log.Errorf("Failed to send negative response for query")
+	}
+}
+
+// writeMsg sends the response, truncating it with TC bit set if it does not fit into the client's udp buffer.
+func (s *Server) writeMsg(w dns.ResponseWriter, req *dns.Msg, response *dns.Msg) error {
+	if _, ok := w.RemoteAddr().(*net.UDPAddr); ok {
+		size := dns.MinMsgSize
+		if opt := req.IsEdns0(); opt != nil {
+			size = int(opt.UDPSize())
+		}
+		response.Truncate(size)
+	}
+
+	return w.WriteMsg(response)
+}
@@ -19,8 +19,10 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"net/http/httptest"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -29,7 +31,12 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"dns-server/datastore"
+	"dns-server/dnssec"
+	"dns-server/forward"
+	"dns-server/metrics"
 	"dns-server/mgmt"
+	"dns-server/querylog"
+	"dns-server/ratelimit"
 	"dns-server/util"
 )
 
@@ -68,8 +75,27 @@ func TestForward(t *testing.T) {
 	var ipMgmtAddString = util.DefaultIP
 	var forwarder = defaultTestForwarder
 	var loadBalance = false
+	var forwarderPort uint = util.DefaultForwarderPort
+	var forwardPolicy = forward.PolicyRoundRobin
+	var cacheSize uint = util.DefaultCacheSize
+	var transferACL = ""
+	var secondaries = ""
+	var tsigKeys = ""
+	var queryLog = ""
+	var queryLogFormat = querylog.FormatJSON
+	var queryLogRate uint = 0
+	var rateLimit uint = 0
+	var rateLimitSlip uint = util.DefaultRateLimitSlip
+	var rateLimitWL = ""
+	var storeName = datastore.StoreBoltDB
+	var etcdEndpoints = ""
+	var etcdPrefix = util.DefaultEtcdPrefix
+	var configFile = ""
 	parameters := &InputParameters{&dbName, &port, &mgmtPort, &connTimeOut,
-		&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance}
+		&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+		&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+		&rateLimit, &rateLimitSlip, &rateLimitWL, &storeName, &etcdEndpoints, &etcdPrefix,
+		&configFile}
 	config := validateInputAndGenerateConfig(parameters)
 
 	store := &datastore.BoltDB{FileName: config.dbName, TTL: util.DefaultTTL}
@@ -123,8 +149,10 @@ func TestForward(t *testing.T) {
 	})
 
 	t.Run("WrongForwardAddress", func(t *testing.T) {
-		config.forwarder = net.ParseIP("0.0.0.0")
-		defer func() { config.forwarder = net.ParseIP(defaultTestForwarder) }()
+		defaultForwarders := config.forwarders
+		config.forwarders, _ = forward.NewPool(nil, forward.PolicyRoundRobin, time.Second)
+		defer func() { config.forwarders = defaultForwarders }()
+		config.cache.Flush()
 
 		dnsMsg := new(dns.Msg)
 		dnsMsg.Id = dns.Id()
@@ -143,7 +171,8 @@ func TestForward(t *testing.T) {
 type mockDnsRespWriter struct {
 	// mock.Mock
 	// dns.ResponseWriter
-	rspMsg *dns.Msg
+	rspMsg   *dns.Msg
+	remoteIP net.IP
 }
 
 func (m *mockDnsRespWriter) LocalAddr() net.Addr {
@@ -151,7 +180,10 @@ func (m *mockDnsRespWriter) LocalAddr() net.Addr {
 }
 
 func (m *mockDnsRespWriter) RemoteAddr() net.Addr {
-	panic(panicImplement)
+	if m.remoteIP != nil {
+		return &net.UDPAddr{IP: m.remoteIP, Port: util.DefaultDNSPort}
+	}
+	return &net.UDPAddr{IP: net.ParseIP(util.DefaultIP), Port: util.DefaultDNSPort}
 }
 
 func (m *mockDnsRespWriter) WriteMsg(msg *dns.Msg) error {
@@ -197,8 +229,27 @@ func TestHandleDNS(t *testing.T) {
 	var ipMgmtAddString = util.DefaultIP
 	var forwarder = defaultTestForwarder
 	var loadBalance = false
+	var forwarderPort uint = util.DefaultForwarderPort
+	var forwardPolicy = forward.PolicyRoundRobin
+	var cacheSize uint = util.DefaultCacheSize
+	var transferACL = ""
+	var secondaries = ""
+	var tsigKeys = ""
+	var queryLog = ""
+	var queryLogFormat = querylog.FormatJSON
+	var queryLogRate uint = 0
+	var rateLimit uint = 0
+	var rateLimitSlip uint = util.DefaultRateLimitSlip
+	var rateLimitWL = ""
+	var storeName = datastore.StoreBoltDB
+	var etcdEndpoints = ""
+	var etcdPrefix = util.DefaultEtcdPrefix
+	var configFile = ""
 	parameters := &InputParameters{&dbName, &port, &mgmtPort, &connTimeOut,
-		&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance}
+		&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+		&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+		&rateLimit, &rateLimitSlip, &rateLimitWL, &storeName, &etcdEndpoints, &etcdPrefix,
+		&configFile}
 	config := validateInputAndGenerateConfig(parameters)
 
 	store := &datastore.BoltDB{FileName: config.dbName, TTL: util.DefaultTTL}
@@ -266,6 +317,175 @@ func TestHandleDNS(t *testing.T) {
 		assert.Equal(t, dns.RcodeServerFailure, mockDnsWriter.rspMsg.Rcode, errorInResponse)
 	})
 
+	t.Run("MetricsRecorded", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		body := recorder.Body.String()
+		assert.Equal(t, true, strings.Contains(body, `dns_server_requests_total{qtype="A"}`), errorInResponse)
+		assert.Equal(t, true, strings.Contains(body, `dns_server_responses_total{outcome="local",rcode="NOERROR"}`),
+			errorInResponse)
+		assert.Equal(t, true, strings.Contains(body,
+			`dns_server_responses_total{outcome="servfail",rcode="SERVFAIL"}`), errorInResponse)
+		assert.Equal(t, true, strings.Contains(body, `dns_server_responses_total{outcome="formerr",rcode="FORMERR"}`),
+			errorInResponse)
+		assert.Equal(t, true, strings.Contains(body, `dns_server_datastore_duration_seconds_count{operation="lookup"}`),
+			errorInResponse)
+	})
+
+	t.Run("RateLimited", func(t *testing.T) {
+		config.rateLimiter = ratelimit.New(1, 2, nil)
+		defer func() { config.rateLimiter = nil }()
+
+		req := &dns.Msg{Question: []dns.Question{{Name: "www.example.com.",
+			Qtype:  dns.TypeA,
+			Qclass: dns.ClassINET}}}
+		var rcodes []int
+		var truncated []bool
+		for i := 0; i < 3; i++ {
+			mockDnsWriter := &mockDnsRespWriter{}
+			dnsServer.handleDNS(mockDnsWriter, req)
+			if mockDnsWriter.rspMsg == nil {
+				rcodes = append(rcodes, -1)
+				truncated = append(truncated, false)
+				continue
+			}
+			rcodes = append(rcodes, mockDnsWriter.rspMsg.Rcode)
+			truncated = append(truncated, mockDnsWriter.rspMsg.Truncated)
+		}
+		// Answered, dropped, then truncated
+		assert.Equal(t, []int{dns.RcodeSuccess, -1, dns.RcodeSuccess}, rcodes, errorInResponse)
+		assert.Equal(t, []bool{false, false, true}, truncated, errorInResponse)
+	})
+
+	t.Run("CNAMEToExternalTarget", func(t *testing.T) {
+		err = store.SetResourceRecord(".", &datastore.ResourceRecord{Name: "alias.example.com.", Type: "CNAME",
+			Class: "IN", TTL: 30, RData: []string{testDomainServer}})
+		assert.Equal(t, nil, err, "Error in setting the record")
+		defer store.DelResourceRecord(".", "alias.example.com.", "CNAME")
+
+		req := &dns.Msg{Question: []dns.Question{{Name: "alias.example.com.",
+			Qtype:  dns.TypeA,
+			Qclass: dns.ClassINET}}}
+		mockDnsWriter := &mockDnsRespWriter{}
+		dnsServer.handleDNS(mockDnsWriter, req)
+		assert.Equal(t, 2, len(mockDnsWriter.rspMsg.Answer), errorInResponse)
+		assert.Equal(t, "alias.example.com.\t30\tIN\tCNAME\twww.edgegallery.org.",
+			mockDnsWriter.rspMsg.Answer[0].String(), errorInResponse)
+		assert.Contains(t, mockDnsWriter.rspMsg.Answer[1].String(), testDomainServer, errorInResponse)
+		// The target data comes from the forwarder
+		assert.Equal(t, false, mockDnsWriter.rspMsg.Authoritative, errorInResponse)
+	})
+
+	t.Run("AuthoritativeZoneNegativeAnswers", func(t *testing.T) {
+		err = store.SetResourceRecord("example.com.", &datastore.ResourceRecord{Name: "example.com.", Type: "SOA",
+			Class: "IN", TTL: 30, RData: []string{"ns1.example.com. admin.example.com. 1 3600 600 86400 10"}})
+		assert.Equal(t, nil, err, "Error in setting the record")
+		defer store.DelResourceRecord("example.com.", "example.com.", "SOA")
+
+		req := &dns.Msg{Question: []dns.Question{{Name: "nonexist.example.com.",
+			Qtype:  dns.TypeA,
+			Qclass: dns.ClassINET}}}
+		mockDnsWriter := &mockDnsRespWriter{}
+		dnsServer.handleDNS(mockDnsWriter, req)
+		assert.Equal(t, dns.RcodeNameError, mockDnsWriter.rspMsg.Rcode, errorInResponse)
+		assert.Equal(t, true, mockDnsWriter.rspMsg.Authoritative, errorInResponse)
+		assert.Equal(t, 0, len(mockDnsWriter.rspMsg.Answer), errorInResponse)
+		assert.Equal(t, "example.com.\t10\tIN\tSOA\tns1.example.com. admin.example.com. 1 3600 600 86400 10",
+			mockDnsWriter.rspMsg.Ns[0].String(), errorInResponse)
+
+		req = &dns.Msg{Question: []dns.Question{{Name: exampleDomain,
+			Qtype:  dns.TypeAAAA,
+			Qclass: dns.ClassINET}}}
+		mockDnsWriter = &mockDnsRespWriter{}
+		dnsServer.handleDNS(mockDnsWriter, req)
+		assert.Equal(t, dns.RcodeSuccess, mockDnsWriter.rspMsg.Rcode, errorInResponse)
+		assert.Equal(t, 0, len(mockDnsWriter.rspMsg.Answer), errorInResponse)
+		assert.Equal(t, 1, len(mockDnsWriter.rspMsg.Ns), errorInResponse)
+	})
+
+	t.Run("SignedZoneAnswers", func(t *testing.T) {
+		err = store.SetResourceRecord("example.com.", &datastore.ResourceRecord{Name: "example.com.", Type: "SOA",
+			Class: "IN", TTL: 30, RData: []string{"ns1.example.com. admin.example.com. 1 3600 600 86400 10"}})
+		assert.Equal(t, nil, err, "Error in setting the record")
+		defer store.DelResourceRecord("example.com.", "example.com.", "SOA")
+		ksk, _ := dnssec.GenerateKey("example.com.", dnssec.KeyTypeKSK, dns.ECDSAP256SHA256)
+		zsk, _ := dnssec.GenerateKey("example.com.", dnssec.KeyTypeZSK, dns.ECDSAP256SHA256)
+		err = store.SetZoneKeys("example.com.", []datastore.ZoneKey{*ksk, *zsk})
+		assert.Equal(t, nil, err, "Error in setting the keys")
+		defer store.SetZoneKeys("example.com.", nil)
+		dnsServer.config.signer = dnssec.New(store)
+		defer func() { dnsServer.config.signer = nil }()
+
+		query := func(name string, qtype uint16, do bool) *dns.Msg {
+			req := new(dns.Msg)
+			req.SetQuestion(name, qtype)
+			if do {
+				req.SetEdns0(4096, true)
+			}
+			mockDnsWriter := &mockDnsRespWriter{}
+			dnsServer.handleDNS(mockDnsWriter, req)
+			return mockDnsWriter.rspMsg
+		}
+
+		rsp := query(exampleDomain, dns.TypeA, true)
+		assert.Equal(t, 2, len(rsp.Answer), errorInResponse)
+		assert.Equal(t, dns.TypeRRSIG, rsp.Answer[1].Header().Rrtype, errorInResponse)
+		assert.Equal(t, true, rsp.IsEdns0().Do(), errorInResponse)
+		rsp = query(exampleDomain, dns.TypeA, false)
+		assert.Equal(t, 1, len(rsp.Answer), errorInResponse)
+
+		rsp = query("example.com.", dns.TypeDNSKEY, true)
+		assert.Equal(t, 3, len(rsp.Answer), errorInResponse)
+		assert.Equal(t, dns.TypeDNSKEY, rsp.Answer[0].Header().Rrtype, errorInResponse)
+
+		rsp = query("nonexist.example.com.", dns.TypeA, true)
+		assert.Equal(t, dns.RcodeNameError, rsp.Rcode, errorInResponse)
+		types := make(map[uint16]int)
+		for _, rr := range rsp.Ns {
+			types[rr.Header().Rrtype]++
+		}
+		assert.Equal(t, map[uint16]int{dns.TypeSOA: 1, dns.TypeNSEC: 1, dns.TypeRRSIG: 2}, types, errorInResponse)
+	})
+
+	t.Run("SplitHorizonViews", func(t *testing.T) {
+		err := store.SetView(&datastore.View{Name: "mgmt", CIDRs: []string{"10.10.0.0/16"}})
+		assert.Equal(t, nil, err, "Error in setting the view")
+		defer store.DeleteView("mgmt")
+		err = store.WithView("mgmt").SetResourceRecord(".", &datastore.ResourceRecord{Name: exampleDomain,
+			Type: "A", Class: "IN", TTL: 30, RData: []string{"10.10.1.100"}})
+		assert.Equal(t, nil, err, "Error in setting the record")
+
+		query := func(remoteIP string, name string) *dns.Msg {
+			req := &dns.Msg{Question: []dns.Question{{Name: name, Qtype: dns.TypeA, Qclass: dns.ClassINET}}}
+			mockDnsWriter := &mockDnsRespWriter{remoteIP: net.ParseIP(remoteIP)}
+			dnsServer.handleDNS(mockDnsWriter, req)
+			return mockDnsWriter.rspMsg
+		}
+
+		rsp := query("10.10.1.1", exampleDomain)
+		assert.Equal(t, "www.example.com.\t30\tIN\tA\t10.10.1.100", rsp.Answer[0].String(), errorInResponse)
+		rsp = query("192.168.1.1", exampleDomain)
+		assert.Equal(t, fmt.Sprintf("www.example.com.\t30\tIN\tA\t%s", dnsConfigTestIP1), rsp.Answer[0].String(),
+			errorInResponse)
+	})
+
+	t.Run("ForwardingQueryFromCache", func(t *testing.T) {
+		config.cache.Flush()
+		before := config.cache.Stats()
+		for i := 0; i < 3; i++ {
+			req := new(dns.Msg)
+			req.SetQuestion("www.cached.org.", dns.TypeA)
+			mockDnsWriter := &mockDnsRespWriter{}
+			dnsServer.handleDNS(mockDnsWriter, req)
+			assert.Equal(t, req.Id, mockDnsWriter.rspMsg.Id, errorInResponse)
+			assert.Contains(t, mockDnsWriter.rspMsg.Answer[0].String(), "www.cached.org.", errorInResponse)
+		}
+		after := config.cache.Stats()
+		assert.Equal(t, uint64(2), after.Hits-before.Hits, errorInResponse)
+		assert.Equal(t, uint64(1), after.Misses-before.Misses, errorInResponse)
+		assert.Equal(t, 1, after.Size, errorInResponse)
+	})
+
 	t.Run("ForwardingQuery", func(t *testing.T) {
 		dnsMsg := new(dns.Msg)
 		dnsMsg.Id = dns.Id()
@@ -280,3 +500,320 @@ func TestHandleDNS(t *testing.T) {
 	})
 
 }
+
+type mockMgmtCtrl struct {
+}
+
+func (m *mockMgmtCtrl) StartController(store *datastore.DataStore, ipAddr net.IP, port uint) {
+}
+
+func (m *mockMgmtCtrl) StopController() error {
+	return nil
+}
+
+// Get a free port which is not used by both udp and tcp listeners
+func getFreeTestPort(t *testing.T) uint {
+	for i := 0; i < 10; i++ {
+		tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Equal(t, nil, err, "Error in getting free port")
+		port := tcpListener.Addr().(*net.TCPAddr).Port
+		udpConn, err := net.ListenPacket("udp", fmt.Sprintf("127.0.0.1:%d", port))
+		_ = tcpListener.Close()
+		if err == nil {
+			_ = udpConn.Close()
+			return uint(port)
+		}
+	}
+	t.Fatal("No free port available for testing")
+	return 0
+}
+
+// Exchange with retry till the server is up
+func exchangeWithRetry(client *dns.Client, req *dns.Msg, address string) (*dns.Msg, error) {
+	var (
+		rsp *dns.Msg
+		err error
+	)
+	for i := 0; i < 20; i++ {
+		rsp, _, err = client.Exchange(req, address)
+		if err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return rsp, err
+}
+
+func TestDNSOverUDPAndTCP(t *testing.T) {
+	defer func() {
+		_ = os.RemoveAll(datastore.DBPath)
+		r := recover()
+		if r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	var dbName = "test_db"
+	var port = getFreeTestPort(t)
+	var mgmtPort uint = util.DefaultManagementPort
+	var connTimeOut uint = util.DefaultConnTimeout
+	var ipAddString = "127.0.0.1"
+	var ipMgmtAddString = util.DefaultIP
+	var forwarder = util.DefaultIP
+	var loadBalance = false
+	var forwarderPort uint = util.DefaultForwarderPort
+	var forwardPolicy = forward.PolicyRoundRobin
+	var cacheSize uint = util.DefaultCacheSize
+	var transferACL = ""
+	var secondaries = ""
+	var tsigKeys = ""
+	var queryLog = ""
+	var queryLogFormat = querylog.FormatJSON
+	var queryLogRate uint = 0
+	var rateLimit uint = 0
+	var rateLimitSlip uint = util.DefaultRateLimitSlip
+	var rateLimitWL = ""
+	var storeName = datastore.StoreBoltDB
+	var etcdEndpoints = ""
+	var etcdPrefix = util.DefaultEtcdPrefix
+	var configFile = ""
+	parameters := &InputParameters{&dbName, &port, &mgmtPort, &connTimeOut,
+		&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+		&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+		&rateLimit, &rateLimitSlip, &rateLimitWL, &storeName, &etcdEndpoints, &etcdPrefix,
+		&configFile}
+	config := validateInputAndGenerateConfig(parameters)
+
+	store := &datastore.BoltDB{FileName: config.dbName, TTL: util.DefaultTTL}
+	dnsServer := NewServer(config, store, &mockMgmtCtrl{})
+	err := dnsServer.Run()
+	assert.Equal(t, nil, err, "Error in starting the server")
+	defer dnsServer.Stop()
+
+	err = store.SetResourceRecord(".", &datastore.ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN",
+		TTL: 30, RData: []string{dnsConfigTestIP1}})
+	assert.Equal(t, nil, err, "Error in setting the record")
+
+	var largeRData []string
+	for i := 1; i <= 100; i++ {
+		largeRData = append(largeRData, fmt.Sprintf(ipAddFormatter, 10, 0, 0, i))
+	}
+	err = store.SetResourceRecord(".", &datastore.ResourceRecord{Name: testDomainServer, Type: "A", Class: "IN",
+		TTL: 30, RData: largeRData})
+	assert.Equal(t, nil, err, "Error in setting the record")
+
+	address := fmt.Sprintf("127.0.0.1:%d", port)
+
+	t.Run("QueryOverUDP", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetQuestion(exampleDomain, dns.TypeA)
+		rsp, err := exchangeWithRetry(&dns.Client{Net: "udp"}, req, address)
+		assert.Equal(t, nil, err, errorInResponse)
+		assert.Equal(t, dns.RcodeSuccess, rsp.Rcode, errorInResponse)
+		assert.Equal(t, fmt.Sprintf("www.example.com.\t30\tIN\tA\t%s", dnsConfigTestIP1),
+			rsp.Answer[0].String(), errorInResponse)
+	})
+
+	t.Run("QueryOverTCP", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetQuestion(exampleDomain, dns.TypeA)
+		rsp, err := exchangeWithRetry(&dns.Client{Net: "tcp"}, req, address)
+		assert.Equal(t, nil, err, errorInResponse)
+		assert.Equal(t, dns.RcodeSuccess, rsp.Rcode, errorInResponse)
+		assert.Equal(t, fmt.Sprintf("www.example.com.\t30\tIN\tA\t%s", dnsConfigTestIP1),
+			rsp.Answer[0].String(), errorInResponse)
+	})
+
+	t.Run("LargeAnswerTruncatedOverUDP", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetQuestion(testDomainServer, dns.TypeA)
+		rsp, err := exchangeWithRetry(&dns.Client{Net: "udp"}, req, address)
+		assert.Equal(t, nil, err, errorInResponse)
+		assert.Equal(t, true, rsp.Truncated, errorInResponse)
+		assert.Less(t, len(rsp.Answer), len(largeRData), errorInResponse)
+	})
+
+	t.Run("LargeAnswerWithEdnsOverUDP", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetQuestion(testDomainServer, dns.TypeA)
+		req.SetEdns0(dns.DefaultMsgSize, false)
+		rsp, err := exchangeWithRetry(&dns.Client{Net: "udp", UDPSize: dns.DefaultMsgSize}, req, address)
+		assert.Equal(t, nil, err, errorInResponse)
+		assert.Equal(t, false, rsp.Truncated, errorInResponse)
+		assert.Equal(t, len(largeRData), len(rsp.Answer), errorInResponse)
+	})
+
+	t.Run("LargeAnswerOverTCP", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetQuestion(testDomainServer, dns.TypeA)
+		rsp, err := exchangeWithRetry(&dns.Client{Net: "tcp"}, req, address)
+		assert.Equal(t, nil, err, errorInResponse)
+		assert.Equal(t, false, rsp.Truncated, errorInResponse)
+		assert.Equal(t, len(largeRData), len(rsp.Answer), errorInResponse)
+	})
+}
+
+func TestZoneTransfer(t *testing.T) {
+	defer func() {
+		_ = os.RemoveAll(datastore.DBPath)
+		r := recover()
+		if r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	const exampleZone = "example.com."
+	const soaRData = "ns1.example.com. admin.example.com. 10 3600 600 86400 10"
+
+	var dbName = "test_db"
+	var port = getFreeTestPort(t)
+	var mgmtPort uint = util.DefaultManagementPort
+	var connTimeOut uint = util.DefaultConnTimeout
+	var ipAddString = "127.0.0.1"
+	var ipMgmtAddString = util.DefaultIP
+	var forwarder = util.DefaultIP
+	var loadBalance = false
+	var forwarderPort uint = util.DefaultForwarderPort
+	var forwardPolicy = forward.PolicyRoundRobin
+	var cacheSize uint = util.DefaultCacheSize
+	var transferACL = "192.168.1.0/24, 127.0.0.1"
+	var secondaries = ""
+	var tsigKeys = ""
+	var queryLog = ""
+	var queryLogFormat = querylog.FormatJSON
+	var queryLogRate uint = 0
+	var rateLimit uint = 0
+	var rateLimitSlip uint = util.DefaultRateLimitSlip
+	var rateLimitWL = ""
+	var storeName = datastore.StoreBoltDB
+	var etcdEndpoints = ""
+	var etcdPrefix = util.DefaultEtcdPrefix
+	var configFile = ""
+	parameters := &InputParameters{&dbName, &port, &mgmtPort, &connTimeOut,
+		&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+		&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+		&rateLimit, &rateLimitSlip, &rateLimitWL, &storeName, &etcdEndpoints, &etcdPrefix,
+		&configFile}
+	config := validateInputAndGenerateConfig(parameters)
+
+	store := &datastore.BoltDB{FileName: config.dbName, TTL: util.DefaultTTL}
+	dnsServer := NewServer(config, store, &mockMgmtCtrl{})
+	err := dnsServer.Run()
+	assert.Equal(t, nil, err, "Error in starting the server")
+	defer dnsServer.Stop()
+
+	// More records than a transfer message carries
+	var largeRData []string
+	for i := 1; i <= 2*util.TransferChunkSize; i++ {
+		largeRData = append(largeRData, fmt.Sprintf(ipAddFormatter, 10, 0, i/256, i%256))
+	}
+	err = store.ImportZone(exampleZone, []datastore.ResourceRecord{
+		{Name: exampleZone, Type: "SOA", Class: "IN", TTL: 30, RData: []string{soaRData}},
+		{Name: exampleDomain, Type: "A", Class: "IN", TTL: 30, RData: []string{dnsConfigTestIP1}},
+		{Name: testDomainServer, Type: "A", Class: "IN", TTL: 30, RData: largeRData},
+	})
+	assert.Equal(t, nil, err, "Error in setting the record")
+
+	address := fmt.Sprintf("127.0.0.1:%d", port)
+	// Wait till the server is up
+	ready := new(dns.Msg)
+	ready.SetQuestion(exampleDomain, dns.TypeA)
+	_, err = exchangeWithRetry(&dns.Client{Net: "tcp"}, ready, address)
+	assert.Equal(t, nil, err, errorInResponse)
+
+	transferIn := func(req *dns.Msg) ([]dns.RR, int, error) {
+		ch, err := new(dns.Transfer).In(req, address)
+		if err != nil {
+			return nil, 0, err
+		}
+		var rrs []dns.RR
+		envelopes := 0
+		for envelope := range ch {
+			if envelope.Error != nil {
+				return nil, 0, envelope.Error
+			}
+			rrs = append(rrs, envelope.RR...)
+			envelopes++
+		}
+		return rrs, envelopes, nil
+	}
+
+	t.Run("AXFROverTCP", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetAxfr(exampleZone)
+		rrs, envelopes, err := transferIn(req)
+		assert.Equal(t, nil, err, errorInResponse)
+		assert.Equal(t, len(largeRData)+3, len(rrs), errorInResponse)
+		assert.Equal(t, true, envelopes > 1, errorInResponse)
+		assert.Equal(t, dns.TypeSOA, rrs[0].Header().Rrtype, errorInResponse)
+		assert.Equal(t, rrs[0].String(), rrs[len(rrs)-1].String(), errorInResponse)
+	})
+
+	t.Run("AXFROverUDPRefused", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetAxfr(exampleZone)
+		rsp, err := exchangeWithRetry(&dns.Client{Net: "udp"}, req, address)
+		assert.Equal(t, nil, err, errorInResponse)
+		assert.Equal(t, dns.RcodeRefused, rsp.Rcode, errorInResponse)
+	})
+
+	t.Run("NotAuthoritative", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetAxfr("example1.com.")
+		rsp, err := exchangeWithRetry(&dns.Client{Net: "tcp"}, req, address)
+		assert.Equal(t, nil, err, errorInResponse)
+		assert.Equal(t, dns.RcodeNotAuth, rsp.Rcode, errorInResponse)
+	})
+
+	t.Run("ClientNotInACL", func(t *testing.T) {
+		acl := dnsServer.config.transferACL
+		dnsServer.config.transferACL = acl[:1]
+		defer func() {
+			dnsServer.config.transferACL = acl
+		}()
+		req := new(dns.Msg)
+		req.SetAxfr(exampleZone)
+		rsp, err := exchangeWithRetry(&dns.Client{Net: "tcp"}, req, address)
+		assert.Equal(t, nil, err, errorInResponse)
+		assert.Equal(t, dns.RcodeRefused, rsp.Rcode, errorInResponse)
+	})
+
+	t.Run("IXFROverTCP", func(t *testing.T) {
+		err := store.SetResourceRecord(exampleZone, &datastore.ResourceRecord{Name: exampleDomain, Type: "A",
+			Class: "IN", TTL: 30, RData: []string{"192.168.1.10"}})
+		assert.Equal(t, nil, err, "Error in setting the record")
+
+		req := new(dns.Msg)
+		req.SetIxfr(exampleZone, 10, "ns1.example.com.", "admin.example.com.")
+		rrs, _, err := transferIn(req)
+		assert.Equal(t, nil, err, errorInResponse)
+		// current SOA, SOA 10, deleted, SOA 11, added, current SOA
+		assert.Equal(t, 6, len(rrs), errorInResponse)
+		assert.Equal(t, "www.example.com.\t30\tIN\tA\t"+dnsConfigTestIP1, rrs[2].String(), errorInResponse)
+		assert.Equal(t, "www.example.com.\t30\tIN\tA\t192.168.1.10", rrs[4].String(), errorInResponse)
+
+		// Falls back to the full zone when the changes are not available
+		req.SetIxfr(exampleZone, 5, "ns1.example.com.", "admin.example.com.")
+		rrs, _, err = transferIn(req)
+		assert.Equal(t, nil, err, errorInResponse)
+		assert.Equal(t, len(largeRData)+3, len(rrs), errorInResponse)
+	})
+
+	t.Run("IXFROverUDP", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetIxfr(exampleZone, 10, "ns1.example.com.", "admin.example.com.")
+		rsp, err := exchangeWithRetry(&dns.Client{Net: "udp"}, req, address)
+		assert.Equal(t, nil, err, errorInResponse)
+		assert.Equal(t, dns.RcodeSuccess, rsp.Rcode, errorInResponse)
+		assert.Equal(t, 1, len(rsp.Answer), errorInResponse)
+		assert.Equal(t, uint32(11), rsp.Answer[0].(*dns.SOA).Serial, errorInResponse)
+	})
+
+	t.Run("IXFRWithoutSOA", func(t *testing.T) {
+		req := new(dns.Msg)
+		req.SetQuestion(exampleZone, dns.TypeIXFR)
+		rsp, err := exchangeWithRetry(&dns.Client{Net: "tcp"}, req, address)
+		assert.Equal(t, nil, err, errorInResponse)
+		assert.Equal(t, dns.RcodeFormatError, rsp.Rcode, errorInResponse)
+	})
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package dnssec online DNSSEC signing of the answers from the local zones
+package dnssec
+
+import (
+	"crypto"
+	"fmt"
+	"strings"
+
+	"github.com/miekg/dns"
+
+	"dns-server/datastore"
+	"dns-server/util"
+)
+
+// Key types.
+const (
+	KeyTypeKSK = "ksk"
+	KeyTypeZSK = "zsk"
+)
+
+// DefaultAlgorithm algorithm of the generated keys when not specified.
+const DefaultAlgorithm = "ECDSAP256SHA256"
+
+// keyBits key size of the supported algorithms.
+var keyBits = map[uint8]int{dns.RSASHA256: 2048, dns.ECDSAP256SHA256: 256, dns.ECDSAP384SHA384: 384,
+	dns.ED25519: 256}
+
+// KeyStatus DNSSEC key of a zone, without the private key.
+type KeyStatus struct {
+	KeyTag    uint16 `json:"keyTag"`
+	Type      string `json:"type"`
+	Algorithm string `json:"algorithm"`
+	Active    bool   `json:"active"`
+	DNSKEY    string `json:"dnskey"`
+}
+
+// signingKey parsed DNSSEC key of a zone.
+type signingKey struct {
+	dnskey  *dns.DNSKEY
+	private crypto.Signer
+	active  bool
+}
+
+// ParseAlgorithm get the algorithm number of the supported algorithm mnemonic, default if empty.
+func ParseAlgorithm(name string) (uint8, error) {
+	if len(name) == 0 {
+		name = DefaultAlgorithm
+	}
+	algorithm, ok := dns.StringToAlgorithm[strings.ToUpper(name)]
+	if _, supported := keyBits[algorithm]; !ok || !supported {
+		return 0, fmt.Errorf("unsupported algorithm(%s)", name)
+	}
+
+	return algorithm, nil
+}
+
+// GenerateKey generate an active key of the type for the zone.
+func GenerateKey(zone string, keyType string, algorithm uint8) (*datastore.ZoneKey, error) {
+	bits, ok := keyBits[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm(%d)", algorithm)
+	}
+	flags := uint16(dns.ZONE)
+	switch keyType {
+	case KeyTypeKSK:
+		flags |= dns.SEP
+	case KeyTypeZSK:
+	default:
+		return nil, fmt.Errorf("unsupported key type(%s)", keyType)
+	}
+
+	dnskey := &dns.DNSKEY{Hdr: dns.RR_Header{Name: dns.Fqdn(strings.ToLower(zone)), Rrtype: dns.TypeDNSKEY,
+		Class: dns.ClassINET, Ttl: util.DNSSECKeyTTL}, Flags: flags, Protocol: 3, Algorithm: algorithm}
+	private, err := dnskey.Generate(bits)
+	if err != nil {
+		return nil, fmt.Errorf("generating %s of zone(%s) failed", keyType, zone)
+	}
+
+	return &datastore.ZoneKey{DNSKEY: dnskey.String(), PrivateKey: dnskey.PrivateKeyString(private), Active: true}, nil
+}
+
+// KeyType get the type of the key, ksk if the SEP flag is set.
+func KeyType(dnskey *dns.DNSKEY) string {
+	if dnskey.Flags&dns.SEP != 0 {
+		return KeyTypeKSK
+	}
+
+	return KeyTypeZSK
+}
+
+// ParseDNSKEY parse the public key of the zone key.
+func ParseDNSKEY(key *datastore.ZoneKey) (*dns.DNSKEY, error) {
+	rr, err := dns.NewRR(key.DNSKEY)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dnskey, %s", err.Error())
+	}
+	dnskey, ok := rr.(*dns.DNSKEY)
+	if !ok {
+		return nil, fmt.Errorf("invalid dnskey")
+	}
+
+	return dnskey, nil
+}
+
+// Status get the keys of the zone without the private keys.
+func Status(keys []datastore.ZoneKey) ([]KeyStatus, error) {
+	status := make([]KeyStatus, 0, len(keys))
+	for i := range keys {
+		dnskey, err := ParseDNSKEY(&keys[i])
+		if err != nil {
+			return nil, err
+		}
+		status = append(status, KeyStatus{KeyTag: dnskey.KeyTag(), Type: KeyType(dnskey),
+			Algorithm: dns.AlgorithmToString[dnskey.Algorithm], Active: keys[i].Active, DNSKEY: dnskey.String()})
+	}
+
+	return status, nil
+}
+
+// DS get the DS records of the key signing keys of the zone, to be published in the parent zone.
+func DS(keys []datastore.ZoneKey, digest uint8) ([]dns.RR, error) {
+	records := make([]dns.RR, 0)
+	for i := range keys {
+		dnskey, err := ParseDNSKEY(&keys[i])
+		if err != nil {
+			return nil, err
+		}
+		if KeyType(dnskey) != KeyTypeKSK {
+			continue
+		}
+		ds := dnskey.ToDS(digest)
+		if ds == nil {
+			return nil, fmt.Errorf("unsupported digest(%d)", digest)
+		}
+		records = append(records, ds)
+	}
+
+	return records, nil
+}
+
+// parseSigningKeys parse the public and private keys of the zone keys.
+func parseSigningKeys(keys []datastore.ZoneKey) ([]signingKey, error) {
+	signingKeys := make([]signingKey, 0, len(keys))
+	for i := range keys {
+		dnskey, err := ParseDNSKEY(&keys[i])
+		if err != nil {
+			return nil, err
+		}
+		private, err := dnskey.NewPrivateKey(keys[i].PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key of key(%d), %s", dnskey.KeyTag(), err.Error())
+		}
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("invalid private key of key(%d)", dnskey.KeyTag())
+		}
+		signingKeys = append(signingKeys, signingKey{dnskey: dnskey, private: signer, active: keys[i].Active})
+	}
+
+	return signingKeys, nil
+}
@@ -0,0 +1,487 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package dnssec
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	"dns-server/datastore"
+	"dns-server/util"
+)
+
+// Signer signs the answers from the local zones having DNSSEC keys, for the clients setting the DO bit. Zones, keys
+// and owner names are cached for a short while, so the changes made through the other servers sharing the data
+// store are picked up. Signatures are cached until shortly before they expire, as long as the rrset and the keys
+// are unchanged.
+type Signer struct {
+	store      datastore.DataStore
+	mutex      sync.Mutex
+	zones      []string
+	expires    time.Time
+	keys       map[string]*zoneKeys
+	names      map[string]*zoneNames
+	signatures map[string]*rrsetSignatures
+}
+
+// zoneKeys parsed keys of a zone, none if the zone is not signed.
+type zoneKeys struct {
+	zone    string
+	keys    []signingKey
+	id      string // public keys and states of the keys, changed along with any of them
+	expires time.Time
+}
+
+// rrsetSignatures cached signatures of an rrset by the view, owner and type.
+type rrsetSignatures struct {
+	signed  string // keys id and the records signed
+	rrsigs  []*dns.RRSIG
+	expires time.Time
+}
+
+// zoneNames owner names of a zone in the canonical order along with their record types, for the denial of
+// existence.
+type zoneNames struct {
+	zone    string
+	names   []string
+	types   map[string][]uint16
+	expires time.Time
+}
+
+// New create a signer of the answers from the data store.
+func New(store datastore.DataStore) *Signer {
+	return &Signer{store: store, keys: make(map[string]*zoneKeys), names: make(map[string]*zoneNames),
+		signatures: make(map[string]*rrsetSignatures)}
+}
+
+// Invalidate drop the cached zones, keys, names and signatures, to apply the changes immediately.
+func (s *Signer) Invalidate() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expires = time.Time{}
+	s.keys = make(map[string]*zoneKeys)
+	s.names = make(map[string]*zoneNames)
+	s.signatures = make(map[string]*rrsetSignatures)
+}
+
+// DNSKEY get the DNSKEY records of the signed zone, nil if the name is not the apex of a signed zone.
+func (s *Signer) DNSKEY(name string) []dns.RR {
+	if s == nil {
+		return nil
+	}
+	keys := s.getZoneKeys(name)
+	if keys == nil || !strings.EqualFold(keys.zone, name) {
+		return nil
+	}
+
+	rrs := make([]dns.RR, 0, len(keys.keys))
+	for _, key := range keys.keys {
+		dnskey := *key.dnskey
+		dnskey.Hdr.Name = name
+		rrs = append(rrs, &dnskey)
+	}
+
+	return rrs
+}
+
+// Sign add the signatures of the answer, and the signed denial of existence of a negative answer, when the client
+// sets the DO bit. View is the view of the client the answer is from.
+func (s *Signer) Sign(view string, req *dns.Msg, response *dns.Msg) {
+	opt := req.IsEdns0()
+	if s == nil || opt == nil || !opt.Do() {
+		return
+	}
+
+	var answer, authority []dns.RR
+	for _, rrset := range splitRRSets(response.Answer) {
+		answer = append(answer, rrset...)
+		keys := s.getZoneKeys(rrset[0].Header().Name)
+		if keys == nil {
+			continue
+		}
+		signOwner := ""
+		if names := s.getZoneNames(view, keys.zone); names != nil {
+			// Synthesized answer is signed as the wildcard, proving no closer match exists
+			if wildcard := names.wildcardOf(rrset[0].Header().Name); len(wildcard) != 0 {
+				signOwner = wildcard
+				authority = append(authority, s.signNSECs(view, keys, names.cover(rrset[0].Header().Name,
+					rrset[0].Header().Ttl))...)
+			}
+		}
+		answer = append(answer, s.signRRSet(view, keys, rrset, signOwner)...)
+	}
+	response.Answer = answer
+
+	if len(response.Answer) == 0 && len(response.Ns) == 1 && response.Ns[0].Header().Rrtype == dns.TypeSOA {
+		soa := response.Ns[0]
+		if keys := s.getZoneKeys(soa.Header().Name); keys != nil && strings.EqualFold(keys.zone, soa.Header().Name) {
+			authority = append([]dns.RR{soa}, s.signRRSet(view, keys, []dns.RR{soa}, "")...)
+			if names := s.getZoneNames(view, keys.zone); names != nil {
+				ttl := soa.Header().Ttl
+				if soaRR, ok := soa.(*dns.SOA); ok && soaRR.Minttl < ttl {
+					ttl = soaRR.Minttl
+				}
+				authority = append(authority, s.signNSECs(view, keys, names.deny(req.Question[0].Name, ttl))...)
+			}
+			response.Ns = nil
+		}
+	}
+	response.Ns = append(response.Ns, authority...)
+	response.SetEdns0(util.EDNSBufferSize, true)
+}
+
+// signNSECs get the NSEC records along with their signatures.
+func (s *Signer) signNSECs(view string, keys *zoneKeys, nsecs []*dns.NSEC) []dns.RR {
+	var rrs []dns.RR
+	for _, nsec := range nsecs {
+		rrs = append(rrs, nsec)
+		rrs = append(rrs, s.signRRSet(view, keys, []dns.RR{nsec}, "")...)
+	}
+
+	return rrs
+}
+
+// signRRSet get the signatures of the rrset, from the cache if the rrset was signed by the same keys and the
+// signatures are not close to expire.
+func (s *Signer) signRRSet(view string, keys *zoneKeys, rrset []dns.RR, wildcardOwner string) []dns.RR {
+	owner := rrset[0].Header().Name
+	if len(wildcardOwner) != 0 {
+		owner = wildcardOwner
+	}
+	cacheKey := view + "/" + strings.ToLower(owner) + "/" + dns.TypeToString[rrset[0].Header().Rrtype]
+	signed := keys.id + "\n" + rrsetText(rrset)
+
+	now := time.Now()
+	s.mutex.Lock()
+	cached, ok := s.signatures[cacheKey]
+	s.mutex.Unlock()
+	if !ok || cached.signed != signed || now.After(cached.expires) {
+		cached = &rrsetSignatures{signed: signed, rrsigs: keys.sign(rrset, wildcardOwner)}
+		if len(cached.rrsigs) == 0 {
+			return nil
+		}
+		expiration := cached.rrsigs[0].Expiration
+		for _, rrsig := range cached.rrsigs {
+			if rrsig.Expiration < expiration {
+				expiration = rrsig.Expiration
+			}
+		}
+		cached.expires = time.Unix(int64(expiration)-util.DNSSECSignatureRefresh, 0)
+		s.mutex.Lock()
+		if len(s.signatures) >= util.DNSSECSignatureCacheSize {
+			s.signatures = make(map[string]*rrsetSignatures)
+		}
+		s.signatures[cacheKey] = cached
+		s.mutex.Unlock()
+	}
+
+	// Owner of the signatures follows the case of the answer
+	rrsigs := make([]dns.RR, 0, len(cached.rrsigs))
+	for _, rrsig := range cached.rrsigs {
+		rr := dns.Copy(rrsig)
+		rr.Header().Name = rrset[0].Header().Name
+		rrsigs = append(rrsigs, rr)
+	}
+
+	return rrsigs
+}
+
+// rrsetText get the records of the rrset in text without the owner, in a stable order.
+func rrsetText(rrset []dns.RR) string {
+	texts := make([]string, 0, len(rrset))
+	for _, rr := range rrset {
+		texts = append(texts, strings.TrimPrefix(rr.String(), rr.Header().Name))
+	}
+	sort.Strings(texts)
+
+	return strings.Join(texts, "\n")
+}
+
+// getZoneKeys get the keys of the most specific local zone of the name, nil if the zone is not signed.
+func (s *Signer) getZoneKeys(name string) *zoneKeys {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	if now.After(s.expires) {
+		zones, err := s.store.ListZones()
+		if err != nil {
+			log.Errorf("Failed to list the zones for signing. %s", err.Error())
+			return nil
+		}
+		s.zones, s.expires = zones, now.Add(util.DNSSECCacheTTL*time.Second)
+	}
+	zone := ""
+	for _, candidate := range s.zones {
+		if dns.IsSubDomain(candidate, name) && dns.CountLabel(candidate) >= dns.CountLabel(zone) {
+			zone = candidate
+		}
+	}
+	if len(zone) == 0 {
+		return nil
+	}
+
+	keys, ok := s.keys[zone]
+	if !ok || now.After(keys.expires) {
+		keys = &zoneKeys{zone: zone, expires: now.Add(util.DNSSECCacheTTL * time.Second)}
+		if stored, err := s.store.GetZoneKeys(zone); err == nil && len(stored) != 0 {
+			if keys.keys, err = parseSigningKeys(stored); err != nil {
+				log.Errorf("Failed to parse the keys of zone(%s). %s", zone, err.Error())
+			}
+		}
+		for _, key := range keys.keys {
+			keys.id += fmt.Sprintf("%s %t;", key.dnskey.String(), key.active)
+		}
+		s.keys[zone] = keys
+	}
+	if len(keys.keys) == 0 {
+		return nil
+	}
+
+	return keys
+}
+
+// getZoneNames get the owner names of the zone for the view, nil if not available.
+func (s *Signer) getZoneNames(view string, zone string) *zoneNames {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	cacheKey := view + "/" + zone
+	if names, ok := s.names[cacheKey]; ok && now.Before(names.expires) {
+		return names
+	}
+
+	types, err := s.store.WithView(view).GetZoneNames(zone)
+	if err != nil {
+		log.Errorf("Failed to read the names of zone(%s). %s", zone, err.Error())
+		return nil
+	}
+	names := newZoneNames(zone, types)
+	names.expires = now.Add(util.DNSSECCacheTTL * time.Second)
+	s.names[cacheKey] = names
+
+	return names
+}
+
+// sign get the signatures of the rrset by the active keys, DNSKEY rrset is signed by the key signing keys and the
+// rest by the zone signing keys. Key signing keys sign all when there is no active zone signing key. Wildcard owner
+// is the wildcard the rrset is synthesized from, empty if none.
+func (z *zoneKeys) sign(rrset []dns.RR, wildcardOwner string) []*dns.RRSIG {
+	keyType := KeyTypeZSK
+	if rrset[0].Header().Rrtype == dns.TypeDNSKEY || !z.hasActive(KeyTypeZSK) {
+		keyType = KeyTypeKSK
+	}
+	signRRSet := rrset
+	if len(wildcardOwner) != 0 {
+		signRRSet = make([]dns.RR, 0, len(rrset))
+		for _, rr := range rrset {
+			rr = dns.Copy(rr)
+			rr.Header().Name = wildcardOwner
+			signRRSet = append(signRRSet, rr)
+		}
+	}
+
+	now := time.Now().Unix()
+	var rrsigs []*dns.RRSIG
+	for _, key := range z.keys {
+		if !key.active || KeyType(key.dnskey) != keyType {
+			continue
+		}
+		rrsig := &dns.RRSIG{Hdr: dns.RR_Header{Ttl: rrset[0].Header().Ttl}, KeyTag: key.dnskey.KeyTag(),
+			SignerName: z.zone, Algorithm: key.dnskey.Algorithm,
+			Inception:  uint32(now - util.DNSSECSignatureInception),
+			Expiration: uint32(now + util.DNSSECSignatureValidity)}
+		if err := rrsig.Sign(key.private, signRRSet); err != nil {
+			log.Errorf("Failed to sign %s %s. %s", rrset[0].Header().Name,
+				dns.TypeToString[rrset[0].Header().Rrtype], err.Error())
+			continue
+		}
+		rrsig.Hdr.Name = rrset[0].Header().Name
+		rrsigs = append(rrsigs, rrsig)
+	}
+
+	return rrsigs
+}
+
+func (z *zoneKeys) hasActive(keyType string) bool {
+	for _, key := range z.keys {
+		if key.active && KeyType(key.dnskey) == keyType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitRRSets split the records into the rrsets, keeping the order.
+func splitRRSets(rrs []dns.RR) [][]dns.RR {
+	var rrsets [][]dns.RR
+	for _, rr := range rrs {
+		found := false
+		for i := range rrsets {
+			h := rrsets[i][0].Header()
+			if strings.EqualFold(h.Name, rr.Header().Name) && h.Rrtype == rr.Header().Rrtype &&
+				h.Class == rr.Header().Class {
+				rrsets[i] = append(rrsets[i], rr)
+				found = true
+				break
+			}
+		}
+		if !found {
+			rrsets = append(rrsets, []dns.RR{rr})
+		}
+	}
+
+	return rrsets
+}
+
+func newZoneNames(zone string, types map[string][]uint16) *zoneNames {
+	zone = strings.ToLower(zone)
+	if _, ok := types[zone]; !ok {
+		types[zone] = nil
+	}
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return canonicalLess(names[i], names[j])
+	})
+
+	return &zoneNames{zone: zone, names: names, types: types}
+}
+
+// index get the position of the name in the canonical order, where it would be inserted if not exists.
+func (z *zoneNames) index(name string) int {
+	return sort.Search(len(z.names), func(i int) bool {
+		return !canonicalLess(z.names[i], name)
+	})
+}
+
+// exists check the name exists with any record type or as an empty non-terminal.
+func (z *zoneNames) exists(name string) bool {
+	name = strings.ToLower(name)
+	if _, ok := z.types[name]; ok {
+		return true
+	}
+	// Descendants follow the name in the canonical order
+	i := z.index(name)
+
+	return i < len(z.names) && dns.IsSubDomain(name, z.names[i])
+}
+
+// closestEncloser get the longest existing ancestor of the name within the zone.
+func (z *zoneNames) closestEncloser(name string) string {
+	name = strings.ToLower(name)
+	for off, end := dns.NextLabel(name, 0); !end; off, end = dns.NextLabel(name, off) {
+		if ancestor := name[off:]; ancestor == z.zone || z.exists(ancestor) {
+			return ancestor
+		}
+	}
+
+	return z.zone
+}
+
+// wildcardOf get the wildcard the answer of the name is synthesized from, empty if the name exists.
+func (z *zoneNames) wildcardOf(name string) string {
+	if z.exists(name) {
+		return ""
+	}
+	wildcard := "*." + z.closestEncloser(name)
+	if _, ok := z.types[wildcard]; ok {
+		return wildcard
+	}
+
+	return ""
+}
+
+// nsec get the NSEC record of the existing name at the position.
+func (z *zoneNames) nsec(i int, ttl uint32) *dns.NSEC {
+	owner := z.names[i]
+	types := append([]uint16{dns.TypeRRSIG, dns.TypeNSEC}, z.types[owner]...)
+	if owner == z.zone {
+		types = append(types, dns.TypeDNSKEY)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	return &dns.NSEC{Hdr: dns.RR_Header{Name: owner, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: ttl},
+		NextDomain: z.names[(i+1)%len(z.names)], TypeBitMap: types}
+}
+
+// cover get the NSEC record covering the name which does not exist, the one of the previous name.
+func (z *zoneNames) cover(name string, ttl uint32) []*dns.NSEC {
+	i := z.index(strings.ToLower(name))
+	if i == 0 {
+		return nil
+	}
+
+	return []*dns.NSEC{z.nsec(i-1, ttl)}
+}
+
+// deny get the NSEC records proving the name or its record type does not exist: the NSEC of the name for NODATA,
+// otherwise the NSEC covering the name along with the NSEC of the wildcard of its closest encloser, or the one
+// covering the wildcard.
+func (z *zoneNames) deny(name string, ttl uint32) []*dns.NSEC {
+	name = strings.ToLower(name)
+	var indexes []int
+	if _, ok := z.types[name]; ok {
+		indexes = append(indexes, z.index(name))
+	} else {
+		indexes = append(indexes, z.index(name)-1)
+		if !z.exists(name) {
+			wildcard := "*." + z.closestEncloser(name)
+			if _, ok := z.types[wildcard]; ok {
+				indexes = append(indexes, z.index(wildcard))
+			} else {
+				indexes = append(indexes, z.index(wildcard)-1)
+			}
+		}
+	}
+
+	var nsecs []*dns.NSEC
+	for i, index := range indexes {
+		if index < 0 || (i > 0 && index == indexes[0]) {
+			continue
+		}
+		nsecs = append(nsecs, z.nsec(index, ttl))
+	}
+
+	return nsecs
+}
+
+// canonicalLess compare the names in the canonical order(RFC 4034), label by label from the root.
+func canonicalLess(a string, b string) bool {
+	la, lb := dns.SplitDomainName(strings.ToLower(a)), dns.SplitDomainName(strings.ToLower(b))
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		if x, y := la[len(la)-i], lb[len(lb)-i]; x != y {
+			return x < y
+		}
+	}
+
+	return len(la) < len(lb)
+}
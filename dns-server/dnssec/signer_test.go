@@ -0,0 +1,264 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package dnssec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+
+	"dns-server/datastore"
+)
+
+const (
+	testZone       = "example.com."
+	errorInSigning = "Error in signing"
+)
+
+// newSignedStore create a store with a signed zone, returning the zone signing and key signing keys.
+func newSignedStore(t *testing.T) (datastore.DataStore, *dns.DNSKEY, *dns.DNSKEY) {
+	store := &datastore.MemoryStore{}
+	assert.Nil(t, store.Open(), "Error in opening the store")
+	records := []datastore.ResourceRecord{
+		{Name: testZone, Type: "SOA", Class: "IN", TTL: 60,
+			RData: []string{"ns1.example.com. admin.example.com. 1 3600 600 86400 10"}},
+		{Name: "www.example.com.", Type: "A", Class: "IN", TTL: 30, RData: []string{"192.168.1.1"}},
+		{Name: "app.sub.example.com.", Type: "A", Class: "IN", TTL: 30, RData: []string{"192.168.1.2"}},
+		{Name: "*.wild.example.com.", Type: "A", Class: "IN", TTL: 30, RData: []string{"192.168.1.3"}},
+	}
+	assert.Nil(t, store.ImportZone(testZone, records), "Error in importing the zone")
+
+	ksk, err := GenerateKey(testZone, KeyTypeKSK, dns.ECDSAP256SHA256)
+	assert.Nil(t, err, "Error in generating the key")
+	zsk, err := GenerateKey(testZone, KeyTypeZSK, dns.ECDSAP256SHA256)
+	assert.Nil(t, err, "Error in generating the key")
+	assert.Nil(t, store.SetZoneKeys(testZone, []datastore.ZoneKey{*ksk, *zsk}), "Error in saving the keys")
+	kskKey, _ := ParseDNSKEY(ksk)
+	zskKey, _ := ParseDNSKEY(zsk)
+
+	return store, zskKey, kskKey
+}
+
+func newQuery(name string, qtype uint16, do bool) *dns.Msg {
+	req := new(dns.Msg)
+	req.SetQuestion(name, qtype)
+	if do {
+		req.SetEdns0(4096, true)
+	}
+
+	return req
+}
+
+// lookup answer the query from the store as the server does, signing the response.
+func lookup(t *testing.T, store datastore.DataStore, signer *Signer, req *dns.Msg) *dns.Msg {
+	response := new(dns.Msg)
+	response.SetReply(req)
+	rrs, err := store.GetResourceRecord(&req.Question[0])
+	if err != nil {
+		notFound, ok := err.(*datastore.NotFoundError)
+		assert.True(t, ok && notFound.Authority != nil, errorInSigning)
+		if !notFound.NameExists {
+			response.Rcode = dns.RcodeNameError
+		}
+		response.Ns = []dns.RR{notFound.Authority}
+	} else {
+		response.Answer = *rrs
+	}
+	signer.Sign("", req, response)
+
+	return response
+}
+
+// verify check the records of the section are signed by the key, returning the signed records by type.
+func verify(t *testing.T, rrs []dns.RR, key *dns.DNSKEY) map[uint16][]dns.RR {
+	signed := make(map[uint16][]dns.RR)
+	for _, rr := range rrs {
+		rrsig, ok := rr.(*dns.RRSIG)
+		if !ok || rrsig.KeyTag != key.KeyTag() {
+			continue
+		}
+		var covered []dns.RR
+		for _, rr := range rrs {
+			if rr.Header().Rrtype == rrsig.TypeCovered && rr.Header().Name == rrsig.Hdr.Name {
+				covered = append(covered, rr)
+			}
+		}
+		assert.Nil(t, rrsig.Verify(key, covered), errorInSigning)
+		assert.True(t, rrsig.ValidityPeriod(time.Now()), errorInSigning)
+		signed[rrsig.TypeCovered] = append(signed[rrsig.TypeCovered], covered...)
+	}
+
+	return signed
+}
+
+func TestSigner(t *testing.T) {
+	store, zsk, ksk := newSignedStore(t)
+	defer store.Close()
+	signer := New(store)
+
+	t.Run("PositiveAnswer", func(t *testing.T) {
+		response := lookup(t, store, signer, newQuery("www.example.com.", dns.TypeA, true))
+		assert.Equal(t, 2, len(response.Answer), errorInSigning)
+		assert.Equal(t, 1, len(verify(t, response.Answer, zsk)[dns.TypeA]), errorInSigning)
+		assert.True(t, response.IsEdns0().Do(), errorInSigning)
+	})
+
+	t.Run("WithoutDO", func(t *testing.T) {
+		response := lookup(t, store, signer, newQuery("www.example.com.", dns.TypeA, false))
+		assert.Equal(t, 1, len(response.Answer), errorInSigning)
+		assert.Nil(t, response.IsEdns0(), errorInSigning)
+	})
+
+	t.Run("DNSKEY", func(t *testing.T) {
+		assert.Nil(t, signer.DNSKEY("www.example.com."), errorInSigning)
+		req := newQuery(testZone, dns.TypeDNSKEY, true)
+		response := new(dns.Msg)
+		response.SetReply(req)
+		response.Answer = signer.DNSKEY(testZone)
+		signer.Sign("", req, response)
+		assert.Equal(t, 3, len(response.Answer), errorInSigning)
+		assert.Equal(t, 2, len(verify(t, response.Answer, ksk)[dns.TypeDNSKEY]), errorInSigning)
+	})
+
+	t.Run("WildcardAnswer", func(t *testing.T) {
+		response := lookup(t, store, signer, newQuery("host.wild.example.com.", dns.TypeA, true))
+		rrsig := response.Answer[1].(*dns.RRSIG)
+		assert.Equal(t, uint8(3), rrsig.Labels, errorInSigning)
+		assert.Equal(t, 1, len(verify(t, response.Answer, zsk)[dns.TypeA]), errorInSigning)
+		// No closer match exists
+		nsecs := verify(t, response.Ns, zsk)[dns.TypeNSEC]
+		assert.Equal(t, 1, len(nsecs), errorInSigning)
+		assert.Equal(t, "*.wild.example.com.", nsecs[0].Header().Name, errorInSigning)
+		assert.Equal(t, "www.example.com.", nsecs[0].(*dns.NSEC).NextDomain, errorInSigning)
+	})
+
+	t.Run("NameError", func(t *testing.T) {
+		response := lookup(t, store, signer, newQuery("zzz.example.com.", dns.TypeA, true))
+		assert.Equal(t, dns.RcodeNameError, response.Rcode, errorInSigning)
+		assert.Equal(t, 1, len(verify(t, response.Ns, zsk)[dns.TypeSOA]), errorInSigning)
+		nsecs := verify(t, response.Ns, zsk)[dns.TypeNSEC]
+		assert.Equal(t, 2, len(nsecs), errorInSigning)
+		// Name is covered by the last NSEC of the chain, and the wildcard of the apex by the first
+		assert.Equal(t, "www.example.com.", nsecs[0].Header().Name, errorInSigning)
+		assert.Equal(t, testZone, nsecs[0].(*dns.NSEC).NextDomain, errorInSigning)
+		assert.Equal(t, testZone, nsecs[1].Header().Name, errorInSigning)
+		assert.Equal(t, uint32(10), nsecs[0].Header().Ttl, errorInSigning)
+
+		// Same NSEC covers both the name and the wildcard
+		response = lookup(t, store, signer, newQuery("none.example.com.", dns.TypeA, true))
+		nsecs = verify(t, response.Ns, zsk)[dns.TypeNSEC]
+		assert.Equal(t, 1, len(nsecs), errorInSigning)
+		assert.Equal(t, "app.sub.example.com.", nsecs[0].(*dns.NSEC).NextDomain, errorInSigning)
+	})
+
+	t.Run("NoData", func(t *testing.T) {
+		response := lookup(t, store, signer, newQuery("www.example.com.", dns.TypeAAAA, true))
+		assert.Equal(t, dns.RcodeSuccess, response.Rcode, errorInSigning)
+		nsecs := verify(t, response.Ns, zsk)[dns.TypeNSEC]
+		assert.Equal(t, 1, len(nsecs), errorInSigning)
+		assert.Equal(t, "www.example.com.", nsecs[0].Header().Name, errorInSigning)
+		assert.Equal(t, []uint16{dns.TypeA, dns.TypeRRSIG, dns.TypeNSEC}, nsecs[0].(*dns.NSEC).TypeBitMap,
+			errorInSigning)
+	})
+
+	t.Run("EmptyNonTerminal", func(t *testing.T) {
+		response := lookup(t, store, signer, newQuery("sub.example.com.", dns.TypeA, true))
+		assert.Equal(t, dns.RcodeSuccess, response.Rcode, errorInSigning)
+		nsecs := verify(t, response.Ns, zsk)[dns.TypeNSEC]
+		assert.Equal(t, 1, len(nsecs), errorInSigning)
+		assert.Equal(t, testZone, nsecs[0].Header().Name, errorInSigning)
+		assert.Equal(t, "app.sub.example.com.", nsecs[0].(*dns.NSEC).NextDomain, errorInSigning)
+	})
+
+	t.Run("SignaturesCached", func(t *testing.T) {
+		// ECDSA signatures differ on each signing, the same one is the cached one
+		signature := func() string {
+			response := lookup(t, store, signer, newQuery("www.example.com.", dns.TypeA, true))
+			assert.Equal(t, 1, len(verify(t, response.Answer, zsk)[dns.TypeA]), errorInSigning)
+			return response.Answer[1].(*dns.RRSIG).Signature
+		}
+		cached := signature()
+		assert.Equal(t, cached, signature(), errorInSigning)
+
+		// Changed rrset is signed again
+		err := store.SetResourceRecord(testZone, &datastore.ResourceRecord{Name: "www.example.com.", Type: "A",
+			Class: "IN", TTL: 30, RData: []string{"192.168.1.4"}})
+		assert.Nil(t, err, "Error in setting the record")
+		changed := signature()
+		assert.NotEqual(t, cached, changed, errorInSigning)
+
+		signer.Invalidate()
+		assert.NotEqual(t, changed, signature(), errorInSigning)
+	})
+
+	t.Run("UnsignedZone", func(t *testing.T) {
+		assert.Nil(t, store.SetZoneKeys(testZone, nil), "Error in deleting the keys")
+		signer.Invalidate()
+		response := lookup(t, store, signer, newQuery("www.example.com.", dns.TypeA, true))
+		assert.Equal(t, 1, len(response.Answer), errorInSigning)
+		assert.Nil(t, signer.DNSKEY(testZone), errorInSigning)
+	})
+
+	t.Run("NilSigner", func(t *testing.T) {
+		var nilSigner *Signer
+		response := lookup(t, store, nilSigner, newQuery("www.example.com.", dns.TypeA, true))
+		assert.Equal(t, 1, len(response.Answer), errorInSigning)
+		assert.Nil(t, nilSigner.DNSKEY(testZone), errorInSigning)
+	})
+}
+
+func TestKeys(t *testing.T) {
+	t.Run("ParseAlgorithm", func(t *testing.T) {
+		algorithm, err := ParseAlgorithm("")
+		assert.Nil(t, err, "Error in parsing the algorithm")
+		assert.Equal(t, dns.ECDSAP256SHA256, algorithm, "Error in parsing the algorithm")
+		algorithm, err = ParseAlgorithm("ed25519")
+		assert.Nil(t, err, "Error in parsing the algorithm")
+		assert.Equal(t, dns.ED25519, algorithm, "Error in parsing the algorithm")
+		_, err = ParseAlgorithm("RSAMD5")
+		assert.EqualError(t, err, "unsupported algorithm(RSAMD5)", "Error in parsing the algorithm")
+	})
+
+	t.Run("DS", func(t *testing.T) {
+		ksk, _ := GenerateKey(testZone, KeyTypeKSK, dns.ED25519)
+		zsk, _ := GenerateKey(testZone, KeyTypeZSK, dns.ED25519)
+		records, err := DS([]datastore.ZoneKey{*ksk, *zsk}, dns.SHA256)
+		assert.Nil(t, err, "Error in generating the DS")
+		assert.Equal(t, 1, len(records), "Error in generating the DS")
+		dnskey, _ := ParseDNSKEY(ksk)
+		assert.Equal(t, dnskey.KeyTag(), records[0].(*dns.DS).KeyTag, "Error in generating the DS")
+		assert.Equal(t, testZone, records[0].Header().Name, "Error in generating the DS")
+
+		status, err := Status([]datastore.ZoneKey{*ksk, *zsk})
+		assert.Nil(t, err, "Error in reading the keys")
+		assert.Equal(t, KeyTypeKSK, status[0].Type, "Error in reading the keys")
+		assert.Equal(t, KeyTypeZSK, status[1].Type, "Error in reading the keys")
+		assert.Equal(t, "ED25519", status[1].Algorithm, "Error in reading the keys")
+	})
+
+	t.Run("CanonicalOrder", func(t *testing.T) {
+		names := []string{"example.", "a.example.", "yljkjljk.a.example.", "Z.a.example.", "zABC.a.EXAMPLE.",
+			"z.example.", "*.z.example."}
+		zoneNames := newZoneNames("example.", map[string][]uint16{})
+		for _, name := range names {
+			zoneNames.types[name] = nil
+		}
+		sorted := newZoneNames("example.", zoneNames.types).names
+		assert.Equal(t, names, sorted, "Error in ordering")
+	})
+}
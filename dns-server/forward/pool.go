@@ -0,0 +1,293 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package forward upstream dns servers used for forwarding the queries
+package forward
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	"dns-server/metrics"
+	"dns-server/util"
+)
+
+const (
+	// PolicyRoundRobin select the upstreams one after the other.
+	PolicyRoundRobin = "round_robin"
+	// PolicyFastest select the upstream with the lowest round trip time first.
+	PolicyFastest = "fastest"
+	// tlsScheme prefix of DNS-over-TLS upstream.
+	tlsScheme = "tls://"
+	// rttWeight smoothing factor of the round trip time, in percentage of the latest sample.
+	rttWeight = 30
+)
+
+// Upstream dns server configuration.
+type Upstream struct {
+	Address    string `json:"address"`
+	TLS        bool   `json:"tls"`
+	ServerName string `json:"serverName,omitempty"`
+}
+
+// UpstreamStatus upstream configuration along with its health.
+type UpstreamStatus struct {
+	Upstream
+	Failures uint32 `json:"failures"`
+	Ejected  bool   `json:"ejected"`
+	RTT      string `json:"rtt"`
+}
+
+type upstream struct {
+	Upstream
+	failures     uint32
+	ejectedUntil time.Time
+	rtt          time.Duration
+}
+
+// Pool upstream dns servers with health tracking.
+type Pool struct {
+	mutex     sync.Mutex
+	upstreams []*upstream
+	policy    string
+	next      int
+	timeout   time.Duration
+}
+
+// NewPool create an upstream pool with the selection policy.
+func NewPool(upstreams []Upstream, policy string, timeout time.Duration) (*Pool, error) {
+	p := &Pool{timeout: timeout}
+	if err := p.SetConfig(upstreams, policy); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// ParseUpstream parse the upstream in the format [tls://]ip[:port][#servername], default port is used when the
+// port is not specified, 853 for tls.
+func ParseUpstream(spec string, defaultPort uint) (*Upstream, error) {
+	u := &Upstream{}
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, tlsScheme) {
+		u.TLS = true
+		spec = strings.TrimPrefix(spec, tlsScheme)
+		defaultPort = util.DefaultDoTPort
+	}
+	if idx := strings.Index(spec, "#"); idx != -1 {
+		if !u.TLS {
+			return nil, fmt.Errorf("server name is supported only for tls forwarder")
+		}
+		u.ServerName = spec[idx+1:]
+		spec = spec[:idx]
+	}
+
+	host, port := spec, strconv.Itoa(int(defaultPort))
+	if h, p, err := net.SplitHostPort(spec); err == nil {
+		host, port = h, p
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("error: parsing forwarder failed, not in ipv4/ipv6 format")
+	}
+	if ip.IsMulticast() || ip.Equal(net.IPv4bcast) {
+		return nil, fmt.Errorf("error: multicast or broadcast ip address ")
+	}
+	portNo, err := strconv.Atoi(port)
+	if err != nil || portNo <= 0 || portNo > util.MaxPortNumber {
+		return nil, fmt.Errorf("error: forwarder port number not in valid range")
+	}
+	u.Address = net.JoinHostPort(ip.String(), port)
+
+	return u, nil
+}
+
+// SetConfig replace the upstreams and the selection policy, health of the retained upstreams are kept.
+func (p *Pool) SetConfig(upstreams []Upstream, policy string) error {
+	if policy != PolicyRoundRobin && policy != PolicyFastest {
+		return fmt.Errorf("unsupported forward policy(%s)", policy)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	existing := make(map[Upstream]*upstream)
+	for _, u := range p.upstreams {
+		existing[u.Upstream] = u
+	}
+	p.upstreams = make([]*upstream, 0, len(upstreams))
+	for _, u := range upstreams {
+		if old, ok := existing[u]; ok {
+			p.upstreams = append(p.upstreams, old)
+			continue
+		}
+		p.upstreams = append(p.upstreams, &upstream{Upstream: u})
+	}
+	p.policy = policy
+	p.next = 0
+
+	return nil
+}
+
+// Policy get the selection policy.
+func (p *Pool) Policy() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.policy
+}
+
+// Status get the upstreams along with the health status.
+func (p *Pool) Status() []UpstreamStatus {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	status := make([]UpstreamStatus, 0, len(p.upstreams))
+	for _, u := range p.upstreams {
+		status = append(status, UpstreamStatus{Upstream: u.Upstream, Failures: u.failures,
+			Ejected: now.Before(u.ejectedUntil), RTT: u.rtt.String()})
+	}
+
+	return status
+}
+
+// IsEmpty check any upstream is configured.
+func (p *Pool) IsEmpty() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return len(p.upstreams) == 0
+}
+
+// candidates get the upstreams in the order to try, ejected upstreams are used only if all are ejected.
+func (p *Pool) candidates() []*upstream {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if len(p.upstreams) == 0 {
+		return nil
+	}
+	now := time.Now()
+	var healthy []*upstream
+	start := p.next % len(p.upstreams)
+	p.next++
+	for i := range p.upstreams {
+		u := p.upstreams[(start+i)%len(p.upstreams)]
+		if now.Before(u.ejectedUntil) {
+			continue
+		}
+		healthy = append(healthy, u)
+	}
+	if len(healthy) == 0 {
+		healthy = append(healthy, p.upstreams...)
+	}
+	if p.policy == PolicyFastest {
+		// Upstreams without any measurement yet are tried first to learn their rtt
+		sort.SliceStable(healthy, func(i, j int) bool {
+			return healthy[i].rtt < healthy[j].rtt
+		})
+	}
+
+	return healthy
+}
+
+func (p *Pool) markSuccess(u *upstream, rtt time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	u.failures = 0
+	u.ejectedUntil = time.Time{}
+	if u.rtt == 0 {
+		u.rtt = rtt
+	} else {
+		u.rtt = (u.rtt*(100-rttWeight) + rtt*rttWeight) / 100
+	}
+}
+
+func (p *Pool) markFailure(u *upstream) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	u.failures++
+	if u.failures >= util.ForwarderMaxFails {
+		log.Infof("Forwarder %s ejected after %d failures.", u.Address, u.failures)
+		u.ejectedUntil = time.Now().Add(util.ForwarderEjectDuration * time.Second)
+	}
+}
+
+func (p *Pool) newClient(u *upstream, network string) *dns.Client {
+	c := &dns.Client{Net: network, Timeout: p.timeout}
+	if u.TLS {
+		c.Net = "tcp-tls"
+		serverName := u.ServerName
+		if len(serverName) == 0 {
+			serverName, _, _ = net.SplitHostPort(u.Address)
+		}
+		c.TLSConfig = &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+	}
+
+	return c
+}
+
+// exchangeWith send the request to the upstream, retrying over tcp when the udp response is truncated.
+func (p *Pool) exchangeWith(u *upstream, req *dns.Msg) (*dns.Msg, time.Duration, error) {
+	c := p.newClient(u, "udp")
+	ret, rtt, err := c.Exchange(req, u.Address)
+	if err == nil && ret != nil && ret.Truncated && c.Net == "udp" {
+		c = p.newClient(u, "tcp")
+		ret, rtt, err = c.Exchange(req, u.Address)
+	}
+	if err == nil && ret == nil {
+		err = fmt.Errorf("empty response")
+	}
+	metrics.ObserveForward(u.Address, rtt, err)
+
+	return ret, rtt, err
+}
+
+// Exchange forward the request to the upstreams as per the policy, success and name error responses are accepted.
+func (p *Pool) Exchange(req *dns.Msg) (*dns.Msg, error) {
+	candidates := p.candidates()
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("could not resolve the request %q and no forwarder is configured",
+			req.Question[0].Name)
+	}
+
+	// Exchange will not retry on failure, so retry with the next upstreams.
+	for i := 0; i < util.ForwardRetryCount; i++ {
+		u := candidates[i%len(candidates)]
+		ret, rtt, err := p.exchangeWith(u, req)
+		if err != nil {
+			p.markFailure(u)
+			continue
+		}
+		p.markSuccess(u, rtt)
+		if ret.Rcode == dns.RcodeSuccess || ret.Rcode == dns.RcodeNameError {
+			return ret, nil
+		}
+	}
+
+	return nil, fmt.Errorf("forward of request %q was not accepted", req.Question[0].Name)
+}
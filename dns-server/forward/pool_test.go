@@ -0,0 +1,169 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package forward
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/agiledragon/gomonkey"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	upstream1       = "192.168.1.1:53"
+	upstream2       = "192.168.1.2:53"
+	testDomain      = "www.example.com."
+	errorForwarding = "Error in forwarding"
+)
+
+func newTestRequest() *dns.Msg {
+	req := new(dns.Msg)
+	req.SetQuestion(testDomain, dns.TypeA)
+	return req
+}
+
+func TestParseUpstream(t *testing.T) {
+	u, err := ParseUpstream("192.168.1.1", 53)
+	assert.Equal(t, nil, err, "Error in parsing")
+	assert.Equal(t, Upstream{Address: upstream1}, *u, "Error in parsing")
+
+	u, err = ParseUpstream("192.168.1.1:5353", 53)
+	assert.Equal(t, nil, err, "Error in parsing")
+	assert.Equal(t, "192.168.1.1:5353", u.Address, "Error in parsing")
+
+	u, err = ParseUpstream("[2001:db8::1]:5353", 53)
+	assert.Equal(t, nil, err, "Error in parsing")
+	assert.Equal(t, "[2001:db8::1]:5353", u.Address, "Error in parsing")
+
+	u, err = ParseUpstream("tls://1.1.1.1#cloudflare-dns.com", 53)
+	assert.Equal(t, nil, err, "Error in parsing")
+	assert.Equal(t, Upstream{Address: "1.1.1.1:853", TLS: true, ServerName: "cloudflare-dns.com"}, *u,
+		"Error in parsing")
+
+	_, err = ParseUpstream("1.1.1.1#cloudflare-dns.com", 53)
+	assert.EqualError(t, err, "server name is supported only for tls forwarder", "Error in parsing")
+	_, err = ParseUpstream("127.0.0.256", 53)
+	assert.EqualError(t, err, "error: parsing forwarder failed, not in ipv4/ipv6 format", "Error in parsing")
+	_, err = ParseUpstream("224.0.0.1", 53)
+	assert.NotEqual(t, nil, err, "Error in parsing")
+	_, err = ParseUpstream("192.168.1.1:0", 53)
+	assert.EqualError(t, err, "error: forwarder port number not in valid range", "Error in parsing")
+}
+
+func TestPoolExchange(t *testing.T) {
+	var addresses []string
+	failing := map[string]bool{}
+	rtts := map[string]time.Duration{upstream1: 20 * time.Millisecond, upstream2: 10 * time.Millisecond}
+	var c *dns.Client
+	patch := gomonkey.ApplyMethod(reflect.TypeOf(c), "Exchange", func(client *dns.Client, m *dns.Msg,
+		address string) (r *dns.Msg, rtt time.Duration, err error) {
+		addresses = append(addresses, address)
+		if failing[address] {
+			return nil, 0, fmt.Errorf("i/o timeout")
+		}
+		rsp := new(dns.Msg)
+		rsp.SetRcode(m, dns.RcodeSuccess)
+		if m.Question[0].Name != testDomain {
+			rsp.SetRcode(m, dns.RcodeNameError)
+		}
+		return rsp, rtts[address], nil
+	})
+	defer patch.Reset()
+
+	upstreams := []Upstream{{Address: upstream1}, {Address: upstream2}}
+
+	t.Run("NoForwarder", func(t *testing.T) {
+		pool, _ := NewPool(nil, PolicyRoundRobin, time.Second)
+		_, err := pool.Exchange(newTestRequest())
+		assert.EqualError(t, err, "could not resolve the request \"www.example.com.\" and no forwarder is "+
+			"configured", errorForwarding)
+	})
+
+	t.Run("InvalidPolicy", func(t *testing.T) {
+		_, err := NewPool(upstreams, "random", time.Second)
+		assert.EqualError(t, err, "unsupported forward policy(random)", errorForwarding)
+	})
+
+	t.Run("RoundRobin", func(t *testing.T) {
+		addresses = nil
+		pool, _ := NewPool(upstreams, PolicyRoundRobin, time.Second)
+		for i := 0; i < 4; i++ {
+			_, err := pool.Exchange(newTestRequest())
+			assert.Equal(t, nil, err, errorForwarding)
+		}
+		assert.Equal(t, []string{upstream1, upstream2, upstream1, upstream2}, addresses, errorForwarding)
+	})
+
+	t.Run("FastestFirst", func(t *testing.T) {
+		pool, _ := NewPool(upstreams, PolicyFastest, time.Second)
+		// Learn the rtt of both
+		_, _ = pool.Exchange(newTestRequest())
+		_, _ = pool.Exchange(newTestRequest())
+		addresses = nil
+		for i := 0; i < 3; i++ {
+			_, _ = pool.Exchange(newTestRequest())
+		}
+		assert.Equal(t, []string{upstream2, upstream2, upstream2}, addresses, errorForwarding)
+	})
+
+	t.Run("NameErrorPassThrough", func(t *testing.T) {
+		pool, _ := NewPool(upstreams, PolicyRoundRobin, time.Second)
+		req := new(dns.Msg)
+		req.SetQuestion("nonexist.example.com.", dns.TypeA)
+		rsp, err := pool.Exchange(req)
+		assert.Equal(t, nil, err, errorForwarding)
+		assert.Equal(t, dns.RcodeNameError, rsp.Rcode, errorForwarding)
+	})
+
+	t.Run("FailoverAndEjection", func(t *testing.T) {
+		failing[upstream1] = true
+		defer delete(failing, upstream1)
+		pool, _ := NewPool(upstreams, PolicyRoundRobin, time.Second)
+		for i := 0; i < 6; i++ {
+			_, err := pool.Exchange(newTestRequest())
+			assert.Equal(t, nil, err, errorForwarding)
+		}
+		status := pool.Status()
+		assert.Equal(t, true, status[0].Ejected, errorForwarding)
+		assert.Equal(t, false, status[1].Ejected, errorForwarding)
+
+		// Ejected upstream is not tried anymore
+		addresses = nil
+		_, _ = pool.Exchange(newTestRequest())
+		_, _ = pool.Exchange(newTestRequest())
+		assert.Equal(t, []string{upstream2, upstream2}, addresses, errorForwarding)
+
+		// Health is retained when the configuration is updated
+		_ = pool.SetConfig(upstreams, PolicyFastest)
+		assert.Equal(t, true, pool.Status()[0].Ejected, errorForwarding)
+		assert.Equal(t, PolicyFastest, pool.Policy(), errorForwarding)
+	})
+
+	t.Run("AllFailed", func(t *testing.T) {
+		failing[upstream1] = true
+		failing[upstream2] = true
+		defer delete(failing, upstream1)
+		defer delete(failing, upstream2)
+		pool, _ := NewPool(upstreams, PolicyRoundRobin, time.Second)
+		_, err := pool.Exchange(newTestRequest())
+		assert.EqualError(t, err, "forward of request \"www.example.com.\" was not accepted", errorForwarding)
+	})
+}
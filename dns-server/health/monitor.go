@@ -0,0 +1,214 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package health probes of the record addresses, used to drop the unhealthy addresses from the answers
+package health
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"dns-server/util"
+)
+
+const (
+	// ProtocolTCP probe by connecting to the port.
+	ProtocolTCP = "tcp"
+	// ProtocolHTTP probe by http GET of the path, any status below 400 is healthy.
+	ProtocolHTTP = "http"
+)
+
+// Target address probed along with the probe method.
+type Target struct {
+	Protocol string
+	Address  string // ip:port
+	Path     string
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// TargetStatus target along with its health.
+type TargetStatus struct {
+	Protocol  string `json:"protocol"`
+	Address   string `json:"address"`
+	Path      string `json:"path,omitempty"`
+	Healthy   bool   `json:"healthy"`
+	Failures  uint32 `json:"failures"`
+	LastProbe string `json:"lastProbe,omitempty"`
+}
+
+type target struct {
+	Target
+	healthy   bool
+	failures  uint32
+	lastProbe time.Time
+	lastUsed  time.Time
+}
+
+// Monitor probes the targets in the background. A target is probed from its first health query until it is not
+// queried for the idle timeout, so only the addresses still in use are probed.
+type Monitor struct {
+	mutex       sync.Mutex
+	targets     map[Target]*target
+	idleTimeout time.Duration
+	done        chan struct{}
+	stopOnce    sync.Once
+}
+
+// New create a health monitor without any target.
+func New() *Monitor {
+	return &Monitor{
+		targets:     make(map[Target]*target),
+		idleTimeout: util.HealthCheckIdleTimeout * time.Second,
+		done:        make(chan struct{}),
+	}
+}
+
+// IsHealthy get the health of the target, starting to probe it if not yet. A target is healthy until the probes
+// fail, and always with no monitor.
+func (m *Monitor) IsHealthy(t Target) bool {
+	if m == nil {
+		return true
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	tg, ok := m.targets[t]
+	if !ok {
+		select {
+		case <-m.done:
+			return true
+		default:
+		}
+		tg = &target{Target: t, healthy: true}
+		m.targets[t] = tg
+		go m.run(tg)
+	}
+	tg.lastUsed = time.Now()
+
+	return tg.healthy
+}
+
+// Status get the targets with their health, ordered by address.
+func (m *Monitor) Status() []TargetStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	status := make([]TargetStatus, 0, len(m.targets))
+	for _, tg := range m.targets {
+		s := TargetStatus{Protocol: tg.Protocol, Address: tg.Address, Path: tg.Path, Healthy: tg.healthy,
+			Failures: tg.failures}
+		if !tg.lastProbe.IsZero() {
+			s.LastProbe = tg.lastProbe.Format(time.RFC3339)
+		}
+		status = append(status, s)
+	}
+	sort.Slice(status, func(i, j int) bool {
+		if status[i].Address != status[j].Address {
+			return status[i].Address < status[j].Address
+		}
+		return status[i].Protocol+status[i].Path < status[j].Protocol+status[j].Path
+	})
+
+	return status
+}
+
+// Stop stop probing all the targets.
+func (m *Monitor) Stop() {
+	if m == nil {
+		return
+	}
+	m.stopOnce.Do(func() {
+		close(m.done)
+	})
+}
+
+// run probe the target every interval until it is idle or the monitor is stopped.
+func (m *Monitor) run(tg *target) {
+	ticker := time.NewTicker(tg.Interval)
+	defer ticker.Stop()
+	for {
+		if !m.update(tg, probe(&tg.Target)) {
+			return
+		}
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// update record the probe result of the target, false when the target is idle and not probed anymore.
+func (m *Monitor) update(tg *target, err error) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if time.Since(tg.lastUsed) > m.idleTimeout {
+		delete(m.targets, tg.Target)
+		log.Debugf("Stopped probing idle target(%s %s).", tg.Protocol, tg.Address)
+		return false
+	}
+	tg.lastProbe = time.Now()
+	if err != nil {
+		tg.failures++
+		if tg.healthy && tg.failures >= util.HealthCheckMaxFails {
+			tg.healthy = false
+			log.Warnf("Target(%s %s) is unhealthy. %s", tg.Protocol, tg.Address, err.Error())
+		}
+		return true
+	}
+	if !tg.healthy {
+		log.Infof("Target(%s %s) recovered.", tg.Protocol, tg.Address)
+	}
+	tg.healthy, tg.failures = true, 0
+
+	return true
+}
+
+// probe check the target once.
+func probe(t *Target) error {
+	if t.Protocol != ProtocolHTTP {
+		conn, err := net.DialTimeout("tcp", t.Address, t.Timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	client := &http.Client{
+		Timeout: t.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	rsp, err := client.Get("http://" + t.Address + t.Path)
+	if err != nil {
+		return err
+	}
+	_ = rsp.Body.Close()
+	if rsp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("http status %d", rsp.StatusCode)
+	}
+
+	return nil
+}
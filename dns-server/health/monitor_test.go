@@ -0,0 +1,119 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package health
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const errorInHealth = "Error in health check"
+
+// waitHealth wait until the target health is as expected.
+func waitHealth(m *Monitor, target Target, healthy bool) bool {
+	for i := 0; i < 100; i++ {
+		if m.IsHealthy(target) == healthy {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+func TestProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen on tcp port. %s", err.Error())
+	}
+	address := listener.Addr().String()
+	err = probe(&Target{Protocol: ProtocolTCP, Address: address, Timeout: time.Second})
+	assert.Equal(t, nil, err, errorInHealth)
+	_ = listener.Close()
+	err = probe(&Target{Protocol: ProtocolTCP, Address: address, Timeout: time.Second})
+	assert.NotEqual(t, nil, err, errorInHealth)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		http.Redirect(w, r, "/down", http.StatusFound)
+	}))
+	defer server.Close()
+	address = strings.TrimPrefix(server.URL, "http://")
+	// Redirect is not followed
+	err = probe(&Target{Protocol: ProtocolHTTP, Address: address, Path: "/up", Timeout: time.Second})
+	assert.Equal(t, nil, err, errorInHealth)
+	err = probe(&Target{Protocol: ProtocolHTTP, Address: address, Path: "/down", Timeout: time.Second})
+	assert.EqualError(t, err, "http status 503", errorInHealth)
+}
+
+func TestMonitor(t *testing.T) {
+	var unhealthy int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&unhealthy) != 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	m := New()
+	defer m.Stop()
+	target := Target{Protocol: ProtocolHTTP, Address: strings.TrimPrefix(server.URL, "http://"), Path: "/health",
+		Interval: 10 * time.Millisecond, Timeout: time.Second}
+
+	t.Run("HealthyUntilProbed", func(t *testing.T) {
+		assert.Equal(t, true, m.IsHealthy(target), errorInHealth)
+		status := m.Status()
+		assert.Equal(t, 1, len(status), errorInHealth)
+		assert.Equal(t, "/health", status[0].Path, errorInHealth)
+	})
+
+	t.Run("UnhealthyAndRecovered", func(t *testing.T) {
+		atomic.StoreInt32(&unhealthy, 1)
+		assert.Equal(t, true, waitHealth(m, target, false), errorInHealth)
+		atomic.StoreInt32(&unhealthy, 0)
+		assert.Equal(t, true, waitHealth(m, target, true), errorInHealth)
+	})
+
+	t.Run("IdleTargetRemoved", func(t *testing.T) {
+		m.mutex.Lock()
+		m.idleTimeout = 0
+		m.mutex.Unlock()
+		for i := 0; i < 100 && len(m.Status()) != 0; i++ {
+			time.Sleep(20 * time.Millisecond)
+		}
+		assert.Equal(t, 0, len(m.Status()), errorInHealth)
+	})
+
+	t.Run("Stopped", func(t *testing.T) {
+		m.Stop()
+		assert.Equal(t, true, m.IsHealthy(target), errorInHealth)
+		assert.Equal(t, 0, len(m.Status()), errorInHealth)
+
+		var monitor *Monitor
+		assert.Equal(t, true, monitor.IsHealthy(target), errorInHealth)
+		monitor.Stop()
+	})
+}
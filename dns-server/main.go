@@ -25,11 +25,20 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
+	"dns-server/cache"
 	"dns-server/datastore"
+	"dns-server/dnssec"
+	"dns-server/forward"
+	"dns-server/health"
+	"dns-server/metrics"
 	"dns-server/mgmt"
+	"dns-server/notify"
+	"dns-server/querylog"
+	"dns-server/ratelimit"
 	"dns-server/util"
 )
 
@@ -41,8 +50,24 @@ type InputParameters struct {
 	connTimeOut     *uint   // connection time out value
 	ipAddString     *string // dns listening ip
 	ipMgmtAddString *string // management interface listening ip
-	forwarder       *string // forwarder ip address
+	forwarder       *string // forwarder ip address list
 	loadBalance     *bool   // need load balancing?
+	forwarderPort   *uint   // forwarder port number
+	forwardPolicy   *string // forwarder selection policy
+	cacheSize       *uint   // forward response cache size
+	transferACL     *string // clients allowed to transfer the zones
+	secondaries     *string // secondaries to notify of the zone changes
+	tsigKeys        *string // tsig keys allowed to update the zones
+	queryLog        *string // query log destination
+	queryLogFormat  *string // query log format
+	queryLogRate    *uint   // query log entries per second before sampling
+	rateLimit       *uint   // responses per second per client prefix and query name
+	rateLimitSlip   *uint   // ratio of the rate limited responses truncated instead of dropped
+	rateLimitWL     *string // clients not rate limited
+	store           *string // data store backend
+	etcdEndpoints   *string // etcd endpoints of the etcd data store
+	etcdPrefix      *string // prefix of the data store keys in etcd
+	configFile      *string // config file of the settings reloaded on SIGHUP
 }
 
 const invalidMulticastErr = "error: multicast or broadcast ip address "
@@ -55,6 +80,15 @@ func registerInputParameters(inParam *InputParameters) {
 		return
 	}
 	inParam.dbName = flag.String("db", "dbEgDns", "Database name")
+	inParam.store = flag.String("store", datastore.StoreBoltDB,
+		"Data store backend(boltdb/memory/etcd), records of the memory store are lost on restart")
+	inParam.etcdEndpoints = flag.String("etcdEndpoints", "",
+		"Comma separated etcd endpoints in http[s]://ip:port format, for the etcd data store")
+	inParam.etcdPrefix = flag.String("etcdPrefix", util.DefaultEtcdPrefix,
+		"Prefix of the data store keys in etcd, dns servers sharing the prefix share the records")
+	inParam.configFile = flag.String("config", "",
+		"Json config file of the forwarder, forwarderPort, forwardPolicy and loadBalance settings, overriding "+
+			"the command line and re-read on SIGHUP, replacing the forwarders set through the management API")
 	inParam.port = flag.Uint("port", util.DefaultDNSPort, "Port number to listens to")
 	inParam.mgmtPort = flag.Uint("managementPort", util.DefaultManagementPort,
 		"Management interface port number to listens to")
@@ -63,8 +97,33 @@ func registerInputParameters(inParam *InputParameters) {
 	inParam.ipAddString = flag.String("ipAdd", util.DefaultIP, "Ipv4/Ipv6 address to listens to")
 	inParam.ipMgmtAddString = flag.String("managementIpAdd", util.DefaultIP,
 		"Management Ipv4/Ipv6 address to listens to")
-	inParam.forwarder = flag.String("forwarder", util.DefaultIP, "Forwarder")
+	inParam.forwarder = flag.String("forwarder", util.DefaultIP,
+		"Comma separated forwarders in [tls://]ip[:port][#servername] format")
 	inParam.loadBalance = flag.Bool("loadBalance", false, "Load balance using random shuffle")
+	inParam.forwarderPort = flag.Uint("forwarderPort", util.DefaultForwarderPort,
+		"Forwarder port number, if not specified along with the forwarder")
+	inParam.forwardPolicy = flag.String("forwardPolicy", forward.PolicyRoundRobin,
+		"Forwarder selection policy(round_robin/fastest)")
+	inParam.cacheSize = flag.Uint("cacheSize", util.DefaultCacheSize,
+		"Number of forwarded responses to cache, 0 to disable the cache")
+	inParam.transferACL = flag.String("transferACL", "",
+		"Comma separated ip/cidr of the clients allowed to transfer the zones, none if not specified")
+	inParam.secondaries = flag.String("secondaries", "",
+		"Comma separated secondaries in ip[:port] format to notify of the zone changes")
+	inParam.tsigKeys = flag.String("tsigKeys", "",
+		"Comma separated TSIG keys allowed to update the zones in zone:name:algorithm:secret format, "+
+			"algorithm is one of hmac-sha1/hmac-sha256/hmac-sha512 and secret is base64 encoded")
+	inParam.queryLog = flag.String("queryLog", "",
+		"Query log file path, or unix:path of a socket to write to, query logging is disabled if not specified")
+	inParam.queryLogFormat = flag.String("queryLogFormat", querylog.FormatJSON, "Query log format(json/dnstap)")
+	inParam.queryLogRate = flag.Uint("queryLogRate", 0,
+		"Query log entries per second, the queries are sampled above the rate, 0 to log all the queries")
+	inParam.rateLimit = flag.Uint("rateLimit", 0,
+		"Udp responses per second per client prefix and query name, 0 to disable the rate limit")
+	inParam.rateLimitSlip = flag.Uint("rateLimitSlip", util.DefaultRateLimitSlip,
+		"Every n-th rate limited response is truncated instead of dropped(0~10), 0 to drop all")
+	inParam.rateLimitWL = flag.String("rateLimitWhitelist", "",
+		"Comma separated ip/cidr of the clients not rate limited")
 
 	flag.Parse()
 }
@@ -82,6 +141,29 @@ func validateInputAndGenerateConfig(inParam *InputParameters) *Config {
 		log.Fatalf("Failed to parse db name(%s). %s", *inParam.dbName, err.Error())
 	}
 
+	// Validate data store
+	var etcdEndpoints []string
+	switch *inParam.store {
+	case datastore.StoreBoltDB, datastore.StoreMemory:
+	case datastore.StoreEtcd:
+		for _, endpoint := range strings.Split(*inParam.etcdEndpoints, ",") {
+			if endpoint = strings.TrimSpace(endpoint); len(endpoint) != 0 {
+				etcdEndpoints = append(etcdEndpoints, endpoint)
+			}
+		}
+		if len(etcdEndpoints) == 0 {
+			err := fmt.Errorf("error: etcd endpoints are required for the etcd data store")
+			log.Fatalf("Failed to parse etcd endpoints(%s).", err.Error())
+		}
+		if len(*inParam.etcdPrefix) == 0 {
+			err := fmt.Errorf("error: etcd prefix should not be empty")
+			log.Fatalf("Failed to parse etcd prefix(%s).", err.Error())
+		}
+	default:
+		err := fmt.Errorf("error: unsupported data store")
+		log.Fatalf("Failed to parse data store(%s). %s", *inParam.store, err.Error())
+	}
+
 	// Validate DNS port range
 	if *inParam.port > util.MaxPortNumber || *inParam.port == 0 {
 		err := fmt.Errorf("error: port number not in valid range")
@@ -128,38 +210,137 @@ func validateInputAndGenerateConfig(inParam *InputParameters) *Config {
 		log.Fatalf(multicastBroadcastIpErr, *inParam.ipMgmtAddString, err.Error())
 	}
 
-	// Validate forwarder
-	forwarderAdd := net.ParseIP(*inParam.forwarder)
-	if forwarderAdd == nil {
-		err := fmt.Errorf("error: parsing forwarder failed, not in ipv4/ipv6 format")
-		log.Fatalf("Failed to parse forwarder address(%s). %s", *inParam.forwarder, err.Error())
+	// Validate forwarder port range
+	if *inParam.forwarderPort > util.MaxPortNumber || *inParam.forwarderPort == 0 {
+		err := fmt.Errorf("error: forwarder port number not in valid range")
+		log.Fatalf("Failed to parse forwarder port number(%s).", err.Error())
 	}
 
-	if forwarderAdd != nil && (forwarderAdd.IsMulticast() || forwarderAdd.Equal(net.IPv4bcast)) {
-		err := fmt.Errorf(invalidMulticastErr)
-		log.Fatalf(multicastBroadcastIpErr, *inParam.forwarder, err.Error())
+	// Read the reloadable settings, the config file overrides the command line
+	flagSettings := reloadSettings{Forwarder: *inParam.forwarder, ForwarderPort: *inParam.forwarderPort,
+		ForwardPolicy: *inParam.forwardPolicy, LoadBalance: *inParam.loadBalance}
+	settings, err := readReloadSettings(*inParam.configFile, flagSettings)
+	if err != nil {
+		log.Fatalf("Failed to read config file(%s). %s", *inParam.configFile, err.Error())
+	}
+
+	// Validate forwarders
+	upstreams, err := parseForwarders(settings.Forwarder, settings.ForwarderPort)
+	if err != nil {
+		log.Fatalf("Failed to parse forwarders(%s). %s", settings.Forwarder, err.Error())
+	}
+
+	forwarders, err := forward.NewPool(upstreams, settings.ForwardPolicy,
+		time.Duration(*inParam.connTimeOut)*time.Second)
+	if err != nil {
+		log.Fatalf("Failed to parse forward policy(%s). %s", settings.ForwardPolicy, err.Error())
+	}
+
+	// Validate cache size
+	if *inParam.cacheSize > util.MaxCacheSize {
+		err := fmt.Errorf("error: cache size not in valid range(0~%d)", util.MaxCacheSize)
+		log.Fatalf("Failed to parse cache size(%s).", err.Error())
+	}
+
+	// Validate zone transfer acl
+	transferACL, err := parseACL(*inParam.transferACL)
+	if err != nil {
+		log.Fatalf("Failed to parse transfer acl(%s). %s", *inParam.transferACL, err.Error())
+	}
+
+	// Validate secondaries
+	var secondaries []string
+	for _, secondaryAdd := range strings.Split(*inParam.secondaries, ",") {
+		if len(strings.TrimSpace(secondaryAdd)) == 0 {
+			continue
+		}
+		secondary, err := notify.ParseSecondary(secondaryAdd)
+		if err != nil {
+			log.Fatalf("Failed to parse secondary address(%s). %s", secondaryAdd, err.Error())
+		}
+		secondaries = append(secondaries, secondary)
+	}
+
+	// Validate dynamic update keys
+	tsigKeys, err := parseTSIGKeys(*inParam.tsigKeys)
+	if err != nil {
+		log.Fatalf("Failed to parse tsig keys. %s", err.Error())
+	}
+
+	// Validate query log
+	var queryLog *querylog.Logger
+	if len(*inParam.queryLog) != 0 {
+		queryLog, err = querylog.New(*inParam.queryLog, *inParam.queryLogFormat, *inParam.queryLogRate)
+		if err != nil {
+			log.Fatalf("Failed to open query log(%s). %s", *inParam.queryLog, err.Error())
+		}
+	}
+
+	// Validate rate limit
+	if *inParam.rateLimitSlip > util.MaxRateLimitSlip {
+		err := fmt.Errorf("error: rate limit slip not in valid range(0~10)")
+		log.Fatalf("Failed to parse rate limit slip(%s).", err.Error())
+	}
+	rateLimitWL, err := parseACL(*inParam.rateLimitWL)
+	if err != nil {
+		log.Fatalf("Failed to parse rate limit whitelist(%s). %s", *inParam.rateLimitWL, err.Error())
+	}
+	var rateLimiter *ratelimit.Limiter
+	if *inParam.rateLimit != 0 {
+		rateLimiter = ratelimit.New(*inParam.rateLimit, *inParam.rateLimitSlip, rateLimitWL)
 	}
 
 	return &Config{dbName: *inParam.dbName,
+		store:             *inParam.store,
+		etcdEndpoints:     etcdEndpoints,
+		etcdPrefix:        *inParam.etcdPrefix,
+		configFile:        *inParam.configFile,
+		flagSettings:      flagSettings,
 		port:              *inParam.port,
 		mgmtPort:          *inParam.mgmtPort,
 		ipAdd:             ipAdd,
 		ipMgmtAdd:         ipMgmtAdd,
 		connectionTimeout: *inParam.connTimeOut,
-		forwarder:         forwarderAdd,
-		loadBalance:       *inParam.loadBalance,
+		forwarders:        forwarders,
+		cache:             cache.New(int(*inParam.cacheSize)),
+		loadBalance:       settings.LoadBalance,
+		transferACL:       transferACL,
+		notifier:          notify.New(secondaries, time.Duration(*inParam.connTimeOut)*time.Second),
+		tsigKeys:          tsigKeys,
+		monitor:           health.New(),
+		queryLog:          queryLog,
+		rateLimiter:       rateLimiter,
+	}
+}
+
+// newDataStore create the data store of the configured backend.
+func newDataStore(config *Config) datastore.DataStore {
+	switch config.store {
+	case datastore.StoreMemory:
+		return &datastore.MemoryStore{LoadBalance: config.loadBalance, Health: config.monitor}
+	case datastore.StoreEtcd:
+		return &datastore.EtcdStore{Endpoints: config.etcdEndpoints, Prefix: config.etcdPrefix,
+			LoadBalance: config.loadBalance, Health: config.monitor}
+	default:
+		return &datastore.BoltDB{FileName: config.dbName, TTL: util.DefaultTTL, LoadBalance: config.loadBalance,
+			Health: config.monitor}
 	}
 }
 
-func waitForSignal() {
+// waitForSignal wait for the signal to stop the dns server, reloading the settings on SIGHUP meanwhile.
+func waitForSignal(reload func() error) {
 	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
-	for {
-		select {
-		case s := <-sig:
-			log.Infof("Signal(%d) received, stopping dns server\n", s)
-			os.Exit(0)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sig)
+	for s := range sig {
+		if s == syscall.SIGHUP {
+			if err := reload(); err != nil {
+				log.Errorf("Failed to reload the settings. %s", err.Error())
+			}
+			continue
 		}
+		log.Infof("Signal(%d) received, stopping dns server", s)
+		return
 	}
 }
 
@@ -171,9 +352,14 @@ func main() {
 	registerInputParameters(inputParam)
 
 	config := validateInputAndGenerateConfig(inputParam)
+	if err := metrics.RegisterCache(config.cache); err != nil {
+		log.Errorf("Failed to register the cache metrics. %s", err.Error())
+	}
 
-	store := &datastore.BoltDB{FileName: config.dbName, TTL: util.DefaultTTL}
-	mgmtCtl := &mgmt.Controller{}
+	store := newDataStore(config)
+	config.signer = dnssec.New(store)
+	mgmtCtl := &mgmt.Controller{Forwarders: config.forwarders, Cache: config.cache, Notifier: config.notifier,
+		Health: config.monitor, RateLimiter: config.rateLimiter, Signer: config.signer}
 	dnsServer := NewServer(config, store, mgmtCtl)
 
 	defer dnsServer.Stop()
@@ -182,5 +368,5 @@ func main() {
 	}
 
 	log.Info("DNS server started successfully.")
-	waitForSignal()
+	waitForSignal(dnsServer.Reload)
 }
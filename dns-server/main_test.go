@@ -26,7 +26,9 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"dns-server/datastore"
+	"dns-server/forward"
 	"dns-server/mgmt"
+	"dns-server/querylog"
 	"dns-server/util"
 )
 
@@ -38,6 +40,22 @@ var ipAddString = util.DefaultIP
 var ipMgmtAddString = util.DefaultIP
 var forwarder = util.DefaultIP
 var loadBalance = false
+var forwarderPort uint = util.DefaultForwarderPort
+var forwardPolicy = forward.PolicyRoundRobin
+var cacheSize uint = util.DefaultCacheSize
+var transferACL = ""
+var secondaries = ""
+var tsigKeys = ""
+var queryLog = ""
+var queryLogFormat = querylog.FormatJSON
+var queryLogRate uint = 0
+var rateLimit uint = 0
+var rateLimitSlip uint = util.DefaultRateLimitSlip
+var rateLimitWL = ""
+var store = datastore.StoreBoltDB
+var etcdEndpoints = ""
+var etcdPrefix = util.DefaultEtcdPrefix
+var configFile = ""
 var ePanic = "Panic expected"
 var eError = "Error expected"
 var panicProblem = "a problem"
@@ -71,7 +89,7 @@ func TestMainDnsServer(t *testing.T) {
 	})
 	defer patch3.Reset()
 
-	patch4 := gomonkey.ApplyFunc(waitForSignal, func() { // Empty Impl
+	patch4 := gomonkey.ApplyFunc(waitForSignal, func(reload func() error) { // Empty Impl
 	})
 	defer patch4.Reset()
 
@@ -93,7 +111,10 @@ func TestMainDnsServer(t *testing.T) {
 		}()
 		var invalidPortNo uint = 0
 		parameters := InputParameters{&dbName, &port, &invalidPortNo, &connTimeOut,
-			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance}
+			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -104,6 +125,22 @@ func TestMainDnsServer(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -122,7 +159,10 @@ func TestMainDnsServer(t *testing.T) {
 		}()
 		var invalidPortNo uint = 65536
 		parameters := InputParameters{&dbName, &port, &invalidPortNo, &connTimeOut,
-			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance}
+			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -133,6 +173,22 @@ func TestMainDnsServer(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -169,7 +225,7 @@ func TestMainDnsServer1(t *testing.T) {
 	})
 	defer patch3.Reset()
 
-	patch4 := gomonkey.ApplyFunc(waitForSignal, func() { // Empty Impl
+	patch4 := gomonkey.ApplyFunc(waitForSignal, func(reload func() error) { // Empty Impl
 	})
 	defer patch4.Reset()
 
@@ -191,7 +247,10 @@ func TestMainDnsServer1(t *testing.T) {
 		}()
 		var invalidIpAdd = "127.0.0.256"
 		parameters := InputParameters{&dbName, &port, &mgmtPort, &connTimeOut,
-			&ipAddString, &ipMgmtAddString, &invalidIpAdd, &loadBalance}
+			&ipAddString, &ipMgmtAddString, &invalidIpAdd, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -202,6 +261,22 @@ func TestMainDnsServer1(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -220,7 +295,10 @@ func TestMainDnsServer1(t *testing.T) {
 			}
 		}()
 		parameters := InputParameters{&dbName, &port, &port, &connTimeOut,
-			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance}
+			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -231,6 +309,22 @@ func TestMainDnsServer1(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -267,7 +361,7 @@ func TestMainDnsServer2(t *testing.T) {
 	})
 	defer patch3.Reset()
 
-	patch4 := gomonkey.ApplyFunc(waitForSignal, func() { // Empty Impl
+	patch4 := gomonkey.ApplyFunc(waitForSignal, func(reload func() error) { // Empty Impl
 	})
 	defer patch4.Reset()
 
@@ -290,7 +384,10 @@ func TestMainDnsServer2(t *testing.T) {
 
 		var invalidDbName = "test.db"
 		parameters := InputParameters{&invalidDbName, &port, &mgmtPort, &connTimeOut,
-			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance}
+			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -301,6 +398,22 @@ func TestMainDnsServer2(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -320,7 +433,10 @@ func TestMainDnsServer2(t *testing.T) {
 		}()
 		var invalidIpAdd = "127.0.0.256"
 		parameters := InputParameters{&dbName, &port, &mgmtPort, &connTimeOut,
-			&ipAddString, &invalidIpAdd, &forwarder, &loadBalance}
+			&ipAddString, &invalidIpAdd, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -331,6 +447,22 @@ func TestMainDnsServer2(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -367,7 +499,7 @@ func TestMainDnsServer3(t *testing.T) {
 	})
 	defer patch3.Reset()
 
-	patch4 := gomonkey.ApplyFunc(waitForSignal, func() { // Empty Impl
+	patch4 := gomonkey.ApplyFunc(waitForSignal, func(reload func() error) { // Empty Impl
 	})
 	defer patch4.Reset()
 
@@ -389,7 +521,10 @@ func TestMainDnsServer3(t *testing.T) {
 		}()
 		var invalidIpAdd = "128.15.47.299"
 		parameters := InputParameters{&dbName, &port, &mgmtPort, &connTimeOut,
-			&invalidIpAdd, &ipMgmtAddString, &forwarder, &loadBalance}
+			&invalidIpAdd, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -400,6 +535,22 @@ func TestMainDnsServer3(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -418,7 +569,10 @@ func TestMainDnsServer3(t *testing.T) {
 		}()
 		var invalidIpAdd = "1::2lkh"
 		parameters := InputParameters{&dbName, &port, &mgmtPort, &connTimeOut,
-			&invalidIpAdd, &ipMgmtAddString, &forwarder, &loadBalance}
+			&invalidIpAdd, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -429,6 +583,22 @@ func TestMainDnsServer3(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -465,7 +635,7 @@ func TestMainDnsServer4(t *testing.T) {
 	})
 	defer patch3.Reset()
 
-	patch4 := gomonkey.ApplyFunc(waitForSignal, func() { // Empty Impl
+	patch4 := gomonkey.ApplyFunc(waitForSignal, func(reload func() error) { // Empty Impl
 	})
 	defer patch4.Reset()
 
@@ -487,7 +657,10 @@ func TestMainDnsServer4(t *testing.T) {
 		}()
 		var invalidIpAdd = ""
 		parameters := InputParameters{&dbName, &port, &mgmtPort, &connTimeOut,
-			&invalidIpAdd, &ipMgmtAddString, &forwarder, &loadBalance}
+			&invalidIpAdd, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -498,6 +671,22 @@ func TestMainDnsServer4(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -516,7 +705,10 @@ func TestMainDnsServer4(t *testing.T) {
 		}()
 		var invalidIpAdd = "a"
 		parameters := InputParameters{&dbName, &port, &mgmtPort, &connTimeOut,
-			&invalidIpAdd, &ipMgmtAddString, &forwarder, &loadBalance}
+			&invalidIpAdd, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -527,6 +719,22 @@ func TestMainDnsServer4(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -563,7 +771,7 @@ func TestMainDnsServer5(t *testing.T) {
 	})
 	defer patch3.Reset()
 
-	patch4 := gomonkey.ApplyFunc(waitForSignal, func() { // Empty Impl
+	patch4 := gomonkey.ApplyFunc(waitForSignal, func(reload func() error) { // Empty Impl
 	})
 	defer patch4.Reset()
 
@@ -581,7 +789,10 @@ func TestMainDnsServer5(t *testing.T) {
 			}
 		}()
 		parameters := InputParameters{&dbName, &port, &mgmtPort, &connTimeOut,
-			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance}
+			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -592,6 +803,22 @@ func TestMainDnsServer5(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -615,7 +842,10 @@ func TestMainDnsServer5(t *testing.T) {
 			"qwertyuiopqwertyuiopqwertyuiopqwertyuiopqwertyuiopqwertyuiopqwertyuiop"
 
 		parameters := InputParameters{&invalidDbName, &port, &mgmtPort, &connTimeOut,
-			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance}
+			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -626,6 +856,22 @@ func TestMainDnsServer5(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -662,7 +908,7 @@ func TestMainDnsServer6(t *testing.T) {
 	})
 	defer patch3.Reset()
 
-	patch4 := gomonkey.ApplyFunc(waitForSignal, func() { // Empty Impl
+	patch4 := gomonkey.ApplyFunc(waitForSignal, func(reload func() error) { // Empty Impl
 	})
 	defer patch4.Reset()
 
@@ -684,7 +930,10 @@ func TestMainDnsServer6(t *testing.T) {
 		}()
 		var invalidPortNo uint = 0
 		parameters := InputParameters{&dbName, &invalidPortNo, &mgmtPort, &connTimeOut,
-			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance}
+			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -695,6 +944,22 @@ func TestMainDnsServer6(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -714,7 +979,10 @@ func TestMainDnsServer6(t *testing.T) {
 		}()
 		var invalidPortNo uint = 65536
 		parameters := InputParameters{&dbName, &invalidPortNo, &mgmtPort, &connTimeOut,
-			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance}
+			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -725,6 +993,22 @@ func TestMainDnsServer6(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -762,7 +1046,7 @@ func TestMainDnsServer7(t *testing.T) {
 	})
 	defer patch3.Reset()
 
-	patch4 := gomonkey.ApplyFunc(waitForSignal, func() { // Empty Impl
+	patch4 := gomonkey.ApplyFunc(waitForSignal, func(reload func() error) { // Empty Impl
 	})
 	defer patch4.Reset()
 
@@ -784,7 +1068,10 @@ func TestMainDnsServer7(t *testing.T) {
 		}()
 		var invalidConnT uint = 0
 		parameters := InputParameters{&dbName, &port, &mgmtPort, &invalidConnT,
-			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance}
+			&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+			&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+			&rateLimit, &rateLimitSlip, &rateLimitWL, &store, &etcdEndpoints, &etcdPrefix,
+			&configFile}
 
 		patch5 := gomonkey.ApplyFunc(registerInputParameters, func(inParam *InputParameters) {
 			inParam.dbName = parameters.dbName
@@ -795,6 +1082,22 @@ func TestMainDnsServer7(t *testing.T) {
 			inParam.ipMgmtAddString = parameters.ipMgmtAddString
 			inParam.forwarder = parameters.forwarder
 			inParam.loadBalance = parameters.loadBalance
+			inParam.forwarderPort = parameters.forwarderPort
+			inParam.forwardPolicy = parameters.forwardPolicy
+			inParam.cacheSize = parameters.cacheSize
+			inParam.transferACL = parameters.transferACL
+			inParam.secondaries = parameters.secondaries
+			inParam.tsigKeys = parameters.tsigKeys
+			inParam.queryLog = parameters.queryLog
+			inParam.queryLogFormat = parameters.queryLogFormat
+			inParam.queryLogRate = parameters.queryLogRate
+			inParam.rateLimit = parameters.rateLimit
+			inParam.rateLimitSlip = parameters.rateLimitSlip
+			inParam.rateLimitWL = parameters.rateLimitWL
+			inParam.store = parameters.store
+			inParam.etcdEndpoints = parameters.etcdEndpoints
+			inParam.etcdPrefix = parameters.etcdPrefix
+			inParam.configFile = parameters.configFile
 			return
 		})
 		defer patch5.Reset()
@@ -802,3 +1105,14 @@ func TestMainDnsServer7(t *testing.T) {
 		main()
 	})
 }
+
+func TestNewDataStore(t *testing.T) {
+	_, ok := newDataStore(&Config{dbName: dbName, store: datastore.StoreBoltDB}).(*datastore.BoltDB)
+	assert.Equal(t, true, ok, "Error in data store")
+	_, ok = newDataStore(&Config{store: datastore.StoreMemory}).(*datastore.MemoryStore)
+	assert.Equal(t, true, ok, "Error in data store")
+	etcdStore, ok := newDataStore(&Config{store: datastore.StoreEtcd, etcdEndpoints: []string{"127.0.0.1:2379"},
+		etcdPrefix: util.DefaultEtcdPrefix}).(*datastore.EtcdStore)
+	assert.Equal(t, true, ok, "Error in data store")
+	assert.Equal(t, []string{"127.0.0.1:2379"}, etcdStore.Endpoints, "Error in data store")
+}
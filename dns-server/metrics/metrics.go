@@ -0,0 +1,205 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package metrics prometheus metrics of the dns server
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"dns-server/cache"
+)
+
+const namespace = "dns_server"
+
+// Outcomes of the dns requests.
+const (
+	// OutcomeLocal answered from the local zones.
+	OutcomeLocal = "local"
+	// OutcomeForwarded answered by the forwarders or from the forward cache.
+	OutcomeForwarded = "forwarded"
+	// OutcomeTransfer zone transfer.
+	OutcomeTransfer = "transfer"
+	// OutcomeUpdate dynamic update.
+	OutcomeUpdate = "update"
+	// OutcomeServFail answered with SERVFAIL.
+	OutcomeServFail = "servfail"
+	// OutcomeFormErr answered with FORMERR.
+	OutcomeFormErr = "formerr"
+	// OutcomeRefused answered with REFUSED.
+	OutcomeRefused = "refused"
+	// OutcomeRateLimited dropped or answered truncated by the rate limit.
+	OutcomeRateLimited = "ratelimited"
+)
+
+// Data store operations.
+const (
+	// OperationLookup record lookup of a query.
+	OperationLookup = "lookup"
+	// OperationTransfer records read for a zone transfer.
+	OperationTransfer = "transfer"
+	// OperationUpdate records changed by a dynamic update.
+	OperationUpdate = "update"
+)
+
+// Reasons of the query log entries dropped.
+const (
+	// DropSampled sampled out under load.
+	DropSampled = "sampled"
+	// DropOverflow queue of the entries is full.
+	DropOverflow = "overflow"
+	// DropUnavailable query log destination is not available.
+	DropUnavailable = "unavailable"
+)
+
+// otherType label of the query types without a mnemonic, keeping the label values bounded.
+const otherType = "OTHER"
+
+var (
+	registry = prometheus.NewRegistry()
+
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "requests_total",
+		Help:      "DNS requests by query type.",
+	}, []string{"qtype"})
+	responses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "responses_total",
+		Help:      "DNS responses by outcome and rcode.",
+	}, []string{"outcome", "rcode"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "request_duration_seconds",
+		Help:      "DNS request handling time by outcome.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 16),
+	}, []string{"outcome"})
+	forwardDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "forward_duration_seconds",
+		Help:      "Round trip time of the forwarded requests by upstream.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"upstream"})
+	forwardFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "forward_failures_total",
+		Help:      "Forwarded requests failed by upstream.",
+	}, []string{"upstream"})
+	dataStoreDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "datastore_duration_seconds",
+		Help:      "Data store latency by operation.",
+		Buckets:   prometheus.ExponentialBuckets(0.00005, 2, 16),
+	}, []string{"operation"})
+	queryLogDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "querylog_dropped_total",
+		Help:      "Query log entries dropped by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	registry.MustRegister(prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	registry.MustRegister(requests, responses, requestDuration, forwardDuration, forwardFailures, dataStoreDuration,
+		queryLogDropped)
+}
+
+// Handler http handler exposing the metrics in prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RegisterCache expose the statistics of the forward cache.
+func RegisterCache(c *cache.Cache) error {
+	if c == nil {
+		return nil
+	}
+	collectors := []prometheus.Collector{
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_hits_total",
+			Help:      "Forward cache hits.",
+		}, func() float64 { return float64(c.Stats().Hits) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_misses_total",
+			Help:      "Forward cache misses.",
+		}, func() float64 { return float64(c.Stats().Misses) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "cache_entries",
+			Help:      "Responses in the forward cache.",
+		}, func() float64 { return float64(c.Stats().Size) }),
+	}
+	for _, collector := range collectors {
+		if err := registry.Register(collector); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ObserveRequest count the request by query type.
+func ObserveRequest(qtype uint16) {
+	label, ok := dns.TypeToString[qtype]
+	if !ok {
+		label = otherType
+	}
+	requests.WithLabelValues(label).Inc()
+}
+
+// ObserveResponse count the response, error rcodes refine the outcome of the request.
+func ObserveResponse(outcome string, rcode int, duration time.Duration) {
+	switch rcode {
+	case dns.RcodeServerFailure:
+		outcome = OutcomeServFail
+	case dns.RcodeFormatError:
+		outcome = OutcomeFormErr
+	case dns.RcodeRefused:
+		outcome = OutcomeRefused
+	}
+	rcodeLabel, ok := dns.RcodeToString[rcode]
+	if !ok {
+		rcodeLabel = otherType
+	}
+	responses.WithLabelValues(outcome, rcodeLabel).Inc()
+	requestDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+}
+
+// ObserveForward record the round trip time of a request to the upstream, or its failure.
+func ObserveForward(upstream string, rtt time.Duration, err error) {
+	if err != nil {
+		forwardFailures.WithLabelValues(upstream).Inc()
+		return
+	}
+	forwardDuration.WithLabelValues(upstream).Observe(rtt.Seconds())
+}
+
+// ObserveDataStore record the latency of a data store operation started at the given time.
+func ObserveDataStore(operation string, start time.Time) {
+	dataStoreDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// ObserveQueryLogDropped count a query log entry dropped for the reason.
+func ObserveQueryLogDropped(reason string) {
+	queryLogDropped.WithLabelValues(reason).Inc()
+}
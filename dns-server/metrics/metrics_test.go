@@ -0,0 +1,96 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"dns-server/cache"
+)
+
+const errorInMetrics = "Error in metrics"
+
+// scrape get the metrics in prometheus text format.
+func scrape(t *testing.T) string {
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := ioutil.ReadAll(recorder.Body)
+	assert.Equal(t, nil, err, errorInMetrics)
+	return string(body)
+}
+
+func TestObserveRequest(t *testing.T) {
+	ObserveRequest(dns.TypeAAAA)
+	ObserveRequest(dns.TypeAAAA)
+	ObserveRequest(65000)
+	assert.Equal(t, float64(2), testutil.ToFloat64(requests.WithLabelValues("AAAA")), errorInMetrics)
+	assert.Equal(t, float64(1), testutil.ToFloat64(requests.WithLabelValues(otherType)), errorInMetrics)
+}
+
+func TestObserveResponse(t *testing.T) {
+	ObserveResponse(OutcomeLocal, dns.RcodeNameError, time.Millisecond)
+	ObserveResponse(OutcomeForwarded, dns.RcodeServerFailure, time.Millisecond)
+	ObserveResponse(OutcomeTransfer, dns.RcodeRefused, time.Millisecond)
+	ObserveResponse(OutcomeLocal, dns.RcodeFormatError, time.Millisecond)
+	assert.Equal(t, float64(1), testutil.ToFloat64(responses.WithLabelValues(OutcomeLocal, "NXDOMAIN")),
+		errorInMetrics)
+	// Error rcodes refine the outcome
+	assert.Equal(t, float64(1), testutil.ToFloat64(responses.WithLabelValues(OutcomeServFail, "SERVFAIL")),
+		errorInMetrics)
+	assert.Equal(t, float64(1), testutil.ToFloat64(responses.WithLabelValues(OutcomeRefused, "REFUSED")),
+		errorInMetrics)
+	assert.Equal(t, float64(1), testutil.ToFloat64(responses.WithLabelValues(OutcomeFormErr, "FORMERR")),
+		errorInMetrics)
+	assert.Equal(t, true, strings.Contains(scrape(t),
+		`dns_server_request_duration_seconds_count{outcome="servfail"} 1`), errorInMetrics)
+}
+
+func TestObserveForwardAndDataStore(t *testing.T) {
+	ObserveForward("192.168.1.1:53", 20*time.Millisecond, nil)
+	ObserveForward("192.168.1.1:53", 0, fmt.Errorf("timeout"))
+	ObserveDataStore(OperationLookup, time.Now())
+	assert.Equal(t, float64(1), testutil.ToFloat64(forwardFailures.WithLabelValues("192.168.1.1:53")),
+		errorInMetrics)
+
+	body := scrape(t)
+	assert.Equal(t, true, strings.Contains(body,
+		`dns_server_forward_duration_seconds_count{upstream="192.168.1.1:53"} 1`), errorInMetrics)
+	assert.Equal(t, true, strings.Contains(body,
+		`dns_server_datastore_duration_seconds_count{operation="lookup"} 1`), errorInMetrics)
+}
+
+func TestRegisterCache(t *testing.T) {
+	responseCache := cache.New(10)
+	assert.Equal(t, nil, RegisterCache(nil), errorInMetrics)
+	assert.Equal(t, nil, RegisterCache(responseCache), errorInMetrics)
+	// Only one cache is exposed
+	assert.NotEqual(t, nil, RegisterCache(cache.New(10)), errorInMetrics)
+
+	_ = responseCache.Get(&dns.Question{Name: "www.example.com.", Qtype: dns.TypeA, Qclass: dns.ClassINET})
+	body := scrape(t)
+	assert.Equal(t, true, strings.Contains(body, "dns_server_cache_misses_total 1"), errorInMetrics)
+	assert.Equal(t, true, strings.Contains(body, "dns_server_cache_entries 0"), errorInMetrics)
+}
@@ -18,24 +18,91 @@
 package mgmt
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/miekg/dns"
 	log "github.com/sirupsen/logrus"
 
+	"dns-server/cache"
 	"dns-server/datastore"
+	"dns-server/dnssec"
+	"dns-server/forward"
+	"dns-server/health"
+	"dns-server/metrics"
+	"dns-server/notify"
+	"dns-server/ratelimit"
 	"dns-server/util"
 )
 
 type Controller struct {
-	dataStore datastore.DataStore
-	echo      *echo.Echo
+	dataStore   datastore.DataStore
+	echo        *echo.Echo
+	Forwarders  *forward.Pool
+	Cache       *cache.Cache
+	Notifier    *notify.Notifier
+	Health      *health.Monitor
+	RateLimiter *ratelimit.Limiter
+	Signer      *dnssec.Signer
 }
 
-const invalidInputErr = "invalid input!"
+// ForwardersConfig forwarders configuration request.
+type ForwardersConfig struct {
+	Policy     string   `json:"policy"`
+	Forwarders []string `json:"forwarders"`
+}
+
+// ForwardersStatus forwarders configuration and health response.
+type ForwardersStatus struct {
+	Policy     string                   `json:"policy"`
+	Forwarders []forward.UpstreamStatus `json:"forwarders"`
+}
+
+// ZoneRequest zone creation request.
+type ZoneRequest struct {
+	Zone string `json:"zone"`
+}
+
+// ViewRequest view creation/update request.
+type ViewRequest struct {
+	CIDRs []string `json:"cidrs"`
+}
+
+// BatchRequest batch update request.
+type BatchRequest struct {
+	Operations []datastore.BatchOperation `json:"operations"`
+}
+
+// BatchResponse batch update response, applied is false when none of the operations are applied.
+type BatchResponse struct {
+	Applied bool                    `json:"applied"`
+	Results []datastore.BatchResult `json:"results"`
+}
+
+// ResourceRecordList paginated list of resource records.
+type ResourceRecordList struct {
+	Total   int                            `json:"total"`
+	Offset  int                            `json:"offset"`
+	Limit   int                            `json:"limit"`
+	Records []datastore.ZoneResourceRecord `json:"records"`
+}
+
+const (
+	invalidInputErr = "invalid input!"
+	zoneFileUrl     = "/mep/dns_server_mgmt/v1/zonefile"
+	batchUrl        = "/mep/dns_server_mgmt/v1/rrecord/batch"
+	zoneOptionsUrl  = "/mep/dns_server_mgmt/v1/zoneoptions"
+	dnssecUrl       = "/mep/dns_server_mgmt/v1/dnssec"
+	zoneFileMIME    = "text/dns"
+)
 
 func (e *Controller) StartController(store *datastore.DataStore, ipAddr net.IP, port uint) {
 	// Echo instance
@@ -44,27 +111,484 @@ func (e *Controller) StartController(store *datastore.DataStore, ipAddr net.IP,
 	// Middleware
 	e.echo.Use(middleware.Logger())
 	e.echo.Use(middleware.Recover())
-	e.echo.Use(middleware.BodyLimit(util.MaxPacketSize))
+	e.echo.Use(middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{
+		// Zone file import and batch update have their own limits
+		Skipper: func(c echo.Context) bool { return c.Path() == zoneFileUrl || c.Path() == batchUrl },
+		Limit:   util.MaxPacketSize,
+	}))
 
 	// Routes
+	e.echo.GET("/mep/dns_server_mgmt/v1/views", e.handleListViews)
+	e.echo.PUT("/mep/dns_server_mgmt/v1/views/:view", e.handleSetView)
+	e.echo.DELETE("/mep/dns_server_mgmt/v1/views/:view", e.handleDeleteView)
+	e.echo.GET("/mep/dns_server_mgmt/v1/zones", e.handleListZones)
+	e.echo.POST("/mep/dns_server_mgmt/v1/zones", e.handleCreateZone)
+	e.echo.DELETE("/mep/dns_server_mgmt/v1/zones/:zone", e.handleDeleteZone)
+	e.echo.GET(zoneOptionsUrl, e.handleGetZoneOptions)
+	e.echo.PUT(zoneOptionsUrl, e.handleSetZoneOptions)
+	e.echo.GET(dnssecUrl, e.handleGetDNSSEC)
+	e.echo.POST(dnssecUrl, e.handleEnableDNSSEC)
+	e.echo.DELETE(dnssecUrl, e.handleDisableDNSSEC)
+	e.echo.POST(dnssecUrl+"/keys", e.handleAddZoneKey)
+	e.echo.DELETE(dnssecUrl+"/keys/:keytag", e.handleDeleteZoneKey)
+	e.echo.GET(dnssecUrl+"/ds", e.handleExportDS)
+	e.echo.GET(zoneFileUrl, e.handleExportZoneFile)
+	e.echo.PUT(zoneFileUrl, e.handleImportZoneFile, middleware.BodyLimit(util.MaxZoneFileSize))
+	e.echo.GET("/mep/dns_server_mgmt/v1/rrecord", e.handleListResourceRecords)
+	e.echo.GET("/mep/dns_server_mgmt/v1/rrecord/:fqdn/:rrtype", e.handleGetResourceRecord)
 	e.echo.POST("/mep/dns_server_mgmt/v1/rrecord", e.handleAddResourceRecords)
+	e.echo.POST(batchUrl, e.handleBatchResourceRecords, middleware.BodyLimit(util.MaxBatchSize))
 	e.echo.PUT("/mep/dns_server_mgmt/v1/rrecord/:fqdn/:rrtype", e.handleSetResourceRecords)
 	e.echo.DELETE("/mep/dns_server_mgmt/v1/rrecord/:fqdn/:rrtype", e.handleDeleteResourceRecord)
+	e.echo.GET("/mep/dns_server_mgmt/v1/forwarders", e.handleGetForwarders)
+	e.echo.PUT("/mep/dns_server_mgmt/v1/forwarders", e.handleSetForwarders)
+	e.echo.GET("/mep/dns_server_mgmt/v1/cache", e.handleGetCacheStats)
+	e.echo.DELETE("/mep/dns_server_mgmt/v1/cache", e.handleFlushCache)
+	e.echo.GET("/mep/dns_server_mgmt/v1/healthchecks", e.handleGetHealthChecks)
+	e.echo.GET("/mep/dns_server_mgmt/v1/ratelimit", e.handleGetRateLimitStats)
 	e.echo.GET("/health", e.handleHealthResult)
+	e.echo.GET("/metrics", echo.WrapHandler(metrics.Handler()))
 
 	e.dataStore = *store
 
-	// Start server
-	e.echo.Logger.Fatal(e.echo.Start(fmt.Sprintf("%s:%d", ipAddr.String(), port)))
+	// Start server, closed on stop
+	err := e.echo.Start(fmt.Sprintf("%s:%d", ipAddr.String(), port))
+	if err != http.ErrServerClosed {
+		e.echo.Logger.Fatal(err)
+	}
 }
 
+// StopController stop the controller, waiting for the in-flight requests to complete.
 func (e *Controller) StopController() error {
-	e.dataStore = nil
 	if e.echo == nil {
+		e.dataStore = nil
 		return nil
 	}
 
-	return e.echo.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), util.ShutdownTimeout*time.Second)
+	defer cancel()
+	err := e.echo.Shutdown(ctx)
+	e.dataStore = nil
+
+	return err
+}
+
+func (e *Controller) handleListViews(c echo.Context) error {
+	views, err := e.dataStore.ListViews()
+	if err != nil {
+		log.Error("Failed to list the views.", nil)
+		return c.String(http.StatusInternalServerError, "Error in retrieving the data.")
+	}
+
+	return c.JSON(http.StatusOK, views)
+}
+
+func (e *Controller) handleSetView(c echo.Context) error {
+	// Input Example:
+	//{
+	//	"cidrs": [
+	//      "10.10.0.0/16",
+	//      "2001:db8::/64"
+	//     ]
+	//}
+	name := c.Param("view")
+	req := ViewRequest{}
+	if nil != c.Bind(&req) {
+		log.Error("Error in parsing the view put request body.", nil)
+		return c.String(http.StatusBadRequest, invalidInputErr)
+	}
+	if err := validateViewName(name); err != nil || len(req.CIDRs) == 0 {
+		return c.String(http.StatusBadRequest, invalidInputErr)
+	}
+
+	if err := e.dataStore.SetView(&datastore.View{Name: name, CIDRs: req.CIDRs}); err != nil {
+		log.Errorf("Failed to set the view(%s). %s", name, err.Error())
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	log.Infof("Updated view(%s) with client networks %v.", name, req.CIDRs)
+
+	return c.String(http.StatusOK, "success in updating view.")
+}
+
+func (e *Controller) handleDeleteView(c echo.Context) error {
+	name := c.Param("view")
+	if err := validateViewName(name); err != nil {
+		return c.String(http.StatusBadRequest, "invalid input parameters!")
+	}
+
+	if err := e.dataStore.DeleteView(name); err != nil {
+		log.Errorf("Failed to delete the view(%s). %s", name, err.Error())
+		return c.String(http.StatusNotFound, "view not found!")
+	}
+	log.Infof("Deleted view(%s) along with its zones.", name)
+
+	return c.String(http.StatusOK, "Success")
+}
+
+// getViewStore get the data store of the view in the request, default view when not given.
+func (e *Controller) getViewStore(c echo.Context) (datastore.DataStore, error) {
+	view := c.QueryParam("view")
+	if len(view) == 0 {
+		return e.dataStore, nil
+	}
+	if err := validateViewName(view); err != nil {
+		return nil, err
+	}
+
+	views, err := e.dataStore.ListViews()
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range views {
+		if v.Name == view {
+			return e.dataStore.WithView(view), nil
+		}
+	}
+
+	return nil, fmt.Errorf("view(%s) not found", view)
+}
+
+// validateViewName view name can have only letters, digits, '-' and '_'.
+func validateViewName(view string) error {
+	if len(view) == 0 || len(view) > util.MaxViewNameLength {
+		return fmt.Errorf("invalid view value")
+	}
+	for _, ch := range view {
+		if !(ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' || ch == '-' || ch == '_') {
+			return fmt.Errorf("invalid view value")
+		}
+	}
+
+	return nil
+}
+
+func (e *Controller) handleListZones(c echo.Context) error {
+	store, err := e.getViewStore(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	zones, err := store.ListZones()
+	if err != nil {
+		log.Error("Failed to list the zones.", nil)
+		return c.String(http.StatusInternalServerError, "Error in retrieving the data.")
+	}
+
+	return c.JSON(http.StatusOK, zones)
+}
+
+func (e *Controller) handleCreateZone(c echo.Context) error {
+	// Input Example:
+	//{
+	//	"zone": "example.com."
+	//}
+	store, err := e.getViewStore(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	req := ZoneRequest{}
+	if nil != c.Bind(&req) {
+		log.Error("Error in parsing the zone post request body.", nil)
+		return c.String(http.StatusBadRequest, invalidInputErr)
+	}
+	if err := validateZoneName(req.Zone); err != nil {
+		log.Error("Error in validating the zone post request body.", err)
+		return c.String(http.StatusBadRequest, invalidInputErr)
+	}
+
+	if err := store.CreateZone(req.Zone); err != nil {
+		log.Errorf("Failed to create the zone(%s). %s", req.Zone, err.Error())
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	log.Infof("Created new zone(%s).", req.Zone)
+	e.notifyZoneChange(c, req.Zone)
+
+	return c.String(http.StatusOK, "success in creating zone.")
+}
+
+func (e *Controller) handleDeleteZone(c echo.Context) error {
+	store, err := e.getViewStore(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	zone := c.Param("zone")
+	if err := validateZoneName(zone); err != nil {
+		return c.String(http.StatusBadRequest, "invalid input parameters!")
+	}
+	if !isZoneExists(store, zone) {
+		return c.String(http.StatusNotFound, "zone not found!")
+	}
+
+	if err := store.DeleteZone(zone); err != nil {
+		log.Errorf("Failed to delete the zone(%s). %s", zone, err.Error())
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	log.Infof("Deleted zone(%s) along with its records.", zone)
+
+	return c.String(http.StatusOK, "Success")
+}
+
+func (e *Controller) handleGetZoneOptions(c echo.Context) error {
+	store, err := e.getViewStore(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	zone := c.QueryParam("zone")
+	if len(zone) == 0 {
+		zone = "."
+	}
+	if err := validateZoneName(zone); err != nil {
+		return c.String(http.StatusBadRequest, "invalid input parameters!")
+	}
+
+	options, err := store.GetZoneOptions(zone)
+	if err != nil {
+		return c.String(http.StatusNotFound, "zone not found!")
+	}
+
+	return c.JSON(http.StatusOK, options)
+}
+
+func (e *Controller) handleSetZoneOptions(c echo.Context) error {
+	// Input Example:
+	//{
+	//	"autoPtr": true
+	//}
+	store, err := e.getViewStore(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	zone := c.QueryParam("zone")
+	if len(zone) == 0 {
+		zone = "."
+	}
+	if err := validateZoneName(zone); err != nil {
+		return c.String(http.StatusBadRequest, "invalid input parameters!")
+	}
+	options := datastore.ZoneOptions{}
+	if nil != c.Bind(&options) {
+		log.Error("Error in parsing the zone options put request body.", nil)
+		return c.String(http.StatusBadRequest, invalidInputErr)
+	}
+	if !isZoneExists(store, zone) {
+		return c.String(http.StatusNotFound, "zone not found!")
+	}
+
+	if err := store.SetZoneOptions(zone, &options); err != nil {
+		log.Errorf("Failed to set the zone(%s) options. %s", zone, err.Error())
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	log.Infof("Updated zone(%s) options, automatic reverse entries %t.", zone, options.AutoPTR)
+
+	return c.String(http.StatusOK, "success in updating zone options.")
+}
+
+func (e *Controller) handleExportZoneFile(c echo.Context) error {
+	store, err := e.getViewStore(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	zone := c.QueryParam("zone")
+	if len(zone) == 0 {
+		zone = "."
+	}
+	if err := validateZoneName(zone); err != nil {
+		return c.String(http.StatusBadRequest, "invalid input parameters!")
+	}
+	if !isZoneExists(store, zone) {
+		return c.String(http.StatusNotFound, "zone not found!")
+	}
+
+	zoneRecords, err := store.ListResourceRecords(&datastore.RecordFilter{Zone: zone})
+	if err != nil {
+		log.Error("Failed to list the zone records.", nil)
+		return c.String(http.StatusInternalServerError, "Error in retrieving the data.")
+	}
+	records := make([]datastore.ResourceRecord, 0, len(zoneRecords))
+	for _, zoneRecord := range zoneRecords {
+		records = append(records, zoneRecord.ResourceRecord)
+	}
+
+	var zoneFile bytes.Buffer
+	if err = datastore.WriteZoneFile(&zoneFile, zone, records); err != nil {
+		log.Errorf("Failed to export the zone(%s). %s", zone, err.Error())
+		return c.String(http.StatusInternalServerError, "Error in exporting the zone.")
+	}
+
+	return c.Blob(http.StatusOK, zoneFileMIME, zoneFile.Bytes())
+}
+
+func (e *Controller) handleImportZoneFile(c echo.Context) error {
+	// Input Example(RFC 1035 master file):
+	// $ORIGIN example.com.
+	// @    30 IN SOA ns1.example.com. admin.example.com. 1 3600 600 86400 30
+	// www  30 IN A   192.168.1.101
+	store, err := e.getViewStore(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	zone := c.QueryParam("zone")
+	if len(zone) == 0 {
+		zone = "."
+	}
+	if err := validateZoneName(zone); err != nil {
+		return c.String(http.StatusBadRequest, "invalid input parameters!")
+	}
+
+	records, err := datastore.ParseZoneFile(zone, c.Request().Body)
+	if err != nil {
+		log.Errorf("Error in parsing the zone file. %s", err.Error())
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	for i := range records {
+		if err = e.validateSetRecordInput(zone, &records[i]); err != nil {
+			log.Errorf("Error in validating the zone file entry %s %s.", records[i].Name, records[i].Type)
+			return c.String(http.StatusBadRequest, fmt.Sprintf("invalid entry %s %s", records[i].Name,
+				records[i].Type))
+		}
+	}
+
+	if err = store.ImportZone(zone, records); err != nil {
+		log.Errorf("Failed to import the zone(%s). %s", zone, err.Error())
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	log.Infof("Imported zone(%s) with %d entries.", zone, len(records))
+	e.notifyZoneChange(c, zone)
+
+	return c.String(http.StatusOK, fmt.Sprintf("success in importing %d entries.", len(records)))
+}
+
+// notifyZoneChange notify the secondaries of the changes to the authoritative zones in the default view.
+func (e *Controller) notifyZoneChange(c echo.Context, zones ...string) {
+	if e.Notifier == nil || len(c.QueryParam("view")) != 0 {
+		return
+	}
+
+	notified := make(map[string]bool)
+	for _, zone := range zones {
+		if notified[zone] {
+			continue
+		}
+		notified[zone] = true
+		soa, err := e.dataStore.ListResourceRecords(&datastore.RecordFilter{Zone: zone, Name: zone, Type: "SOA"})
+		if err == nil && len(soa) != 0 {
+			e.Notifier.Notify(zone)
+		}
+	}
+}
+
+// isZoneExists check the zone is one of the local zones.
+func isZoneExists(store datastore.DataStore, zone string) bool {
+	zones, err := store.ListZones()
+	if err != nil {
+		return false
+	}
+	for _, z := range zones {
+		if z == zone {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateZoneName zone should be a fully qualified domain name, e.g. example.com.
+func validateZoneName(zone string) error {
+	if len(zone) == 0 || len(zone) >= util.MaxDNSFQDNLength || !dns.IsFqdn(zone) {
+		return fmt.Errorf("invalid zone value")
+	}
+	if _, ok := dns.IsDomainName(zone); !ok || strings.Contains(zone, "*") {
+		return fmt.Errorf("invalid zone value")
+	}
+
+	return nil
+}
+
+func (e *Controller) handleListResourceRecords(c echo.Context) error {
+	// Query Example: ?zone=example.com.&name=www.example.com.&type=A&offset=0&limit=100
+	store, err := e.getViewStore(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	filter := &datastore.RecordFilter{Zone: c.QueryParam("zone"), Name: c.QueryParam("name"),
+		Type: c.QueryParam("type")}
+	if len(filter.Zone) >= util.MaxDNSFQDNLength || len(filter.Name) > util.MaxDNSFQDNLength ||
+		len(filter.Type) > util.MaxDNSFQDNLength {
+		return c.String(http.StatusBadRequest, "invalid input parameters!")
+	}
+	offset, limit, err := parsePagination(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "invalid input parameters!")
+	}
+
+	records, err := store.ListResourceRecords(filter)
+	if err != nil {
+		log.Errorf("Failed to list the resource records. %s", err.Error())
+		return c.String(http.StatusBadRequest, invalidInputErr)
+	}
+
+	list := ResourceRecordList{Total: len(records), Offset: offset, Limit: limit,
+		Records: make([]datastore.ZoneResourceRecord, 0)}
+	if offset < len(records) {
+		end := offset + limit
+		if end > len(records) {
+			end = len(records)
+		}
+		list.Records = records[offset:end]
+	}
+
+	return c.JSON(http.StatusOK, list)
+}
+
+func (e *Controller) handleGetResourceRecord(c echo.Context) error {
+	store, err := e.getViewStore(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	zone := c.QueryParam("zone")
+	fqdn := c.Param("fqdn")
+	rrtype := c.Param("rrtype")
+
+	if len(fqdn) == 0 || len(rrtype) == 0 || len(zone) >= util.MaxDNSFQDNLength {
+		return c.String(http.StatusBadRequest, "invalid input parameters!")
+	}
+
+	records, err := store.ListResourceRecords(&datastore.RecordFilter{Zone: zone, Name: fqdn, Type: rrtype})
+	if err != nil {
+		log.Errorf("Failed to get the resource record. %s", err.Error())
+		return c.String(http.StatusBadRequest, invalidInputErr)
+	}
+	if len(records) == 0 {
+		return c.String(http.StatusNotFound, "record not found!")
+	}
+
+	return c.JSON(http.StatusOK, records[0])
+}
+
+// parsePagination get the offset and limit query parameters of a list request.
+func parsePagination(c echo.Context) (int, int, error) {
+	offset, limit := 0, util.DefaultPageLimit
+	var err error
+	if value := c.QueryParam("offset"); len(value) != 0 {
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset")
+		}
+	}
+	if value := c.QueryParam("limit"); len(value) != 0 {
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > util.MaxPageLimit {
+			return 0, 0, fmt.Errorf("invalid limit")
+		}
+	}
+
+	return offset, limit, nil
 }
 
 func (e *Controller) handleAddResourceRecords(c echo.Context) error {
@@ -78,6 +602,12 @@ func (e *Controller) handleAddResourceRecords(c echo.Context) error {
 	//      "192.168.1.101"
 	//     ]
 	//}
+	// rData for other types, CNAME/PTR/NS: "gw.example.com.", MX: "10 mail.example.com.",
+	// SRV: "10 5 8080 app.example.com.", TXT: "any text"
+	store, err := e.getViewStore(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
 
 	zone := c.QueryParam("zone")
 
@@ -91,26 +621,27 @@ func (e *Controller) handleAddResourceRecords(c echo.Context) error {
 		zone = "."
 	}
 
-	err := e.validateSetRecordInput(zone, &rr)
+	err = e.validateSetRecordInput(zone, &rr)
 	if err != nil {
 		log.Error("Error in validating the rr post request body.", err)
 		return c.String(http.StatusBadRequest, invalidInputErr)
 	}
 
 	// Check already exists, then no need to add again
-	exists := e.dataStore.IsResourceRecordExists(zone, &rr)
+	exists := store.IsResourceRecordExists(zone, &rr)
 	if exists == true {
 		log.Error("Record already exist.")
 		return c.String(http.StatusBadRequest, "record already exists!")
 	}
 
-	err = e.dataStore.SetResourceRecord(zone, &rr)
+	err = store.SetResourceRecord(zone, &rr)
 	if err != nil {
 		log.Error("Failed to set the zone entries.")
 		return c.String(http.StatusInternalServerError, err.Error())
 	}
 	log.Debugf("Added new resource record entry(zone: %s, name: %s, type: %s, class: %s, ttl: %d).",
 		zone, rr.Name, rr.Type, rr.Class, rr.TTL)
+	e.notifyZoneChange(c, zone)
 
 	return c.String(http.StatusOK, "success in adding rr entry.")
 }
@@ -126,6 +657,11 @@ func (e *Controller) handleSetResourceRecords(c echo.Context) error {
 	//      "192.168.1.101"
 	//     ]
 	//}
+	store, err := e.getViewStore(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
 	zone := c.QueryParam("zone")
 	fqdn := c.Param("fqdn")
 	rrtype := c.Param("rrtype")
@@ -148,30 +684,105 @@ func (e *Controller) handleSetResourceRecords(c echo.Context) error {
 		zone = "."
 	}
 	//Update the input param
-	err := e.validateSetRecordInput(zone, &rr)
+	err = e.validateSetRecordInput(zone, &rr)
 	if err != nil {
 		log.Error("Error in validating the rr post request body.", err)
 		return c.String(http.StatusBadRequest, invalidInputErr)
 	}
 
 	// Check already exists, if not exist then cant update
-	exists := e.dataStore.IsResourceRecordExists(zone, &rr)
+	exists := store.IsResourceRecordExists(zone, &rr)
 	if exists != true {
 		log.Error("Record not exist, cannot update.", nil)
 		return c.String(http.StatusBadRequest, "record not exist, cannot update!")
 	}
 	// Store in DB
-	err = e.dataStore.SetResourceRecord(zone, &rr)
+	err = store.SetResourceRecord(zone, &rr)
 	if err != nil {
 		log.Error("Failed to set the zone entries.", nil)
 		return c.String(http.StatusInternalServerError, err.Error())
 	}
 	log.Debugf("Updated new resource record entry(zone: %s, name: %s, type: %s, class: %s, ttl: %d).",
 		zone, rr.Name, rr.Type, rr.Class, rr.TTL)
+	e.notifyZoneChange(c, zone)
 
 	return c.String(http.StatusOK, "success in updating rr entry.")
 }
 
+func (e *Controller) handleBatchResourceRecords(c echo.Context) error {
+	// Input Example:
+	//{
+	//	"operations": [
+	//		{"op": "add", "zone": ".", "rr": {"name": "www.example.com.", "type": "A", "class": "IN", "ttl": 30,
+	//			"rData": ["192.168.1.101"]}},
+	//		{"op": "delete", "zone": ".", "rr": {"name": "www.example1.com.", "type": "A"}}
+	//	]
+	//}
+	store, err := e.getViewStore(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	req := BatchRequest{}
+	if nil != c.Bind(&req) {
+		log.Error("Error in parsing the batch post request body.", nil)
+		return c.String(http.StatusBadRequest, invalidInputErr)
+	}
+	if len(req.Operations) == 0 || len(req.Operations) > util.MaxBatchOperations {
+		return c.String(http.StatusBadRequest, invalidInputErr)
+	}
+
+	// Validate all the operations before touching the data store
+	rsp := BatchResponse{Results: make([]datastore.BatchResult, len(req.Operations))}
+	valid := true
+	for i := range req.Operations {
+		rsp.Results[i] = datastore.BatchResult{Index: i, Status: datastore.BatchRolledBack}
+		if err := e.validateBatchOperation(&req.Operations[i]); err != nil {
+			log.Errorf("Error in validating the batch operation %d. %s", i, err.Error())
+			rsp.Results[i] = datastore.BatchResult{Index: i, Status: datastore.BatchFailed, Error: invalidInputErr}
+			valid = false
+		}
+	}
+	if !valid {
+		return c.JSON(http.StatusBadRequest, rsp)
+	}
+
+	results, err := store.ApplyBatch(req.Operations)
+	rsp.Results = results
+	if err != nil {
+		log.Errorf("Failed to apply the batch update. %s", err.Error())
+		return c.JSON(http.StatusBadRequest, rsp)
+	}
+	rsp.Applied = true
+	log.Debugf("Applied batch update of %d operations.", len(req.Operations))
+	zones := make([]string, 0, len(req.Operations))
+	for _, op := range req.Operations {
+		zones = append(zones, op.Zone)
+	}
+	e.notifyZoneChange(c, zones...)
+
+	return c.JSON(http.StatusOK, rsp)
+}
+
+func (e *Controller) validateBatchOperation(op *datastore.BatchOperation) error {
+	if len(op.Zone) == 0 {
+		op.Zone = "."
+	}
+
+	switch op.Op {
+	case datastore.BatchOpAdd, datastore.BatchOpUpdate:
+		return e.validateSetRecordInput(op.Zone, &op.RR)
+	case datastore.BatchOpDelete:
+		if len(op.RR.Name) == 0 || len(op.RR.Name) > util.MaxDNSFQDNLength || len(op.RR.Type) == 0 ||
+			len(op.RR.Type) > util.MaxDNSFQDNLength || len(op.Zone) >= util.MaxDNSFQDNLength {
+			return fmt.Errorf("invalid input parameters")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported operation(%s)", op.Op)
+	}
+}
+
 func (e *Controller) validateSetRecordInput(zone string, rr *datastore.ResourceRecord) error {
 	// Input Example:
 	//{
@@ -192,6 +803,11 @@ func (e *Controller) validateSetRecordInput(zone string, rr *datastore.ResourceR
 		return err
 	}
 
+	// SOA is the zone level configuration, only at zone apex
+	if rr.Type == "SOA" && (!strings.EqualFold(zone, rr.Name) || len(rr.RData) != 1) {
+		return fmt.Errorf("invalid soa value")
+	}
+
 	return nil
 }
 
@@ -202,19 +818,55 @@ func (e *Controller) validateResourceRecords(rr *datastore.ResourceRecord) error
 		len(rr.Class) == 0 || len(rr.Class) > util.MaxDNSFQDNLength {
 		return fmt.Errorf("invalid resource record value")
 	}
+	// Wildcard is allowed only as the left most label, e.g. *.video.mec.local.
+	if strings.Contains(rr.Name, "*") && (!strings.HasPrefix(rr.Name, "*.") || strings.Count(rr.Name, "*") != 1) {
+		return fmt.Errorf("invalid resource record value")
+	}
+	if rr.Type == "CNAME" && len(rr.RData) != 1 {
+		return fmt.Errorf("invalid resource record value")
+	}
 	for _, rData := range rr.RData {
+		if err := e.validateRData(rr.Type, rData); err != nil {
+			return err
+		}
+	}
+	if len(rr.Weights) != 0 && len(rr.Weights) != len(rr.RData) {
+		return fmt.Errorf("invalid resource record value")
+	}
+	if rr.HealthCheck != nil {
+		return rr.HealthCheck.Validate(rr.Type)
+	}
+
+	return nil
+}
+
+func (e *Controller) validateRData(rrType string, rData string) error {
+	switch rrType {
+	case "A", "AAAA":
 		mgmtIP := net.ParseIP(rData)
 		if len(rData) == 0 ||
 			len(rData) > util.MaxIPLength ||
 			nil == mgmtIP || mgmtIP.IsMulticast() || mgmtIP.Equal(net.IPv4bcast) {
 			return fmt.Errorf("invalid resource record value")
 		}
+	default:
+		if len(rData) == 0 || len(rData) > util.MaxRDataLength {
+			return fmt.Errorf("invalid resource record value")
+		}
+		if err := datastore.ValidateRData(rrType, rData); err != nil {
+			return fmt.Errorf("invalid resource record value")
+		}
 	}
 
 	return nil
 }
 
 func (e *Controller) handleDeleteResourceRecord(c echo.Context) error {
+	store, err := e.getViewStore(c)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
 	zone := c.QueryParam("zone")
 	fqdn := c.Param("fqdn")
 	rrtype := c.Param("rrtype")
@@ -227,15 +879,100 @@ func (e *Controller) handleDeleteResourceRecord(c echo.Context) error {
 		zone = "."
 	}
 
-	err := e.dataStore.DelResourceRecord(zone, fqdn, rrtype)
+	deletedZone, err := store.DelResourceRecord(zone, fqdn, rrtype)
 	if err != nil {
 		log.Error("Failed to Delete Resource.", nil)
 		return c.String(http.StatusInternalServerError, "Error in retrieving the data.")
 	}
+	e.notifyZoneChange(c, deletedZone)
 
 	return c.String(http.StatusOK, "Success")
 }
 
+func (e *Controller) handleGetForwarders(c echo.Context) error {
+	if e.Forwarders == nil {
+		return c.String(http.StatusNotFound, "forwarders not available!")
+	}
+
+	return c.JSON(http.StatusOK, ForwardersStatus{Policy: e.Forwarders.Policy(), Forwarders: e.Forwarders.Status()})
+}
+
+func (e *Controller) handleSetForwarders(c echo.Context) error {
+	// Forwarders set at runtime are replaced when the settings are reloaded from the config file(SIGHUP)
+	// Input Example:
+	//{
+	//	"policy": "fastest",
+	//	"forwarders": [
+	//      "8.8.8.8",
+	//      "tls://1.1.1.1:853#cloudflare-dns.com"
+	//     ]
+	//}
+	if e.Forwarders == nil {
+		return c.String(http.StatusNotFound, "forwarders not available!")
+	}
+
+	config := ForwardersConfig{}
+	if nil != c.Bind(&config) {
+		log.Error("Error in parsing the forwarders put request body.", nil)
+		return c.String(http.StatusBadRequest, invalidInputErr)
+	}
+	if len(config.Policy) == 0 {
+		config.Policy = e.Forwarders.Policy()
+	}
+
+	upstreams := make([]forward.Upstream, 0, len(config.Forwarders))
+	for _, spec := range config.Forwarders {
+		upstream, err := forward.ParseUpstream(spec, util.DefaultForwarderPort)
+		if err != nil {
+			log.Errorf("Invalid forwarder(%s) in the request. %s", spec, err.Error())
+			return c.String(http.StatusBadRequest, invalidInputErr)
+		}
+		upstreams = append(upstreams, *upstream)
+	}
+
+	if err := e.Forwarders.SetConfig(upstreams, config.Policy); err != nil {
+		log.Errorf("Failed to set the forwarders. %s", err.Error())
+		return c.String(http.StatusBadRequest, invalidInputErr)
+	}
+	log.Infof("Updated forwarders(policy: %s, forwarders: %v).", config.Policy, config.Forwarders)
+
+	return c.String(http.StatusOK, "success in updating forwarders.")
+}
+
+func (e *Controller) handleGetCacheStats(c echo.Context) error {
+	if e.Cache == nil {
+		return c.String(http.StatusNotFound, "cache not available!")
+	}
+
+	return c.JSON(http.StatusOK, e.Cache.Stats())
+}
+
+func (e *Controller) handleFlushCache(c echo.Context) error {
+	if e.Cache == nil {
+		return c.String(http.StatusNotFound, "cache not available!")
+	}
+	e.Cache.Flush()
+	log.Info("Flushed the forward response cache.")
+
+	return c.String(http.StatusOK, "Success")
+}
+
+func (e *Controller) handleGetHealthChecks(c echo.Context) error {
+	if e.Health == nil {
+		return c.String(http.StatusNotFound, "health check not available!")
+	}
+
+	return c.JSON(http.StatusOK, e.Health.Status())
+}
+
+func (e *Controller) handleGetRateLimitStats(c echo.Context) error {
+	if e.RateLimiter == nil {
+		return c.String(http.StatusNotFound, "rate limit not available!")
+	}
+
+	return c.JSON(http.StatusOK, e.RateLimiter.Stats())
+}
+
 func (e *Controller) handleHealthResult(c echo.Context) error {
 	return c.String(http.StatusOK, "OK")
 }
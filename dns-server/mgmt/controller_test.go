@@ -20,17 +20,25 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/agiledragon/gomonkey"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
 
+	"dns-server/cache"
 	"dns-server/datastore"
+	"dns-server/dnssec"
+	"dns-server/forward"
+	"dns-server/health"
+	"dns-server/notify"
+	"dns-server/ratelimit"
 )
 
 // Query dns rules request in mp1 interface
@@ -64,6 +72,12 @@ var egOrg = "www.example.org."
 var rr_invalidIP = "{\"name\": \"www.e.com.\",\"type\": \"A\",\"class\": \"IN\",\"ttl\": 30,\"rData\": [\"255.255.255.255\"]}"
 var rr_invalidrrtype = "{\"name\": \"www.e.com.\",\"type\": \"AAB\",\"class\": \"IN\",\"ttl\": 30,\"rData\": [\"192.168.1.100\"]}"
 var rr_invalidTTL = "{\"name\": \"www.e.com.\",\"type\": \"A\",\"class\": \"IN\",\"ttl\": 0,\"rData\": [\"192.168.1.1005\"]}"
+var rr_srv = "{\"name\": \"_http._tcp.example.com.\",\"type\": \"SRV\",\"class\": \"IN\",\"ttl\": 30,\"rData\": [\"10 5 8080 app.example.com.\"]}"
+var rr_invalidSrv = "{\"name\": \"_http._tcp.example.com.\",\"type\": \"SRV\",\"class\": \"IN\",\"ttl\": 30,\"rData\": [\"192.168.1.100\"]}"
+var rr_multiCname = "{\"name\": \"www.example.com.\",\"type\": \"CNAME\",\"class\": \"IN\",\"ttl\": 30,\"rData\": [\"a.example.com.\", \"b.example.com.\"]}"
+var rr_wildcard = "{\"name\": \"*.video.example.com.\",\"type\": \"A\",\"class\": \"IN\",\"ttl\": 30,\"rData\": [\"192.168.1.100\"]}"
+var rr_invalidWildcard = "{\"name\": \"www.*.example.com.\",\"type\": \"A\",\"class\": \"IN\",\"ttl\": 30,\"rData\": [\"192.168.1.100\"]}"
+var rr_soaNotApex = "{\"name\": \"www.example.com.\",\"type\": \"SOA\",\"class\": \"IN\",\"ttl\": 30,\"rData\": [\"ns1.example.com. admin.example.com. 1 3600 600 86400 10\"]}"
 var rr_setinvalidrrtype = "{\"name\": \"www.example.com.\",\"type\": \"AAB\",\"class\": \"IN\",\"ttl\": 30,\"rData\": [\"192.168.1.100\"]}"
 
 func TestRestControllerOperations(t *testing.T) {
@@ -96,9 +110,9 @@ func TestRestControllerOperations(t *testing.T) {
 		assert.Equal(t, rr_eg100, (*rrResponse)[0].String(),
 			"Error")
 
-		err = store.DelResourceRecord("", eg, "A")
+		_, err = store.DelResourceRecord("", eg, "A")
 		assert.Equal(t, nil, err, errRecord)
-		err = store.DelResourceRecord("", eg1, "A")
+		_, err = store.DelResourceRecord("", eg1, "A")
 		assert.NotEqual(t, nil, err, errRecord)
 	})
 
@@ -121,12 +135,12 @@ func TestRestControllerOperations(t *testing.T) {
 		assert.Equal(t, nil, err, "Error")
 
 		// zone name given as record
-		err = store.DelResourceRecord("", "www.example.org.", "A")
+		_, err = store.DelResourceRecord("", "www.example.org.", "A")
 		assert.NotEqual(t, nil, err, errRecord)
 
-		err = store.DelResourceRecord("", eg, "A")
+		_, err = store.DelResourceRecord("", eg, "A")
 		assert.Equal(t, nil, err, errRecord)
-		err = store.DelResourceRecord("", eg1, "A")
+		_, err = store.DelResourceRecord("", eg1, "A")
 		assert.Equal(t, nil, err, errRecord)
 	})
 
@@ -158,9 +172,9 @@ func TestRestControllerOperations(t *testing.T) {
 		assert.Equal(t, rr_eg101, (*rrResponse)[0].String(),
 			"Error")
 
-		err = store.DelResourceRecord("", eg, "A")
+		_, err = store.DelResourceRecord("", eg, "A")
 		assert.Equal(t, nil, err, errRecord)
-		err = store.DelResourceRecord("", eg1, "A")
+		_, err = store.DelResourceRecord("", eg1, "A")
 		assert.Equal(t, nil, err, errRecord)
 	})
 
@@ -193,14 +207,14 @@ func TestRestControllerOperations(t *testing.T) {
 			"Error")
 
 		// zone name given as record
-		err = store.DelResourceRecord("", ".", "A")
+		_, err = store.DelResourceRecord("", ".", "A")
 		assert.NotEqual(t, nil, err, errRecord)
-		err = store.DelResourceRecord("", "com.", "A")
+		_, err = store.DelResourceRecord("", "com.", "A")
 		assert.NotEqual(t, nil, err, errRecord)
 
-		err = store.DelResourceRecord("", eg, "A")
+		_, err = store.DelResourceRecord("", eg, "A")
 		assert.Equal(t, nil, err, errRecord)
-		err = store.DelResourceRecord("", eg1, "A")
+		_, err = store.DelResourceRecord("", eg1, "A")
 		assert.Equal(t, nil, err, errRecord)
 	})
 
@@ -242,14 +256,14 @@ func TestRestControllerOperations(t *testing.T) {
 			"Error")
 
 		// zone name given as record
-		err = store.DelResourceRecord("invalid", ".", "A")
+		_, err = store.DelResourceRecord("invalid", ".", "A")
 		assert.NotEqual(t, nil, err, errRecord)
-		err = store.DelResourceRecord("", "org.", "A")
+		_, err = store.DelResourceRecord("", "org.", "A")
 		assert.NotEqual(t, nil, err, errRecord)
 
-		err = store.DelResourceRecord("", eg, "A")
+		_, err = store.DelResourceRecord("", eg, "A")
 		assert.Equal(t, nil, err, errRecord)
-		err = store.DelResourceRecord("org", egOrg, "A")
+		_, err = store.DelResourceRecord("org", egOrg, "A")
 		assert.Equal(t, nil, err, errRecord)
 	})
 
@@ -306,7 +320,7 @@ func TestRestControllerOperations(t *testing.T) {
 		err = mgmtCtl.handleDeleteResourceRecord(delContext1)
 		assert.Equal(t, nil, err, "Error")
 		assert.Equal(t, http.StatusOK, delContext1.Response().Status, "Error")
-		err = store.DelResourceRecord("", eg, "A")
+		_, err = store.DelResourceRecord("", eg, "A")
 		assert.NotEqual(t, nil, err, errRecord)
 
 		deleteUrl2 := "/mep/dns_server_mgmt/v1/rrecord/www.example.org./A"
@@ -320,7 +334,7 @@ func TestRestControllerOperations(t *testing.T) {
 		err = mgmtCtl.handleDeleteResourceRecord(delContext2)
 		assert.Equal(t, nil, err, "Error")
 		assert.Equal(t, http.StatusOK, delContext2.Response().Status, "Error")
-		err = store.DelResourceRecord("", egOrg, "A")
+		_, err = store.DelResourceRecord("", egOrg, "A")
 		assert.NotEqual(t, nil, err, errRecord)
 
 		deleteUrl4 := "/mep/dns_server_mgmt/v1/rrecord/www.example1.com./A"
@@ -334,7 +348,7 @@ func TestRestControllerOperations(t *testing.T) {
 		err = mgmtCtl.handleDeleteResourceRecord(delContext4)
 		assert.Equal(t, nil, err, "Error")
 		assert.Equal(t, http.StatusOK, delContext4.Response().Status, "Error")
-		err = store.DelResourceRecord("", eg1, "A")
+		_, err = store.DelResourceRecord("", eg1, "A")
 		assert.NotEqual(t, nil, err, errRecord)
 	})
 	t.Run("DeleteRequestEmptyFqdn", func(t *testing.T) {
@@ -398,9 +412,9 @@ func TestRestControllerOperations(t *testing.T) {
 			"Error")
 		assert.Equal(t, "www.example.com.\t32\tIN\tA\t192.168.1.102", (*rrResponse)[1].String(),
 			"Error")
-		err = store.DelResourceRecord("", eg, "A")
+		_, err = store.DelResourceRecord("", eg, "A")
 		assert.Equal(t, nil, err, errRecord)
-		err = store.DelResourceRecord("", eg1, "A")
+		_, err = store.DelResourceRecord("", eg1, "A")
 		assert.NotEqual(t, nil, err, errRecord)
 	})
 	t.Run("BasicOperationsOnAddRecordBindErr", func(t *testing.T) {
@@ -473,7 +487,7 @@ func TestRestControllerOperations(t *testing.T) {
 		c.SetParamValues("org")
 		err = mgmtCtl.handleAddResourceRecords(c)
 		assert.Equal(t, nil, err, "Error")
-		err = store.DelResourceRecord("", eg, "A")
+		_, err = store.DelResourceRecord("", eg, "A")
 		assert.Equal(t, nil, err, errRecord)
 	})
 
@@ -521,7 +535,7 @@ func TestRestControllerOperations(t *testing.T) {
 		c.SetParamValues(eg, "A")
 		err = mgmtCtl.handleSetResourceRecords(c)
 		assert.Equal(t, nil, err, "Error")
-		err = store.DelResourceRecord("", eg, "A")
+		_, err = store.DelResourceRecord("", eg, "A")
 		assert.Equal(t, nil, err, errRecord)
 	})
 	t.Run("BasicOperationsOnSetRecordInvalidInput", func(t *testing.T) {
@@ -554,7 +568,7 @@ func TestRestControllerOperations(t *testing.T) {
 		c.SetParamValues("A")
 		err = mgmtCtl.handleSetResourceRecords(c)
 		assert.Equal(t, nil, err, "Error")
-		err = store.DelResourceRecord("", eg, "A")
+		_, err = store.DelResourceRecord("", eg, "A")
 		assert.Equal(t, nil, err, errRecord)
 	})
 
@@ -588,7 +602,7 @@ func TestRestControllerOperations(t *testing.T) {
 		c.SetParamValues(eg+".in", "A")
 		err = mgmtCtl.handleSetResourceRecords(c)
 		assert.Equal(t, nil, err, "Error")
-		err = store.DelResourceRecord("", eg, "A")
+		_, err = store.DelResourceRecord("", eg, "A")
 		assert.Equal(t, nil, err, errRecord)
 	})
 	t.Run("BasicOperationsOnSetRecordInvalidRdata", func(t *testing.T) {
@@ -612,7 +626,7 @@ func TestRestControllerOperations(t *testing.T) {
 		c.SetParamValues("www.e.com.", "A")
 		err = mgmtCtl.handleSetResourceRecords(c)
 		assert.Equal(t, nil, err, "Error")
-		err = store.DelResourceRecord("", eg, "A")
+		_, err = store.DelResourceRecord("", eg, "A")
 		assert.Equal(t, nil, err, errRecord)
 	})
 	t.Run("BasicOperationsOnSetRecordRecordNotExists", func(t *testing.T) {
@@ -659,9 +673,1000 @@ func TestRestControllerOperations(t *testing.T) {
 		err = mgmtCtl.handleSetResourceRecords(c)
 		assert.Equal(t, nil, err, "Error")
 		patch.Reset()
-		err = store.DelResourceRecord("", eg, "A")
+		_, err = store.DelResourceRecord("", eg, "A")
 		assert.Equal(t, nil, err, errRecord)
 	})
+	t.Run("AddSRVRecord", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPost, url+"?zone=example.com.", strings.NewReader(rr_srv))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleAddResourceRecords(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+
+		rrResponse, _ := store.GetResourceRecord(&dns.Question{Name: "_http._tcp.example.com.",
+			Qtype: dns.TypeSRV, Qclass: dns.ClassINET})
+		assert.Equal(t, "_http._tcp.example.com.\t30\tIN\tSRV\t10 5 8080 app.example.com.",
+			(*rrResponse)[0].String(), "Error")
+		_, err = store.DelResourceRecord("example.com.", "_http._tcp.example.com.", "SRV")
+		assert.Equal(t, nil, err, errRecord)
+	})
+
+	t.Run("AddInvalidSRVRecord", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPost, url, strings.NewReader(rr_invalidSrv))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleAddResourceRecords(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+	})
+
+	t.Run("AddMultipleCNAMERecord", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPost, url, strings.NewReader(rr_multiCname))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleAddResourceRecords(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+	})
+
+	t.Run("AddWildcardRecord", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPost, url+"?zone=example.com.", strings.NewReader(rr_wildcard))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleAddResourceRecords(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+
+		rrResponse, _ := store.GetResourceRecord(&dns.Question{Name: "app1.video.example.com.",
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.Equal(t, "app1.video.example.com.\t30\tIN\tA\t192.168.1.100", (*rrResponse)[0].String(), "Error")
+
+		newRequest, err = http.NewRequest(http.MethodDelete, url+"/*.video.example.com./A", nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder = httptest.NewRecorder()
+		c = e.NewContext(newRequest, recorder)
+		c.SetParamNames("fqdn", "rrtype")
+		c.SetParamValues("*.video.example.com.", "A")
+		err = mgmtCtl.handleDeleteResourceRecord(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+	})
+
+	t.Run("AddInvalidWildcardRecord", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPost, url, strings.NewReader(rr_invalidWildcard))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleAddResourceRecords(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+	})
+
+	t.Run("AddSOANotAtZoneApex", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPost, url+"?zone=example.com.", strings.NewReader(rr_soaNotApex))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleAddResourceRecords(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+	})
+
 	//Cleanup Db
 	_ = os.RemoveAll(datastore.DBPath)
 }
+
+func TestForwardersOperations(t *testing.T) {
+	pool, _ := forward.NewPool(nil, forward.PolicyRoundRobin, time.Second)
+	mgmtCtl := &Controller{Forwarders: pool}
+	forwardersUrl := "/mep/dns_server_mgmt/v1/forwarders"
+
+	t.Run("SetForwarders", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPut, forwardersUrl, strings.NewReader(
+			"{\"policy\": \"fastest\", \"forwarders\": [\"192.168.1.1\", \"tls://1.1.1.1#cloudflare-dns.com\"]}"))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleSetForwarders(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+
+		newRequest, err = http.NewRequest(http.MethodGet, forwardersUrl, nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder = httptest.NewRecorder()
+		c = e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleGetForwarders(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		status := ForwardersStatus{}
+		_ = json.Unmarshal(recorder.Body.Bytes(), &status)
+		assert.Equal(t, forward.PolicyFastest, status.Policy, "Error")
+		assert.Equal(t, 2, len(status.Forwarders), "Error")
+		assert.Equal(t, "192.168.1.1:53", status.Forwarders[0].Address, "Error")
+		assert.Equal(t, "1.1.1.1:853", status.Forwarders[1].Address, "Error")
+		assert.Equal(t, true, status.Forwarders[1].TLS, "Error")
+	})
+
+	t.Run("SetInvalidForwarders", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPut, forwardersUrl, strings.NewReader(
+			"{\"forwarders\": [\"192.168.1.256\"]}"))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleSetForwarders(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+
+		newRequest, err = http.NewRequest(http.MethodPut, forwardersUrl, strings.NewReader(
+			"{\"policy\": \"random\", \"forwarders\": [\"192.168.1.1\"]}"))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder = httptest.NewRecorder()
+		c = e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleSetForwarders(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+	})
+}
+
+func TestCacheOperations(t *testing.T) {
+	responseCache := cache.New(10)
+	mgmtCtl := &Controller{Cache: responseCache}
+	cacheUrl := "/mep/dns_server_mgmt/v1/cache"
+
+	req := new(dns.Msg)
+	req.SetQuestion(eg, dns.TypeA)
+	rsp := new(dns.Msg)
+	rsp.SetReply(req)
+	rr, _ := dns.NewRR(rr_eg100)
+	rsp.Answer = []dns.RR{rr}
+	responseCache.Set(rsp)
+	_ = responseCache.Get(&req.Question[0])
+
+	t.Run("GetCacheStats", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodGet, cacheUrl, nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleGetCacheStats(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		stats := cache.Stats{}
+		_ = json.Unmarshal(recorder.Body.Bytes(), &stats)
+		assert.Equal(t, cache.Stats{Hits: 1, Misses: 0, Size: 1, Capacity: 10}, stats, "Error")
+	})
+
+	t.Run("FlushCache", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodDelete, cacheUrl, nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleFlushCache(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		assert.Equal(t, 0, responseCache.Stats().Size, "Error")
+	})
+
+	t.Run("CacheNotAvailable", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodDelete, cacheUrl, nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = (&Controller{}).handleFlushCache(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Error")
+	})
+}
+
+func TestListOperations(t *testing.T) {
+	defer func() {
+		_ = os.RemoveAll(datastore.DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	store := &datastore.BoltDB{FileName: "testlistdb", TTL: 30}
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+	defer store.Close()
+
+	mgmtCtl := &Controller{dataStore: store}
+	for _, entry := range []string{rr_entry, rr_entry1, rr_entry2} {
+		rr := &datastore.ResourceRecord{}
+		_ = json.Unmarshal([]byte(entry), rr)
+		err = store.SetResourceRecord(".", rr)
+		assert.Equal(t, nil, err, "Error")
+	}
+	rr := &datastore.ResourceRecord{}
+	_ = json.Unmarshal([]byte(rr_srv), rr)
+	err = store.SetResourceRecord("example.com.", rr)
+	assert.Equal(t, nil, err, "Error")
+
+	list := func(query string) (int, ResourceRecordList) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodGet, url+query, nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleListResourceRecords(c)
+		assert.Equal(t, nil, err, "Error")
+		records := ResourceRecordList{}
+		_ = json.Unmarshal(recorder.Body.Bytes(), &records)
+		return recorder.Code, records
+	}
+
+	t.Run("ListZones", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodGet, "/mep/dns_server_mgmt/v1/zones", nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleListZones(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		assert.Equal(t, "[\".\",\"example.com.\"]\n", recorder.Body.String(), "Error")
+	})
+
+	t.Run("ListAllRecords", func(t *testing.T) {
+		code, records := list("")
+		assert.Equal(t, http.StatusOK, code, "Error")
+		assert.Equal(t, 4, records.Total, "Error")
+		assert.Equal(t, 4, len(records.Records), "Error")
+		assert.Equal(t, "example.com.", records.Records[3].Zone, "Error")
+		assert.Equal(t, "SRV", records.Records[3].Type, "Error")
+	})
+
+	t.Run("ListFilteredRecords", func(t *testing.T) {
+		code, records := list("?zone=.&type=A")
+		assert.Equal(t, http.StatusOK, code, "Error")
+		assert.Equal(t, 3, records.Total, "Error")
+
+		code, records = list("?name=" + egOrg)
+		assert.Equal(t, http.StatusOK, code, "Error")
+		assert.Equal(t, 1, records.Total, "Error")
+		assert.Equal(t, []string{"192.168.1.102"}, records.Records[0].RData, "Error")
+
+		code, _ = list("?type=AAB")
+		assert.Equal(t, http.StatusBadRequest, code, "Error")
+	})
+
+	t.Run("ListPaginatedRecords", func(t *testing.T) {
+		code, records := list("?offset=1&limit=2")
+		assert.Equal(t, http.StatusOK, code, "Error")
+		assert.Equal(t, 4, records.Total, "Error")
+		assert.Equal(t, 2, len(records.Records), "Error")
+		assert.Equal(t, egOrg, records.Records[0].Name, "Error")
+
+		code, records = list("?offset=10")
+		assert.Equal(t, http.StatusOK, code, "Error")
+		assert.Equal(t, 0, len(records.Records), "Error")
+
+		code, _ = list("?limit=0")
+		assert.Equal(t, http.StatusBadRequest, code, "Error")
+		code, _ = list("?offset=-1")
+		assert.Equal(t, http.StatusBadRequest, code, "Error")
+	})
+
+	t.Run("GetRecord", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodGet, url+"/"+eg1+"/A", nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		c.SetParamNames("fqdn", "rrtype")
+		c.SetParamValues(eg1, "A")
+		err = mgmtCtl.handleGetResourceRecord(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		record := datastore.ZoneResourceRecord{}
+		_ = json.Unmarshal(recorder.Body.Bytes(), &record)
+		assert.Equal(t, datastore.ZoneResourceRecord{Zone: ".", ResourceRecord: datastore.ResourceRecord{
+			Name: eg1, Type: "A", Class: "IN", TTL: 30, RData: []string{"192.168.1.101"}}}, record, "Error")
+	})
+
+	t.Run("GetRecordNotFound", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodGet, url+"/"+eg1+"/AAAA", nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		c.SetParamNames("fqdn", "rrtype")
+		c.SetParamValues(eg1, "AAAA")
+		err = mgmtCtl.handleGetResourceRecord(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Error")
+	})
+}
+
+func TestZoneOperations(t *testing.T) {
+	defer func() {
+		_ = os.RemoveAll(datastore.DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	store := &datastore.BoltDB{FileName: "testzonedb", TTL: 30}
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+	defer store.Close()
+
+	mgmtCtl := &Controller{dataStore: store}
+	zonesUrl := "/mep/dns_server_mgmt/v1/zones"
+	zoneFile := "$ORIGIN example.com.\n" +
+		"@   30 IN SOA ns1 admin 1 3600 600 86400 10\n" +
+		"www 30 IN A   192.168.1.101\n" +
+		"www 30 IN A   192.168.1.102\n" +
+		"app 30 IN CNAME www\n"
+
+	t.Run("CreateZone", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPost, zonesUrl, strings.NewReader("{\"zone\": \"example.com.\"}"))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleCreateZone(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+
+		zones, _ := store.ListZones()
+		assert.Equal(t, []string{".", "example.com."}, zones, "Error")
+	})
+
+	t.Run("CreateInvalidZone", func(t *testing.T) {
+		for _, body := range []string{"{\"zone\": \"example.com\"}", "{\"zone\": \"*.example.com.\"}",
+			"{\"zone\": \"example.com.\"}"} {
+			e := echo.New()
+			newRequest, err := http.NewRequest(http.MethodPost, zonesUrl, strings.NewReader(body))
+			assert.Equal(t, nil, err, "Error")
+			newRequest.Header.Set(cont, appj)
+			recorder := httptest.NewRecorder()
+			c := e.NewContext(newRequest, recorder)
+			err = mgmtCtl.handleCreateZone(c)
+			assert.Equal(t, nil, err, "Error")
+			assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+		}
+	})
+
+	t.Run("ImportZoneFile", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPut, zoneFileUrl+"?zone=example.com.",
+			strings.NewReader(zoneFile))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, zoneFileMIME)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleImportZoneFile(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		assert.Equal(t, "success in importing 3 entries.", recorder.Body.String(), "Error")
+
+		rrResponse, _ := store.GetResourceRecord(&dns.Question{Name: "app.example.com.",
+			Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.Equal(t, 3, len(*rrResponse), "Error")
+	})
+
+	t.Run("ImportInvalidZoneFile", func(t *testing.T) {
+		for _, body := range []string{"www 30 IN A 192.168.1\n", "www 30 IN A 255.255.255.255\n",
+			"www.example.org. 30 IN A 192.168.1.1\n"} {
+			e := echo.New()
+			newRequest, err := http.NewRequest(http.MethodPut, zoneFileUrl+"?zone=example.com.",
+				strings.NewReader(body))
+			assert.Equal(t, nil, err, "Error")
+			recorder := httptest.NewRecorder()
+			c := e.NewContext(newRequest, recorder)
+			err = mgmtCtl.handleImportZoneFile(c)
+			assert.Equal(t, nil, err, "Error")
+			assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+		}
+		// Zone is not modified
+		records, _ := store.ListResourceRecords(&datastore.RecordFilter{Zone: "example.com."})
+		assert.Equal(t, 3, len(records), "Error")
+	})
+
+	t.Run("ExportZoneFile", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodGet, zoneFileUrl+"?zone=example.com.", nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleExportZoneFile(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		assert.Equal(t, zoneFileMIME, recorder.Header().Get(echo.HeaderContentType), "Error")
+		assert.Equal(t, "$ORIGIN example.com.\n"+
+			"example.com.\t30\tIN\tSOA\tns1.example.com. admin.example.com. 1 3600 600 86400 10\n"+
+			"app.example.com.\t30\tIN\tCNAME\twww.example.com.\n"+
+			"www.example.com.\t30\tIN\tA\t192.168.1.101\n"+
+			"www.example.com.\t30\tIN\tA\t192.168.1.102\n", recorder.Body.String(), "Error")
+	})
+
+	t.Run("ExportUnknownZoneFile", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodGet, zoneFileUrl+"?zone=example.org.", nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleExportZoneFile(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Error")
+	})
+
+	t.Run("SetZoneOptions", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPut, zoneOptionsUrl+"?zone=example.com.",
+			strings.NewReader("{\"autoPtr\": true}"))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleSetZoneOptions(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+
+		rrResponse, err := store.GetResourceRecord(&dns.Question{Name: "101.1.168.192.in-addr.arpa.",
+			Qtype: dns.TypePTR, Qclass: dns.ClassINET})
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, "101.1.168.192.in-addr.arpa.\t30\tIN\tPTR\twww.example.com.", (*rrResponse)[0].String(),
+			"Error")
+	})
+
+	t.Run("GetZoneOptions", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodGet, zoneOptionsUrl+"?zone=example.com.", nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleGetZoneOptions(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		assert.Equal(t, "{\"autoPtr\":true}\n", recorder.Body.String(), "Error")
+	})
+
+	t.Run("UnknownZoneOptions", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPut, zoneOptionsUrl+"?zone=example.org.",
+			strings.NewReader("{\"autoPtr\": true}"))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleSetZoneOptions(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Error")
+
+		recorder = httptest.NewRecorder()
+		c = e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleGetZoneOptions(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Error")
+	})
+
+	t.Run("DeleteZone", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodDelete, zonesUrl+"/example.com.", nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		c.SetParamNames("zone")
+		c.SetParamValues("example.com.")
+		err = mgmtCtl.handleDeleteZone(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+
+		records, _ := store.ListResourceRecords(&datastore.RecordFilter{Zone: "example.com."})
+		assert.Equal(t, 0, len(records), "Error")
+		records, _ = store.ListResourceRecords(&datastore.RecordFilter{Type: "PTR"})
+		assert.Equal(t, 0, len(records), "Error")
+
+		recorder = httptest.NewRecorder()
+		c = e.NewContext(newRequest, recorder)
+		c.SetParamNames("zone")
+		c.SetParamValues("example.com.")
+		err = mgmtCtl.handleDeleteZone(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Error")
+	})
+
+	t.Run("DeleteDefaultZone", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodDelete, zonesUrl+"/.", nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		c.SetParamNames("zone")
+		c.SetParamValues(".")
+		err = mgmtCtl.handleDeleteZone(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+	})
+}
+
+func TestBatchOperations(t *testing.T) {
+	defer func() {
+		_ = os.RemoveAll(datastore.DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	store := &datastore.BoltDB{FileName: "testbatchdb", TTL: 30}
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+	defer store.Close()
+
+	mgmtCtl := &Controller{dataStore: store}
+	batch := func(body string) (int, BatchResponse) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPost, batchUrl, strings.NewReader(body))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleBatchResourceRecords(c)
+		assert.Equal(t, nil, err, "Error")
+		rsp := BatchResponse{}
+		_ = json.Unmarshal(recorder.Body.Bytes(), &rsp)
+		return recorder.Code, rsp
+	}
+
+	t.Run("BatchAdd", func(t *testing.T) {
+		code, rsp := batch("{\"operations\": [{\"op\": \"add\", \"rr\": " + rr_entry + "}, " +
+			"{\"op\": \"add\", \"zone\": \"example.com.\", \"rr\": " + rr_srv + "}]}")
+		assert.Equal(t, http.StatusOK, code, "Error")
+		assert.Equal(t, true, rsp.Applied, "Error")
+		assert.Equal(t, 2, len(rsp.Results), "Error")
+
+		records, _ := store.ListResourceRecords(&datastore.RecordFilter{})
+		assert.Equal(t, 2, len(records), "Error")
+	})
+
+	t.Run("BatchInvalidOperation", func(t *testing.T) {
+		code, rsp := batch("{\"operations\": [{\"op\": \"add\", \"rr\": " + rr_entry1 + "}, " +
+			"{\"op\": \"add\", \"rr\": " + rr_invalidIP + "}]}")
+		assert.Equal(t, http.StatusBadRequest, code, "Error")
+		assert.Equal(t, false, rsp.Applied, "Error")
+		assert.Equal(t, datastore.BatchRolledBack, rsp.Results[0].Status, "Error")
+		assert.Equal(t, datastore.BatchFailed, rsp.Results[1].Status, "Error")
+
+		records, _ := store.ListResourceRecords(&datastore.RecordFilter{})
+		assert.Equal(t, 2, len(records), "Error")
+	})
+
+	t.Run("BatchRolledBack", func(t *testing.T) {
+		code, rsp := batch("{\"operations\": [" +
+			"{\"op\": \"delete\", \"rr\": {\"name\": \"www.example.com.\", \"type\": \"A\"}}, " +
+			"{\"op\": \"update\", \"rr\": " + rr_entry2 + "}]}")
+		assert.Equal(t, http.StatusBadRequest, code, "Error")
+		assert.Equal(t, false, rsp.Applied, "Error")
+		assert.Equal(t, []datastore.BatchResult{{Index: 0, Status: datastore.BatchRolledBack},
+			{Index: 1, Status: datastore.BatchFailed, Error: "record not exist, cannot update"}}, rsp.Results,
+			"Error")
+
+		rrResponse, _ := store.GetResourceRecord(&dns.Question{Name: eg, Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		assert.Equal(t, rr_eg100, (*rrResponse)[0].String(), "Error")
+	})
+
+	t.Run("BatchEmpty", func(t *testing.T) {
+		code, _ := batch("{\"operations\": []}")
+		assert.Equal(t, http.StatusBadRequest, code, "Error")
+	})
+}
+
+func TestViewOperations(t *testing.T) {
+	defer func() {
+		_ = os.RemoveAll(datastore.DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	store := &datastore.BoltDB{FileName: "testviewdb", TTL: 30}
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+	defer store.Close()
+
+	mgmtCtl := &Controller{dataStore: store}
+	viewsUrl := "/mep/dns_server_mgmt/v1/views"
+
+	setView := func(view string, body string) int {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPut, viewsUrl+"/"+view, strings.NewReader(body))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		c.SetParamNames("view")
+		c.SetParamValues(view)
+		err = mgmtCtl.handleSetView(c)
+		assert.Equal(t, nil, err, "Error")
+		return recorder.Code
+	}
+
+	t.Run("SetView", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, setView("ue", "{\"cidrs\": [\"10.0.0.0/8\"]}"), "Error")
+		assert.Equal(t, http.StatusBadRequest, setView("ue.1", "{\"cidrs\": [\"10.0.0.0/8\"]}"), "Error")
+		assert.Equal(t, http.StatusBadRequest, setView("mgmt", "{\"cidrs\": []}"), "Error")
+		assert.Equal(t, http.StatusBadRequest, setView("mgmt", "{\"cidrs\": [\"10.0.0.0/8\"]}"), "Error")
+
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodGet, viewsUrl, nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleListViews(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, "[{\"name\":\"ue\",\"cidrs\":[\"10.0.0.0/8\"]}]\n", recorder.Body.String(), "Error")
+	})
+
+	t.Run("AddRecordToView", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPost, url+"?view=ue", strings.NewReader(rr_entry))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleAddResourceRecords(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+
+		records, _ := store.WithView("ue").ListResourceRecords(&datastore.RecordFilter{})
+		assert.Equal(t, 1, len(records), "Error")
+		records, _ = store.ListResourceRecords(&datastore.RecordFilter{})
+		assert.Equal(t, 0, len(records), "Error")
+	})
+
+	t.Run("AddRecordToUnknownView", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPost, url+"?view=app", strings.NewReader(rr_entry))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleAddResourceRecords(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+		assert.Equal(t, "view(app) not found", recorder.Body.String(), "Error")
+	})
+
+	t.Run("DeleteView", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodDelete, viewsUrl+"/ue", nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		c.SetParamNames("view")
+		c.SetParamValues("ue")
+		err = mgmtCtl.handleDeleteView(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+
+		recorder = httptest.NewRecorder()
+		c = e.NewContext(newRequest, recorder)
+		c.SetParamNames("view")
+		c.SetParamValues("ue")
+		err = mgmtCtl.handleDeleteView(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Error")
+	})
+}
+
+func TestNotifyOperations(t *testing.T) {
+	defer func() {
+		_ = os.RemoveAll(datastore.DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	store := &datastore.BoltDB{FileName: "testnotifydb", TTL: 30}
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+	defer store.Close()
+	err = store.SetResourceRecord("example.com.", &datastore.ResourceRecord{Name: "example.com.", Type: "SOA",
+		Class: "IN", TTL: 30, RData: []string{"ns1.example.com. admin.example.com. 1 3600 600 86400 10"}})
+	assert.Equal(t, nil, err, "Error")
+	err = store.SetView(&datastore.View{Name: "ue", CIDRs: []string{"10.0.0.0/8"}})
+	assert.Equal(t, nil, err, "Error")
+
+	secondary, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.Equal(t, nil, err, "Error")
+	defer secondary.Close()
+	mgmtCtl := &Controller{dataStore: store,
+		Notifier: notify.New([]string{secondary.LocalAddr().String()}, time.Second)}
+
+	// receiveNotify get the zone of the NOTIFY received by the secondary, empty if none received
+	receiveNotify := func() string {
+		buf := make([]byte, dns.MinMsgSize)
+		_ = secondary.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		n, addr, err := secondary.ReadFrom(buf)
+		if err != nil {
+			return ""
+		}
+		req := new(dns.Msg)
+		if req.Unpack(buf[:n]) != nil || req.Opcode != dns.OpcodeNotify {
+			return ""
+		}
+		rsp := new(dns.Msg)
+		rsp.SetReply(req)
+		rspBytes, _ := rsp.Pack()
+		_, _ = secondary.WriteTo(rspBytes, addr)
+		return req.Question[0].Name
+	}
+	addRecord := func(query string) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPost, url+query, strings.NewReader(rr_entry))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleAddResourceRecords(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+	}
+
+	t.Run("ChangeInAuthoritativeZone", func(t *testing.T) {
+		addRecord("?zone=example.com.")
+		assert.Equal(t, "example.com.", receiveNotify(), "Error")
+	})
+
+	t.Run("ChangeInNonAuthoritativeZone", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodPost, url, strings.NewReader(rr_entry1))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleAddResourceRecords(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, "", receiveNotify(), "Error")
+	})
+
+	t.Run("DeleteNotifiesZoneOfRecord", func(t *testing.T) {
+		// Zone not given, the record is found in its zone
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodDelete, "/mep/dns_server_mgmt/v1/rrecord/www.example.com./A",
+			strings.NewReader(""))
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		c.SetParamNames("fqdn", "rrtype")
+		c.SetParamValues(eg, "A")
+		err = mgmtCtl.handleDeleteResourceRecord(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		assert.Equal(t, "example.com.", receiveNotify(), "Error")
+	})
+
+	t.Run("ChangeInView", func(t *testing.T) {
+		addRecord("?zone=example.com.&view=ue")
+		assert.Equal(t, "", receiveNotify(), "Error")
+	})
+}
+
+func TestHealthCheckOperations(t *testing.T) {
+	monitor := health.New()
+	defer monitor.Stop()
+	mgmtCtl := &Controller{Health: monitor}
+	healthCheckUrl := "/mep/dns_server_mgmt/v1/healthchecks"
+
+	t.Run("ValidateAnswerPolicy", func(t *testing.T) {
+		rr := &datastore.ResourceRecord{Name: eg, Type: "A", Class: "IN", TTL: 30,
+			RData: []string{"192.168.1.1", "192.168.1.2"}, Weights: []uint32{3, 1}, MaxAnswers: 1,
+			HealthCheck: &datastore.HealthCheck{Protocol: "http", Port: 8080, Path: "/health"}}
+		assert.Equal(t, nil, mgmtCtl.validateSetRecordInput("example.com.", rr), "Error")
+
+		rr.Weights = []uint32{1}
+		assert.NotEqual(t, nil, mgmtCtl.validateSetRecordInput("example.com.", rr), "Error")
+		rr.Weights = nil
+		rr.HealthCheck.Protocol = "udp"
+		assert.EqualError(t, mgmtCtl.validateSetRecordInput("example.com.", rr),
+			"unsupported health check protocol(udp)", "Error")
+	})
+
+	t.Run("GetHealthChecks", func(t *testing.T) {
+		monitor.IsHealthy(health.Target{Protocol: health.ProtocolTCP, Address: "127.0.0.1:1",
+			Interval: time.Minute, Timeout: time.Second})
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodGet, healthCheckUrl, nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = mgmtCtl.handleGetHealthChecks(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		var status []health.TargetStatus
+		_ = json.Unmarshal(recorder.Body.Bytes(), &status)
+		assert.Equal(t, 1, len(status), "Error")
+		assert.Equal(t, "127.0.0.1:1", status[0].Address, "Error")
+	})
+
+	t.Run("HealthCheckNotAvailable", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodGet, healthCheckUrl, nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = (&Controller{}).handleGetHealthChecks(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Error")
+	})
+}
+
+func TestRateLimitStats(t *testing.T) {
+	rateLimitUrl := "/mep/dns_server_mgmt/v1/ratelimit"
+
+	t.Run("GetRateLimitStats", func(t *testing.T) {
+		limiter := ratelimit.New(1, 2, nil)
+		ip := net.ParseIP("192.168.1.1")
+		for i := 0; i < 4; i++ {
+			limiter.Check(ip, eg)
+		}
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodGet, rateLimitUrl, nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = (&Controller{RateLimiter: limiter}).handleGetRateLimitStats(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		var stats ratelimit.Stats
+		_ = json.Unmarshal(recorder.Body.Bytes(), &stats)
+		assert.Equal(t, ratelimit.Stats{Rate: 1, Slip: 2, Dropped: 2, Slipped: 1, Tracked: 1}, stats, "Error")
+	})
+
+	t.Run("RateLimitNotAvailable", func(t *testing.T) {
+		e := echo.New()
+		newRequest, err := http.NewRequest(http.MethodGet, rateLimitUrl, nil)
+		assert.Equal(t, nil, err, "Error")
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		err = (&Controller{}).handleGetRateLimitStats(c)
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Error")
+	})
+}
+
+func TestDNSSECOperations(t *testing.T) {
+	defer func() {
+		_ = os.RemoveAll(datastore.DBPath)
+		if r := recover(); r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	store := &datastore.BoltDB{FileName: "testdnssecdb", TTL: 30}
+	err := store.Open()
+	assert.Equal(t, nil, err, "Error in opening the db")
+	defer store.Close()
+	assert.Equal(t, nil, store.CreateZone("example.com."), "Error")
+
+	mgmtCtl := &Controller{dataStore: store, Signer: dnssec.New(store)}
+	request := func(method string, target string, body string, handler func(c echo.Context) error,
+		params ...string) *httptest.ResponseRecorder {
+		e := echo.New()
+		newRequest, err := http.NewRequest(method, target, strings.NewReader(body))
+		assert.Equal(t, nil, err, "Error")
+		newRequest.Header.Set(cont, appj)
+		recorder := httptest.NewRecorder()
+		c := e.NewContext(newRequest, recorder)
+		if len(params) != 0 {
+			c.SetParamNames(params[0])
+			c.SetParamValues(params[1])
+		}
+		assert.Equal(t, nil, handler(c), "Error")
+		return recorder
+	}
+	readStatus := func(recorder *httptest.ResponseRecorder) DNSSECStatus {
+		var status DNSSECStatus
+		_ = json.Unmarshal(recorder.Body.Bytes(), &status)
+		return status
+	}
+
+	t.Run("EnableNotAuthoritative", func(t *testing.T) {
+		recorder := request(http.MethodPost, dnssecUrl+"?zone=example.com.", "{}", mgmtCtl.handleEnableDNSSEC)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+		recorder = request(http.MethodPost, dnssecUrl+"?zone=example.org.", "{}", mgmtCtl.handleEnableDNSSEC)
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Error")
+	})
+
+	t.Run("Enable", func(t *testing.T) {
+		err := store.SetResourceRecord("example.com.", &datastore.ResourceRecord{Name: "example.com.", Type: "SOA",
+			Class: "IN", TTL: 30, RData: []string{"ns1.example.com. admin.example.com. 1 3600 600 86400 10"}})
+		assert.Equal(t, nil, err, "Error in setting the record")
+
+		recorder := request(http.MethodPost, dnssecUrl+"?zone=example.com.", "{\"algorithm\": \"RSAMD5\"}",
+			mgmtCtl.handleEnableDNSSEC)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+		recorder = request(http.MethodPost, dnssecUrl+"?zone=example.com.", "{}", mgmtCtl.handleEnableDNSSEC)
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		status := readStatus(recorder)
+		assert.Equal(t, true, status.Signed, "Error")
+		assert.Equal(t, 2, len(status.Keys), "Error")
+		assert.Equal(t, dnssec.KeyTypeKSK, status.Keys[0].Type, "Error")
+		assert.Equal(t, "ECDSAP256SHA256", status.Keys[1].Algorithm, "Error")
+		assert.NotContains(t, recorder.Body.String(), "Private", "Error")
+		assert.Equal(t, 2, len(mgmtCtl.Signer.DNSKEY("example.com.")), "Error")
+
+		recorder = request(http.MethodPost, dnssecUrl+"?zone=example.com.", "{}", mgmtCtl.handleEnableDNSSEC)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+	})
+
+	t.Run("RolloverZSK", func(t *testing.T) {
+		recorder := request(http.MethodPost, dnssecUrl+"/keys?zone=example.com.", "{\"type\": \"zsk\"}",
+			mgmtCtl.handleAddZoneKey)
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		status := readStatus(recorder)
+		assert.Equal(t, 3, len(status.Keys), "Error")
+		assert.Equal(t, []bool{true, false, true}, []bool{status.Keys[0].Active, status.Keys[1].Active,
+			status.Keys[2].Active}, "Error")
+		assert.Equal(t, 3, len(mgmtCtl.Signer.DNSKEY("example.com.")), "Error")
+
+		// Active key is kept, the retired one is deleted
+		recorder = request(http.MethodDelete, dnssecUrl+"/keys/?zone=example.com.", "",
+			mgmtCtl.handleDeleteZoneKey, "keytag", fmt.Sprint(status.Keys[2].KeyTag))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+		recorder = request(http.MethodDelete, dnssecUrl+"/keys/?zone=example.com.", "",
+			mgmtCtl.handleDeleteZoneKey, "keytag", fmt.Sprint(status.Keys[1].KeyTag))
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		recorder = request(http.MethodDelete, dnssecUrl+"/keys/?zone=example.com.", "",
+			mgmtCtl.handleDeleteZoneKey, "keytag", fmt.Sprint(status.Keys[1].KeyTag))
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Error")
+
+		recorder = request(http.MethodGet, dnssecUrl+"?zone=example.com.", "", mgmtCtl.handleGetDNSSEC)
+		assert.Equal(t, 2, len(readStatus(recorder).Keys), "Error")
+	})
+
+	t.Run("ExportDS", func(t *testing.T) {
+		recorder := request(http.MethodGet, dnssecUrl+"/ds?zone=example.com.&digest=SHA384", "",
+			mgmtCtl.handleExportDS)
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		assert.Equal(t, zoneFileMIME, recorder.Header().Get(echo.HeaderContentType), "Error")
+		ds, err := dns.NewRR(recorder.Body.String())
+		assert.Equal(t, nil, err, "Error")
+		assert.Equal(t, dns.SHA384, ds.(*dns.DS).DigestType, "Error")
+		assert.Equal(t, readStatus(request(http.MethodGet, dnssecUrl+"?zone=example.com.", "",
+			mgmtCtl.handleGetDNSSEC)).Keys[0].KeyTag, ds.(*dns.DS).KeyTag, "Error")
+
+		recorder = request(http.MethodGet, dnssecUrl+"/ds?zone=example.com.&digest=MD5", "", mgmtCtl.handleExportDS)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code, "Error")
+	})
+
+	t.Run("Disable", func(t *testing.T) {
+		recorder := request(http.MethodDelete, dnssecUrl+"?zone=example.com.", "", mgmtCtl.handleDisableDNSSEC)
+		assert.Equal(t, http.StatusOK, recorder.Code, "Error")
+		assert.Equal(t, 0, len(mgmtCtl.Signer.DNSKEY("example.com.")), "Error")
+
+		recorder = request(http.MethodGet, dnssecUrl+"/ds?zone=example.com.", "", mgmtCtl.handleExportDS)
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Error")
+		recorder = request(http.MethodPost, dnssecUrl+"/keys?zone=example.com.", "{\"type\": \"ksk\"}",
+			mgmtCtl.handleAddZoneKey)
+		assert.Equal(t, http.StatusNotFound, recorder.Code, "Error")
+	})
+}
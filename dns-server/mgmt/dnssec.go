@@ -0,0 +1,272 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mgmt
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	"dns-server/datastore"
+	"dns-server/dnssec"
+)
+
+// DNSSECRequest zone signing request, default algorithm if empty.
+type DNSSECRequest struct {
+	Algorithm string `json:"algorithm"`
+}
+
+// ZoneKeyRequest key rollover request, new key of the type replaces the active keys of the type.
+type ZoneKeyRequest struct {
+	Type string `json:"type"`
+}
+
+// DNSSECStatus DNSSEC keys of a zone.
+type DNSSECStatus struct {
+	Zone   string             `json:"zone"`
+	Signed bool               `json:"signed"`
+	Keys   []dnssec.KeyStatus `json:"keys"`
+}
+
+// dsDigests digest types of the exported DS records.
+var dsDigests = map[string]uint8{"SHA1": dns.SHA1, "SHA256": dns.SHA256, "SHA384": dns.SHA384}
+
+// getDNSSECZone get the zone of the DNSSEC request, keys belong to the zones of the default view.
+func (e *Controller) getDNSSECZone(c echo.Context) (string, int, string) {
+	zone := c.QueryParam("zone")
+	if err := validateZoneName(zone); err != nil {
+		return "", http.StatusBadRequest, "invalid input parameters!"
+	}
+	if !isZoneExists(e.dataStore, zone) {
+		return "", http.StatusNotFound, "zone not found!"
+	}
+
+	return zone, http.StatusOK, ""
+}
+
+func (e *Controller) handleGetDNSSEC(c echo.Context) error {
+	zone, code, msg := e.getDNSSECZone(c)
+	if code != http.StatusOK {
+		return c.String(code, msg)
+	}
+
+	return e.writeDNSSECStatus(c, zone)
+}
+
+func (e *Controller) handleEnableDNSSEC(c echo.Context) error {
+	// Input Example:
+	//{
+	//	"algorithm": "ECDSAP256SHA256"
+	//}
+	zone, code, msg := e.getDNSSECZone(c)
+	if code != http.StatusOK {
+		return c.String(code, msg)
+	}
+	req := DNSSECRequest{}
+	if nil != c.Bind(&req) {
+		log.Error("Error in parsing the dnssec post request body.", nil)
+		return c.String(http.StatusBadRequest, invalidInputErr)
+	}
+	algorithm, err := dnssec.ParseAlgorithm(req.Algorithm)
+	if err != nil {
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+
+	// Negative answers are signed along with the SOA
+	soa, err := e.dataStore.ListResourceRecords(&datastore.RecordFilter{Zone: zone, Name: zone, Type: "SOA"})
+	if err != nil || len(soa) == 0 {
+		return c.String(http.StatusBadRequest, "only the authoritative zones can be signed!")
+	}
+	keys, err := e.dataStore.GetZoneKeys(zone)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Error in retrieving the data.")
+	}
+	if len(keys) != 0 {
+		return c.String(http.StatusBadRequest, "zone already signed!")
+	}
+
+	for _, keyType := range []string{dnssec.KeyTypeKSK, dnssec.KeyTypeZSK} {
+		key, err := dnssec.GenerateKey(zone, keyType, algorithm)
+		if err != nil {
+			log.Errorf("Failed to generate the keys of zone(%s). %s", zone, err.Error())
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		keys = append(keys, *key)
+	}
+	if err = e.dataStore.SetZoneKeys(zone, keys); err != nil {
+		log.Errorf("Failed to save the keys of zone(%s). %s", zone, err.Error())
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	e.Signer.Invalidate()
+	log.Infof("Enabled DNSSEC of zone(%s) with %s keys.", zone, dns.AlgorithmToString[algorithm])
+
+	return e.writeDNSSECStatus(c, zone)
+}
+
+func (e *Controller) handleDisableDNSSEC(c echo.Context) error {
+	zone, code, msg := e.getDNSSECZone(c)
+	if code != http.StatusOK {
+		return c.String(code, msg)
+	}
+
+	if err := e.dataStore.SetZoneKeys(zone, nil); err != nil {
+		log.Errorf("Failed to delete the keys of zone(%s). %s", zone, err.Error())
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	e.Signer.Invalidate()
+	log.Infof("Disabled DNSSEC of zone(%s).", zone)
+
+	return c.String(http.StatusOK, "Success")
+}
+
+func (e *Controller) handleAddZoneKey(c echo.Context) error {
+	// Input Example:
+	//{
+	//	"type": "zsk"
+	//}
+	zone, code, msg := e.getDNSSECZone(c)
+	if code != http.StatusOK {
+		return c.String(code, msg)
+	}
+	req := ZoneKeyRequest{}
+	if nil != c.Bind(&req) || (req.Type != dnssec.KeyTypeKSK && req.Type != dnssec.KeyTypeZSK) {
+		log.Error("Error in parsing the zone key post request body.", nil)
+		return c.String(http.StatusBadRequest, invalidInputErr)
+	}
+	keys, err := e.dataStore.GetZoneKeys(zone)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Error in retrieving the data.")
+	}
+	if len(keys) == 0 {
+		return c.String(http.StatusNotFound, "zone not signed!")
+	}
+
+	// Previous keys of the type stay published until deleted, so the cached signatures remain valid
+	algorithm := uint8(0)
+	for i := range keys {
+		dnskey, err := dnssec.ParseDNSKEY(&keys[i])
+		if err != nil {
+			return c.String(http.StatusInternalServerError, err.Error())
+		}
+		if dnssec.KeyType(dnskey) == req.Type {
+			keys[i].Active = false
+		}
+		algorithm = dnskey.Algorithm
+	}
+	key, err := dnssec.GenerateKey(zone, req.Type, algorithm)
+	if err != nil {
+		log.Errorf("Failed to generate the %s of zone(%s). %s", req.Type, zone, err.Error())
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	if err = e.dataStore.SetZoneKeys(zone, append(keys, *key)); err != nil {
+		log.Errorf("Failed to save the keys of zone(%s). %s", zone, err.Error())
+		return c.String(http.StatusBadRequest, err.Error())
+	}
+	e.Signer.Invalidate()
+	log.Infof("Rolled over the %s of zone(%s).", req.Type, zone)
+
+	return e.writeDNSSECStatus(c, zone)
+}
+
+func (e *Controller) handleDeleteZoneKey(c echo.Context) error {
+	zone, code, msg := e.getDNSSECZone(c)
+	if code != http.StatusOK {
+		return c.String(code, msg)
+	}
+	keyTag, err := strconv.ParseUint(c.Param("keytag"), 10, 16)
+	if err != nil {
+		return c.String(http.StatusBadRequest, "invalid input parameters!")
+	}
+	keys, err := e.dataStore.GetZoneKeys(zone)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Error in retrieving the data.")
+	}
+
+	for i := range keys {
+		dnskey, err := dnssec.ParseDNSKEY(&keys[i])
+		if err != nil || dnskey.KeyTag() != uint16(keyTag) {
+			continue
+		}
+		if keys[i].Active {
+			return c.String(http.StatusBadRequest, "active key cannot be deleted!")
+		}
+		if err = e.dataStore.SetZoneKeys(zone, append(keys[:i], keys[i+1:]...)); err != nil {
+			log.Errorf("Failed to save the keys of zone(%s). %s", zone, err.Error())
+			return c.String(http.StatusBadRequest, err.Error())
+		}
+		e.Signer.Invalidate()
+		log.Infof("Deleted key(%d) of zone(%s).", keyTag, zone)
+		return c.String(http.StatusOK, "Success")
+	}
+
+	return c.String(http.StatusNotFound, "key not found!")
+}
+
+func (e *Controller) handleExportDS(c echo.Context) error {
+	// Query Example: ?zone=example.com.&digest=SHA256
+	zone, code, msg := e.getDNSSECZone(c)
+	if code != http.StatusOK {
+		return c.String(code, msg)
+	}
+	digestName := c.QueryParam("digest")
+	if len(digestName) == 0 {
+		digestName = "SHA256"
+	}
+	digest, ok := dsDigests[strings.ToUpper(digestName)]
+	if !ok {
+		return c.String(http.StatusBadRequest, "invalid input parameters!")
+	}
+	keys, err := e.dataStore.GetZoneKeys(zone)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Error in retrieving the data.")
+	}
+	if len(keys) == 0 {
+		return c.String(http.StatusNotFound, "zone not signed!")
+	}
+
+	records, err := dnssec.DS(keys, digest)
+	if err != nil {
+		log.Errorf("Failed to generate the DS of zone(%s). %s", zone, err.Error())
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+	var ds bytes.Buffer
+	for _, record := range records {
+		ds.WriteString(record.String())
+		ds.WriteString("\n")
+	}
+
+	return c.Blob(http.StatusOK, zoneFileMIME, ds.Bytes())
+}
+
+// writeDNSSECStatus respond the keys of the zone, without the private keys.
+func (e *Controller) writeDNSSECStatus(c echo.Context, zone string) error {
+	keys, err := e.dataStore.GetZoneKeys(zone)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, "Error in retrieving the data.")
+	}
+	status, err := dnssec.Status(keys)
+	if err != nil {
+		return c.String(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, DNSSECStatus{Zone: zone, Signed: len(keys) != 0, Keys: status})
+}
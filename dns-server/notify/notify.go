@@ -0,0 +1,107 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package notify DNS NOTIFY(RFC 1996) of the zone changes to the secondary servers
+package notify
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	"dns-server/util"
+)
+
+// Notifier sends NOTIFY to the secondaries when a zone changes.
+type Notifier struct {
+	secondaries   []string
+	client        *dns.Client
+	retryInterval time.Duration
+}
+
+// New create a notifier for the secondaries in ip:port format.
+func New(secondaries []string, timeout time.Duration) *Notifier {
+	return &Notifier{
+		secondaries:   secondaries,
+		client:        &dns.Client{Net: "udp", Timeout: timeout},
+		retryInterval: util.NotifyRetryInterval * time.Second,
+	}
+}
+
+// ParseSecondary parse the secondary in the format ip[:port], dns port is used when the port is not specified.
+func ParseSecondary(spec string) (string, error) {
+	spec = strings.TrimSpace(spec)
+	host, port := spec, strconv.Itoa(util.DefaultDNSPort)
+	if h, p, err := net.SplitHostPort(spec); err == nil {
+		host, port = h, p
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", fmt.Errorf("error: parsing secondary failed, not in ipv4/ipv6 format")
+	}
+	if ip.IsMulticast() || ip.Equal(net.IPv4bcast) {
+		return "", fmt.Errorf("error: multicast or broadcast ip address ")
+	}
+	portNo, err := strconv.Atoi(port)
+	if err != nil || portNo <= 0 || portNo > util.MaxPortNumber {
+		return "", fmt.Errorf("error: secondary port number not in valid range")
+	}
+
+	return net.JoinHostPort(ip.String(), port), nil
+}
+
+// Notify send NOTIFY of the zone to all the secondaries in the background.
+func (n *Notifier) Notify(zone string) {
+	if n == nil {
+		return
+	}
+	for _, secondary := range n.secondaries {
+		go func(secondary string) {
+			if err := n.notify(zone, secondary); err != nil {
+				log.Errorf("Failed to notify secondary(%s) of zone(%s) change. %s", secondary, zone, err.Error())
+			}
+		}(secondary)
+	}
+}
+
+// notify send NOTIFY of the zone to the secondary, retrying until it is acknowledged.
+func (n *Notifier) notify(zone string, secondary string) error {
+	req := new(dns.Msg)
+	req.SetNotify(dns.Fqdn(zone))
+
+	var err error
+	for attempt := 1; attempt <= util.NotifyRetries; attempt++ {
+		var rsp *dns.Msg
+		rsp, _, err = n.client.Exchange(req, secondary)
+		if err == nil && (rsp.Opcode != dns.OpcodeNotify || rsp.Rcode != dns.RcodeSuccess) {
+			err = fmt.Errorf("notify not acknowledged, rcode %s", dns.RcodeToString[rsp.Rcode])
+		}
+		if err == nil {
+			log.Debugf("Notified secondary(%s) of zone(%s) change.", secondary, zone)
+			return nil
+		}
+		if attempt < util.NotifyRetries {
+			time.Sleep(n.retryInterval)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testZone      = "example.com."
+	errorInNotify = "Error in notify"
+)
+
+// startSecondary start a secondary on a free udp port, answering NOTIFY with the given rcode.
+func startSecondary(t *testing.T, rcode int, received chan<- string) (string, func()) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen on udp port. %s", err.Error())
+	}
+	server := &dns.Server{PacketConn: conn, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		received <- req.Question[0].Name
+		rsp := new(dns.Msg)
+		rsp.SetRcode(req, rcode)
+		_ = w.WriteMsg(rsp)
+	})}
+	go func() {
+		_ = server.ActivateAndServe()
+	}()
+
+	return conn.LocalAddr().String(), func() {
+		_ = server.Shutdown()
+	}
+}
+
+func TestParseSecondary(t *testing.T) {
+	secondary, err := ParseSecondary("192.168.1.1")
+	assert.Equal(t, nil, err, errorInNotify)
+	assert.Equal(t, "192.168.1.1:53", secondary, errorInNotify)
+
+	secondary, err = ParseSecondary(" [2001:db8::1]:5353 ")
+	assert.Equal(t, nil, err, errorInNotify)
+	assert.Equal(t, "[2001:db8::1]:5353", secondary, errorInNotify)
+
+	_, err = ParseSecondary("secondary.example.com")
+	assert.EqualError(t, err, "error: parsing secondary failed, not in ipv4/ipv6 format", errorInNotify)
+	_, err = ParseSecondary("192.168.1.1:0")
+	assert.EqualError(t, err, "error: secondary port number not in valid range", errorInNotify)
+	_, err = ParseSecondary("224.0.0.1")
+	assert.NotEqual(t, nil, err, errorInNotify)
+}
+
+func TestNotify(t *testing.T) {
+	t.Run("Acknowledged", func(t *testing.T) {
+		received := make(chan string, 10)
+		address, stop := startSecondary(t, dns.RcodeSuccess, received)
+		defer stop()
+
+		n := New([]string{address}, time.Second)
+		n.Notify(testZone)
+		select {
+		case zone := <-received:
+			assert.Equal(t, testZone, zone, errorInNotify)
+		case <-time.After(5 * time.Second):
+			t.Errorf("NOTIFY not received")
+		}
+	})
+
+	t.Run("RetriedUntilAcknowledged", func(t *testing.T) {
+		received := make(chan string, 10)
+		address, stop := startSecondary(t, dns.RcodeServerFailure, received)
+		defer stop()
+
+		n := New([]string{address}, time.Second)
+		n.retryInterval = 10 * time.Millisecond
+		err := n.notify(testZone, address)
+		assert.EqualError(t, err, "notify not acknowledged, rcode SERVFAIL", errorInNotify)
+		assert.Equal(t, 3, len(received), errorInNotify)
+	})
+
+	t.Run("NoSecondaries", func(t *testing.T) {
+		var n *Notifier
+		n.Notify(testZone)
+		New(nil, time.Second).Notify(testZone)
+	})
+}
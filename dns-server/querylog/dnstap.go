@@ -0,0 +1,234 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package querylog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"dns-server/util"
+)
+
+// dnstapContentType content type of the dnstap frame streams.
+const dnstapContentType = "protobuf:dnstap.Dnstap"
+
+// Field numbers and values of the dnstap protobuf schema(dnstap.proto).
+const (
+	dnstapIdentity    = 1
+	dnstapVersion     = 2
+	dnstapExtra       = 3
+	dnstapMessage     = 14
+	dnstapType        = 15
+	dnstapTypeMessage = 1
+
+	messageType             = 1
+	messageSocketFamily     = 2
+	messageSocketProtocol   = 3
+	messageQueryAddress     = 4
+	messageQueryPort        = 6
+	messageQueryTimeSec     = 8
+	messageQueryTimeNsec    = 9
+	messageQueryMessage     = 10
+	messageResponseTimeSec  = 12
+	messageResponseTimeNsec = 13
+	messageResponseMessage  = 14
+
+	messageTypeClientResponse = 6
+	socketFamilyINET          = 1
+	socketFamilyINET6         = 2
+	socketProtocolUDP         = 1
+	socketProtocolTCP         = 2
+)
+
+// Frame streams control frames and fields.
+const (
+	controlAccept           = 1
+	controlStart            = 2
+	controlStop             = 3
+	controlReady            = 4
+	controlFinish           = 5
+	controlFieldContentType = 1
+	maxControlFrameLength   = 512
+)
+
+// dnstapVersionName version of the dnstap messages.
+const dnstapVersionName = "dns-server"
+
+// dnstapIdentityName identity of the dnstap messages, the host name.
+var dnstapIdentityName, _ = os.Hostname()
+
+// encodeDNSTap encode the entry as a dnstap CLIENT_RESPONSE message, along with the query. The source of the answer
+// is in the extra field.
+func encodeDNSTap(e *Entry) ([]byte, error) {
+	query, err := e.Query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	var msg []byte
+	msg = protowire.AppendTag(msg, messageType, protowire.VarintType)
+	msg = protowire.AppendVarint(msg, messageTypeClientResponse)
+	ip, port, tcp := clientAddress(e.Client)
+	if ip != nil {
+		family, address := uint64(socketFamilyINET6), []byte(ip.To16())
+		if ip4 := ip.To4(); ip4 != nil {
+			family, address = socketFamilyINET, []byte(ip4)
+		}
+		protocol := uint64(socketProtocolUDP)
+		if tcp {
+			protocol = socketProtocolTCP
+		}
+		msg = protowire.AppendTag(msg, messageSocketFamily, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, family)
+		msg = protowire.AppendTag(msg, messageSocketProtocol, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, protocol)
+		msg = protowire.AppendTag(msg, messageQueryAddress, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, address)
+		msg = protowire.AppendTag(msg, messageQueryPort, protowire.VarintType)
+		msg = protowire.AppendVarint(msg, uint64(port))
+	}
+	msg = appendTime(msg, messageQueryTimeSec, messageQueryTimeNsec, e.Time)
+	msg = protowire.AppendTag(msg, messageQueryMessage, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, query)
+	if e.Response != nil {
+		response, err := e.Response.Pack()
+		if err != nil {
+			return nil, err
+		}
+		msg = appendTime(msg, messageResponseTimeSec, messageResponseTimeNsec, e.Time.Add(e.Latency))
+		msg = protowire.AppendTag(msg, messageResponseMessage, protowire.BytesType)
+		msg = protowire.AppendBytes(msg, response)
+	}
+
+	var tap []byte
+	tap = protowire.AppendTag(tap, dnstapIdentity, protowire.BytesType)
+	tap = protowire.AppendString(tap, dnstapIdentityName)
+	tap = protowire.AppendTag(tap, dnstapVersion, protowire.BytesType)
+	tap = protowire.AppendString(tap, dnstapVersionName)
+	tap = protowire.AppendTag(tap, dnstapExtra, protowire.BytesType)
+	tap = protowire.AppendString(tap, e.Source)
+	tap = protowire.AppendTag(tap, dnstapMessage, protowire.BytesType)
+	tap = protowire.AppendBytes(tap, msg)
+	tap = protowire.AppendTag(tap, dnstapType, protowire.VarintType)
+	tap = protowire.AppendVarint(tap, dnstapTypeMessage)
+
+	return tap, nil
+}
+
+func appendTime(b []byte, secField protowire.Number, nsecField protowire.Number, t time.Time) []byte {
+	b = protowire.AppendTag(b, secField, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(t.Unix()))
+	b = protowire.AppendTag(b, nsecField, protowire.Fixed32Type)
+	return protowire.AppendFixed32(b, uint32(t.Nanosecond()))
+}
+
+// writeFrame write a data frame of the frame stream.
+func writeFrame(w *bufio.Writer, payload []byte) error {
+	if err := binary.Write(w, binary.BigEndian, uint32(len(payload))); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// writeControl write a control frame, with the dnstap content type for the ready, accept and start frames.
+func writeControl(w *bufio.Writer, controlType uint32) error {
+	control := make([]byte, 4, 12+len(dnstapContentType))
+	binary.BigEndian.PutUint32(control, controlType)
+	if controlType != controlStop && controlType != controlFinish {
+		control = append(control, 0, 0, 0, controlFieldContentType, 0, 0, 0, byte(len(dnstapContentType)))
+		control = append(control, dnstapContentType...)
+	}
+	// Control frame is escaped with a zero length
+	if err := binary.Write(w, binary.BigEndian, []uint32{0, uint32(len(control))}); err != nil {
+		return err
+	}
+	if _, err := w.Write(control); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// readControl read a control frame, returning its type.
+func readControl(r io.Reader) (uint32, error) {
+	var header [2]uint32
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return 0, err
+	}
+	if header[0] != 0 || header[1] < 4 || header[1] > maxControlFrameLength {
+		return 0, fmt.Errorf("invalid control frame")
+	}
+	control := make([]byte, header[1])
+	if _, err := io.ReadFull(r, control); err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint32(control), nil
+}
+
+// start start the frame stream, after the handshake with the socket reader.
+func (o *output) start() error {
+	if o.bidirectional {
+		if err := writeControl(o.w, controlReady); err != nil {
+			return err
+		}
+		if err := o.expectControl(controlAccept); err != nil {
+			return err
+		}
+	}
+
+	return writeControl(o.w, controlStart)
+}
+
+// stop stop the frame stream, waiting for the socket reader to finish.
+func (o *output) stop() error {
+	if err := writeControl(o.w, controlStop); err != nil {
+		return err
+	}
+	if o.bidirectional {
+		return o.expectControl(controlFinish)
+	}
+
+	return nil
+}
+
+// expectControl read the control frame from the socket reader, expecting the type.
+func (o *output) expectControl(expected uint32) error {
+	if conn, ok := o.conn.(net.Conn); ok {
+		_ = conn.SetReadDeadline(time.Now().Add(util.QueryLogDialTimeout * time.Second))
+		defer func() {
+			_ = conn.SetReadDeadline(time.Time{})
+		}()
+	}
+	controlType, err := readControl(o.conn)
+	if err != nil {
+		return err
+	}
+	if controlType != expected {
+		return fmt.Errorf("unexpected control frame(%d) from the reader", controlType)
+	}
+
+	return nil
+}
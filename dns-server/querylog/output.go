@@ -0,0 +1,168 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package querylog
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"dns-server/util"
+)
+
+// output destination of the entries, framed as per the format.
+type output struct {
+	format        string
+	conn          io.ReadWriteCloser // file or socket
+	w             *bufio.Writer
+	bidirectional bool // frame streams handshake with the socket reader
+}
+
+// record JSON entry of the query log.
+type record struct {
+	Time     string  `json:"time"`
+	Client   string  `json:"client"`
+	Port     int     `json:"port"`
+	Protocol string  `json:"protocol"`
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`
+	Class    string  `json:"class"`
+	Rcode    string  `json:"rcode,omitempty"`
+	Answers  int     `json:"answers"`
+	Latency  float64 `json:"latencyMs"`
+	Source   string  `json:"source"`
+}
+
+// openFileOutput open the file to append the entries. A frame stream has a single start, so a dnstap file is written
+// from the beginning.
+func openFileOutput(path string, format string) (*output, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if format == FormatDNSTap {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return newOutput(file, format, false)
+}
+
+// dialOutput connect to the unix socket of the query log reader.
+func dialOutput(path string, format string) (*output, error) {
+	conn, err := net.DialTimeout("unix", path, util.QueryLogDialTimeout*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	return newOutput(conn, format, true)
+}
+
+func newOutput(conn io.ReadWriteCloser, format string, bidirectional bool) (*output, error) {
+	out := &output{format: format, conn: conn, w: bufio.NewWriter(conn), bidirectional: bidirectional}
+	if format == FormatDNSTap {
+		if err := out.start(); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// write write the entry, an entry which can not be encoded is skipped.
+func (o *output) write(e *Entry) error {
+	var payload []byte
+	var err error
+	if o.format == FormatDNSTap {
+		if payload, err = encodeDNSTap(e); err == nil {
+			return writeFrame(o.w, payload)
+		}
+	} else {
+		if payload, err = encodeJSON(e); err == nil {
+			_, err = o.w.Write(append(payload, '\n'))
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *output) flush() error {
+	return o.w.Flush()
+}
+
+// close flush the entries, ending the frame stream, and close the destination.
+func (o *output) close() error {
+	err := o.w.Flush()
+	if err == nil && o.format == FormatDNSTap {
+		err = o.stop()
+	}
+	if closeErr := o.conn.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// encodeJSON encode the entry as a JSON record.
+func encodeJSON(e *Entry) ([]byte, error) {
+	if len(e.Query.Question) == 0 {
+		return nil, fmt.Errorf("query without question")
+	}
+	question := e.Query.Question[0]
+	r := record{
+		Time:     e.Time.UTC().Format(time.RFC3339Nano),
+		Protocol: "udp",
+		Name:     question.Name,
+		Type:     dns.Type(question.Qtype).String(),
+		Class:    dns.Class(question.Qclass).String(),
+		Latency:  float64(e.Latency.Microseconds()) / 1000,
+		Source:   e.Source,
+	}
+	ip, port, tcp := clientAddress(e.Client)
+	if ip != nil {
+		r.Client, r.Port = ip.String(), port
+	}
+	if tcp {
+		r.Protocol = "tcp"
+	}
+	if e.Response != nil {
+		r.Rcode = dns.RcodeToString[e.Response.Rcode]
+		r.Answers = len(e.Response.Answer)
+	}
+
+	return json.Marshal(r)
+}
+
+// clientAddress get the ip, port and whether the client is over tcp.
+func clientAddress(addr net.Addr) (net.IP, int, bool) {
+	switch a := addr.(type) {
+	case *net.UDPAddr:
+		return a.IP, a.Port, false
+	case *net.TCPAddr:
+		return a.IP, a.Port, true
+	default:
+		return nil, 0, false
+	}
+}
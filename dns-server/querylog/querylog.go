@@ -0,0 +1,219 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package querylog logging of the dns queries along with their responses, as newline delimited JSON or dnstap
+package querylog
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	"dns-server/metrics"
+	"dns-server/util"
+)
+
+const (
+	// FormatJSON newline delimited JSON.
+	FormatJSON = "json"
+	// FormatDNSTap dnstap messages in frame streams.
+	FormatDNSTap = "dnstap"
+	// unixScheme prefix of a unix socket destination, a file is written otherwise.
+	unixScheme = "unix:"
+	// fileScheme optional prefix of a file destination.
+	fileScheme = "file:"
+)
+
+// Entry query along with its response.
+type Entry struct {
+	Time     time.Time // time the query was received
+	Client   net.Addr
+	Query    *dns.Msg
+	Response *dns.Msg // nil if not answered
+	Latency  time.Duration
+	Source   string // local, forwarded, transfer or update
+}
+
+// Logger writes the entries to the destination in the background. Entries are sampled once the query rate exceeds
+// the limit, and dropped when the destination can not keep up or is not available.
+type Logger struct {
+	network     string
+	path        string
+	format      string
+	entries     chan *Entry
+	sampler     *sampler
+	done        chan struct{}
+	stopped     chan struct{}
+	closeOnce   sync.Once
+	retryPeriod time.Duration
+}
+
+// New create a logger writing to the destination, a file path or unix:path of a socket, in the format. Rate is the
+// maximum number of entries per second before sampling, 0 to log all the entries.
+func New(destination string, format string, rate uint) (*Logger, error) {
+	if format != FormatJSON && format != FormatDNSTap {
+		return nil, fmt.Errorf("unsupported query log format(%s)", format)
+	}
+	l := &Logger{
+		network:     "file",
+		path:        strings.TrimPrefix(destination, fileScheme),
+		format:      format,
+		entries:     make(chan *Entry, util.QueryLogBufferSize),
+		sampler:     &sampler{limit: uint64(rate)},
+		done:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+		retryPeriod: util.QueryLogRetryInterval * time.Second,
+	}
+	if strings.HasPrefix(destination, unixScheme) {
+		l.network, l.path = "unix", strings.TrimPrefix(destination, unixScheme)
+	}
+	if len(l.path) == 0 {
+		return nil, fmt.Errorf("query log destination is empty")
+	}
+
+	// A file must be writable at the start, the socket reader may come up later
+	out, err := l.open()
+	if err != nil && l.network == "file" {
+		return nil, err
+	}
+	go l.run(out)
+
+	return l, nil
+}
+
+// Log queue the entry to be written, unless it is sampled out or the queue is full.
+func (l *Logger) Log(e *Entry) {
+	if l == nil {
+		return
+	}
+	if !l.sampler.sample(e.Time) {
+		metrics.ObserveQueryLogDropped(metrics.DropSampled)
+		return
+	}
+	select {
+	case l.entries <- e:
+	default:
+		metrics.ObserveQueryLogDropped(metrics.DropOverflow)
+	}
+}
+
+// Close write the queued entries and close the destination.
+func (l *Logger) Close() {
+	if l == nil {
+		return
+	}
+	l.closeOnce.Do(func() {
+		close(l.done)
+		<-l.stopped
+	})
+}
+
+// open open the destination, ready to write the entries.
+func (l *Logger) open() (*output, error) {
+	if l.network == "unix" {
+		return dialOutput(l.path, l.format)
+	}
+	return openFileOutput(l.path, l.format)
+}
+
+// run write the queued entries until closed, reopening the destination after a failure.
+func (l *Logger) run(out *output) {
+	defer close(l.stopped)
+	var retryAt time.Time
+	write := func(e *Entry) {
+		if out == nil && time.Now().After(retryAt) {
+			var err error
+			if out, err = l.open(); err != nil {
+				log.Errorf("Failed to open the query log(%s). %s", l.path, err.Error())
+				retryAt = time.Now().Add(l.retryPeriod)
+			}
+		}
+		if out == nil {
+			metrics.ObserveQueryLogDropped(metrics.DropUnavailable)
+			return
+		}
+		err := out.write(e)
+		if err == nil && len(l.entries) == 0 {
+			err = out.flush()
+		}
+		if err != nil {
+			log.Errorf("Failed to write the query log(%s). %s", l.path, err.Error())
+			_ = out.close()
+			out, retryAt = nil, time.Now().Add(l.retryPeriod)
+		}
+	}
+
+	for {
+		select {
+		case e := <-l.entries:
+			write(e)
+		case <-l.done:
+			for len(l.entries) != 0 {
+				write(<-l.entries)
+			}
+			if out != nil {
+				if err := out.close(); err != nil {
+					log.Errorf("Failed to close the query log(%s). %s", l.path, err.Error())
+				}
+			}
+			return
+		}
+	}
+}
+
+// sampler samples the entries once the rate exceeds the limit. The entries are sampled as per the rate of the
+// previous second to spread them over the second, up to the limit of entries per second.
+type sampler struct {
+	mutex    sync.Mutex
+	limit    uint64
+	second   int64
+	logged   uint64
+	count    uint64
+	previous uint64
+}
+
+// sample check the entry at the given time is to be logged.
+func (s *sampler) sample(now time.Time) bool {
+	if s.limit == 0 {
+		return true
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if second := now.Unix(); second != s.second {
+		s.previous = 0
+		if second == s.second+1 {
+			s.previous = s.count
+		}
+		s.second, s.count, s.logged = second, 0, 0
+	}
+	s.count++
+	if s.logged >= s.limit {
+		return false
+	}
+	if s.previous > s.limit && rand.Float64()*float64(s.previous) >= float64(s.limit) {
+		return false
+	}
+	s.logged++
+
+	return true
+}
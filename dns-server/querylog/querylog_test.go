@@ -0,0 +1,243 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package querylog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const errorInQueryLog = "Error in query log"
+
+func newTestEntry() *Entry {
+	query := new(dns.Msg)
+	query.SetQuestion("www.example.com.", dns.TypeA)
+	response := new(dns.Msg)
+	response.SetReply(query)
+	rr, _ := dns.NewRR("www.example.com. 30 IN A 192.168.1.1")
+	response.Answer = []dns.RR{rr}
+
+	return &Entry{Time: time.Now(), Client: &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5353}, Query: query,
+		Response: response, Latency: 1500 * time.Microsecond, Source: "local"}
+}
+
+// readFrame read a frame of the frame stream, the control frames are returned with their type.
+func readFrame(t *testing.T, r *bufio.Reader) ([]byte, uint32) {
+	escape, err := r.Peek(4)
+	if err != nil {
+		t.Fatalf("Failed to read frame. %s", err.Error())
+	}
+	if length := binary.BigEndian.Uint32(escape); length != 0 {
+		frame := make([]byte, 4+length)
+		_, _ = io.ReadFull(r, frame)
+		return frame[4:], 0
+	}
+	controlType, err := readControl(r)
+	assert.Equal(t, nil, err, errorInQueryLog)
+	return nil, controlType
+}
+
+// dnstapFields get the varint and bytes fields of the protobuf message.
+func dnstapFields(b []byte) map[protowire.Number][]byte {
+	fields := make(map[protowire.Number][]byte)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		b = b[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			fields[num] = v
+			b = b[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			fields[num] = protowire.AppendVarint(nil, v)
+			b = b[n:]
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			b = b[n:]
+		}
+	}
+	return fields
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("query.log", "text", 0)
+	assert.EqualError(t, err, "unsupported query log format(text)", errorInQueryLog)
+	_, err = New("unix:", FormatJSON, 0)
+	assert.EqualError(t, err, "query log destination is empty", errorInQueryLog)
+	_, err = New("/nonexistent/query.log", FormatJSON, 0)
+	assert.NotEqual(t, nil, err, errorInQueryLog)
+
+	// Socket reader may come up later
+	l, err := New("unix:/nonexistent/dnstap.sock", FormatDNSTap, 0)
+	assert.Equal(t, nil, err, errorInQueryLog)
+	l.Log(newTestEntry())
+	l.Close()
+
+	var logger *Logger
+	logger.Log(newTestEntry())
+	logger.Close()
+}
+
+func TestJSONFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "querylog")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "query.log")
+
+	l, err := New("file:"+path, FormatJSON, 0)
+	assert.Equal(t, nil, err, errorInQueryLog)
+	l.Log(newTestEntry())
+	entry := newTestEntry()
+	entry.Client = &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 4000}
+	entry.Response, entry.Source = nil, "forwarded"
+	l.Log(entry)
+	l.Close()
+
+	content, err := ioutil.ReadFile(path)
+	assert.Equal(t, nil, err, errorInQueryLog)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Equal(t, 2, len(lines), errorInQueryLog)
+	r := record{}
+	_ = json.Unmarshal([]byte(lines[0]), &r)
+	assert.Equal(t, record{Time: r.Time, Client: "10.0.0.1", Port: 5353, Protocol: "udp", Name: "www.example.com.",
+		Type: "A", Class: "IN", Rcode: "NOERROR", Answers: 1, Latency: 1.5, Source: "local"}, r, errorInQueryLog)
+	assert.Equal(t, true, strings.Contains(lines[1], `"client":"2001:db8::1","port":4000,"protocol":"tcp"`),
+		errorInQueryLog)
+	assert.Equal(t, false, strings.Contains(lines[1], "rcode"), errorInQueryLog)
+}
+
+func TestDNSTapFile(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "querylog")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dnstap.fstrm")
+
+	l, err := New(path, FormatDNSTap, 0)
+	assert.Equal(t, nil, err, errorInQueryLog)
+	entry := newTestEntry()
+	l.Log(entry)
+	l.Close()
+
+	file, err := os.Open(path)
+	assert.Equal(t, nil, err, errorInQueryLog)
+	defer file.Close()
+	r := bufio.NewReader(file)
+	_, controlType := readFrame(t, r)
+	assert.Equal(t, uint32(controlStart), controlType, errorInQueryLog)
+	frame, _ := readFrame(t, r)
+	_, controlType = readFrame(t, r)
+	assert.Equal(t, uint32(controlStop), controlType, errorInQueryLog)
+
+	tap := dnstapFields(frame)
+	assert.Equal(t, "local", string(tap[dnstapExtra]), errorInQueryLog)
+	msg := dnstapFields(tap[dnstapMessage])
+	assert.Equal(t, []byte{messageTypeClientResponse}, msg[messageType], errorInQueryLog)
+	assert.Equal(t, []byte{socketFamilyINET}, msg[messageSocketFamily], errorInQueryLog)
+	assert.Equal(t, []byte(net.ParseIP("10.0.0.1").To4()), msg[messageQueryAddress], errorInQueryLog)
+	response := new(dns.Msg)
+	assert.Equal(t, nil, response.Unpack(msg[messageResponseMessage]), errorInQueryLog)
+	assert.Equal(t, entry.Response.Answer[0].String(), response.Answer[0].String(), errorInQueryLog)
+}
+
+func TestDNSTapSocket(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "querylog")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dnstap.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to listen on unix socket. %s", err.Error())
+	}
+	defer listener.Close()
+
+	frames := make(chan []byte, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+		for {
+			frame, controlType := readFrame(t, r)
+			switch {
+			case controlType == controlReady:
+				_ = writeControl(w, controlAccept)
+			case controlType == controlStop:
+				_ = writeControl(w, controlFinish)
+				close(frames)
+				return
+			case frame != nil:
+				frames <- frame
+			}
+		}
+	}()
+
+	l, err := New("unix:"+path, FormatDNSTap, 0)
+	assert.Equal(t, nil, err, errorInQueryLog)
+	l.Log(newTestEntry())
+	l.Log(newTestEntry())
+	l.Close()
+
+	count := 0
+	for range frames {
+		count++
+	}
+	assert.Equal(t, 2, count, errorInQueryLog)
+}
+
+func TestSampler(t *testing.T) {
+	s := &sampler{limit: 100}
+	now := time.Unix(1600000000, 0)
+	logged := 0
+	for i := 0; i < 1000; i++ {
+		if s.sample(now) {
+			logged++
+		}
+	}
+	assert.Equal(t, 100, logged, errorInQueryLog)
+
+	// Sampled from the start of the next second as per the previous load
+	logged = 0
+	for i := 0; i < 1000; i++ {
+		if s.sample(now.Add(time.Second)) {
+			logged++
+		}
+	}
+	assert.Equal(t, true, logged > 50 && logged <= 100, errorInQueryLog)
+
+	// All logged after an idle second
+	logged = 0
+	for i := 0; i < 100; i++ {
+		if s.sample(now.Add(3 * time.Second)) {
+			logged++
+		}
+	}
+	assert.Equal(t, 100, logged, errorInQueryLog)
+	assert.Equal(t, true, (&sampler{}).sample(now), errorInQueryLog)
+}
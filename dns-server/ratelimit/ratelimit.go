@@ -0,0 +1,177 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package ratelimit response rate limiting of the clients, per client prefix and query name
+package ratelimit
+
+import (
+	"container/list"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"dns-server/util"
+)
+
+// Action action on a query as per the rate limit.
+type Action int
+
+const (
+	// Allow answer the query.
+	Allow Action = iota
+	// Slip answer with an empty truncated response, so a real client retries over tcp.
+	Slip
+	// Drop do not answer the query.
+	Drop
+)
+
+// Stats rate limiting statistics.
+type Stats struct {
+	Rate    uint   `json:"rate"`
+	Slip    uint   `json:"slip"`
+	Dropped uint64 `json:"dropped"`
+	Slipped uint64 `json:"slipped"`
+	Tracked int    `json:"tracked"`
+}
+
+type key struct {
+	prefix [net.IPv6len]byte
+	name   string
+}
+
+// bucket token bucket of a client prefix and query name, refilled at the rate up to a second of responses.
+type bucket struct {
+	tokens  float64
+	last    time.Time
+	limited uint64
+	element *list.Element // of the bucket key in the least recently used order
+}
+
+// Limiter limits the responses per client prefix and query name. Every slip-th limited response is truncated instead
+// of dropped, clients of the whitelist are not limited. When too many are tracked, the least recently used bucket
+// makes room for the new one.
+type Limiter struct {
+	mutex      sync.Mutex
+	rate       uint
+	slip       uint
+	whitelist  []*net.IPNet
+	buckets    map[key]*bucket
+	recent     *list.List // bucket keys, most recently used first
+	maxEntries int
+	lastSweep  time.Time
+	dropped    uint64
+	slipped    uint64
+}
+
+// New create a limiter of rate responses per second, 0 slip never truncates.
+func New(rate uint, slip uint, whitelist []*net.IPNet) *Limiter {
+	return &Limiter{rate: rate, slip: slip, whitelist: whitelist, buckets: make(map[key]*bucket), recent: list.New(),
+		maxEntries: util.RateLimitMaxEntries, lastSweep: time.Now()}
+}
+
+// Check get the action on the response to the client for the query name.
+func (l *Limiter) Check(ip net.IP, name string) Action {
+	if l == nil || ip == nil {
+		return Allow
+	}
+	for _, ipNet := range l.whitelist {
+		if ipNet.Contains(ip) {
+			return Allow
+		}
+	}
+	k := key{prefix: clientPrefix(ip), name: strings.ToLower(name)}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	if elapsed := now.Sub(l.lastSweep); elapsed > util.RateLimitSweepInterval*time.Second ||
+		(len(l.buckets) >= l.maxEntries && elapsed > time.Second) {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[k]
+	if ok {
+		l.recent.MoveToFront(b.element)
+	} else {
+		if len(l.buckets) >= l.maxEntries {
+			// A flood of new names must not stop limiting the tracked ones, the idlest bucket is dropped instead
+			l.remove(l.recent.Back().Value.(key))
+		}
+		b = &bucket{tokens: float64(l.rate), last: now, element: l.recent.PushFront(k)}
+		l.buckets[k] = b
+	}
+	b.refill(now, float64(l.rate))
+	if b.tokens >= 1 {
+		b.tokens--
+		return Allow
+	}
+
+	b.limited++
+	if l.slip != 0 && b.limited%uint64(l.slip) == 0 {
+		l.slipped++
+		return Slip
+	}
+	l.dropped++
+
+	return Drop
+}
+
+// Stats get the rate limiting statistics.
+func (l *Limiter) Stats() Stats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return Stats{Rate: l.rate, Slip: l.slip, Dropped: l.dropped, Slipped: l.slipped, Tracked: len(l.buckets)}
+}
+
+// sweep remove the buckets refilled by now, they are the same as new ones.
+func (l *Limiter) sweep(now time.Time) {
+	for k, b := range l.buckets {
+		b.refill(now, float64(l.rate))
+		if b.tokens >= float64(l.rate) {
+			l.remove(k)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (l *Limiter) remove(k key) {
+	if b, ok := l.buckets[k]; ok {
+		l.recent.Remove(b.element)
+		delete(l.buckets, k)
+	}
+}
+
+func (b *bucket) refill(now time.Time, rate float64) {
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > rate {
+		b.tokens = rate
+	}
+	b.last = now
+}
+
+// clientPrefix get the network prefix of the client, clients of the same network share the limit.
+func clientPrefix(ip net.IP) [net.IPv6len]byte {
+	var prefix [net.IPv6len]byte
+	if ip4 := ip.To4(); ip4 != nil {
+		copy(prefix[:], ip4.Mask(net.CIDRMask(util.RateLimitIPv4PrefixLength, 8*net.IPv4len)).To16())
+	} else {
+		copy(prefix[:], ip.Mask(net.CIDRMask(util.RateLimitIPv6PrefixLength, 8*net.IPv6len)))
+	}
+
+	return prefix
+}
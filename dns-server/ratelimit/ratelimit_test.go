@@ -0,0 +1,115 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ratelimit
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testName         = "www.example.com."
+	errorInRateLimit = "Error in rate limit"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Run("DropAndSlip", func(t *testing.T) {
+		l := New(2, 2, nil)
+		ip := net.ParseIP("192.168.1.1")
+		assert.Equal(t, Allow, l.Check(ip, testName), errorInRateLimit)
+		assert.Equal(t, Allow, l.Check(ip, testName), errorInRateLimit)
+		assert.Equal(t, Drop, l.Check(ip, testName), errorInRateLimit)
+		assert.Equal(t, Slip, l.Check(ip, testName), errorInRateLimit)
+		assert.Equal(t, Drop, l.Check(ip, testName), errorInRateLimit)
+		// Other names are limited separately, names are case insensitive
+		assert.Equal(t, Allow, l.Check(ip, "abc.example.com."), errorInRateLimit)
+		assert.Equal(t, Slip, l.Check(ip, "WWW.example.com."), errorInRateLimit)
+		assert.Equal(t, Stats{Rate: 2, Slip: 2, Dropped: 2, Slipped: 2, Tracked: 2}, l.Stats(), errorInRateLimit)
+	})
+
+	t.Run("NoSlip", func(t *testing.T) {
+		l := New(1, 0, nil)
+		ip := net.ParseIP("192.168.1.1")
+		assert.Equal(t, Allow, l.Check(ip, testName), errorInRateLimit)
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, Drop, l.Check(ip, testName), errorInRateLimit)
+		}
+	})
+
+	t.Run("PrefixShared", func(t *testing.T) {
+		l := New(1, 0, nil)
+		assert.Equal(t, Allow, l.Check(net.ParseIP("192.168.1.1"), testName), errorInRateLimit)
+		assert.Equal(t, Drop, l.Check(net.ParseIP("192.168.1.200"), testName), errorInRateLimit)
+		assert.Equal(t, Allow, l.Check(net.ParseIP("192.168.2.1"), testName), errorInRateLimit)
+		assert.Equal(t, Allow, l.Check(net.ParseIP("2001:db8::1"), testName), errorInRateLimit)
+		assert.Equal(t, Drop, l.Check(net.ParseIP("2001:db8:0:ff::1"), testName), errorInRateLimit)
+		assert.Equal(t, Allow, l.Check(net.ParseIP("2001:db8:0:100::1"), testName), errorInRateLimit)
+	})
+
+	t.Run("Whitelist", func(t *testing.T) {
+		_, ipNet, _ := net.ParseCIDR("10.0.0.0/8")
+		l := New(1, 0, []*net.IPNet{ipNet})
+		for i := 0; i < 5; i++ {
+			assert.Equal(t, Allow, l.Check(net.ParseIP("10.1.1.1"), testName), errorInRateLimit)
+		}
+		assert.Equal(t, 0, l.Stats().Tracked, errorInRateLimit)
+	})
+
+	t.Run("Refill", func(t *testing.T) {
+		l := New(1, 0, nil)
+		ip := net.ParseIP("192.168.1.1")
+		assert.Equal(t, Allow, l.Check(ip, testName), errorInRateLimit)
+		assert.Equal(t, Drop, l.Check(ip, testName), errorInRateLimit)
+		l.buckets[key{prefix: clientPrefix(ip), name: testName}].last = time.Now().Add(-time.Second)
+		assert.Equal(t, Allow, l.Check(ip, testName), errorInRateLimit)
+	})
+
+	t.Run("Sweep", func(t *testing.T) {
+		l := New(1, 0, nil)
+		_ = l.Check(net.ParseIP("192.168.1.1"), testName)
+		_ = l.Check(net.ParseIP("192.168.2.1"), testName)
+		l.buckets[key{prefix: clientPrefix(net.ParseIP("192.168.1.1")), name: testName}].last =
+			time.Now().Add(-time.Minute)
+		l.sweep(time.Now())
+		assert.Equal(t, 1, l.Stats().Tracked, errorInRateLimit)
+		assert.Equal(t, 1, l.recent.Len(), errorInRateLimit)
+	})
+
+	t.Run("Eviction", func(t *testing.T) {
+		l := New(1, 0, nil)
+		l.maxEntries = 2
+		ip := net.ParseIP("192.168.1.1")
+		assert.Equal(t, Allow, l.Check(ip, testName), errorInRateLimit)
+		assert.Equal(t, Allow, l.Check(ip, "abc.example.com."), errorInRateLimit)
+		assert.Equal(t, Drop, l.Check(ip, testName), errorInRateLimit)
+		// New names keep being limited when the table is full, the least recently used bucket is evicted
+		assert.Equal(t, Allow, l.Check(ip, "new.example.com."), errorInRateLimit)
+		assert.Equal(t, Drop, l.Check(ip, "new.example.com."), errorInRateLimit)
+		assert.Equal(t, Drop, l.Check(ip, testName), errorInRateLimit)
+		assert.Equal(t, Allow, l.Check(ip, "abc.example.com."), errorInRateLimit)
+		assert.Equal(t, 2, l.Stats().Tracked, errorInRateLimit)
+		assert.Equal(t, 2, l.recent.Len(), errorInRateLimit)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		var l *Limiter
+		assert.Equal(t, Allow, l.Check(net.ParseIP("192.168.1.1"), testName), errorInRateLimit)
+	})
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"dns-server/forward"
+	"dns-server/util"
+)
+
+// reloadSettings settings applied without restarting, re-read from the config file on SIGHUP.
+type reloadSettings struct {
+	Forwarder     string `json:"forwarder"`
+	ForwarderPort uint   `json:"forwarderPort"`
+	ForwardPolicy string `json:"forwardPolicy"`
+	LoadBalance   bool   `json:"loadBalance"`
+}
+
+// readReloadSettings read the settings of the json config file over the defaults, the settings not in the file keep
+// the defaults. Only the defaults are used if no config file.
+func readReloadSettings(path string, defaults reloadSettings) (reloadSettings, error) {
+	settings := defaults
+	if len(path) == 0 {
+		return settings, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return defaults, fmt.Errorf("error: reading config file failed, %s", err.Error())
+	}
+	if err = json.Unmarshal(data, &settings); err != nil {
+		return defaults, fmt.Errorf("error: parsing config file failed, %s", err.Error())
+	}
+	if settings.ForwarderPort > util.MaxPortNumber || settings.ForwarderPort == 0 {
+		return defaults, fmt.Errorf("error: forwarder port number not in valid range")
+	}
+
+	return settings, nil
+}
+
+// parseForwarders parse the comma separated forwarders, the default ip stands for no forwarder.
+func parseForwarders(spec string, defaultPort uint) ([]forward.Upstream, error) {
+	var upstreams []forward.Upstream
+	for _, forwarderAdd := range strings.Split(spec, ",") {
+		if strings.TrimSpace(forwarderAdd) == util.DefaultIP {
+			continue
+		}
+		upstream, err := forward.ParseUpstream(forwarderAdd, defaultPort)
+		if err != nil {
+			return nil, fmt.Errorf("invalid forwarder address(%s), %s", forwarderAdd, err.Error())
+		}
+		upstreams = append(upstreams, *upstream)
+	}
+
+	return upstreams, nil
+}
+
+// Reload re-read the forwarder and load balancing settings from the config file and apply them, the running settings
+// are kept if the config file is not valid. The settings of the file over the command line win, forwarders set through
+// the management API since the start or the last reload are replaced.
+func (s *Server) Reload() error {
+	settings, err := readReloadSettings(s.config.configFile, s.config.flagSettings)
+	if err != nil {
+		return err
+	}
+	upstreams, err := parseForwarders(settings.Forwarder, settings.ForwarderPort)
+	if err != nil {
+		return err
+	}
+	if err = s.config.forwarders.SetConfig(upstreams, settings.ForwardPolicy); err != nil {
+		return err
+	}
+	s.dataStore.SetLoadBalance(settings.LoadBalance)
+	log.Infof("Reloaded the settings(forwarders: %s, policy: %s, load balance: %t).", settings.Forwarder,
+		settings.ForwardPolicy, settings.LoadBalance)
+
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"dns-server/datastore"
+	"dns-server/forward"
+)
+
+const errorInReload = "Error in reload"
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(os.TempDir(), "dns-server-reload-test.json")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write the config file. %s", err.Error())
+	}
+
+	return path
+}
+
+func TestReadReloadSettings(t *testing.T) {
+	defaults := reloadSettings{Forwarder: "8.8.8.8", ForwarderPort: 53, ForwardPolicy: forward.PolicyRoundRobin}
+	path := writeConfigFile(t, `{"forwarder": "1.1.1.1,9.9.9.9", "loadBalance": true}`)
+	defer os.Remove(path)
+
+	settings, err := readReloadSettings("", defaults)
+	assert.Equal(t, nil, err, errorInReload)
+	assert.Equal(t, defaults, settings, errorInReload)
+
+	settings, err = readReloadSettings(path, defaults)
+	assert.Equal(t, nil, err, errorInReload)
+	assert.Equal(t, reloadSettings{Forwarder: "1.1.1.1,9.9.9.9", ForwarderPort: 53,
+		ForwardPolicy: forward.PolicyRoundRobin, LoadBalance: true}, settings, errorInReload)
+
+	_ = ioutil.WriteFile(path, []byte(`{"forwarderPort": 0}`), 0600)
+	_, err = readReloadSettings(path, defaults)
+	assert.EqualError(t, err, "error: forwarder port number not in valid range", errorInReload)
+
+	_ = ioutil.WriteFile(path, []byte(`forwarder=1.1.1.1`), 0600)
+	_, err = readReloadSettings(path, defaults)
+	assert.NotEqual(t, nil, err, errorInReload)
+
+	_, err = readReloadSettings(path+".missing", defaults)
+	assert.NotEqual(t, nil, err, errorInReload)
+}
+
+func TestReload(t *testing.T) {
+	forwarders, _ := forward.NewPool(nil, forward.PolicyRoundRobin, time.Second)
+	store := &datastore.MemoryStore{}
+	assert.Equal(t, nil, store.Open(), errorInReload)
+	defer store.Close()
+	path := writeConfigFile(t, `{"forwarder": "1.1.1.1", "forwardPolicy": "fastest", "loadBalance": true}`)
+	defer os.Remove(path)
+	dnsServer := NewServer(&Config{forwarders: forwarders, configFile: path,
+		flagSettings: reloadSettings{Forwarder: "0.0.0.0", ForwarderPort: 53, ForwardPolicy: forward.PolicyRoundRobin}},
+		store, &mockMgmtCtrl{})
+
+	t.Run("Applied", func(t *testing.T) {
+		assert.Equal(t, nil, dnsServer.Reload(), errorInReload)
+		assert.Equal(t, forward.PolicyFastest, forwarders.Policy(), errorInReload)
+		status := forwarders.Status()
+		assert.Equal(t, 1, len(status), errorInReload)
+		assert.Equal(t, "1.1.1.1:53", status[0].Address, errorInReload)
+	})
+
+	t.Run("InvalidConfigKeepsRunningSettings", func(t *testing.T) {
+		_ = ioutil.WriteFile(path, []byte(`{"forwarder": "1.1.1.1", "forwardPolicy": "random"}`), 0600)
+		assert.EqualError(t, dnsServer.Reload(), "unsupported forward policy(random)", errorInReload)
+		_ = ioutil.WriteFile(path, []byte(`{"forwarder": "forwarder.example.com"}`), 0600)
+		assert.NotEqual(t, nil, dnsServer.Reload(), errorInReload)
+		assert.Equal(t, forward.PolicyFastest, forwarders.Policy(), errorInReload)
+		assert.Equal(t, 1, len(forwarders.Status()), errorInReload)
+	})
+
+	t.Run("RemovedSettingsRevertToCommandLine", func(t *testing.T) {
+		_ = ioutil.WriteFile(path, []byte(`{}`), 0600)
+		assert.Equal(t, nil, dnsServer.Reload(), errorInReload)
+		assert.Equal(t, forward.PolicyRoundRobin, forwarders.Policy(), errorInReload)
+		assert.Equal(t, true, forwarders.IsEmpty(), errorInReload)
+	})
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package main
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	"dns-server/metrics"
+	"dns-server/util"
+)
+
+// parseACL parse the comma separated CIDRs or IPs of an acl, like the clients allowed to transfer the zones.
+func parseACL(acl string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range strings.Split(acl, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("error: parsing acl failed, not in ipv4/ipv6 format")
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("error: parsing acl failed, not in cidr format")
+		}
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+// isTransferAllowed check the client is allowed by the transfer acl, none is allowed when the acl is empty.
+func (s *Server) isTransferAllowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range s.config.transferACL {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleTransfer answer the AXFR/IXFR query of an authoritative zone in the default view. AXFR is served only over
+// TCP, IXFR over UDP gets the current SOA so that the client retries over TCP(RFC 1995).
+func (s *Server) handleTransfer(w dns.ResponseWriter, req *dns.Msg) {
+	question := req.Question[0]
+	if !s.isTransferAllowed(clientIP(w)) {
+		log.Infof("Zone transfer of %s refused for %s.", question.Name, w.RemoteAddr().String())
+		s.writeErrorResponse(w, req, dns.RcodeRefused)
+		return
+	}
+
+	_, isUDP := w.RemoteAddr().(*net.UDPAddr)
+	if question.Qtype == dns.TypeAXFR && isUDP {
+		s.writeErrorResponse(w, req, dns.RcodeRefused)
+		return
+	}
+
+	zone := strings.ToLower(question.Name)
+	var rrs []dns.RR
+	var err error
+	readStart := time.Now()
+	if question.Qtype == dns.TypeIXFR {
+		// Client's version of the zone is in the authority section
+		var clientSOA *dns.SOA
+		if len(req.Ns) == 1 {
+			clientSOA, _ = req.Ns[0].(*dns.SOA)
+		}
+		if clientSOA == nil {
+			s.writeErrorResponse(w, req, dns.RcodeFormatError)
+			return
+		}
+		rrs, err = s.dataStore.GetZoneIncrementalTransfer(zone, clientSOA.Serial)
+		if err == nil && isUDP {
+			s.writeTransferSOA(w, req, rrs)
+			return
+		}
+	}
+	// Falls back to the full zone when the changes are not available
+	if err == nil && rrs == nil {
+		rrs, err = s.dataStore.GetZoneTransfer(zone)
+	}
+	metrics.ObserveDataStore(metrics.OperationTransfer, readStart)
+	if err != nil {
+		log.Debugf("Zone transfer of %s failed. %s", question.Name, err.Error())
+		s.writeErrorResponse(w, req, dns.RcodeNotAuth)
+		return
+	}
+
+	ch := make(chan *dns.Envelope, len(rrs)/util.TransferChunkSize+1)
+	for start := 0; start < len(rrs); start += util.TransferChunkSize {
+		end := start + util.TransferChunkSize
+		if end > len(rrs) {
+			end = len(rrs)
+		}
+		ch <- &dns.Envelope{RR: rrs[start:end]}
+	}
+	close(ch)
+
+	tr := new(dns.Transfer)
+	if err = tr.Out(w, req, ch); err != nil {
+		log.Errorf("Failed to send the zone transfer of %s.", question.Name)
+	}
+	if err = w.Close(); err != nil {
+		log.Debugf("Failed to close the zone transfer connection.")
+	}
+	log.Infof("Zone transfer(%s) of %s sent to %s, %d records.", dns.TypeToString[question.Qtype],
+		question.Name, w.RemoteAddr().String(), len(rrs))
+}
+
+// writeTransferSOA answer the IXFR query with the current SOA of the zone only.
+func (s *Server) writeTransferSOA(w dns.ResponseWriter, req *dns.Msg, rrs []dns.RR) {
+	if len(rrs) == 0 {
+		answer, err := s.dataStore.GetResourceRecord(&dns.Question{Name: req.Question[0].Name,
+			Qtype: dns.TypeSOA, Qclass: dns.ClassINET})
+		if err != nil {
+			s.writeErrorResponse(w, req, dns.RcodeServerFailure)
+			return
+		}
+		rrs = *answer
+	}
+
+	s.writeSuccessResponse("", &[]dns.RR{rrs[0]}, true, w, req)
+}
@@ -0,0 +1,577 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package main
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+
+	"dns-server/datastore"
+	"dns-server/metrics"
+)
+
+// tsigAlgorithms supported TSIG algorithms.
+var tsigAlgorithms = map[string]string{"hmac-sha1": dns.HmacSHA1, "hmac-sha256": dns.HmacSHA256,
+	"hmac-sha512": dns.HmacSHA512}
+
+// tsigKey TSIG key allowed to update a zone.
+type tsigKey struct {
+	name      string
+	algorithm string
+	secret    string
+}
+
+// parseTSIGKeys parse the comma separated TSIG keys in zone:name:algorithm:secret format, secret is base64 encoded.
+// Returns the keys of each zone.
+func parseTSIGKeys(keys string) (map[string][]tsigKey, error) {
+	zoneKeys := make(map[string][]tsigKey)
+	secrets := make(map[string]string)
+	for _, entry := range strings.Split(keys, ",") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		fields := strings.Split(entry, ":")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("error: tsig key not in zone:name:algorithm:secret format")
+		}
+		zone, name := strings.ToLower(dns.Fqdn(fields[0])), strings.ToLower(dns.Fqdn(fields[1]))
+		if _, ok := dns.IsDomainName(zone); !ok {
+			return nil, fmt.Errorf("error: invalid zone(%s) of tsig key", fields[0])
+		}
+		if _, ok := dns.IsDomainName(name); !ok {
+			return nil, fmt.Errorf("error: invalid tsig key name(%s)", fields[1])
+		}
+		algorithm, ok := tsigAlgorithms[strings.ToLower(fields[2])]
+		if !ok {
+			return nil, fmt.Errorf("error: unsupported tsig algorithm(%s)", fields[2])
+		}
+		if _, err := base64.StdEncoding.DecodeString(fields[3]); err != nil || len(fields[3]) == 0 {
+			return nil, fmt.Errorf("error: tsig key(%s) secret not in base64 format", fields[1])
+		}
+		// Key names identify the secret in the message, so a name can have only one secret
+		if secret, exists := secrets[name]; exists && secret != fields[3] {
+			return nil, fmt.Errorf("error: tsig key(%s) configured with different secrets", fields[1])
+		}
+		secrets[name] = fields[3]
+		zoneKeys[zone] = append(zoneKeys[zone], tsigKey{name: name, algorithm: algorithm, secret: fields[3]})
+	}
+
+	return zoneKeys, nil
+}
+
+// tsigSecrets get the secrets of all the keys by the key name, as used by the dns server to verify the messages.
+func tsigSecrets(zoneKeys map[string][]tsigKey) map[string]string {
+	secrets := make(map[string]string)
+	for _, keys := range zoneKeys {
+		for _, key := range keys {
+			secrets[key.name] = key.secret
+		}
+	}
+
+	return secrets
+}
+
+// acceptMsg accept the dynamic updates along with the messages accepted by default.
+func acceptMsg(dh dns.Header) dns.MsgAcceptAction {
+	const qrBit, opcodeShift = 1 << 15, 11
+	if dh.Bits&qrBit == 0 && int(dh.Bits>>opcodeShift)&0xF == dns.OpcodeUpdate {
+		// Zone section must have only one entry, other sections can have many
+		if dh.Qdcount != 1 {
+			return dns.MsgReject
+		}
+		return dns.MsgAccept
+	}
+
+	return dns.DefaultMsgAcceptFunc(dh)
+}
+
+// isUpdateAuthorized check the update is signed by one of the keys of the zone.
+func (s *Server) isUpdateAuthorized(w dns.ResponseWriter, req *dns.Msg, zone string) int {
+	tsig := req.IsTsig()
+	if tsig == nil {
+		return dns.RcodeRefused
+	}
+	if err := w.TsigStatus(); err != nil {
+		log.Infof("Dynamic update of %s with invalid tsig(%s). %s", zone, tsig.Hdr.Name, err.Error())
+		return dns.RcodeNotAuth
+	}
+	for _, key := range s.config.tsigKeys[zone] {
+		if strings.EqualFold(key.name, tsig.Hdr.Name) && strings.EqualFold(key.algorithm, tsig.Algorithm) {
+			return dns.RcodeSuccess
+		}
+	}
+	log.Infof("Dynamic update of %s with tsig(%s) not allowed for the zone.", zone, tsig.Hdr.Name)
+
+	return dns.RcodeNotAuth
+}
+
+// handleUpdate apply the dynamic update(RFC 2136) to a local zone in the default view, the update must be signed with
+// a TSIG key of the zone.
+func (s *Server) handleUpdate(w dns.ResponseWriter, req *dns.Msg) {
+	zone := strings.ToLower(req.Question[0].Name)
+	if req.Question[0].Qtype != dns.TypeSOA {
+		s.writeUpdateResponse(w, req, dns.RcodeFormatError)
+		return
+	}
+	if _, ok := s.config.tsigKeys[zone]; !ok {
+		s.writeErrorResponse(w, req, dns.RcodeRefused)
+		return
+	}
+	if rcode := s.isUpdateAuthorized(w, req, zone); rcode != dns.RcodeSuccess {
+		s.writeErrorResponse(w, req, rcode)
+		return
+	}
+
+	zones, err := s.dataStore.ListZones()
+	if err != nil {
+		s.writeUpdateResponse(w, req, dns.RcodeServerFailure)
+		return
+	}
+	if !containsZone(zones, zone) {
+		// Signed NOTAUTH is taken as TSIG failure by the clients, so it is sent unsigned
+		s.writeErrorResponse(w, req, dns.RcodeNotAuth)
+		return
+	}
+
+	// Updates are evaluated on the current records, so they are applied one after the other
+	s.updateMutex.Lock()
+	rcode := s.applyUpdate(zone, zones, req)
+	s.updateMutex.Unlock()
+	if rcode == dns.RcodeSuccess {
+		log.Infof("Dynamic update of %s applied from %s.", zone, w.RemoteAddr().String())
+		soa, err := s.dataStore.ListResourceRecords(&datastore.RecordFilter{Zone: zone, Name: zone, Type: "SOA"})
+		if err == nil && len(soa) != 0 {
+			s.config.notifier.Notify(zone)
+		}
+	}
+	s.writeUpdateResponse(w, req, rcode)
+}
+
+// applyUpdate check the prerequisites and apply the updates of the zone, returns the rcode of the response.
+func (s *Server) applyUpdate(zone string, zones []string, req *dns.Msg) int {
+	u := &zoneUpdate{store: s.dataStore, zone: zone, zones: zones, names: make(map[string]map[uint16]*rrSet)}
+	if rcode := u.checkPrerequisites(req.Answer); rcode != dns.RcodeSuccess {
+		return rcode
+	}
+	if rcode := u.prescan(req.Ns); rcode != dns.RcodeSuccess {
+		return rcode
+	}
+	if err := u.apply(req.Ns); err != nil {
+		log.Errorf("Failed to read the records of zone(%s). %s", zone, err.Error())
+		return dns.RcodeServerFailure
+	}
+
+	ops := u.batchOperations()
+	if len(ops) == 0 {
+		return dns.RcodeSuccess
+	}
+	applyStart := time.Now()
+	_, err := s.dataStore.ApplyBatch(ops)
+	metrics.ObserveDataStore(metrics.OperationUpdate, applyStart)
+	if err != nil {
+		log.Errorf("Failed to apply the dynamic update of zone(%s). %s", zone, err.Error())
+		return dns.RcodeServerFailure
+	}
+
+	return dns.RcodeSuccess
+}
+
+// writeUpdateResponse send the response of the update, signed with the key of the request.
+func (s *Server) writeUpdateResponse(w dns.ResponseWriter, req *dns.Msg, rcode int) {
+	response := new(dns.Msg)
+	response.SetRcode(req, rcode)
+	if tsig := req.IsTsig(); tsig != nil && w.TsigStatus() == nil {
+		response.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsig.Fudge, time.Now().Unix())
+	}
+
+	if err := w.WriteMsg(response); err != nil {
+		log.Errorf("Failed to send update response")
+	}
+}
+
+func containsZone(zones []string, zone string) bool {
+	for _, z := range zones {
+		if strings.EqualFold(z, zone) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rrSet records of a name and type.
+type rrSet struct {
+	zone    string // zone holding the records in the data store, empty if not stored
+	ttl     uint32
+	rrs     []dns.RR
+	changed bool
+	stored  *datastore.ResourceRecord // record in the data store, its answer policy is kept on update
+}
+
+// zoneUpdate records of the names touched by the update, loaded from the data store on demand.
+type zoneUpdate struct {
+	store datastore.DataStore
+	zone  string
+	zones []string // all the local zones
+	names map[string]map[uint16]*rrSet
+}
+
+// isInZone check the name belongs to the zone of the update. Names of a more specific local zone belong to that
+// zone, they are updated only with its own keys.
+func (u *zoneUpdate) isInZone(name string) bool {
+	if !dns.IsSubDomain(u.zone, name) {
+		return false
+	}
+	for _, zone := range u.zones {
+		if !strings.EqualFold(zone, u.zone) && dns.IsSubDomain(u.zone, zone) && dns.IsSubDomain(zone, name) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// load get the records of the name by type. As on lookup, the records of the name may be held by a less specific
+// zone up to the default zone, they are updated in that zone.
+func (u *zoneUpdate) load(name string) (map[uint16]*rrSet, error) {
+	name = strings.ToLower(name)
+	if sets, ok := u.names[name]; ok {
+		return sets, nil
+	}
+
+	records, err := u.store.ListResourceRecords(&datastore.RecordFilter{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	sets := make(map[uint16]*rrSet)
+	for i := range records {
+		if !dns.IsSubDomain(records[i].Zone, u.zone) {
+			continue
+		}
+		rrs, err := records[i].DNSRecords()
+		if err != nil || len(rrs) == 0 {
+			continue
+		}
+		rrType := rrs[0].Header().Rrtype
+		// Records of the most specific zone are the ones answered
+		if set, ok := sets[rrType]; ok && dns.CountLabel(set.zone) > dns.CountLabel(records[i].Zone) {
+			continue
+		}
+		sets[rrType] = &rrSet{zone: records[i].Zone, ttl: records[i].TTL, rrs: rrs,
+			stored: &records[i].ResourceRecord}
+	}
+	u.names[name] = sets
+
+	return sets, nil
+}
+
+// isNameInUse check the name has any records.
+func isNameInUse(sets map[uint16]*rrSet) bool {
+	for _, set := range sets {
+		if len(set.rrs) != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkPrerequisites check the prerequisite section(RFC 2136 section 3.2) against the current records.
+func (u *zoneUpdate) checkPrerequisites(prereqs []dns.RR) int {
+	type setKey struct {
+		name   string
+		rrType uint16
+	}
+	expected := make(map[setKey][]dns.RR)
+
+	for _, rr := range prereqs {
+		hdr := rr.Header()
+		if hdr.Ttl != 0 {
+			return dns.RcodeFormatError
+		}
+		if !u.isInZone(hdr.Name) {
+			return dns.RcodeNotZone
+		}
+		sets, err := u.load(hdr.Name)
+		if err != nil {
+			return dns.RcodeServerFailure
+		}
+
+		switch hdr.Class {
+		case dns.ClassANY:
+			if hdr.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if hdr.Rrtype == dns.TypeANY && !isNameInUse(sets) {
+				return dns.RcodeNameError
+			}
+			if set, ok := sets[hdr.Rrtype]; hdr.Rrtype != dns.TypeANY && (!ok || len(set.rrs) == 0) {
+				return dns.RcodeNXRrset
+			}
+		case dns.ClassNONE:
+			if hdr.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if hdr.Rrtype == dns.TypeANY && isNameInUse(sets) {
+				return dns.RcodeYXDomain
+			}
+			if set, ok := sets[hdr.Rrtype]; hdr.Rrtype != dns.TypeANY && ok && len(set.rrs) != 0 {
+				return dns.RcodeYXRrset
+			}
+		case dns.ClassINET:
+			key := setKey{name: strings.ToLower(hdr.Name), rrType: hdr.Rrtype}
+			expected[key] = append(expected[key], rr)
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+
+	// Value dependent prerequisites must match the whole RRset
+	for key, rrs := range expected {
+		set, ok := u.names[key.name][key.rrType]
+		if !ok || !isSameRRSet(set.rrs, rrs) {
+			return dns.RcodeNXRrset
+		}
+	}
+
+	return dns.RcodeSuccess
+}
+
+// isSameRRSet check the records are the same ignoring the TTL and duplicates.
+func isSameRRSet(rrs1 []dns.RR, rrs2 []dns.RR) bool {
+	return containsAllRRs(rrs1, rrs2) && containsAllRRs(rrs2, rrs1)
+}
+
+func containsAllRRs(rrs []dns.RR, subset []dns.RR) bool {
+	for _, rr := range subset {
+		if indexOfRR(rrs, rr) == -1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// indexOfRR find the record with the same data, class of the rr is ignored.
+func indexOfRR(rrs []dns.RR, rr dns.RR) int {
+	for i, existing := range rrs {
+		target := dns.Copy(rr)
+		target.Header().Class = existing.Header().Class
+		if dns.IsDuplicate(existing, target) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// prescan validate the update section(RFC 2136 section 3.4.1) before applying any of the updates.
+func (u *zoneUpdate) prescan(updates []dns.RR) int {
+	for _, rr := range updates {
+		hdr := rr.Header()
+		if !u.isInZone(hdr.Name) {
+			return dns.RcodeNotZone
+		}
+		switch hdr.Class {
+		case dns.ClassINET:
+			if isMetaType(hdr.Rrtype) {
+				return dns.RcodeFormatError
+			}
+			if _, ok := dns.TypeToString[hdr.Rrtype]; !ok || datastore.ValidateRData(dns.TypeToString[hdr.Rrtype],
+				datastore.RDataString(rr)) != nil {
+				return dns.RcodeNotImplemented
+			}
+			// Data store does not support records without TTL
+			if hdr.Ttl == 0 {
+				return dns.RcodeRefused
+			}
+		case dns.ClassANY:
+			if hdr.Ttl != 0 || hdr.Rdlength != 0 || (hdr.Rrtype != dns.TypeANY && isMetaType(hdr.Rrtype)) {
+				return dns.RcodeFormatError
+			}
+		case dns.ClassNONE:
+			if hdr.Ttl != 0 || isMetaType(hdr.Rrtype) {
+				return dns.RcodeFormatError
+			}
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+
+	return dns.RcodeSuccess
+}
+
+func isMetaType(rrType uint16) bool {
+	switch rrType {
+	case dns.TypeANY, dns.TypeAXFR, dns.TypeIXFR, dns.TypeMAILA, dns.TypeMAILB, dns.TypeOPT, dns.TypeTSIG:
+		return true
+	default:
+		return false
+	}
+}
+
+// apply the update section(RFC 2136 section 3.4.2) on the loaded records.
+func (u *zoneUpdate) apply(updates []dns.RR) error {
+	for _, rr := range updates {
+		hdr := rr.Header()
+		name := strings.ToLower(hdr.Name)
+		sets, err := u.load(name)
+		if err != nil {
+			return err
+		}
+		isApex := name == u.zone
+
+		switch hdr.Class {
+		case dns.ClassINET:
+			u.addRR(sets, rr, isApex)
+		case dns.ClassANY:
+			for rrType, set := range sets {
+				// SOA and NS at the zone apex are never deleted as a whole
+				if (hdr.Rrtype == dns.TypeANY || hdr.Rrtype == rrType) &&
+					!(isApex && (rrType == dns.TypeSOA || rrType == dns.TypeNS)) && len(set.rrs) != 0 {
+					set.rrs, set.changed = nil, true
+				}
+			}
+		case dns.ClassNONE:
+			set, ok := sets[hdr.Rrtype]
+			if !ok || hdr.Rrtype == dns.TypeSOA || (isApex && hdr.Rrtype == dns.TypeNS && len(set.rrs) == 1) {
+				continue
+			}
+			if i := indexOfRR(set.rrs, rr); i != -1 {
+				set.rrs, set.changed = append(set.rrs[:i:i], set.rrs[i+1:]...), true
+			}
+		}
+	}
+
+	return nil
+}
+
+// addRR add the record to its RRset, ignored when it conflicts with the CNAME or an older SOA.
+func (u *zoneUpdate) addRR(sets map[uint16]*rrSet, rr dns.RR, isApex bool) {
+	hdr := rr.Header()
+	hasCNAME := sets[dns.TypeCNAME] != nil && len(sets[dns.TypeCNAME].rrs) != 0
+	if (hdr.Rrtype == dns.TypeCNAME && isNameInUse(sets) && !hasCNAME) ||
+		(hdr.Rrtype != dns.TypeCNAME && hasCNAME) {
+		return
+	}
+
+	set, ok := sets[hdr.Rrtype]
+	if !ok {
+		set = &rrSet{}
+		sets[hdr.Rrtype] = set
+	}
+	record := dns.Copy(rr)
+	record.Header().Name = strings.ToLower(hdr.Name)
+
+	switch hdr.Rrtype {
+	case dns.TypeSOA:
+		if !isApex {
+			return
+		}
+		if len(set.rrs) != 0 && !datastore.SerialGreater(rr.(*dns.SOA).Serial, set.rrs[0].(*dns.SOA).Serial) {
+			return
+		}
+		set.rrs = []dns.RR{record}
+	case dns.TypeCNAME:
+		set.rrs = []dns.RR{record}
+	default:
+		if i := indexOfRR(set.rrs, rr); i != -1 {
+			set.rrs[i] = record
+		} else {
+			set.rrs = append(set.rrs, record)
+		}
+	}
+	// RRset shares the same TTL, the latest one is used
+	set.ttl, set.changed = hdr.Ttl, true
+}
+
+// batchOperations get the batch operations to store the changed records. Deletes go first so that the records
+// replacing them do not conflict, SOA goes last so that its serial is kept if greater than the current one.
+func (u *zoneUpdate) batchOperations() []datastore.BatchOperation {
+	var ops []datastore.BatchOperation
+	for name, sets := range u.names {
+		for rrType, set := range sets {
+			if !set.changed {
+				continue
+			}
+			rr := datastore.ResourceRecord{Name: name, Type: dns.TypeToString[rrType], Class: "IN", TTL: set.ttl}
+			for _, record := range set.rrs {
+				rr.RData = append(rr.RData, datastore.RDataString(record))
+			}
+			switch {
+			case len(set.zone) == 0 && len(set.rrs) != 0:
+				ops = append(ops, datastore.BatchOperation{Op: datastore.BatchOpAdd, Zone: u.zone, RR: rr})
+			case len(set.zone) != 0 && len(set.rrs) == 0:
+				ops = append(ops, datastore.BatchOperation{Op: datastore.BatchOpDelete, Zone: set.zone, RR: rr})
+			case len(set.zone) != 0:
+				keepAnswerPolicy(&rr, set.stored)
+				ops = append(ops, datastore.BatchOperation{Op: datastore.BatchOpUpdate, Zone: set.zone, RR: rr})
+			}
+		}
+	}
+
+	sort.Slice(ops, func(i, j int) bool {
+		if (ops[i].Op == datastore.BatchOpDelete) != (ops[j].Op == datastore.BatchOpDelete) {
+			return ops[i].Op == datastore.BatchOpDelete
+		}
+		if (ops[i].RR.Type == "SOA") != (ops[j].RR.Type == "SOA") {
+			return ops[j].RR.Type == "SOA"
+		}
+		if ops[i].RR.Name != ops[j].RR.Name {
+			return ops[i].RR.Name < ops[j].RR.Name
+		}
+		return ops[i].RR.Type < ops[j].RR.Type
+	})
+
+	return ops
+}
+
+// keepAnswerPolicy keep the answer policy of the stored record in the updated record, the weights follow their rData
+// and the added rData get the default weight.
+func keepAnswerPolicy(rr *datastore.ResourceRecord, stored *datastore.ResourceRecord) {
+	if stored == nil {
+		return
+	}
+	rr.MaxAnswers = stored.MaxAnswers
+	rr.HealthCheck = stored.HealthCheck
+	if len(stored.Weights) == 0 || len(stored.Weights) != len(stored.RData) {
+		return
+	}
+	storedRRs, err := stored.DNSRecords()
+	if err != nil {
+		return
+	}
+	weights := make(map[string]uint32, len(storedRRs))
+	for i, record := range storedRRs {
+		weights[datastore.RDataString(record)] = stored.Weights[i]
+	}
+	for _, rData := range rr.RData {
+		weight, ok := weights[rData]
+		if !ok {
+			weight = datastore.DefaultWeight
+		}
+		rr.Weights = append(rr.Weights, weight)
+	}
+}
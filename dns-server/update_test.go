@@ -0,0 +1,365 @@
+/*
+ * Copyright 2021 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+
+	"dns-server/datastore"
+	"dns-server/forward"
+	"dns-server/querylog"
+	"dns-server/util"
+)
+
+const (
+	updateZone      = "example.com."
+	updateKeyName   = "update-key."
+	updateKeySecret = "c2VjcmV0LWtleS1mb3ItdXBkYXRl"
+	otherKeySecret  = "b3RoZXItc2VjcmV0LWtleQ=="
+	errorInUpdate   = "Error in dynamic update"
+)
+
+func TestParseTSIGKeys(t *testing.T) {
+	keys, err := parseTSIGKeys("example.com:update-key:hmac-sha256:" + updateKeySecret +
+		", example.org.:update-key.:HMAC-SHA256:" + updateKeySecret)
+	assert.Equal(t, nil, err, errorInUpdate)
+	assert.Equal(t, []tsigKey{{name: updateKeyName, algorithm: dns.HmacSHA256, secret: updateKeySecret}},
+		keys[updateZone], errorInUpdate)
+	assert.Equal(t, 2, len(keys), errorInUpdate)
+	assert.Equal(t, map[string]string{updateKeyName: updateKeySecret}, tsigSecrets(keys), errorInUpdate)
+
+	keys, err = parseTSIGKeys("")
+	assert.Equal(t, nil, err, errorInUpdate)
+	assert.Equal(t, 0, len(keys), errorInUpdate)
+
+	for _, invalid := range []string{"example.com:update-key:" + updateKeySecret,
+		"example.com:update-key:hmac-md5:" + updateKeySecret,
+		"example.com:update-key:hmac-sha256:not-base64!",
+		"example.com:update-key:hmac-sha256:" + updateKeySecret + ",example.org:update-key:hmac-sha256:" +
+			otherKeySecret} {
+		_, err = parseTSIGKeys(invalid)
+		assert.NotEqual(t, nil, err, errorInUpdate)
+	}
+}
+
+func TestDynamicUpdate(t *testing.T) {
+	defer func() {
+		_ = os.RemoveAll(datastore.DBPath)
+		r := recover()
+		if r != nil {
+			t.Errorf("Panic: %v", r)
+		}
+	}()
+
+	var dbName = "test_db"
+	var port = getFreeTestPort(t)
+	var mgmtPort uint = util.DefaultManagementPort
+	var connTimeOut uint = util.DefaultConnTimeout
+	var ipAddString = "127.0.0.1"
+	var ipMgmtAddString = util.DefaultIP
+	var forwarder = util.DefaultIP
+	var loadBalance = false
+	var forwarderPort uint = util.DefaultForwarderPort
+	var forwardPolicy = forward.PolicyRoundRobin
+	var cacheSize uint = util.DefaultCacheSize
+	var transferACL = ""
+	var secondaries = ""
+	var tsigKeys = fmt.Sprintf("%s:%s:hmac-sha256:%s,other.com.:other-key.:hmac-sha256:%s", updateZone,
+		updateKeyName, updateKeySecret, otherKeySecret)
+	var queryLog = ""
+	var queryLogFormat = querylog.FormatJSON
+	var queryLogRate uint = 0
+	var rateLimit uint = 0
+	var rateLimitSlip uint = util.DefaultRateLimitSlip
+	var rateLimitWL = ""
+	var storeName = datastore.StoreBoltDB
+	var etcdEndpoints = ""
+	var etcdPrefix = util.DefaultEtcdPrefix
+	var configFile = ""
+	parameters := &InputParameters{&dbName, &port, &mgmtPort, &connTimeOut,
+		&ipAddString, &ipMgmtAddString, &forwarder, &loadBalance, &forwarderPort, &forwardPolicy,
+		&cacheSize, &transferACL, &secondaries, &tsigKeys, &queryLog, &queryLogFormat, &queryLogRate,
+		&rateLimit, &rateLimitSlip, &rateLimitWL, &storeName, &etcdEndpoints, &etcdPrefix,
+		&configFile}
+	config := validateInputAndGenerateConfig(parameters)
+
+	store := &datastore.BoltDB{FileName: config.dbName, TTL: util.DefaultTTL}
+	dnsServer := NewServer(config, store, &mockMgmtCtrl{})
+	err := dnsServer.Run()
+	assert.Equal(t, nil, err, "Error in starting the server")
+	defer dnsServer.Stop()
+
+	err = store.ImportZone(updateZone, []datastore.ResourceRecord{
+		{Name: updateZone, Type: "SOA", Class: "IN", TTL: 30,
+			RData: []string{"ns1.example.com. admin.example.com. 10 3600 600 86400 10"}},
+		{Name: updateZone, Type: "NS", Class: "IN", TTL: 30, RData: []string{"ns1.example.com."}},
+		{Name: exampleDomain, Type: "A", Class: "IN", TTL: 30, RData: []string{"192.168.1.1", "192.168.1.2"}},
+	})
+	assert.Equal(t, nil, err, "Error in setting the record")
+	err = store.ImportZone("sub."+updateZone, []datastore.ResourceRecord{
+		{Name: "www.sub." + updateZone, Type: "A", Class: "IN", TTL: 30, RData: []string{"192.168.2.1"}},
+	})
+	assert.Equal(t, nil, err, "Error in setting the record")
+
+	address := fmt.Sprintf("127.0.0.1:%d", port)
+	client := &dns.Client{Net: "tcp", TsigSecret: map[string]string{updateKeyName: updateKeySecret,
+		"other-key.": otherKeySecret, "unknown-key.": otherKeySecret}}
+	// Wait till the server is up
+	ready := new(dns.Msg)
+	ready.SetQuestion(exampleDomain, dns.TypeA)
+	_, err = exchangeWithRetry(client, ready, address)
+	assert.Equal(t, nil, err, errorInResponse)
+
+	newRR := func(text string) dns.RR {
+		rr, err := dns.NewRR(text)
+		assert.Equal(t, nil, err, errorInUpdate)
+		return rr
+	}
+	sendUpdate := func(m *dns.Msg, keyName string) *dns.Msg {
+		if len(keyName) != 0 {
+			m.SetTsig(keyName, dns.HmacSHA256, 300, time.Now().Unix())
+		}
+		rsp, _, err := client.Exchange(m, address)
+		assert.Equal(t, nil, err, errorInUpdate)
+		if err != nil {
+			return new(dns.Msg)
+		}
+		if len(keyName) != 0 && rsp.Rcode == dns.RcodeSuccess {
+			assert.NotEqual(t, (*dns.TSIG)(nil), rsp.IsTsig(), "Response not signed")
+		}
+		return rsp
+	}
+	lookup := func(name string, rrType string) []string {
+		records, _ := store.ListResourceRecords(&datastore.RecordFilter{Name: name, Type: rrType})
+		if len(records) == 0 {
+			return nil
+		}
+		return records[0].RData
+	}
+
+	t.Run("AddRecords", func(t *testing.T) {
+		m := new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.Insert([]dns.RR{newRR("app.example.com. 60 IN A 192.168.2.1"),
+			newRR("app.example.com. 60 IN A 192.168.2.2"),
+			newRR("www.example.com. 30 IN A 192.168.1.3"),
+			newRR("example.com. 30 IN TXT \"v=spf1 -all\"")})
+		rsp := sendUpdate(m, updateKeyName)
+		assert.Equal(t, dns.RcodeSuccess, rsp.Rcode, errorInUpdate)
+
+		assert.Equal(t, []string{"192.168.2.1", "192.168.2.2"}, lookup("app.example.com.", "A"), errorInUpdate)
+		assert.Equal(t, []string{"192.168.1.1", "192.168.1.2", "192.168.1.3"}, lookup(exampleDomain, "A"),
+			errorInUpdate)
+		assert.Equal(t, []string{"v=spf1 -all"}, lookup(updateZone, "TXT"), errorInUpdate)
+
+		// Answered by the server right away
+		req := new(dns.Msg)
+		req.SetQuestion("app.example.com.", dns.TypeA)
+		answer, _, err := client.Exchange(req, address)
+		assert.Equal(t, nil, err, errorInResponse)
+		assert.Equal(t, 2, len(answer.Answer), errorInResponse)
+	})
+
+	t.Run("RecordsOfDefaultZone", func(t *testing.T) {
+		// Name of the zone stored in the default zone, answered from there
+		err := store.SetResourceRecord(datastore.DefaultZone, &datastore.ResourceRecord{Name: "legacy.example.com.",
+			Type: "A", Class: "IN", TTL: 30, RData: []string{"192.168.3.1"}})
+		assert.Equal(t, nil, err, "Error in setting the record")
+		m := new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.Insert([]dns.RR{newRR("legacy.example.com. 30 IN A 192.168.3.2")})
+		assert.Equal(t, dns.RcodeSuccess, sendUpdate(m, updateKeyName).Rcode, errorInUpdate)
+		records, _ := store.ListResourceRecords(&datastore.RecordFilter{Name: "legacy.example.com."})
+		assert.Equal(t, 1, len(records), errorInUpdate)
+		assert.Equal(t, datastore.DefaultZone, records[0].Zone, errorInUpdate)
+		assert.Equal(t, []string{"192.168.3.1", "192.168.3.2"}, records[0].RData, errorInUpdate)
+
+		m = new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.RemoveName([]dns.RR{newRR("legacy.example.com. 0 IN A 0.0.0.0")})
+		assert.Equal(t, dns.RcodeSuccess, sendUpdate(m, updateKeyName).Rcode, errorInUpdate)
+		assert.Equal(t, []string(nil), lookup("legacy.example.com.", "A"), errorInUpdate)
+	})
+
+	t.Run("DeleteRecords", func(t *testing.T) {
+		m := new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.Remove([]dns.RR{newRR("www.example.com. 0 IN A 192.168.1.1")})
+		m.RemoveRRset([]dns.RR{newRR("app.example.com. 0 IN A 0.0.0.0")})
+		rsp := sendUpdate(m, updateKeyName)
+		assert.Equal(t, dns.RcodeSuccess, rsp.Rcode, errorInUpdate)
+		assert.Equal(t, []string{"192.168.1.2", "192.168.1.3"}, lookup(exampleDomain, "A"), errorInUpdate)
+		assert.Equal(t, []string(nil), lookup("app.example.com.", "A"), errorInUpdate)
+	})
+
+	t.Run("ApexSOAAndNSKept", func(t *testing.T) {
+		m := new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.RemoveName([]dns.RR{newRR("example.com. 0 IN A 0.0.0.0")})
+		m.Remove([]dns.RR{newRR("example.com. 0 IN NS ns1.example.com.")})
+		rsp := sendUpdate(m, updateKeyName)
+		assert.Equal(t, dns.RcodeSuccess, rsp.Rcode, errorInUpdate)
+		assert.Equal(t, []string{"ns1.example.com."}, lookup(updateZone, "NS"), errorInUpdate)
+		assert.Equal(t, 1, len(lookup(updateZone, "SOA")), errorInUpdate)
+		assert.Equal(t, []string(nil), lookup(updateZone, "TXT"), errorInUpdate)
+	})
+
+	t.Run("Prerequisites", func(t *testing.T) {
+		m := new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.NameNotUsed([]dns.RR{newRR("www.example.com. 0 IN A 0.0.0.0")})
+		m.Insert([]dns.RR{newRR("www.example.com. 30 IN A 192.168.1.4")})
+		assert.Equal(t, dns.RcodeYXDomain, sendUpdate(m, updateKeyName).Rcode, errorInUpdate)
+
+		m = new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.NameUsed([]dns.RR{newRR("new.example.com. 0 IN A 0.0.0.0")})
+		assert.Equal(t, dns.RcodeNameError, sendUpdate(m, updateKeyName).Rcode, errorInUpdate)
+
+		m = new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.RRsetNotUsed([]dns.RR{newRR("www.example.com. 0 IN A 0.0.0.0")})
+		assert.Equal(t, dns.RcodeYXRrset, sendUpdate(m, updateKeyName).Rcode, errorInUpdate)
+
+		m = new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.Used([]dns.RR{newRR("www.example.com. 0 IN A 192.168.1.2")})
+		assert.Equal(t, dns.RcodeNXRrset, sendUpdate(m, updateKeyName).Rcode, errorInUpdate)
+
+		// Whole RRset matches, the update is applied
+		m = new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.Used([]dns.RR{newRR("www.example.com. 0 IN A 192.168.1.2"), newRR("www.example.com. 0 IN A 192.168.1.3")})
+		m.RRsetUsed([]dns.RR{newRR("example.com. 0 IN SOA . . 0 0 0 0 0")})
+		m.Insert([]dns.RR{newRR("www.example.com. 30 IN A 192.168.1.4")})
+		assert.Equal(t, dns.RcodeSuccess, sendUpdate(m, updateKeyName).Rcode, errorInUpdate)
+		assert.Equal(t, []string{"192.168.1.2", "192.168.1.3", "192.168.1.4"}, lookup(exampleDomain, "A"),
+			errorInUpdate)
+	})
+
+	t.Run("CNAMEConflictIgnored", func(t *testing.T) {
+		m := new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.Insert([]dns.RR{newRR("www.example.com. 30 IN CNAME app.example.com.")})
+		assert.Equal(t, dns.RcodeSuccess, sendUpdate(m, updateKeyName).Rcode, errorInUpdate)
+		assert.Equal(t, []string(nil), lookup(exampleDomain, "CNAME"), errorInUpdate)
+
+		// Replacing the A records with the CNAME in a single update
+		m = new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.RemoveRRset([]dns.RR{newRR("www.example.com. 0 IN A 0.0.0.0")})
+		m.Insert([]dns.RR{newRR("www.example.com. 30 IN CNAME app.example.com.")})
+		assert.Equal(t, dns.RcodeSuccess, sendUpdate(m, updateKeyName).Rcode, errorInUpdate)
+		assert.Equal(t, []string{"app.example.com."}, lookup(exampleDomain, "CNAME"), errorInUpdate)
+		assert.Equal(t, []string(nil), lookup(exampleDomain, "A"), errorInUpdate)
+	})
+
+	t.Run("OutOfZone", func(t *testing.T) {
+		m := new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.Insert([]dns.RR{newRR("www.example.org. 30 IN A 192.168.1.1")})
+		assert.Equal(t, dns.RcodeNotZone, sendUpdate(m, updateKeyName).Rcode, errorInUpdate)
+	})
+
+	t.Run("ChildZone", func(t *testing.T) {
+		m := new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.RemoveName([]dns.RR{newRR("www.sub.example.com. 0 IN A 0.0.0.0")})
+		assert.Equal(t, dns.RcodeNotZone, sendUpdate(m, updateKeyName).Rcode, errorInUpdate)
+		m = new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.RRsetUsed([]dns.RR{newRR("www.sub.example.com. 0 IN A 0.0.0.0")})
+		m.Insert([]dns.RR{newRR("new.example.com. 30 IN A 192.168.1.1")})
+		assert.Equal(t, dns.RcodeNotZone, sendUpdate(m, updateKeyName).Rcode, errorInUpdate)
+		assert.Equal(t, []string{"192.168.2.1"}, lookup("www.sub.example.com.", "A"), errorInUpdate)
+	})
+
+	t.Run("UnsupportedType", func(t *testing.T) {
+		m := new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.Insert([]dns.RR{newRR("www.example.com. 30 IN HINFO \"cpu\" \"os\"")})
+		assert.Equal(t, dns.RcodeNotImplemented, sendUpdate(m, updateKeyName).Rcode, errorInUpdate)
+	})
+
+	t.Run("Unsigned", func(t *testing.T) {
+		m := new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.Insert([]dns.RR{newRR("new.example.com. 30 IN A 192.168.1.1")})
+		assert.Equal(t, dns.RcodeRefused, sendUpdate(m, "").Rcode, errorInUpdate)
+		assert.Equal(t, []string(nil), lookup("new.example.com.", "A"), errorInUpdate)
+	})
+
+	t.Run("KeyOfOtherZone", func(t *testing.T) {
+		m := new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.Insert([]dns.RR{newRR("new.example.com. 30 IN A 192.168.1.1")})
+		assert.Equal(t, dns.RcodeNotAuth, sendUpdate(m, "other-key.").Rcode, errorInUpdate)
+		assert.Equal(t, []string(nil), lookup("new.example.com.", "A"), errorInUpdate)
+	})
+
+	t.Run("UnknownKey", func(t *testing.T) {
+		m := new(dns.Msg)
+		m.SetUpdate(updateZone)
+		m.Insert([]dns.RR{newRR("new.example.com. 30 IN A 192.168.1.1")})
+		m.SetTsig("unknown-key.", dns.HmacSHA256, 300, time.Now().Unix())
+		rsp, _, err := client.Exchange(m, address)
+		// Unsigned response for the unknown key fails the client verification
+		if err == nil {
+			assert.Equal(t, dns.RcodeNotAuth, rsp.Rcode, errorInUpdate)
+		}
+		assert.Equal(t, []string(nil), lookup("new.example.com.", "A"), errorInUpdate)
+	})
+
+	t.Run("ZoneWithoutKeys", func(t *testing.T) {
+		m := new(dns.Msg)
+		m.SetUpdate("example.org.")
+		m.Insert([]dns.RR{newRR("www.example.org. 30 IN A 192.168.1.1")})
+		assert.Equal(t, dns.RcodeRefused, sendUpdate(m, updateKeyName).Rcode, errorInUpdate)
+	})
+
+	t.Run("ZoneNotLocal", func(t *testing.T) {
+		m := new(dns.Msg)
+		m.SetUpdate("other.com.")
+		m.Insert([]dns.RR{newRR("www.other.com. 30 IN A 192.168.1.1")})
+		assert.Equal(t, dns.RcodeNotAuth, sendUpdate(m, "other-key.").Rcode, errorInUpdate)
+	})
+}
+
+func TestKeepAnswerPolicy(t *testing.T) {
+	check := &datastore.HealthCheck{Protocol: "tcp", Port: 80}
+	stored := &datastore.ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN", TTL: 30,
+		RData: []string{"192.168.1.1", "192.168.1.2"}, Weights: []uint32{5, 0}, MaxAnswers: 1, HealthCheck: check}
+
+	rr := &datastore.ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN", TTL: 30,
+		RData: []string{"192.168.1.2", "192.168.1.3"}}
+	keepAnswerPolicy(rr, stored)
+	// Weights follow the rData, the added rData get the default weight
+	assert.Equal(t, []uint32{0, datastore.DefaultWeight}, rr.Weights, errorInUpdate)
+	assert.Equal(t, uint32(1), rr.MaxAnswers, errorInUpdate)
+	assert.Equal(t, check, rr.HealthCheck, errorInUpdate)
+
+	rr = &datastore.ResourceRecord{Name: exampleDomain, Type: "A", Class: "IN", TTL: 30,
+		RData: []string{"192.168.1.3"}}
+	keepAnswerPolicy(rr, nil)
+	assert.Equal(t, 0, len(rr.Weights), errorInUpdate)
+}
@@ -40,14 +40,117 @@ const (
 	DNSUDPPacketSize = 65535
 	// ForwardRetryCount  Max Forward retry count.
 	ForwardRetryCount = 3
+	// DefaultForwarderPort  Default forwarder port.
+	DefaultForwarderPort = 53
+	// DefaultDoTPort  Default DNS-over-TLS forwarder port.
+	DefaultDoTPort = 853
+	// ForwarderMaxFails  Consecutive failures to eject a forwarder.
+	ForwarderMaxFails = 3
+	// ForwarderEjectDuration  Ejection duration of a failed forwarder in seconds.
+	ForwarderEjectDuration = 30
+	// DefaultCacheSize  Default number of responses in the forward cache.
+	DefaultCacheSize = 4096
+	// MaxCacheSize  Maximum number of responses in the forward cache.
+	MaxCacheSize = 1048576
+	// MaxCacheTTL  Maximum TTL of a cached response in seconds.
+	MaxCacheTTL = 3600
+	// MaxNegativeCacheTTL  Maximum TTL of a cached negative response in seconds.
+	MaxNegativeCacheTTL = 300
+	// DefaultPageLimit  Default number of records in a list response.
+	DefaultPageLimit = 100
+	// MaxPageLimit  Maximum number of records in a list response.
+	MaxPageLimit = 1000
 	// DefaultIP  default ip.
 	DefaultIP = "0.0.0.0"
 	// MaxPacketSize  Maximum packet size.
 	MaxPacketSize = "4K"
+	// MaxZoneFileSize  Maximum size of an imported zone file.
+	MaxZoneFileSize = "4M"
+	// MaxBatchSize  Maximum size of a batch update request.
+	MaxBatchSize = "1M"
+	// MaxBatchOperations  Maximum number of operations in a batch update request.
+	MaxBatchOperations = 1000
+	// MaxJournalEntries  Maximum number of changes kept per zone for the incremental zone transfer.
+	MaxJournalEntries = 100
+	// TransferChunkSize  Number of records in a zone transfer message.
+	TransferChunkSize = 100
+	// NotifyRetries  Number of attempts to notify a secondary.
+	NotifyRetries = 3
+	// NotifyRetryInterval  Interval between the notify attempts in seconds.
+	NotifyRetryInterval = 2
+	// DefaultHealthCheckInterval  Default interval between the health probes of an address in seconds.
+	DefaultHealthCheckInterval = 10
+	// MaxHealthCheckInterval  Maximum interval between the health probes of an address in seconds.
+	MaxHealthCheckInterval = 3600
+	// DefaultHealthCheckTimeout  Default timeout of a health probe in seconds.
+	DefaultHealthCheckTimeout = 3
+	// HealthCheckMaxFails  Consecutive probe failures to consider an address unhealthy.
+	HealthCheckMaxFails = 2
+	// HealthCheckIdleTimeout  Duration in seconds an address is probed after it was last answered.
+	HealthCheckIdleTimeout = 600
+	// QueryLogBufferSize  Number of query log entries queued to be written.
+	QueryLogBufferSize = 4096
+	// QueryLogRetryInterval  Interval in seconds to reopen the query log after a failure.
+	QueryLogRetryInterval = 5
+	// QueryLogDialTimeout  Timeout in seconds of connecting and handshaking with the query log socket reader.
+	QueryLogDialTimeout = 2
+	// DefaultRateLimitSlip  Default ratio of the rate limited responses truncated instead of dropped.
+	DefaultRateLimitSlip = 2
+	// MaxRateLimitSlip  Maximum ratio of the rate limited responses truncated instead of dropped.
+	MaxRateLimitSlip = 10
+	// RateLimitIPv4PrefixLength  IPv4 prefix length of the clients sharing a rate limit.
+	RateLimitIPv4PrefixLength = 24
+	// RateLimitIPv6PrefixLength  IPv6 prefix length of the clients sharing a rate limit.
+	RateLimitIPv6PrefixLength = 56
+	// RateLimitMaxEntries  Maximum number of client prefix and query name tracked by the rate limit.
+	RateLimitMaxEntries = 100000
+	// RateLimitSweepInterval  Interval in seconds to remove the idle entries of the rate limit.
+	RateLimitSweepInterval = 10
+	// ShutdownTimeout  Timeout in seconds of draining the in-flight queries and requests on shutdown.
+	ShutdownTimeout = 10
+	// EtcdDialTimeout  Timeout in seconds of connecting to etcd.
+	EtcdDialTimeout = 5
+	// EtcdRequestTimeout  Timeout in seconds of an etcd request.
+	EtcdRequestTimeout = 5
+	// EtcdCommitRetries  Number of attempts to commit a change conflicting with the concurrent changes in etcd.
+	EtcdCommitRetries = 3
+	// EtcdRetryInterval  Interval in seconds to resync with etcd after the watch failed.
+	EtcdRetryInterval = 1
+	// EtcdMaxTxnOps  Maximum number of operations in an etcd transaction, the default max-txn-ops of etcd.
+	EtcdMaxTxnOps = 128
+	// DNSSECSignatureValidity  Validity period in seconds of the DNSSEC signatures.
+	DNSSECSignatureValidity = 604800
+	// DNSSECSignatureInception  Duration in seconds the DNSSEC signatures are backdated, allowing the clock skew.
+	DNSSECSignatureInception = 3600
+	// DNSSECKeyTTL  TTL of the DNSKEY records.
+	DNSSECKeyTTL = 3600
+	// DNSSECCacheTTL  Duration in seconds the zone keys and owner names are cached for signing.
+	DNSSECCacheTTL = 5
+	// DNSSECSignatureRefresh  Duration in seconds before their expiration the cached DNSSEC signatures are renewed.
+	DNSSECSignatureRefresh = 86400
+	// DNSSECSignatureCacheSize  Maximum number of rrsets with cached DNSSEC signatures, all dropped once reached.
+	DNSSECSignatureCacheSize = 100000
+	// EDNSBufferSize  UDP payload size advertised in the EDNS responses.
+	EDNSBufferSize = 1232
 )
 
 const MaxDNSFQDNLength = 253
 const MaxDNSQuestionLength = MaxDNSFQDNLength + 1
 
+// MaxRDataLength Maximum length of a rData entry.
+const MaxRDataLength = 1024
+
+// MaxCNAMEChainLength Maximum number of CNAME to follow while resolving local entries.
+const MaxCNAMEChainLength = 8
+
+// MaxViewNameLength Maximum length of a view name.
+const MaxViewNameLength = 63
+
 // MaxIPLength Considering IPV4(15), IPV6(39) and IPV4-mapped IPV6(45).
 const MaxIPLength = 45
+
+// DefaultEtcdPrefix Default prefix of the data store keys in etcd.
+const DefaultEtcdPrefix = "/dns-server/"
+
+// MaxHealthCheckPathLength Maximum length of the http health check path.
+const MaxHealthCheckPathLength = 256
@@ -14,11 +14,20 @@
 
 local BasePlugin = require "kong.plugins.base_plugin"
 local jwt_decoder = require "kong.plugins.jwt.jwt_parser"
+local cjson = require "cjson.safe"
+local http = require "resty.http"
 
 
 local kong = kong
 local type = type
+local ipairs = ipairs
 local re_gmatch = ngx.re.gmatch
+local APP_SERVICES_HEADER = "X-AppServices"
+local DENYLIST_CACHE_KEY = "appid-header:denylist"
+local DENYLIST_TIMEOUT = 3000
+
+-- last deny list fetched by this worker by url, kept in use while mep auth can not be reached
+local last_denylists = {}
 
 local AddAppIdHeaderHandler = {}
 
@@ -51,7 +60,85 @@ local function retrieve_token()
 end
 
 
-local function add_app_id_check_ip()
+-- fetch the token deny list from mep auth, indexed by jti and by app instance id. The certificate of mep auth is
+-- verified against lua_ssl_trusted_certificate of kong.
+local function fetch_denylist(url)
+  local client = http.new()
+  client:set_timeout(DENYLIST_TIMEOUT)
+  local res, err = client:request_uri(url, {
+    method = "GET",
+    headers = { ["X-Real-Ip"] = ngx.var.server_addr },
+    ssl_verify = true,
+  })
+  if not res then
+    return nil, err
+  end
+  if res.status ~= 200 then
+    return nil, "unexpected deny list response status " .. res.status
+  end
+  local body, err = cjson.decode(res.body)
+  if type(body) ~= "table" then
+    return nil, err or "bad deny list response"
+  end
+
+  local denylist = { jtis = {}, apps = {} }
+  if type(body.jtis) == "table" then
+    for _, jti in ipairs(body.jtis) do
+      denylist.jtis[jti] = true
+    end
+  end
+  if type(body.apps) == "table" then
+    for _, app in ipairs(body.apps) do
+      denylist.apps[app.appInsId] = app.revokedAt
+    end
+  end
+  return denylist
+end
+
+
+-- get the cached deny list, the last one fetched is used when it can not be refreshed
+local function get_denylist(conf)
+  local denylist, err = kong.cache:get(DENYLIST_CACHE_KEY, { ttl = conf.denylist_cache_ttl },
+                                       fetch_denylist, conf.denylist_url)
+  if denylist then
+    last_denylists[conf.denylist_url] = denylist
+    return denylist
+  end
+  denylist = last_denylists[conf.denylist_url]
+  if denylist then
+    kong.log.warn("failed to refresh the token deny list, using the last one: ", err)
+    return denylist
+  end
+  return nil, err
+end
+
+
+local function is_token_denied(conf, claims)
+  if not conf.denylist_url then
+    return false
+  end
+  local denylist, err = get_denylist(conf)
+  if not denylist then
+    -- fail closed, revoked tokens must not be accepted because mep auth can not be reached
+    kong.log.err("failed to get the token deny list: ", err)
+    return true
+  end
+
+  local jti = claims["jti"]
+  if jti and denylist.jtis[jti] then
+    return true
+  end
+  -- all tokens of a deleted app issued until the revocation are denied
+  local revoked_at = denylist.apps[claims["sub"]]
+  if revoked_at then
+    local iat = claims["iat"]
+    return type(iat) ~= "number" or iat <= revoked_at
+  end
+  return false
+end
+
+
+local function add_app_id_check_ip(conf)
   local token, err = retrieve_token()
   if err then
     kong.log.err(err)
@@ -79,16 +166,35 @@ local function add_app_id_check_ip()
     return false
   end
 
+  if is_token_denied(conf, claims) then
+    return false
+  end
+
   local set_header = kong.service.request.set_header
   local clear_header = kong.service.request.clear_header
   clear_header("X-AppinstanceID")
   set_header("X-AppinstanceID", app_id)
+
+  -- forward the services the token is scoped to, tokens without the claim are not scoped
+  clear_header(APP_SERVICES_HEADER)
+  local services = claims["services"]
+  if type(services) == "table" then
+    if #services == 0 then
+      set_header(APP_SERVICES_HEADER, "[]")
+    else
+      local encoded, err = cjson.encode(services)
+      if err then
+        return nil, err
+      end
+      set_header(APP_SERVICES_HEADER, encoded)
+    end
+  end
   return true
 end
 
 
 function AddAppIdHeaderHandler:access(conf)
-  local ok, err = add_app_id_check_ip()
+  local ok, err = add_app_id_check_ip(conf)
   if err then
     kong.log.err(err)
     return kong.response.exit(500, { message = "Unexpected error."})
@@ -23,6 +23,9 @@ local schema = {
         -- The 'config' record is the custom part of the plugin schema
         type = "record",
         fields = {
+          -- mep auth token deny list, tokens are not checked against it when not set
+          { denylist_url = typedefs.url },
+          { denylist_cache_ttl = { type = "number", default = 5, gt = 0 } },
         },
       },
     },
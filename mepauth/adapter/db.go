@@ -17,6 +17,8 @@
 // Package adapter contains database interface and implements database adapter
 package adapter
 
+import "time"
+
 // Database API's
 type Database interface {
 	// InitDatabase initializes database
@@ -31,6 +33,19 @@ type Database interface {
 	// ReadData reads data from database
 	ReadData(data interface{}, cols ...string) (err error)
 
+	// ReadAllData reads all rows of a table from database
+	ReadAllData(table string, container interface{}) (num int64, err error)
+
 	// DeleteData deletes data from database
 	DeleteData(data interface{}, cols ...string) (err error)
+
+	// DeleteDataCount deletes data from database and returns the number of deleted rows
+	DeleteDataCount(data interface{}, cols ...string) (num int64, err error)
+
+	// UpdateDataLocked reads data locked for update and saves it when update returns true, in one transaction.
+	// update is told whether the row exists, the row is inserted when it does not
+	UpdateDataLocked(data interface{}, update func(found bool) bool, cols ...string) (err error)
+
+	// DeleteDataBefore deletes the rows of a table whose time column is before the given time
+	DeleteDataBefore(table string, col string, before time.Time) (num int64, err error)
 }
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"mepauth/util"
+	"time"
 	"unsafe"
 
 	"github.com/astaxie/beego/orm"
@@ -72,12 +73,72 @@ func (db *PgDb) ReadData(data interface{}, cols ...string) (err error) {
 	return err
 }
 
+// ReadAllData reads all rows of a table from postgres database
+func (db *PgDb) ReadAllData(table string, container interface{}) (num int64, err error) {
+	num, err = db.ormer.QueryTable(table).All(container)
+	return num, err
+}
+
 // DeleteData deletes data from postgres database
 func (db *PgDb) DeleteData(data interface{}, cols ...string) (err error) {
 	_, err = db.ormer.Delete(data, cols...)
 	return err
 }
 
+// DeleteDataCount deletes data from postgres database and returns the number of deleted rows
+func (db *PgDb) DeleteDataCount(data interface{}, cols ...string) (num int64, err error) {
+	num, err = db.ormer.Delete(data, cols...)
+	return num, err
+}
+
+// UpdateDataLocked reads data locked for update from postgres database and saves it when update returns true
+func (db *PgDb) UpdateDataLocked(data interface{}, update func(found bool) bool, cols ...string) (err error) {
+	retry, err := db.updateDataLocked(data, update, cols...)
+	if retry {
+		// a concurrent transaction inserted the row first, it is read locked this time
+		_, err = db.updateDataLocked(data, update, cols...)
+	}
+	return err
+}
+
+// Runs one read-update-save transaction, retry tells the row could not be inserted
+func (db *PgDb) updateDataLocked(data interface{}, update func(found bool) bool, cols ...string) (retry bool,
+	err error) {
+	// the shared ormer is not bound to a transaction, each transaction has an ormer of its own
+	o := orm.NewOrm()
+	if err = o.Using(Default); err != nil {
+		return false, err
+	}
+	if err = o.Begin(); err != nil {
+		return false, err
+	}
+	err = o.ReadForUpdate(data, cols...)
+	found := err == nil
+	if err != nil && !errors.Is(err, orm.ErrNoRows) {
+		_ = o.Rollback()
+		return false, err
+	}
+	if !update(found) {
+		return false, o.Rollback()
+	}
+	if found {
+		_, err = o.Update(data)
+	} else {
+		_, err = o.Insert(data)
+	}
+	if err != nil {
+		_ = o.Rollback()
+		return !found, err
+	}
+	return false, o.Commit()
+}
+
+// DeleteDataBefore deletes the rows of a table whose time column is before the given time
+func (db *PgDb) DeleteDataBefore(table string, col string, before time.Time) (num int64, err error) {
+	num, err = db.ormer.QueryTable(table).Filter(col+"__lt", before).Delete()
+	return num, err
+}
+
 // InitDatabase initializes database of type postgres
 func (db *PgDb) InitDatabase() error {
 
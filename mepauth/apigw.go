@@ -92,7 +92,7 @@ func (i *apiGwInitializer) SetupHttpLogPlugin(apiGwUrl string) error {
 }
 
 func (i *apiGwInitializer) SetApiGwConsumer(apiGwUrl string) error {
-	// add mepauth consumer to ApiGw
+	// add mepauth consumer to ApiGw, its jwt credentials are published by the jwt key set keyed by the kid of each key
 	consumerUrl := apiGwUrl + "/consumers"
 	jsonConsumerByte := []byte(fmt.Sprintf(`{ "username": "%s" }`, util.MepAppJwtName))
 	err := i.SendPostRequest(consumerUrl, jsonConsumerByte)
@@ -101,28 +101,59 @@ func (i *apiGwInitializer) SetApiGwConsumer(apiGwUrl string) error {
 		return err
 	}
 
+	// the key is the issuer of the tokens, checked at startup as no token can be issued or verified without it
 	mepAuthKey := util.GetAppConfig("mepauth_key")
 	if len(mepAuthKey) == 0 {
 		msg := "MEP auth key configuration is not set"
 		log.Error(msg)
 		return errors.New(msg)
 	}
-	// add jwt plugin to mepauth consumer
-	apiGwJwtUrl := consumerUrl + "/" + util.MepAppJwtName + "/jwt"
-	jwtPublicKey, err := util.GetPublicKey()
+	return nil
+}
+
+// PublishJwtKey registers a public key of the jwt key set as a jwt credential of the mepauth consumer
+func (i *apiGwInitializer) PublishJwtKey(kid string, alg string, publicKey string) error {
+	apiGwUrl, err := util.GetAPIGwURL()
+	if err != nil {
+		log.Error("Failed to get API gateway URL")
+		return err
+	}
+	credential, err := json.Marshal(&models.JwtCredentialInfo{
+		Algorithm:    alg,
+		Key:          kid,
+		RsaPublicKey: publicKey,
+	})
+	if err != nil {
+		return err
+	}
+	err = i.SendPutRequest(getJwtCredentialUrl(apiGwUrl, kid), credential)
+	if err != nil {
+		log.Error("Failed while adding consumer jwt credential.")
+		return err
+	}
+	return nil
+}
+
+// UnpublishJwtKey removes the jwt credential of a retired key from the mepauth consumer
+func (i *apiGwInitializer) UnpublishJwtKey(kid string) error {
+	apiGwUrl, err := util.GetAPIGwURL()
 	if err != nil {
+		log.Error("Failed to get API gateway URL")
 		return err
 	}
-	apiGwJwtByte := []byte(fmt.Sprintf(`{ "algorithm": "RS512", "key": "%s", "rsa_public_key": "%s" }`,
-		mepAuthKey, string(jwtPublicKey)))
-	err = i.SendPostRequest(apiGwJwtUrl, apiGwJwtByte)
+	err = i.SendDeleteRequest(getJwtCredentialUrl(apiGwUrl, kid))
 	if err != nil {
-		log.Error("Failed while adding consumer token.")
+		log.Error("Failed while deleting consumer jwt credential.")
 		return err
 	}
 	return nil
 }
 
+// The credential is addressed by its key, the kid of the jwt key
+func getJwtCredentialUrl(apiGwUrl string, kid string) string {
+	return apiGwUrl + "/consumers/" + util.MepAppJwtName + "/jwt/" + kid
+}
+
 func (i *apiGwInitializer) SetupApiGwMepServer(apiGwUrl string) error {
 	// add mep server service and route to apiGw.
 	// since mep is also in the same pos, same ip address will work
@@ -146,14 +177,21 @@ func (i *apiGwInitializer) SetupApiGwMepServer(apiGwUrl string) error {
 	}
 	// enable mep server jwt plugin
 	mepServerPluginUrl := apiGwUrl + servicesPath + "/" + util.MepserverName + util.PluginPath
-	jwtConfig := fmt.Sprintf(`{ "name": "%s", "config": { "claims_to_verify": ["exp"] } }`, util.JwtPlugin)
+	jwtConfig := fmt.Sprintf(`{ "name": "%s", "config": { "claims_to_verify": ["exp"], "key_claim_name": "kid" } }`,
+		util.JwtPlugin)
 	err = i.SendPostRequest(mepServerPluginUrl, []byte(jwtConfig))
 	if err != nil {
 		log.Error("Enable mep server jwt plugin failed")
 		return err
 	}
-	// enable mep server appid-header plugin
-	err = i.SendPostRequest(mepServerPluginUrl, []byte(fmt.Sprintf(`{ "name": "%s" }`, util.AppidPlugin)))
+	// enable mep server appid-header plugin, it rejects the tokens on the mep auth deny list
+	mepAuthURL, err := i.getMepAuthURL()
+	if err != nil {
+		return err
+	}
+	appidConfig := fmt.Sprintf(`{ "name": "%s", "config": { "denylist_url": "%s" } }`,
+		util.AppidPlugin, mepAuthURL+routers.TokenDenyListPath)
+	err = i.SendPostRequest(mepServerPluginUrl, []byte(appidConfig))
 	if err != nil {
 		log.Error("Enable mep server appid-header plugin failed.")
 		return err
@@ -183,8 +221,8 @@ func (i *apiGwInitializer) SetupApiGwMepServer(apiGwUrl string) error {
 	return nil
 }
 
-func (i *apiGwInitializer) SetupApiGwMepAuth(apiGwURL string, trustedNetworks *[]byte) error {
-	// add mep auth service and route to apiGw
+// Get the URL the api gateway reaches mep auth at
+func (i *apiGwInitializer) getMepAuthURL() (string, error) {
 	var httpsPort string
 	if strings.EqualFold(util.GetAppConfig("EnableHTTPS"), "true") {
 		httpsPort = util.GetAppConfig("HttpsPort")
@@ -194,17 +232,26 @@ func (i *apiGwInitializer) SetupApiGwMepAuth(apiGwURL string, trustedNetworks *[
 	if len(httpsPort) == 0 {
 		msg := "HTTPS port configuration is not set"
 		log.Error(msg)
-		return errors.New(msg)
+		return "", errors.New(msg)
 	}
 	// Since apiGw is also deployed in same pod, it can reach by the ip address
 	mepAuthHost := util.GetAppConfig("HTTPSAddr")
 	if len(mepAuthHost) == 0 {
 		msg := "MEP auth host configuration is not set"
 		log.Error(msg)
-		return errors.New(msg)
+		return "", errors.New(msg)
 	}
-	mepAuthURL := httpProtocol + "://" + mepAuthHost + ":" + httpsPort
-	err := i.AddServiceRoute(util.MepauthName, []string{routers.AuthTokenPath, routers.AppManagePath}, mepAuthURL, false)
+	return httpProtocol + "://" + mepAuthHost + ":" + httpsPort, nil
+}
+
+func (i *apiGwInitializer) SetupApiGwMepAuth(apiGwURL string, trustedNetworks *[]byte) error {
+	// add mep auth service and route to apiGw
+	mepAuthURL, err := i.getMepAuthURL()
+	if err != nil {
+		return err
+	}
+	err = i.AddServiceRoute(util.MepauthName, []string{routers.AuthTokenPath, routers.AppManagePath, routers.JwksPath},
+		mepAuthURL, false)
 	if err != nil {
 		log.Error("Addition of mep server route to apiGw failed.")
 		return err
@@ -350,6 +397,28 @@ func (i *apiGwInitializer) SendPutRequest(consumerURL string, jsonStr []byte) er
 	return nil
 }
 
+// Send delete request, a missing resource is already deleted
+func (i *apiGwInitializer) SendDeleteRequest(consumerURL string) error {
+	req := httplib.Delete(consumerURL)
+	req.SetTLSClientConfig(i.tlsConfig)
+	resp, err := req.Response()
+	if err != nil {
+		log.Error("Request sending is having error")
+		return err
+	}
+	defer resp.Body.Close()
+	_, err2 := ioutil.ReadAll(resp.Body)
+	if err2 != nil {
+		log.Error("Request's response not received")
+	}
+
+	if !(resp.StatusCode >= 200 && resp.StatusCode <= 299) && resp.StatusCode != http.StatusNotFound {
+		log.Error("Request sending returned failure response with status code " + strconv.Itoa(resp.StatusCode))
+		return errors.New("request sending returned failure response, status is " + strconv.Itoa(resp.StatusCode))
+	}
+	return nil
+}
+
 func (i *apiGwInitializer) getHttpLogPluginData() (data []byte, err error) {
 	c := &models.ConfigInfo{
 		HTTPEndpoint: httpProtocol + "://mep-mm5:80/mep/service_govern/v1/kong_log",
@@ -152,9 +152,6 @@ func TestSetApiGwConsumer(t *testing.T) {
 			patches := ApplyMethod(reflect.TypeOf(initializer), "SendPostRequest", func(*apiGwInitializer, string, []byte) error {
 				return nil
 			})
-			patches.ApplyFunc(util.GetPublicKey, func() ([]byte, error) {
-				return []byte("public_key"), nil
-			})
 			defer patches.Reset()
 			i := &apiGwInitializer{}
 			err := i.SetApiGwConsumer("https://127.0.0.1:8444")
@@ -165,9 +162,6 @@ func TestSetApiGwConsumer(t *testing.T) {
 			patches := ApplyMethod(reflect.TypeOf(initializer), "SendPostRequest", func(*apiGwInitializer, string, []byte) error {
 				return errors.New("send post request error")
 			})
-			patches.ApplyFunc(util.GetPublicKey, func() ([]byte, error) {
-				return []byte("public_key"), nil
-			})
 			defer patches.Reset()
 			i := &apiGwInitializer{}
 			err := i.SetApiGwConsumer("https://127.0.0.1:8444")
@@ -178,9 +172,6 @@ func TestSetApiGwConsumer(t *testing.T) {
 			patches := ApplyMethod(reflect.TypeOf(initializer), "SendPostRequest", func(*apiGwInitializer, string, []byte) error {
 				return nil
 			})
-			patches.ApplyFunc(util.GetPublicKey, func() ([]byte, error) {
-				return []byte("public_key"), nil
-			})
 			beego.AppConfig.Set("mepauth_key", "")
 			defer patches.Reset()
 			defer beego.AppConfig.Set("mepauth_key", "mepauth")
@@ -191,6 +182,56 @@ func TestSetApiGwConsumer(t *testing.T) {
 	})
 }
 
+var jwtCredentialUrl string
+
+func TestPublishJwtKey(t *testing.T) {
+	Convey("publish jwt key", t, func() {
+		Convey("for success", func() {
+			patches := ApplyFunc(util.GetAPIGwURL, func() (string, error) {
+				return "https://127.0.0.1:8444", nil
+			})
+			var initializer *apiGwInitializer
+			patches.ApplyMethod(reflect.TypeOf(initializer), "SendPutRequest", func(_ *apiGwInitializer, url string,
+				_ []byte) error {
+				jwtCredentialUrl = url
+				return nil
+			})
+			patches.ApplyMethod(reflect.TypeOf(initializer), "SendDeleteRequest", func(_ *apiGwInitializer,
+				url string) error {
+				jwtCredentialUrl = url
+				return nil
+			})
+			defer patches.Reset()
+			i := &apiGwInitializer{}
+			err := i.PublishJwtKey("kid1", util.ES256, "public_key")
+			So(err, ShouldBeNil)
+			So(jwtCredentialUrl, ShouldEqual, "https://127.0.0.1:8444/consumers/"+util.MepAppJwtName+"/jwt/kid1")
+			jwtCredentialUrl = ""
+			err = i.UnpublishJwtKey("kid1")
+			So(err, ShouldBeNil)
+			So(jwtCredentialUrl, ShouldEqual, "https://127.0.0.1:8444/consumers/"+util.MepAppJwtName+"/jwt/kid1")
+		})
+		Convey("for fail - send request error", func() {
+			patches := ApplyFunc(util.GetAPIGwURL, func() (string, error) {
+				return "https://127.0.0.1:8444", nil
+			})
+			var initializer *apiGwInitializer
+			patches.ApplyMethod(reflect.TypeOf(initializer), "SendPutRequest", func(*apiGwInitializer, string,
+				[]byte) error {
+				return errors.New("send put request error")
+			})
+			patches.ApplyMethod(reflect.TypeOf(initializer), "SendDeleteRequest", func(*apiGwInitializer,
+				string) error {
+				return errors.New("send delete request error")
+			})
+			defer patches.Reset()
+			i := &apiGwInitializer{}
+			So(i.PublishJwtKey("kid1", util.ES256, "public_key"), ShouldNotBeNil)
+			So(i.UnpublishJwtKey("kid1"), ShouldNotBeNil)
+		})
+	})
+}
+
 func TestSetupApiGwMepServer(t *testing.T) {
 	err := beego.LoadAppConfig("ini", "../conf/app.conf")
 	if err != nil {
@@ -205,7 +246,9 @@ func TestSetupApiGwMepServer(t *testing.T) {
 			patches.ApplyMethod(reflect.TypeOf(initializer), "AddServiceRoute", func(*apiGwInitializer, string, []string, string, bool) error {
 				return nil
 			})
+			beego.AppConfig.Set("HTTPSAddr", "127.0.0.1")
 			defer patches.Reset()
+			defer beego.AppConfig.Set("HTTPSAddr", "")
 			i := &apiGwInitializer{}
 			err := i.SetupApiGwMepServer("https://127.0.0.1:8444")
 			So(err, ShouldBeNil)
@@ -321,7 +364,8 @@ func TestAddServiceRoute(t *testing.T) {
 			patches.ApplyMethod(reflect.TypeOf(initializer), "SendPostRequest", func(*apiGwInitializer, string, []byte) error {
 				return errors.New("send post request error")
 			})
-			patches.ApplyMethod(reflect.TypeOf(initializer), "SendPutRequest", func(*apiGwInitializer, string, []byte) error {
+			patches.ApplyMethod(reflect.TypeOf(initializer), "SendPutRequest", func(*apiGwInitializer, string,
+				[]byte) error {
 				return errors.New("send put request error")
 			})
 			defer patches.Reset()
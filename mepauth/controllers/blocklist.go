@@ -18,116 +18,303 @@
 package controllers
 
 import (
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego/orm"
 	log "github.com/sirupsen/logrus"
+	"mepauth/adapter"
 	"mepauth/models"
 	"mepauth/util"
-	"strconv"
-	"time"
 )
 
-var authInfoList map[string]*models.AkSessionInfo
+const (
+	akStateValidating = "ValidationInProgress"
+	akStateBlocked    = "UnderBlockList"
+	akBlockRecord     = "ak_block_record"
+	blockListStoreDb  = "db"
+)
+
+var errAkNotBlocked = errors.New("ak is not under block list")
+
+// AkBlockList tracks invalid signatures per AK and locks AKs that fail too often
+type AkBlockList interface {
+	// IsBlocked reports whether the AK is currently locked
+	IsBlocked(ak string) bool
+
+	// RecordFailure counts an invalid signature for the AK and locks it once the limit is reached
+	RecordFailure(ak string)
+
+	// Clear forgets the failures of an AK that is not locked
+	Clear(ak string)
 
-// InitAuthInfoList initializes auth info list
+	// List returns the AKs that are currently locked
+	List() ([]*models.AkBlockRecord, error)
+
+	// Unlock removes the lock of an AK
+	Unlock(ak string) error
+}
+
+var akBlockList AkBlockList = NewMemoryBlockList()
+
+// InitAuthInfoList initializes auth info list, persisted in the database when blocklist_store is db
 func InitAuthInfoList() {
-	authInfoList = make(map[string]*models.AkSessionInfo)
+	if util.GetAppConfig("blocklist_store") == blockListStoreDb {
+		akBlockList = NewDbBlockList()
+		return
+	}
+	akBlockList = NewMemoryBlockList()
 }
 
 // Verify that Ak is in block list or not
 func isAkInBlockList(ak string) bool {
-	akInfo, ok := authInfoList[ak]
-	if ok && akInfo.State == "UnderBlockList" {
-		return true
-	}
-	return false
+	return akBlockList.IsBlocked(ak)
 }
 
-// Verify that Ak is in validation list or not
-func isAkInValidationList(ak string) bool {
-	akInfo, ok := authInfoList[ak]
-	if ok && akInfo.State == "ValidationInProgress" {
-		return true
+// Process Ak for block listing
+func processAkForBlockListing(ak string) {
+	akBlockList.RecordFailure(ak)
+}
+
+// Clear Ak from block listing
+func clearAkFromBlockListing(ak string) {
+	akBlockList.Clear(ak)
+}
+
+// Applies one invalid signature to the record, returns a fresh record when there is none or it expired
+func recordFailure(record *models.AkBlockRecord, ak string, now time.Time) *models.AkBlockRecord {
+	if record == nil || !now.Before(record.ExpiresAt) {
+		return &models.AkBlockRecord{
+			Ak:              ak,
+			State:           akStateValidating,
+			ValidateCounter: 1,
+			ExpiresAt:       now.Add(time.Duration(util.ValidateListClearTimer) * time.Second),
+		}
+	}
+	if record.State != akStateValidating {
+		return record
 	}
-	return false
+	record.ValidateCounter++
+	// If received invalid Ak for 3 times move to blockList
+	if record.ValidateCounter >= util.ValidationCounter {
+		log.Info("Received invalid signature " + strconv.FormatInt(record.ValidateCounter, util.BaseVal) +
+			" times, Ak " + ak + " is now under blockList")
+		record.State = akStateBlocked
+		record.ExpiresAt = now.Add(time.Duration(util.BlockListClearTimer) * time.Second)
+	}
+	return record
+}
+
+func isRecordBlocked(record *models.AkBlockRecord, now time.Time) bool {
+	return record != nil && record.State == akStateBlocked && now.Before(record.ExpiresAt)
+}
+
+func sortRecords(records []*models.AkBlockRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Ak < records[j].Ak
+	})
+}
+
+// MemoryBlockList keeps the AK block list in process memory
+type MemoryBlockList struct {
+	mutex   sync.Mutex
+	records map[string]*models.AkBlockRecord
 }
 
-// Start Ak validation
-func startValidatingAk(ak string) {
-	akInfo := new(models.AkSessionInfo)
-	akInfo.Ak = ak
-	akInfo.State = "ValidationInProgress"
-	akInfo.ValidateCounter++
-	akInfo.ClearTimer = time.NewTimer(time.Duration(util.ValidateListClearTimer) * time.Second)
-	go func() {
-		_, ok := <-akInfo.ClearTimer.C
-		if !ok {
-			log.Error("Timer C channel closed")
+// NewMemoryBlockList creates an in-memory AK block list
+func NewMemoryBlockList() *MemoryBlockList {
+	return &MemoryBlockList{records: make(map[string]*models.AkBlockRecord)}
+}
+
+// Returns the live record of the AK, expired records are dropped. Caller holds the mutex.
+func (m *MemoryBlockList) get(ak string, now time.Time) *models.AkBlockRecord {
+	record, ok := m.records[ak]
+	if !ok {
+		return nil
+	}
+	if !now.Before(record.ExpiresAt) {
+		delete(m.records, ak)
+		if record.State == akStateBlocked {
+			log.Info("BlockList timer expired. Ak " + ak + " is moving out of blockList")
 		}
-		delete(authInfoList, ak)
-	}()
-	authInfoList[ak] = akInfo
+		return nil
+	}
+	return record
 }
 
-// Stop Ak validation
-func stopValidatingAk(ak string) {
-	defer func() {
-		if err1 := recover(); err1 != nil {
-			log.Error("panic handled:", err1)
+// IsBlocked reports whether the AK is currently locked
+func (m *MemoryBlockList) IsBlocked(ak string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	return isRecordBlocked(m.get(ak, now), now)
+}
+
+// RecordFailure counts an invalid signature for the AK
+func (m *MemoryBlockList) RecordFailure(ak string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	m.records[ak] = recordFailure(m.get(ak, now), ak, now)
+}
+
+// Clear forgets the failures of an AK that is not locked
+func (m *MemoryBlockList) Clear(ak string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	record := m.get(ak, time.Now())
+	if record != nil && record.State == akStateValidating {
+		delete(m.records, ak)
+	}
+}
+
+// List returns the AKs that are currently locked
+func (m *MemoryBlockList) List() ([]*models.AkBlockRecord, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	records := make([]*models.AkBlockRecord, 0, len(m.records))
+	for ak := range m.records {
+		record := m.get(ak, now)
+		if isRecordBlocked(record, now) {
+			copied := *record
+			records = append(records, &copied)
 		}
-	}()
-
-	akInfo, ok := authInfoList[ak]
-	if ok {
-		akInfo.State = "None"
-		akInfo.ValidateCounter = 0
-		ok := akInfo.ClearTimer.Stop()
-		if ok {
-			log.Info("Validating Timer stopped")
+	}
+	sortRecords(records)
+	return records, nil
+}
+
+// Unlock removes the lock of an AK
+func (m *MemoryBlockList) Unlock(ak string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := time.Now()
+	if !isRecordBlocked(m.get(ak, now), now) {
+		return errAkNotBlocked
+	}
+	delete(m.records, ak)
+	log.Info("Ak " + ak + " is unlocked and moved out of blockList")
+	return nil
+}
+
+// DbBlockList keeps the AK block list in the database so that it survives restarts and is shared by all
+// mepauth instances. Records are changed in row locked transactions, so concurrent instances do not lose updates.
+type DbBlockList struct{}
+
+// NewDbBlockList creates an AK block list persisted through adapter.Db
+func NewDbBlockList() *DbBlockList {
+	return &DbBlockList{}
+}
+
+// Reads the live record of the AK, nil when there is none or it expired
+func (d *DbBlockList) get(ak string, now time.Time) (*models.AkBlockRecord, error) {
+	record := &models.AkBlockRecord{Ak: ak}
+	err := adapter.Db.ReadData(record, "ak")
+	if errors.Is(err, orm.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil && err.Error() != util.PgOkMsg {
+		return nil, err
+	}
+	if !now.Before(record.ExpiresAt) {
+		return nil, nil
+	}
+	return record, nil
+}
+
+// IsBlocked reports whether the AK is currently locked, the AK is taken as locked when the record can not be read
+func (d *DbBlockList) IsBlocked(ak string) bool {
+	now := time.Now()
+	record, err := d.get(ak, now)
+	if err != nil {
+		log.Error("Failed to read block list record of ak " + ak + ", it is taken as blocked: " + err.Error())
+		return true
+	}
+	return isRecordBlocked(record, now)
+}
+
+// RecordFailure counts an invalid signature for the AK
+func (d *DbBlockList) RecordFailure(ak string) {
+	now := time.Now()
+	record := &models.AkBlockRecord{Ak: ak}
+	err := adapter.Db.UpdateDataLocked(record, func(found bool) bool {
+		current := record
+		if !found || !now.Before(record.ExpiresAt) {
+			current = nil
 		}
+		*record = *recordFailure(current, ak, now)
+		return true
+	}, "ak")
+	if err != nil && err.Error() != util.PgOkMsg {
+		log.Error("Failed to save block list record of ak " + ak + ": " + err.Error())
 	}
 }
 
-// Start AK block listing
-func startBlockListingAk(ak string) {
-	akInfo, ok := authInfoList[ak]
-	if ok {
-		akInfo.State = "UnderBlockList"
-		akInfo.ClearTimer = time.NewTimer(time.Duration(util.BlockListClearTimer) * time.Second)
-		go func() {
-			_, ok := <-akInfo.ClearTimer.C
-			if !ok {
-				log.Error("Timer C channel closed")
-			}
-			// Timer expired, so ak is safe now
-			delete(authInfoList, ak)
-			log.Info("BlockList timer expired. Ak " + ak + " is moving out of blockList")
-		}()
+// Deletes the record of the AK if it is still in the state, returns whether it was deleted
+func (d *DbBlockList) deleteInState(ak string, state string) (bool, error) {
+	num, err := adapter.Db.DeleteDataCount(&models.AkBlockRecord{Ak: ak, State: state}, "ak", "state")
+	if errors.Is(err, orm.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil && err.Error() != util.PgOkMsg {
+		return false, err
 	}
+	return num != 0, nil
 }
 
-// Process Ak for block listing
-func processAkForBlockListing(ak string) {
-	if isAkInValidationList(ak) {
-		akInfo, ok := authInfoList[ak]
-		if ok {
-			akInfo.ValidateCounter++
-			// If received invalid Ak for 3 times move to blockList
-			if akInfo.ValidateCounter >= util.ValidationCounter {
-				log.Info("Received invalid signature " + strconv.FormatInt(akInfo.ValidateCounter, util.BaseVal) +
-					" times, Ak " + ak + " is now under blockList")
-				stopValidatingAk(ak)
-				startBlockListingAk(ak)
-				return
-			}
+// Clear forgets the failures of an AK that is not locked
+func (d *DbBlockList) Clear(ak string) {
+	record, err := d.get(ak, time.Now())
+	if err != nil || record == nil || record.State != akStateValidating {
+		return
+	}
+	// the AK may have been locked meanwhile by another instance, the delete keeps the lock
+	_, err = d.deleteInState(ak, akStateValidating)
+	if err != nil {
+		log.Error("Failed to delete block list record of ak " + ak + ": " + err.Error())
+	}
+}
+
+// List returns the AKs that are currently locked
+func (d *DbBlockList) List() ([]*models.AkBlockRecord, error) {
+	var all []*models.AkBlockRecord
+	_, err := adapter.Db.ReadAllData(akBlockRecord, &all)
+	if err != nil && !errors.Is(err, orm.ErrNoRows) {
+		return nil, err
+	}
+	now := time.Now()
+	records := make([]*models.AkBlockRecord, 0, len(all))
+	for _, record := range all {
+		if isRecordBlocked(record, now) {
+			records = append(records, record)
 		}
-	} else {
-		startValidatingAk(ak)
 	}
+	sortRecords(records)
+	return records, nil
 }
 
-// Clear Ak from block listing
-func clearAkFromBlockListing(ak string) {
-	if isAkInValidationList(ak) {
-		stopValidatingAk(ak)
-		delete(authInfoList, ak)
+// Unlock removes the lock of an AK
+func (d *DbBlockList) Unlock(ak string) error {
+	now := time.Now()
+	record, err := d.get(ak, now)
+	if err != nil {
+		return err
+	}
+	if !isRecordBlocked(record, now) {
+		return errAkNotBlocked
+	}
+	deleted, err := d.deleteInState(ak, akStateBlocked)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		// unlocked meanwhile by another request
+		return errAkNotBlocked
 	}
+	log.Info("Ak " + ak + " is unlocked and moved out of blockList")
+	return nil
 }
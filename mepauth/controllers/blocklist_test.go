@@ -13,19 +13,50 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
-
 package controllers
 
 import (
+	"errors"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/agiledragon/gomonkey"
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
 	"github.com/smartystreets/goconvey/convey"
+
+	"mepauth/adapter"
+	"mepauth/models"
+	"mepauth/util"
 )
 
+const blockTestAk = "QVUJMSUMgS0VZLS0tLS0"
+
+func blockAk(ak string) {
+	for i := int64(0); i < util.ValidationCounter; i++ {
+		processAkForBlockListing(ak)
+	}
+}
+
 func TestInitAuthInfoList(t *testing.T) {
 	convey.Convey("Init AuthInfo List", t, func() {
 		convey.Convey("for success", func() {
+			// app.conf loaded by other tests selects the db store
+			blockListStore := beego.AppConfig.String("blocklist_store")
+			_ = beego.AppConfig.Set("blocklist_store", "")
+			defer beego.AppConfig.Set("blocklist_store", blockListStore)
+			InitAuthInfoList()
+			_, ok := akBlockList.(*MemoryBlockList)
+			convey.So(ok, convey.ShouldBeTrue)
+		})
+		convey.Convey("for db store", func() {
+			convey.So(beego.AppConfig.Set("blocklist_store", blockListStoreDb), convey.ShouldBeNil)
 			InitAuthInfoList()
+			_ = beego.AppConfig.Set("blocklist_store", "")
+			defer InitAuthInfoList()
+			_, ok := akBlockList.(*DbBlockList)
+			convey.So(ok, convey.ShouldBeTrue)
 		})
 	})
 }
@@ -34,15 +65,14 @@ func TestIsAkInBlockList(t *testing.T) {
 	convey.Convey("isAkInBlockList", t, func() {
 		convey.Convey("for success", func() {
 			InitAuthInfoList()
-			startValidatingAk("ak")
-			startBlockListingAk("ak")
+			blockAk("ak")
 			res := isAkInBlockList("ak")
 			convey.So(res, convey.ShouldBeTrue)
 		})
 
 		convey.Convey("for fail state", func() {
 			InitAuthInfoList()
-			startValidatingAk("ak")
+			processAkForBlockListing("ak")
 			res := isAkInBlockList("ak")
 			convey.So(res, convey.ShouldBeFalse)
 		})
@@ -54,28 +84,30 @@ func TestIsAkInBlockList(t *testing.T) {
 	})
 }
 
-func TestIsAkInValidationList(t *testing.T) {
-	convey.Convey("isAkInValidationList", t, func() {
-		convey.Convey("for success", func() {
-			InitAuthInfoList()
-			startValidatingAk("ak")
-			res := isAkInValidationList("ak")
-			convey.So(res, convey.ShouldBeTrue)
+func TestRecordFailure(t *testing.T) {
+	convey.Convey("recordFailure", t, func() {
+		now := time.Now()
+		convey.Convey("for new ak", func() {
+			record := recordFailure(nil, "ak", now)
+			convey.So(record.State, convey.ShouldEqual, akStateValidating)
+			convey.So(record.ValidateCounter, convey.ShouldEqual, 1)
+			convey.So(record.ExpiresAt, convey.ShouldEqual,
+				now.Add(time.Duration(util.ValidateListClearTimer)*time.Second))
 		})
-		convey.Convey("for fail", func() {
-			InitAuthInfoList()
-			res := isAkInValidationList("ak")
-			convey.So(res, convey.ShouldBeFalse)
+		convey.Convey("for block listing", func() {
+			record := recordFailure(nil, "ak", now)
+			for i := int64(1); i < util.ValidationCounter; i++ {
+				record = recordFailure(record, "ak", now)
+			}
+			convey.So(record.State, convey.ShouldEqual, akStateBlocked)
+			convey.So(record.ExpiresAt, convey.ShouldEqual,
+				now.Add(time.Duration(util.BlockListClearTimer)*time.Second))
+			convey.So(recordFailure(record, "ak", now).ValidateCounter, convey.ShouldEqual, util.ValidationCounter)
 		})
-	})
-}
-
-func TestStopValidatingAk(t *testing.T) {
-	convey.Convey("stopValidatingAk", t, func() {
-		convey.Convey("for success", func() {
-			InitAuthInfoList()
-			startValidatingAk("ak")
-			stopValidatingAk("ak")
+		convey.Convey("for expired record", func() {
+			record := recordFailure(nil, "ak", now)
+			record = recordFailure(record, "ak", record.ExpiresAt)
+			convey.So(record.ValidateCounter, convey.ShouldEqual, 1)
 		})
 	})
 }
@@ -84,8 +116,18 @@ func TestClearAkFromBlockListing(t *testing.T) {
 	convey.Convey("clearAkFromBlockListing", t, func() {
 		convey.Convey("for success", func() {
 			InitAuthInfoList()
-			startValidatingAk("ak")
+			processAkForBlockListing("ak")
+			processAkForBlockListing("ak")
+			clearAkFromBlockListing("ak")
+			processAkForBlockListing("ak")
+			processAkForBlockListing("ak")
+			convey.So(isAkInBlockList("ak"), convey.ShouldBeFalse)
+		})
+		convey.Convey("for blocked ak", func() {
+			InitAuthInfoList()
+			blockAk("ak")
 			clearAkFromBlockListing("ak")
+			convey.So(isAkInBlockList("ak"), convey.ShouldBeTrue)
 		})
 	})
 }
@@ -93,11 +135,164 @@ func TestClearAkFromBlockListing(t *testing.T) {
 func TestProcessAkForBlockListing(t *testing.T) {
 	convey.Convey("processAkForBlockListing", t, func() {
 		convey.Convey("for success", func() {
-			startValidatingAk("ak")
+			InitAuthInfoList()
+			blockAk("ak")
 			processAkForBlockListing("ak")
+			convey.So(isAkInBlockList("ak"), convey.ShouldBeTrue)
 		})
-		convey.Convey("for fail", func() {
+		convey.Convey("for expired block", func() {
+			InitAuthInfoList()
+			blockAk("ak")
+			record := akBlockList.(*MemoryBlockList).records["ak"]
+			record.ExpiresAt = time.Now()
+			convey.So(isAkInBlockList("ak"), convey.ShouldBeFalse)
 			processAkForBlockListing("ak")
+			convey.So(isAkInBlockList("ak"), convey.ShouldBeFalse)
+		})
+	})
+}
+
+func TestMemoryBlockListUnlock(t *testing.T) {
+	convey.Convey("memory block list unlock", t, func() {
+		blockList := NewMemoryBlockList()
+		blockList.RecordFailure("ak2")
+		for i := int64(0); i < util.ValidationCounter; i++ {
+			blockList.RecordFailure("ak")
+			blockList.RecordFailure("ak1")
+		}
+		records, err := blockList.List()
+		convey.So(err, convey.ShouldBeNil)
+		convey.So(len(records), convey.ShouldEqual, 2)
+		convey.So(records[0].Ak, convey.ShouldEqual, "ak")
+		convey.So(records[1].Ak, convey.ShouldEqual, "ak1")
+
+		convey.So(blockList.Unlock("ak"), convey.ShouldBeNil)
+		convey.So(blockList.IsBlocked("ak"), convey.ShouldBeFalse)
+		convey.So(blockList.Unlock("ak"), convey.ShouldEqual, errAkNotBlocked)
+		convey.So(blockList.Unlock("ak2"), convey.ShouldEqual, errAkNotBlocked)
+		records, _ = blockList.List()
+		convey.So(len(records), convey.ShouldEqual, 1)
+	})
+}
+
+// Block list records kept by the patched database methods
+var blockRecordStore map[string]models.AkBlockRecord
+
+func patchBlockRecordStore() *gomonkey.Patches {
+	var pgdb *adapter.PgDb
+	patches := gomonkey.ApplyMethod(reflect.TypeOf(pgdb), "ReadData",
+		func(_ *adapter.PgDb, data interface{}, _ ...string) error {
+			record := data.(*models.AkBlockRecord)
+			stored, ok := blockRecordStore[record.Ak]
+			if !ok {
+				return orm.ErrNoRows
+			}
+			*record = stored
+			return nil
+		})
+	patches.ApplyMethod(reflect.TypeOf(pgdb), "UpdateDataLocked",
+		func(_ *adapter.PgDb, data interface{}, update func(bool) bool, _ ...string) error {
+			record := data.(*models.AkBlockRecord)
+			stored, ok := blockRecordStore[record.Ak]
+			if ok {
+				*record = stored
+			}
+			if update(ok) {
+				blockRecordStore[record.Ak] = *record
+			}
+			return nil
+		})
+	patches.ApplyMethod(reflect.TypeOf(pgdb), "DeleteDataCount",
+		func(_ *adapter.PgDb, data interface{}, _ ...string) (int64, error) {
+			record := data.(*models.AkBlockRecord)
+			stored, ok := blockRecordStore[record.Ak]
+			if !ok || stored.State != record.State {
+				return 0, nil
+			}
+			delete(blockRecordStore, record.Ak)
+			return 1, nil
+		})
+	patches.ApplyMethod(reflect.TypeOf(pgdb), "ReadAllData",
+		func(_ *adapter.PgDb, _ string, container interface{}) (int64, error) {
+			records := container.(*[]*models.AkBlockRecord)
+			for _, stored := range blockRecordStore {
+				record := stored
+				*records = append(*records, &record)
+			}
+			return int64(len(blockRecordStore)), nil
+		})
+	return patches
+}
+
+func TestDbBlockList(t *testing.T) {
+	adapter.Db = &adapter.PgDb{}
+
+	convey.Convey("db block list", t, func() {
+		blockRecordStore = make(map[string]models.AkBlockRecord)
+		store := blockRecordStore
+		patches := patchBlockRecordStore()
+		defer patches.Reset()
+
+		convey.Convey("for block listing", func() {
+			blockList := NewDbBlockList()
+			blockList.RecordFailure(blockTestAk)
+			convey.So(store[blockTestAk].ValidateCounter, convey.ShouldEqual, 1)
+			convey.So(blockList.IsBlocked(blockTestAk), convey.ShouldBeFalse)
+			for i := int64(1); i < util.ValidationCounter; i++ {
+				blockList.RecordFailure(blockTestAk)
+			}
+			// Another instance sees the lock
+			convey.So(NewDbBlockList().IsBlocked(blockTestAk), convey.ShouldBeTrue)
+			blockList.Clear(blockTestAk)
+			convey.So(blockList.IsBlocked(blockTestAk), convey.ShouldBeTrue)
+
+			records, err := blockList.List()
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(len(records), convey.ShouldEqual, 1)
+			convey.So(records[0].Ak, convey.ShouldEqual, blockTestAk)
+
+			convey.So(blockList.Unlock(blockTestAk), convey.ShouldBeNil)
+			convey.So(blockList.IsBlocked(blockTestAk), convey.ShouldBeFalse)
+			convey.So(blockList.Unlock(blockTestAk), convey.ShouldEqual, errAkNotBlocked)
+		})
+		convey.Convey("for clear", func() {
+			blockList := NewDbBlockList()
+			blockList.RecordFailure(blockTestAk)
+			blockList.Clear(blockTestAk)
+			_, ok := store[blockTestAk]
+			convey.So(ok, convey.ShouldBeFalse)
+		})
+		convey.Convey("for expired lock", func() {
+			store[blockTestAk] = models.AkBlockRecord{Ak: blockTestAk, State: akStateBlocked,
+				ValidateCounter: util.ValidationCounter, ExpiresAt: time.Now().Add(-time.Second)}
+			blockList := NewDbBlockList()
+			convey.So(blockList.IsBlocked(blockTestAk), convey.ShouldBeFalse)
+			records, _ := blockList.List()
+			convey.So(len(records), convey.ShouldEqual, 0)
+			blockList.RecordFailure(blockTestAk)
+			convey.So(store[blockTestAk].State, convey.ShouldEqual, akStateValidating)
+			convey.So(store[blockTestAk].ValidateCounter, convey.ShouldEqual, 1)
+		})
+		convey.Convey("for delete in state", func() {
+			blockList := NewDbBlockList()
+			blockList.RecordFailure(blockTestAk)
+			// a record locked by another instance meanwhile is not deleted by the clear
+			deleted, err := blockList.deleteInState(blockTestAk, akStateBlocked)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(deleted, convey.ShouldBeFalse)
+			convey.So(store[blockTestAk].ValidateCounter, convey.ShouldEqual, 1)
+			deleted, err = blockList.deleteInState(blockTestAk, akStateValidating)
+			convey.So(err, convey.ShouldBeNil)
+			convey.So(deleted, convey.ShouldBeTrue)
+		})
+		convey.Convey("for read failure", func() {
+			var pgdb *adapter.PgDb
+			readPatches := gomonkey.ApplyMethod(reflect.TypeOf(pgdb), "ReadData",
+				func(*adapter.PgDb, interface{}, ...string) error {
+					return errors.New("connection refused")
+				})
+			defer readPatches.Reset()
+			convey.So(NewDbBlockList().IsBlocked(blockTestAk), convey.ShouldBeTrue)
 		})
 	})
 }
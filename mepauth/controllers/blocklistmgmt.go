@@ -0,0 +1,90 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package controllers implements mep auth controller
+package controllers
+
+import (
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	"mepauth/util"
+)
+
+// BlockListController AK block list management controller
+type BlockListController struct {
+	BaseController
+}
+
+// @Title Lists blocked AKs
+// @Description list the AKs locked after repeated invalid signatures
+// @Success 200 ok
+// @Failure 400 bad request
+// @router /appMng/v1/blocklist [get]
+func (c *BlockListController) Get() {
+	log.Info("Get block list request received.")
+	clientIp := c.Ctx.Request.Header.Get(xRealIp)
+	err := c.validateSrcAddress(clientIp)
+	if err != nil {
+		c.handleLoggingForError(clientIp, http.StatusBadRequest, util.ClientIpaddressInvalid)
+		return
+	}
+	c.logReceivedMsg(clientIp)
+
+	records, err := akBlockList.List()
+	if err != nil {
+		c.handleLoggingForError(clientIp, http.StatusInternalServerError, "Error while reading block list")
+		return
+	}
+	c.Data["json"] = records
+	c.handleLoggingForSuccess(clientIp, "")
+}
+
+// @Title Unlocks a blocked AK
+// @Description remove an AK from the block list
+// @Param   ak  query  string  true   "Access key ID"
+// @Success 200 ok
+// @Failure 400 bad request
+// @Failure 404 not found
+// @router /appMng/v1/blocklist [delete]
+func (c *BlockListController) Delete() {
+	log.Info("Unlock AK request received.")
+	clientIp := c.Ctx.Request.Header.Get(xRealIp)
+	err := c.validateSrcAddress(clientIp)
+	if err != nil {
+		c.handleLoggingForError(clientIp, http.StatusBadRequest, util.ClientIpaddressInvalid)
+		return
+	}
+	c.logReceivedMsg(clientIp)
+
+	ak := c.GetString("ak")
+	if validateErr := util.ValidateAk(ak); validateErr != nil {
+		c.handleLoggingForError(clientIp, http.StatusBadRequest, "Invalid input for ak")
+		return
+	}
+	err = akBlockList.Unlock(ak)
+	if err == errAkNotBlocked {
+		c.handleLoggingForError(clientIp, http.StatusNotFound, "Ak is not under block list")
+		return
+	}
+	if err != nil {
+		c.handleLoggingForError(clientIp, http.StatusInternalServerError, "Error while unlocking ak")
+		return
+	}
+	c.Data["json"] = "Unlock success."
+	c.handleLoggingForSuccess(clientIp, "Unlock success.")
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/astaxie/beego/context"
+	log "github.com/sirupsen/logrus"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"mepauth/models"
+	"mepauth/util"
+)
+
+func TestGetBlockList(t *testing.T) {
+	Convey("Test get block list", t, func() {
+		InitAuthInfoList()
+		blockAk(blockTestAk)
+		c := getBlockListController()
+		c.Get()
+		records := c.Data["json"].([]*models.AkBlockRecord)
+		So(len(records), ShouldEqual, 1)
+		So(records[0].Ak, ShouldEqual, blockTestAk)
+	})
+}
+
+func TestGetBlockListFailure(t *testing.T) {
+	Convey("Test get block list failure", t, func() {
+		c := getBlockListController()
+		c.Ctx.Request.Header.Set("X-Real-Ip", "")
+		c.Get()
+		So(c.Data["json"], ShouldContainSubstring, util.ClientIpaddressInvalid)
+	})
+}
+
+func TestUnlockAk(t *testing.T) {
+	Convey("Test unlock ak", t, func() {
+		InitAuthInfoList()
+		blockAk(blockTestAk)
+		Convey("for success", func() {
+			c := getBlockListController()
+			c.Ctx.Input.SetParam("ak", blockTestAk)
+			c.Delete()
+			So(c.Data["json"], ShouldEqual, "Unlock success.")
+			So(isAkInBlockList(blockTestAk), ShouldBeFalse)
+		})
+		Convey("for not blocked ak", func() {
+			c := getBlockListController()
+			c.Ctx.Input.SetParam("ak", "AAAAAAAAAAAAAAAAAAAA")
+			c.Delete()
+			So(c.Data["json"], ShouldEqual, "Ak is not under block list")
+			So(c.Ctx.ResponseWriter.Status, ShouldEqual, http.StatusNotFound)
+		})
+		Convey("for invalid ak", func() {
+			c := getBlockListController()
+			c.Ctx.Input.SetParam("ak", "invalidAk")
+			c.Delete()
+			So(c.Data["json"], ShouldEqual, "Invalid input for ak")
+			So(isAkInBlockList(blockTestAk), ShouldBeTrue)
+		})
+	})
+}
+
+func getBlockListController() *BlockListController {
+	c := &BlockListController{}
+	c.Init(context.NewContext(), "", "", nil)
+	req, err := http.NewRequest("GET", "http://127.0.0.1", strings.NewReader(""))
+	if err != nil {
+		log.Error("prepare http request failed")
+	}
+	c.Ctx.Request = req
+	c.Ctx.Request.Header.Set("X-Real-Ip", "127.0.0.1")
+	c.Ctx.ResponseWriter = &context.Response{}
+	c.Ctx.ResponseWriter.ResponseWriter = httptest.NewRecorder()
+	c.Ctx.Output = context.NewOutput()
+	c.Ctx.Input = context.NewInput()
+	c.Ctx.Output.Reset(c.Ctx)
+	c.Ctx.Input.Reset(c.Ctx)
+	return c
+}
@@ -124,6 +124,13 @@ func (c *ConfController) Delete() {
 		return
 	}
 
+	// outstanding tokens of the app must not outlive its AK/SK
+	err = revokeAppTokens(appInsId)
+	if err != nil {
+		c.handleLoggingForError(clientIp, http.StatusInternalServerError, "Error while revoking app tokens")
+		return
+	}
+
 	authInfoRecord := &models.AuthInfoRecord{
 		AppInsId: appInsId,
 	}
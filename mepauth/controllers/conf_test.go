@@ -268,6 +268,10 @@ func TestDeleteSuccess(t *testing.T) {
 		patches := ApplyMethod(reflect.TypeOf(pgdb), "DeleteData", func(*adapter.PgDb, interface{}, ...string) error {
 			return nil
 		})
+		patches.ApplyMethod(reflect.TypeOf(pgdb), "InsertOrUpdateData", func(*adapter.PgDb, interface{},
+			...string) error {
+			return nil
+		})
 		defer patches.Reset()
 		c.Delete()
 		out := c.Data["json"]
@@ -275,6 +279,24 @@ func TestDeleteSuccess(t *testing.T) {
 	})
 }
 
+func TestDeleteRevokeFailure(t *testing.T) {
+	Convey("Test delete when revoking the app tokens fails", t, func() {
+		validAppInsID := "5abe4782-2c70-4e47-9a4e-0ee3a1a0fd1f"
+		c := getConfController()
+		c.Ctx.Input.SetParam(util.UrlApplicationId, validAppInsID)
+		adapter.Db = &adapter.PgDb{}
+		var pgdb *adapter.PgDb
+		patches := ApplyMethod(reflect.TypeOf(pgdb), "InsertOrUpdateData", func(*adapter.PgDb, interface{},
+			...string) error {
+			return errors.New("db error")
+		})
+		defer patches.Reset()
+		c.Delete()
+		out := c.Data["json"]
+		So(out, ShouldEqual, "Error while revoking app tokens")
+	})
+}
+
 func TestDeleteFailure(t *testing.T) {
 	Convey("Test delete failure", t, func() {
 		c := getConfController()
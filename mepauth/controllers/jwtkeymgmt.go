@@ -0,0 +1,101 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package controllers implements mep auth controller
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"mepauth/models"
+	"mepauth/util"
+)
+
+// JwksController json web key set controller
+type JwksController struct {
+	BaseController
+}
+
+// @Title Gets the json web key set
+// @Description get the public keys verifying the tokens, RFC 7517
+// @Success 200 ok
+// @Failure 500 internal server error
+// @router /.well-known/jwks.json [get]
+func (c *JwksController) Get() {
+	log.Info("Get json web key set request received.")
+	jwkSet, err := getJwkSet(time.Now())
+	if err != nil {
+		c.writeErrorResponse("Error while reading json web key set", http.StatusInternalServerError)
+		return
+	}
+	// verifiers may cache the set until the keys are checked for rotation again
+	c.Ctx.Output.Header("Cache-Control", "public, max-age="+strconv.Itoa(util.JwtKeyCheckInterval))
+	c.Data["json"] = jwkSet
+	c.ServeJSON()
+}
+
+// JwtKeyController jwt signing key management controller
+type JwtKeyController struct {
+	BaseController
+}
+
+// @Title Lists jwt signing keys
+// @Description list the keys verifying tokens, the first one signs them
+// @Success 200 ok
+// @Failure 400 bad request
+// @router /appMng/v1/keys [get]
+func (c *JwtKeyController) Get() {
+	log.Info("Get jwt keys request received.")
+	clientIp := c.Ctx.Request.Header.Get(xRealIp)
+	err := c.validateSrcAddress(clientIp)
+	if err != nil {
+		c.handleLoggingForError(clientIp, http.StatusBadRequest, util.ClientIpaddressInvalid)
+		return
+	}
+	c.logReceivedMsg(clientIp)
+
+	c.Data["json"] = getJwtKeyInfos(time.Now())
+	c.handleLoggingForSuccess(clientIp, "")
+}
+
+// @Title Rotates the jwt signing key
+// @Description sign tokens with a new key, the replaced key keeps verifying tokens during the overlap window
+// @Success 200 ok
+// @Failure 400 bad request
+// @Failure 500 internal server error
+// @router /appMng/v1/keys/rotate [post]
+func (c *JwtKeyController) Rotate() {
+	log.Info("Rotate jwt key request received.")
+	clientIp := c.Ctx.Request.Header.Get(xRealIp)
+	err := c.validateSrcAddress(clientIp)
+	if err != nil {
+		c.handleLoggingForError(clientIp, http.StatusBadRequest, util.ClientIpaddressInvalid)
+		return
+	}
+	c.logReceivedMsg(clientIp)
+
+	record, err := jwtKeys.rotate()
+	if err != nil {
+		c.handleLoggingForError(clientIp, http.StatusInternalServerError, "Error while rotating jwt key")
+		return
+	}
+	c.Data["json"] = &models.JwtKeyInfo{Kid: record.Kid, Alg: record.Alg, CreatedAt: record.CreatedAt.Unix()}
+	c.handleLoggingForSuccess(clientIp, "Rotate success.")
+}
@@ -0,0 +1,532 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package controllers implements mep auth controller
+package controllers
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+	log "github.com/sirupsen/logrus"
+
+	"mepauth/adapter"
+	"mepauth/models"
+	"mepauth/util"
+)
+
+const (
+	jwtKeyRecord = "jwt_key_record"
+	jwkUseSig    = "sig"
+	ecKeySize    = 32
+)
+
+var errNoSigningKey = errors.New("no jwt signing key available")
+
+// JwtKeyPublisher registers the public keys of the jwt key set on the api gateway
+type JwtKeyPublisher interface {
+	PublishJwtKey(kid string, alg string, publicKey string) error
+	UnpublishJwtKey(kid string) error
+}
+
+// Jwt key set, the newest key signs the tokens and the older ones keep verifying them during the overlap window
+type jwtKeySet struct {
+	mutex       sync.RWMutex
+	rotateMutex sync.Mutex
+	keys        []*models.JwtKeyRecord
+	published   map[string]bool
+	publisher   JwtKeyPublisher
+}
+
+var jwtKeys = &jwtKeySet{published: make(map[string]bool)}
+
+// InitJwtKeySet loads the jwt key set, publishes its keys and starts the scheduled rotation
+func InitJwtKeySet(publisher JwtKeyPublisher) error {
+	jwtKeys.rotateMutex.Lock()
+	jwtKeys.publisher = publisher
+	err := jwtKeys.load()
+	if err == nil && len(jwtKeys.snapshot()) == 0 {
+		err = jwtKeys.importConfiguredKey()
+		if err != nil {
+			log.Error("Failed to import the configured jwt key, generating a new one")
+			_, err = jwtKeys.addKey()
+		}
+	}
+	jwtKeys.rotateMutex.Unlock()
+	if err != nil {
+		log.Error("Failed to initialize jwt key set")
+		return err
+	}
+	jwtKeys.maintain(time.Now())
+	go func() {
+		ticker := time.NewTicker(time.Duration(util.JwtKeyCheckInterval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			jwtKeys.maintain(time.Now())
+		}
+	}()
+	return nil
+}
+
+// Rotation interval of the signing key, 0 disables the scheduled rotation
+func getJwtKeyRotationInterval() time.Duration {
+	return time.Duration(beego.AppConfig.DefaultInt64("jwt_key_rotation_interval",
+		util.JwtKeyRotationInterval)) * time.Second
+}
+
+// Time a replaced key keeps verifying tokens, it covers the tokens signed before other instances reload the set
+func getJwtKeyOverlap() time.Duration {
+	overlap := beego.AppConfig.DefaultInt64("jwt_key_overlap", util.JwtKeyOverlap)
+	if minOverlap := int64(util.ExpiresVal + util.JwtKeyCheckInterval); overlap < minOverlap {
+		overlap = minOverlap
+	}
+	return time.Duration(overlap) * time.Second
+}
+
+func getJwtSigningAlg() string {
+	alg := util.GetAppConfig("jwt_signing_alg")
+	if alg == "" {
+		return util.RS512
+	}
+	return alg
+}
+
+// Keys of the set, newest first
+func (s *jwtKeySet) snapshot() []*models.JwtKeyRecord {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.keys
+}
+
+func (s *jwtKeySet) load() error {
+	var records []*models.JwtKeyRecord
+	_, err := adapter.Db.ReadAllData(jwtKeyRecord, &records)
+	if err != nil && !isNotFound(err) {
+		log.Error("Failed to read jwt keys")
+		return err
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].CreatedAt.After(records[j].CreatedAt)
+	})
+	s.mutex.Lock()
+	s.keys = records
+	s.mutex.Unlock()
+	return nil
+}
+
+// The key retires an overlap window after it was replaced, a zero time for the signing key
+func retiresAt(keys []*models.JwtKeyRecord, index int) time.Time {
+	if index == 0 {
+		return time.Time{}
+	}
+	return keys[index-1].CreatedAt.Add(getJwtKeyOverlap())
+}
+
+// Keys which still verify tokens
+func (s *jwtKeySet) liveKeys(now time.Time) []*models.JwtKeyRecord {
+	keys := s.snapshot()
+	live := make([]*models.JwtKeyRecord, 0, len(keys))
+	for i, key := range keys {
+		if i == 0 || now.Before(retiresAt(keys, i)) {
+			live = append(live, key)
+		}
+	}
+	return live
+}
+
+// Reload the set, rotate the signing key when it is due, retire the replaced keys and publish the new ones
+func (s *jwtKeySet) maintain(now time.Time) {
+	s.rotateMutex.Lock()
+	defer s.rotateMutex.Unlock()
+	if err := s.load(); err != nil {
+		return
+	}
+	keys := s.snapshot()
+	interval := getJwtKeyRotationInterval()
+	if len(keys) == 0 || (interval > 0 && !now.Before(keys[0].CreatedAt.Add(interval))) {
+		if _, err := s.addKey(); err != nil {
+			log.Error("Scheduled jwt key rotation failed: " + err.Error())
+		}
+		keys = s.snapshot()
+	}
+	for i := len(keys) - 1; i > 0; i-- {
+		if now.Before(retiresAt(keys, i)) {
+			continue
+		}
+		if err := s.retire(keys[i]); err != nil {
+			log.Error("Failed to retire jwt key " + keys[i].Kid + ": " + err.Error())
+		}
+	}
+	for _, key := range s.snapshot() {
+		if err := s.publish(key); err != nil {
+			log.Error("Failed to publish jwt key " + key.Kid + ": " + err.Error())
+		}
+	}
+}
+
+// Rotate the signing key now, the replaced key keeps verifying tokens during the overlap window
+func (s *jwtKeySet) rotate() (*models.JwtKeyRecord, error) {
+	s.rotateMutex.Lock()
+	defer s.rotateMutex.Unlock()
+	return s.addKey()
+}
+
+func (s *jwtKeySet) addKey() (*models.JwtKeyRecord, error) {
+	alg := getJwtSigningAlg()
+	key, err := util.GenerateJwtKey(alg)
+	if err != nil {
+		return nil, err
+	}
+	record, err := newJwtKeyRecord(alg, key)
+	util.ClearPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	// the gateway has to know the key before any token is signed by it
+	if err = s.publish(record); err != nil {
+		return nil, err
+	}
+	err = adapter.Db.InsertData(record)
+	if err != nil && err.Error() != util.PgOkMsg {
+		if errUnpublish := s.unpublish(record.Kid); errUnpublish != nil {
+			log.Error("Failed to unpublish jwt key " + record.Kid)
+		}
+		return nil, err
+	}
+	s.mutex.Lock()
+	s.keys = append([]*models.JwtKeyRecord{record}, s.keys...)
+	s.mutex.Unlock()
+	log.Info("Jwt signing key rotated, the new key is " + record.Kid)
+	return record, nil
+}
+
+// Import the key configured before the key set existed, it signs until the first rotation
+func (s *jwtKeySet) importConfiguredKey() error {
+	privateKey, err := util.GetPrivateKey()
+	if err != nil {
+		return err
+	}
+	record, err := newJwtKeyRecord(util.RS512, privateKey)
+	util.ClearPrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+	err = adapter.Db.InsertOrUpdateData(record, "kid")
+	if err != nil && err.Error() != util.PgOkMsg {
+		return err
+	}
+	s.mutex.Lock()
+	s.keys = []*models.JwtKeyRecord{record}
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *jwtKeySet) retire(record *models.JwtKeyRecord) error {
+	if err := s.unpublish(record.Kid); err != nil {
+		return err
+	}
+	err := adapter.Db.DeleteData(record, "kid")
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	s.mutex.Lock()
+	keys := make([]*models.JwtKeyRecord, 0, len(s.keys))
+	for _, key := range s.keys {
+		if key.Kid != record.Kid {
+			keys = append(keys, key)
+		}
+	}
+	s.keys = keys
+	s.mutex.Unlock()
+	log.Info("Jwt key " + record.Kid + " retired")
+	return nil
+}
+
+func (s *jwtKeySet) publish(record *models.JwtKeyRecord) error {
+	s.mutex.RLock()
+	published := s.published[record.Kid]
+	s.mutex.RUnlock()
+	if published || s.publisher == nil {
+		return nil
+	}
+	if err := s.publisher.PublishJwtKey(record.Kid, record.Alg, record.PublicKey); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	s.published[record.Kid] = true
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *jwtKeySet) unpublish(kid string) error {
+	if s.publisher != nil {
+		if err := s.publisher.UnpublishJwtKey(kid); err != nil {
+			return err
+		}
+	}
+	s.mutex.Lock()
+	delete(s.published, kid)
+	s.mutex.Unlock()
+	return nil
+}
+
+// Get the current signing key, the private key has to be cleared after use
+func getSigningKey() (*models.JwtKeyRecord, crypto.Signer, error) {
+	keys := jwtKeys.snapshot()
+	if len(keys) == 0 {
+		return nil, nil, errNoSigningKey
+	}
+	privateKey, err := decryptJwtKey(keys[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys[0], privateKey, nil
+}
+
+// Get the public key verifying tokens of the kid, the set is reloaded for keys added by other instances
+func getVerificationKey(kid string, alg string) (crypto.PublicKey, error) {
+	record := findLiveKey(kid)
+	if record == nil {
+		jwtKeys.rotateMutex.Lock()
+		err := jwtKeys.load()
+		jwtKeys.rotateMutex.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		record = findLiveKey(kid)
+	}
+	if record == nil || record.Alg != alg {
+		return nil, errTokenInvalid
+	}
+	return parsePublicKey(record.PublicKey)
+}
+
+func findLiveKey(kid string) *models.JwtKeyRecord {
+	for _, key := range jwtKeys.liveKeys(time.Now()) {
+		if key.Kid == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// Get the public json web key set
+func getJwkSet(now time.Time) (*models.JwkSet, error) {
+	keys := jwtKeys.liveKeys(now)
+	jwkSet := &models.JwkSet{Keys: make([]models.Jwk, 0, len(keys))}
+	for _, key := range keys {
+		publicKey, err := parsePublicKey(key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		jwk, err := publicJwk(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		jwk.Use = jwkUseSig
+		jwk.Alg = key.Alg
+		jwk.Kid = key.Kid
+		jwkSet.Keys = append(jwkSet.Keys, jwk)
+	}
+	return jwkSet, nil
+}
+
+// Get the information of the keys which still verify tokens
+func getJwtKeyInfos(now time.Time) []models.JwtKeyInfo {
+	keys := jwtKeys.snapshot()
+	infos := make([]models.JwtKeyInfo, 0, len(keys))
+	for i, key := range keys {
+		retires := retiresAt(keys, i)
+		if i != 0 && !now.Before(retires) {
+			continue
+		}
+		info := models.JwtKeyInfo{Kid: key.Kid, Alg: key.Alg, CreatedAt: key.CreatedAt.Unix()}
+		if i != 0 {
+			info.RetiresAt = retires.Unix()
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Create the key record, the kid is the RFC 7638 thumbprint of the public key
+func newJwtKeyRecord(alg string, privateKey crypto.Signer) (*models.JwtKeyRecord, error) {
+	if _, err := util.GetJwtSigningMethod(alg); err != nil {
+		return nil, err
+	}
+	jwk, err := publicJwk(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	publicKeyDer, err := x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	privateKeyDer, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	cipherKey, nonce, err := encryptJwtKey(privateKeyDer)
+	util.ClearByteArray(privateKeyDer)
+	if err != nil {
+		return nil, err
+	}
+	return &models.JwtKeyRecord{
+		Kid:        jwkThumbprint(jwk),
+		Alg:        alg,
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyDer})),
+		PrivateKey: cipherKey,
+		Nonce:      nonce,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// Encrypt the private key by the work key, the cipher and the nonce are hex encoded
+func encryptJwtKey(privateKeyDer []byte) (string, string, error) {
+	nonce := make([]byte, util.NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		log.Error("Failed to generate nonce.")
+		return "", "", err
+	}
+	workKey, err := util.GetWorkKey()
+	if err != nil {
+		log.Error("Failed to generate work key.")
+		return "", "", err
+	}
+	cipherKey, err := util.EncryptByAES256GCM(privateKeyDer, workKey, nonce)
+	util.ClearByteArray(workKey)
+	if err != nil {
+		log.Error("Failed to encrypt jwt private key.")
+		return "", "", err
+	}
+	return hex.EncodeToString(cipherKey), hex.EncodeToString(nonce), nil
+}
+
+func decryptJwtKey(record *models.JwtKeyRecord) (crypto.Signer, error) {
+	cipherKey, err := hex.DecodeString(record.PrivateKey)
+	if err != nil {
+		log.Error("Decode of jwt private key failed")
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(record.Nonce)
+	if err != nil {
+		log.Error("Decode of jwt private key nonce failed")
+		return nil, err
+	}
+	workKey, err := util.GetWorkKey()
+	if err != nil {
+		log.Error("Generate work key failed")
+		util.ClearByteArray(nonce)
+		return nil, err
+	}
+	privateKeyDer, err := util.DecryptByAES256GCM(cipherKey, workKey, nonce)
+	util.ClearByteArray(workKey)
+	util.ClearByteArray(nonce)
+	if err != nil {
+		log.Error("Decrypt jwt private key failed")
+		return nil, err
+	}
+	privateKey, err := x509.ParsePKCS8PrivateKey(privateKeyDer)
+	util.ClearByteArray(privateKeyDer)
+	if err != nil {
+		log.Error("Parse jwt private key failed")
+		return nil, err
+	}
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("unsupported jwt private key type")
+	}
+	return signer, nil
+}
+
+func parsePublicKey(publicKeyPem string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPem))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("failed to decode jwt public key")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func encodeJwkValue(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+// EC coordinates are encoded at the full size of the curve
+func padCoordinate(coordinate []byte) []byte {
+	padded := make([]byte, ecKeySize)
+	copy(padded[ecKeySize-len(coordinate):], coordinate)
+	return padded
+}
+
+// Public json web key without its kid, use and alg
+func publicJwk(publicKey crypto.PublicKey) (models.Jwk, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return models.Jwk{
+			Kty: "RSA",
+			N:   encodeJwkValue(key.N.Bytes()),
+			E:   encodeJwkValue(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		if key.Curve.Params().BitSize != ecKeySize*8 {
+			return models.Jwk{}, errors.New("unsupported jwt ec key curve")
+		}
+		return models.Jwk{
+			Kty: "EC",
+			Crv: key.Curve.Params().Name,
+			X:   encodeJwkValue(padCoordinate(key.X.Bytes())),
+			Y:   encodeJwkValue(padCoordinate(key.Y.Bytes())),
+		}, nil
+	case ed25519.PublicKey:
+		return models.Jwk{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   encodeJwkValue(key),
+		}, nil
+	default:
+		return models.Jwk{}, errors.New("unsupported jwt public key type")
+	}
+}
+
+// JWK thumbprint, RFC 7638, the required members in lexicographic order
+func jwkThumbprint(jwk models.Jwk) string {
+	var members string
+	switch jwk.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, jwk.E, jwk.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`, jwk.Crv, jwk.X, jwk.Y)
+	default:
+		members = fmt.Sprintf(`{"crv":"%s","kty":"%s","x":"%s"}`, jwk.Crv, jwk.Kty, jwk.X)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return encodeJwkValue(sum[:])
+}
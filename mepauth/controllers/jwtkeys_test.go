@@ -0,0 +1,229 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/astaxie/beego"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"mepauth/models"
+	"mepauth/util"
+)
+
+var (
+	publishedJwtKeys map[string]string
+	publishJwtKeyErr error
+)
+
+type testJwtKeyPublisher struct{}
+
+func (p *testJwtKeyPublisher) PublishJwtKey(kid string, alg string, _ string) error {
+	if publishJwtKeyErr != nil {
+		return publishJwtKeyErr
+	}
+	publishedJwtKeys[kid] = alg
+	return nil
+}
+
+func (p *testJwtKeyPublisher) UnpublishJwtKey(kid string) error {
+	delete(publishedJwtKeys, kid)
+	return nil
+}
+
+func setTestJwtKeyPublisher() {
+	publishedJwtKeys = make(map[string]string)
+	publishJwtKeyErr = nil
+	jwtKeys.publisher = &testJwtKeyPublisher{}
+	jwtKeys.published = make(map[string]bool)
+}
+
+func TestJwkThumbprint(t *testing.T) {
+	Convey("jwk thumbprint", t, func() {
+		// RFC 7638 section 3.1 example
+		n := "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJE" +
+			"CPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2Q" +
+			"vzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6" +
+			"WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw"
+		jwk := models.Jwk{Kty: "RSA", N: n, E: "AQAB", Alg: util.RS512, Kid: "ignored"}
+		So(jwkThumbprint(jwk), ShouldEqual, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs")
+	})
+}
+
+func TestJwtKeyAlgorithms(t *testing.T) {
+	Convey("jwt key algorithms", t, func() {
+		patches := patchTokenStore()
+		defer patches.Reset()
+		keyPatches := patchJwtKey()
+		defer keyPatches.Reset()
+
+		rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+		rsaRecord, err := newJwtKeyRecord(util.RS512, rsaKey)
+		So(err, ShouldBeNil)
+		ecKey, _ := util.GenerateJwtKey(util.ES256)
+		ecRecord, err := newJwtKeyRecord(util.ES256, ecKey)
+		So(err, ShouldBeNil)
+		edKey, _ := util.GenerateJwtKey(util.EdDSA)
+		edRecord, err := newJwtKeyRecord(util.EdDSA, edKey)
+		So(err, ShouldBeNil)
+
+		for _, tc := range []struct {
+			record *models.JwtKeyRecord
+			kty    string
+		}{{rsaRecord, "RSA"}, {ecRecord, "EC"}, {edRecord, "OKP"}} {
+			jwtKeys.keys = []*models.JwtKeyRecord{tc.record}
+			token, err := generateJwtToken(tokenStoreAppInsId, "127.0.0.1", nil)
+			So(err, ShouldBeNil)
+			claims, err := parseAccessToken(*token)
+			So(err, ShouldBeNil)
+			So(claims.Subject, ShouldEqual, tokenStoreAppInsId)
+
+			jwkSet, err := getJwkSet(time.Now())
+			So(err, ShouldBeNil)
+			So(jwkSet.Keys, ShouldHaveLength, 1)
+			So(jwkSet.Keys[0].Kty, ShouldEqual, tc.kty)
+			So(jwkSet.Keys[0].Alg, ShouldEqual, tc.record.Alg)
+			So(jwkSet.Keys[0].Kid, ShouldEqual, tc.record.Kid)
+			So(jwkThumbprint(jwkSet.Keys[0]), ShouldEqual, tc.record.Kid)
+		}
+
+		Convey("for tampered tokens", func() {
+			jwtKeys.keys = []*models.JwtKeyRecord{edRecord}
+			token, _ := generateJwtToken(tokenStoreAppInsId, "127.0.0.1", nil)
+			parts := strings.Split(*token, ".")
+			_, err := parseAccessToken(parts[0] + "." + parts[1] + ".AAAA")
+			So(err, ShouldEqual, errTokenInvalid)
+			// the key of the kid is bound to its algorithm
+			ecRecord.Kid = edRecord.Kid
+			jwtKeys.keys = []*models.JwtKeyRecord{ecRecord}
+			_, err = parseAccessToken(*token)
+			So(err, ShouldEqual, errTokenInvalid)
+		})
+		Convey("for unsupported algorithm", func() {
+			_, err := newJwtKeyRecord("HS256", edKey)
+			So(err, ShouldNotBeNil)
+			_, err = util.GenerateJwtKey("HS256")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestJwtKeyRotation(t *testing.T) {
+	Convey("jwt key rotation", t, func() {
+		patches := patchTokenStore()
+		defer patches.Reset()
+		keyPatches := patchJwtKey()
+		defer keyPatches.Reset()
+		setTestJwtKeyPublisher()
+		defer func() { jwtKeys.publisher = nil }()
+		beego.AppConfig.Set("jwt_signing_alg", util.EdDSA)
+		defer beego.AppConfig.Set("jwt_signing_alg", util.RS512)
+
+		oldKid := jwtKeys.keys[0].Kid
+		oldToken, _ := generateJwtToken(tokenStoreAppInsId, "127.0.0.1", nil)
+		record, err := jwtKeys.rotate()
+		So(err, ShouldBeNil)
+		So(record.Alg, ShouldEqual, util.EdDSA)
+		So(publishedJwtKeys, ShouldContainKey, record.Kid)
+		So(jwtKeyStore, ShouldContainKey, record.Kid)
+
+		newToken, _ := generateJwtToken(tokenStoreAppInsId, "127.0.0.1", nil)
+		claims, err := parseAccessToken(*newToken)
+		So(err, ShouldBeNil)
+		_, err = parseAccessToken(*oldToken)
+		So(err, ShouldBeNil)
+		jwkSet, _ := getJwkSet(time.Now())
+		So(jwkSet.Keys, ShouldHaveLength, 2)
+		infos := getJwtKeyInfos(time.Now())
+		So(infos[0].Kid, ShouldEqual, record.Kid)
+		So(infos[1].RetiresAt, ShouldEqual, record.CreatedAt.Add(getJwtKeyOverlap()).Unix())
+		So(claims.ID, ShouldNotBeEmpty)
+
+		Convey("for retirement after the overlap", func() {
+			jwtKeys.maintain(time.Now().Add(getJwtKeyOverlap()))
+			So(jwtKeyStore, ShouldNotContainKey, oldKid)
+			So(publishedJwtKeys, ShouldNotContainKey, oldKid)
+			So(jwtKeys.snapshot(), ShouldHaveLength, 1)
+			_, err := parseAccessToken(*oldToken)
+			So(err, ShouldEqual, errTokenInvalid)
+			_, err = parseAccessToken(*newToken)
+			So(err, ShouldBeNil)
+		})
+		Convey("for scheduled rotation", func() {
+			jwtKeys.maintain(time.Now().Add(getJwtKeyRotationInterval()))
+			So(jwtKeys.snapshot()[0].Kid, ShouldNotEqual, record.Kid)
+			So(publishedJwtKeys, ShouldContainKey, jwtKeys.snapshot()[0].Kid)
+		})
+		Convey("for publish failure", func() {
+			publishJwtKeyErr = errors.New("api gateway error")
+			_, err := jwtKeys.rotate()
+			So(err, ShouldNotBeNil)
+			So(jwtKeys.snapshot()[0].Kid, ShouldEqual, record.Kid)
+			So(jwtKeyStore, ShouldHaveLength, 2)
+		})
+	})
+}
+
+func TestJwtKeyControllers(t *testing.T) {
+	Convey("jwt key controllers", t, func() {
+		patches := patchTokenStore()
+		defer patches.Reset()
+		keyPatches := patchJwtKey()
+		defer keyPatches.Reset()
+		setTestJwtKeyPublisher()
+		defer func() { jwtKeys.publisher = nil }()
+		beego.AppConfig.Set("jwt_signing_alg", util.EdDSA)
+		defer beego.AppConfig.Set("jwt_signing_alg", util.RS512)
+		oldKid := jwtKeys.keys[0].Kid
+
+		k := &JwtKeyController{}
+		k.Init(getOAuthTokenController(nil).Ctx, "", "", nil)
+		k.Rotate()
+		info, ok := k.Data["json"].(*models.JwtKeyInfo)
+		So(ok, ShouldBeTrue)
+		So(info.Alg, ShouldEqual, util.EdDSA)
+		So(info.Kid, ShouldNotEqual, oldKid)
+
+		k.Get()
+		infos, ok := k.Data["json"].([]models.JwtKeyInfo)
+		So(ok, ShouldBeTrue)
+		So(infos, ShouldHaveLength, 2)
+		So(infos[1].Kid, ShouldEqual, oldKid)
+
+		c := &JwksController{}
+		c.Init(getOAuthTokenController(nil).Ctx, "", "", nil)
+		c.Get()
+		jwkSet, ok := c.Data["json"].(*models.JwkSet)
+		So(ok, ShouldBeTrue)
+		So(jwkSet.Keys, ShouldHaveLength, 2)
+		So(jwkSet.Keys[0].Kid, ShouldEqual, info.Kid)
+		So(jwkSet.Keys[0].Crv, ShouldEqual, "Ed25519")
+	})
+}
+
+func TestPadCoordinate(t *testing.T) {
+	Convey("pad coordinate", t, func() {
+		So(padCoordinate(big.NewInt(1).Bytes()), ShouldHaveLength, ecKeySize)
+	})
+}
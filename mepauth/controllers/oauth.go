@@ -0,0 +1,297 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package controllers implements mep auth controller
+package controllers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"mepauth/models"
+	"mepauth/util"
+)
+
+// OAuth2 client credentials and refresh token grants, RFC 6749 sections 4.4 and 6
+const (
+	formContentType            = "application/x-www-form-urlencoded"
+	grantTypeClientCredentials = "client_credentials"
+	grantTypeRefreshToken      = "refresh_token"
+	oauthInvalidRequest        = "invalid_request"
+	oauthInvalidClient         = "invalid_client"
+	oauthInvalidGrant          = "invalid_grant"
+	oauthInvalidScope          = "invalid_scope"
+	oauthUnsupportedGrantType  = "unsupported_grant_type"
+	oauthServerError           = "server_error"
+	invalidClientDesc          = "Invalid client id or secret."
+)
+
+var scopeRegexp = regexp.MustCompile(util.ScopeRegex)
+
+// Client credentials parsed from the token request
+type clientCredentials struct {
+	id     string
+	secret string
+	basic  bool
+}
+
+// Client authenticated by its credentials
+type oauthClient struct {
+	ak       string
+	appInsId string
+	basic    bool
+}
+
+// Token requests with a form body use the OAuth2 grants, others the AK/SK signature
+func isOAuthTokenRequest(r *http.Request) bool {
+	return strings.HasPrefix(strings.ToLower(r.Header.Get(util.ContentType)), formContentType)
+}
+
+// Parse the form encoded token request, parameters must not be repeated
+func (c *TokenController) parseTokenForm() (url.Values, error) {
+	var form url.Values
+	if len(c.Ctx.Input.RequestBody) != 0 {
+		values, err := url.ParseQuery(string(c.Ctx.Input.RequestBody))
+		if err != nil {
+			return nil, err
+		}
+		form = values
+	} else {
+		if err := c.Ctx.Request.ParseForm(); err != nil {
+			return nil, err
+		}
+		form = c.Ctx.Request.PostForm
+	}
+	for key, values := range form {
+		if len(values) > 1 {
+			return nil, errors.New("parameter " + key + " is repeated")
+		}
+	}
+	return form, nil
+}
+
+// Client id and secret come either from basic auth or from the body, not both
+func parseClientCredentials(r *http.Request, form url.Values) (*clientCredentials, error) {
+	id, secret, basic := r.BasicAuth()
+	if basic {
+		if form.Get("client_id") != "" || form.Get("client_secret") != "" {
+			return nil, errors.New("multiple client authentication methods")
+		}
+		unescapedId, errId := url.QueryUnescape(id)
+		unescapedSecret, errSecret := url.QueryUnescape(secret)
+		if errId != nil || errSecret != nil {
+			return nil, errors.New("bad basic auth encoding")
+		}
+		return &clientCredentials{id: unescapedId, secret: unescapedSecret, basic: true}, nil
+	}
+	if r.Header.Get(authorization) != "" {
+		return nil, errors.New("unsupported authorization scheme")
+	}
+	return &clientCredentials{id: form.Get("client_id"), secret: form.Get("client_secret")}, nil
+}
+
+func isClientSecretValid(sk []byte, secret string) bool {
+	return subtle.ConstantTimeCompare(sk, []byte(secret)) == 1
+}
+
+// Write an error response in the RFC 6749 section 5.2 format
+func (c *TokenController) writeOAuthError(code int, errCode string, desc string, basic bool) {
+	log.Error(errCode + ": " + desc)
+	if code == http.StatusUnauthorized && basic {
+		c.Ctx.Output.Header("WWW-Authenticate", `Basic realm="`+util.MepauthName+`"`)
+	}
+	c.Ctx.Output.Header("Cache-Control", "no-store")
+	c.Ctx.Output.Header("Pragma", "no-cache")
+	c.Data["json"] = &models.OAuthErrorInfo{
+		Error:            errCode,
+		ErrorDescription: desc,
+	}
+	c.Ctx.ResponseWriter.WriteHeader(code)
+	c.ServeJSON()
+}
+
+// Issue a token for the OAuth2 grants
+func (c *TokenController) postOAuthToken(clientIp string) {
+	c.logReceivedMsg(clientIp)
+	form, err := c.parseTokenForm()
+	if err != nil {
+		c.writeOAuthError(http.StatusBadRequest, oauthInvalidRequest, err.Error(), false)
+		c.logErrResponseMsg(clientIp, "Bad token request")
+		return
+	}
+	switch grantType := form.Get("grant_type"); grantType {
+	case "":
+		c.writeOAuthError(http.StatusBadRequest, oauthInvalidRequest, "Missing grant_type.", false)
+		c.logErrResponseMsg(clientIp, "Bad token request")
+	case grantTypeClientCredentials:
+		c.postClientCredentials(clientIp, form)
+	case grantTypeRefreshToken:
+		c.postRefreshToken(clientIp, form)
+	default:
+		c.writeOAuthError(http.StatusBadRequest, oauthUnsupportedGrantType, "Unsupported grant_type.", false)
+		c.logErrResponseMsg(clientIp, "Unsupported grant type")
+	}
+}
+
+// Authenticate the client by its id and secret, the error response is written on failure
+func (c *TokenController) authenticateClient(clientIp string, form url.Values) (*oauthClient, bool) {
+	cred, err := parseClientCredentials(c.Ctx.Request, form)
+	if err != nil {
+		c.writeOAuthError(http.StatusBadRequest, oauthInvalidRequest, err.Error(), false)
+		c.logErrResponseMsg(clientIp, "Bad client authentication")
+		return nil, false
+	}
+	if util.ValidateAk(cred.id) != nil || cred.secret == "" {
+		c.writeOAuthError(http.StatusUnauthorized, oauthInvalidClient, invalidClientDesc, cred.basic)
+		c.logErrResponseMsg(clientIp, "Bad client credentials")
+		return nil, false
+	}
+	ak := cred.id
+	c.logReceivedMsgWithAk(clientIp, ak)
+
+	if isAkInBlockList(ak) {
+		c.writeOAuthError(http.StatusUnauthorized, oauthInvalidClient, "Access is locked.", cred.basic)
+		c.logErrResponseMsgWithAk(clientIp, "Ak is blockListed", ak)
+		return nil, false
+	}
+
+	appInsId, sk, akExist := getAppInsIdSk(ak)
+	if appInsId == "" || len(sk) == 0 {
+		if akExist {
+			c.writeOAuthError(http.StatusInternalServerError, oauthServerError, internalError, false)
+		} else {
+			c.writeOAuthError(http.StatusUnauthorized, oauthInvalidClient, invalidClientDesc, cred.basic)
+		}
+		c.logErrResponseMsgWithAk(clientIp, "Matching App instance id not found", ak)
+		return nil, false
+	}
+	secretIsValid := isClientSecretValid(sk, cred.secret)
+	util.ClearByteArray(sk)
+	if !secretIsValid {
+		processAkForBlockListing(ak)
+		c.writeOAuthError(http.StatusUnauthorized, oauthInvalidClient, invalidClientDesc, cred.basic)
+		c.logErrResponseMsgWithAk(clientIp, "Client secret is invalid", ak)
+		return nil, false
+	}
+	clearAkFromBlockListing(ak)
+	return &oauthClient{ak: ak, appInsId: appInsId, basic: cred.basic}, true
+}
+
+// Check the optional scope parameter is well formed
+func (c *TokenController) isScopeFormatValid(clientIp string, scope string) bool {
+	if scope != "" && !scopeRegexp.MatchString(scope) {
+		c.writeOAuthError(http.StatusBadRequest, oauthInvalidScope, "Malformed scope.", false)
+		c.logErrResponseMsg(clientIp, "Bad scope")
+		return false
+	}
+	return true
+}
+
+// Issue a token for the client credentials grant
+func (c *TokenController) postClientCredentials(clientIp string, form url.Values) {
+	scope := form.Get("scope")
+	if !c.isScopeFormatValid(clientIp, scope) {
+		return
+	}
+	client, ok := c.authenticateClient(clientIp, form)
+	if !ok {
+		return
+	}
+	services, err := c.getGrantedServices(client.appInsId, scope)
+	c.sendOAuthToken(client, services, err, clientIp)
+}
+
+// Issue a token for the refresh token grant, RFC 6749 section 6, the refresh token is rotated
+func (c *TokenController) postRefreshToken(clientIp string, form url.Values) {
+	refreshToken := form.Get(grantTypeRefreshToken)
+	if refreshToken == "" {
+		c.writeOAuthError(http.StatusBadRequest, oauthInvalidRequest, "Missing refresh_token.", false)
+		c.logErrResponseMsg(clientIp, "Bad token request")
+		return
+	}
+	scope := form.Get("scope")
+	if !c.isScopeFormatValid(clientIp, scope) {
+		return
+	}
+	client, ok := c.authenticateClient(clientIp, form)
+	if !ok {
+		return
+	}
+	record, err := readRefreshToken(refreshToken)
+	if err == errTokenInvalid || (err == nil && record.AppInsId != client.appInsId) {
+		c.writeOAuthError(http.StatusBadRequest, oauthInvalidGrant, "Invalid refresh token.", false)
+		c.logErrResponseMsgWithAk(clientIp, "Refresh token is invalid", client.ak)
+		return
+	}
+	if err != nil {
+		c.writeOAuthError(http.StatusInternalServerError, oauthServerError, internalError, false)
+		c.logErrResponseMsgWithAk(clientIp, "Reading refresh token failed", client.ak)
+		return
+	}
+	var granted []string
+	if err = json.Unmarshal([]byte(record.Services), &granted); err != nil {
+		c.writeOAuthError(http.StatusInternalServerError, oauthServerError, internalError, false)
+		c.logErrResponseMsgWithAk(clientIp, "Parsing refresh token services failed", client.ak)
+		return
+	}
+	services, err := narrowServices(granted, scope)
+	if err == nil && len(services) != 0 {
+		// the required services of the app may have changed since
+		services, err = c.getGrantedServices(client.appInsId, strings.Join(services, " "))
+	}
+	if err == nil {
+		errDelete := deleteRefreshToken(record)
+		if errDelete == errTokenInvalid {
+			c.writeOAuthError(http.StatusBadRequest, oauthInvalidGrant, "Invalid refresh token.", false)
+			c.logErrResponseMsgWithAk(clientIp, "Refresh token is already used", client.ak)
+			return
+		}
+		if errDelete != nil {
+			c.writeOAuthError(http.StatusInternalServerError, oauthServerError, internalError, false)
+			c.logErrResponseMsgWithAk(clientIp, "Deleting refresh token failed", client.ak)
+			return
+		}
+	}
+	c.sendOAuthToken(client, services, err, clientIp)
+}
+
+// Send the token for the granted services, or the error of granting them
+func (c *TokenController) sendOAuthToken(client *oauthClient, services []string, err error, clientIp string) {
+	if err == errScopeNotDeclared {
+		c.writeOAuthError(http.StatusBadRequest, oauthInvalidScope, "Scope exceeds the granted services.", false)
+		c.logErrResponseMsgWithAk(clientIp, "Scope is not granted", client.ak)
+		return
+	}
+	if err != nil {
+		c.writeOAuthError(http.StatusInternalServerError, oauthServerError, internalError, false)
+		c.logErrResponseMsgWithAk(clientIp, "Granting services failed", client.ak)
+		return
+	}
+	tokenInfo := c.getTokenInfo(client.appInsId, client.ak, services)
+	if tokenInfo == nil {
+		return
+	}
+	c.Ctx.Output.Header("Cache-Control", "no-store")
+	c.Ctx.Output.Header("Pragma", "no-cache")
+	c.sendResponseMsg(client.ak, tokenInfo, clientIp)
+}
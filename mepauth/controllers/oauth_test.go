@@ -0,0 +1,243 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/astaxie/beego/context"
+	log "github.com/sirupsen/logrus"
+
+	. "github.com/agiledragon/gomonkey"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"mepauth/adapter"
+	"mepauth/models"
+	"mepauth/util"
+)
+
+const (
+	oauthTestAppInsId = "5abe4782-2c70-4e47-9a4e-0ee3a1a0fd1f"
+	oauthTestAk       = "QVUJMSUMgS0VZLS0tLS0"
+	oauthTestSk       = "DXPb4sqElKhcHe07Kw5uorayETwId1JOjjOIRomRs5wyszoCR5R7AtVa28KT3lSc"
+)
+
+func TestIsOAuthTokenRequest(t *testing.T) {
+	Convey("is oauth token request", t, func() {
+		req, _ := http.NewRequest("POST", "http://127.0.0.1", strings.NewReader(""))
+		So(isOAuthTokenRequest(req), ShouldBeFalse)
+		req.Header.Set(util.ContentType, "application/x-www-form-urlencoded; charset=utf-8")
+		So(isOAuthTokenRequest(req), ShouldBeTrue)
+	})
+}
+
+// Patch the test client, it requires the app_support and traffic services
+func patchOAuthClient() *Patches {
+	patches := ApplyFunc(getAppInsIdSk, func(ak string) (string, []byte, bool) {
+		if ak != oauthTestAk {
+			return "", nil, false
+		}
+		return oauthTestAppInsId, []byte(oauthTestSk), true
+	})
+	patches.ApplyFunc(getRequiredServices, func(_ string) (string, error) {
+		return `["app_support","traffic"]`, nil
+	})
+	return patches
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	patches := patchOAuthClient()
+	patches.ApplyFunc(generateJwtToken, func(_ string, _ string, _ []string) (*string, error) {
+		// sendResponseMsg clears the token, so it must not be a literal
+		token := string([]byte("jwtToken"))
+		return &token, nil
+	})
+	patches.ApplyFunc(issueRefreshToken, func(_ string, _ string, _ []string) (string, error) {
+		return string([]byte("refreshToken")), nil
+	})
+	defer patches.Reset()
+
+	Convey("client credentials grant", t, func() {
+		InitAuthInfoList()
+		Convey("for success with body credentials", func() {
+			c := getOAuthTokenController(url.Values{"grant_type": {"client_credentials"},
+				"client_id": {oauthTestAk}, "client_secret": {oauthTestSk}, "scope": {"app_support"}})
+			c.Post()
+			tokenInfo, ok := c.Data["json"].(*models.TokenInfo)
+			So(ok, ShouldBeTrue)
+			So(tokenInfo.TokenType, ShouldEqual, "Bearer")
+			So(tokenInfo.Scope, ShouldEqual, "app_support")
+			So(c.Ctx.ResponseWriter.Header().Get("Cache-Control"), ShouldEqual, "no-store")
+		})
+		Convey("for success with basic auth", func() {
+			c := getOAuthTokenController(url.Values{"grant_type": {"client_credentials"}})
+			c.Ctx.Request.SetBasicAuth(url.QueryEscape(oauthTestAk), url.QueryEscape(oauthTestSk))
+			c.Post()
+			tokenInfo, ok := c.Data["json"].(*models.TokenInfo)
+			So(ok, ShouldBeTrue)
+			So(tokenInfo.Scope, ShouldEqual, "app_support traffic")
+		})
+		Convey("for undeclared scope", func() {
+			c := getOAuthTokenController(url.Values{"grant_type": {"client_credentials"},
+				"client_id": {oauthTestAk}, "client_secret": {oauthTestSk}, "scope": {"app_support dns"}})
+			c.Post()
+			So(c.Ctx.ResponseWriter.Status, ShouldEqual, http.StatusBadRequest)
+			So(c.Data["json"].(*models.OAuthErrorInfo).Error, ShouldEqual, oauthInvalidScope)
+		})
+		Convey("for invalid secret", func() {
+			for i := int64(0); i < util.ValidationCounter; i++ {
+				c := getOAuthTokenController(url.Values{"grant_type": {"client_credentials"}})
+				c.Ctx.Request.SetBasicAuth(oauthTestAk, "secret")
+				c.Post()
+				So(c.Ctx.ResponseWriter.Status, ShouldEqual, http.StatusUnauthorized)
+				So(c.Ctx.ResponseWriter.Header().Get("WWW-Authenticate"), ShouldNotBeEmpty)
+				So(c.Data["json"].(*models.OAuthErrorInfo).Error, ShouldEqual, oauthInvalidClient)
+			}
+			c := getOAuthTokenController(url.Values{"grant_type": {"client_credentials"},
+				"client_id": {oauthTestAk}, "client_secret": {oauthTestSk}})
+			c.Post()
+			So(c.Data["json"].(*models.OAuthErrorInfo).ErrorDescription, ShouldEqual, "Access is locked.")
+		})
+		Convey("for unknown client", func() {
+			c := getOAuthTokenController(url.Values{"grant_type": {"client_credentials"},
+				"client_id": {"AAAAAAAAAAAAAAAAAAAA"}, "client_secret": {oauthTestSk}})
+			c.Post()
+			So(c.Ctx.ResponseWriter.Status, ShouldEqual, http.StatusUnauthorized)
+			So(c.Data["json"].(*models.OAuthErrorInfo).Error, ShouldEqual, oauthInvalidClient)
+		})
+		Convey("for bad requests", func() {
+			cases := []struct {
+				form    url.Values
+				basic   bool
+				errCode string
+			}{
+				{url.Values{"client_id": {oauthTestAk}}, false, oauthInvalidRequest},
+				{url.Values{"grant_type": {"password"}}, false, oauthUnsupportedGrantType},
+				{url.Values{"grant_type": {"client_credentials"}, "client_id": {oauthTestAk}}, true,
+					oauthInvalidRequest},
+				{url.Values{"grant_type": {"client_credentials", "client_credentials"}}, false, oauthInvalidRequest},
+				{url.Values{"grant_type": {"client_credentials"}, "scope": {"a\"b"}}, false, oauthInvalidScope},
+			}
+			for _, tc := range cases {
+				c := getOAuthTokenController(tc.form)
+				if tc.basic {
+					c.Ctx.Request.SetBasicAuth(oauthTestAk, oauthTestSk)
+				}
+				c.Post()
+				So(c.Ctx.ResponseWriter.Status, ShouldEqual, http.StatusBadRequest)
+				So(c.Data["json"].(*models.OAuthErrorInfo).Error, ShouldEqual, tc.errCode)
+			}
+		})
+	})
+}
+
+func TestRefreshTokenGrant(t *testing.T) {
+	patches := patchOAuthClient()
+	patches.ApplyFunc(generateJwtToken, func(_ string, _ string, _ []string) (*string, error) {
+		token := string([]byte("jwtToken"))
+		return &token, nil
+	})
+	defer patches.Reset()
+
+	Convey("refresh token grant", t, func() {
+		InitAuthInfoList()
+		storePatches := patchTokenStore()
+		defer storePatches.Reset()
+		refreshToken, _ := issueRefreshToken(oauthTestAppInsId, oauthTestAk, []string{"app_support", "traffic"})
+
+		Convey("for success with narrowed scope", func() {
+			c := getOAuthTokenController(url.Values{"grant_type": {"refresh_token"},
+				"refresh_token": {refreshToken}, "scope": {"traffic"}})
+			c.Ctx.Request.SetBasicAuth(oauthTestAk, oauthTestSk)
+			c.Post()
+			tokenInfo, ok := c.Data["json"].(*models.TokenInfo)
+			So(ok, ShouldBeTrue)
+			So(tokenInfo.Scope, ShouldEqual, "traffic")
+			// the refresh token is rotated
+			So(refreshTokenStore, ShouldHaveLength, 1)
+			So(refreshTokenStore, ShouldNotContainKey, hashToken(refreshToken))
+		})
+		Convey("for widened scope", func() {
+			narrowed, _ := issueRefreshToken(oauthTestAppInsId, oauthTestAk, []string{"app_support"})
+			c := getOAuthTokenController(url.Values{"grant_type": {"refresh_token"},
+				"refresh_token": {narrowed}, "scope": {"traffic"}})
+			c.Ctx.Request.SetBasicAuth(oauthTestAk, oauthTestSk)
+			c.Post()
+			So(c.Ctx.ResponseWriter.Status, ShouldEqual, http.StatusBadRequest)
+			So(c.Data["json"].(*models.OAuthErrorInfo).Error, ShouldEqual, oauthInvalidScope)
+			So(refreshTokenStore, ShouldContainKey, hashToken(narrowed))
+		})
+		Convey("for invalid grants", func() {
+			otherApp, _ := issueRefreshToken("0bde4782-2c70-4e47-9a4e-0ee3a1a0fd1f", oauthTestAk, nil)
+			for _, token := range []string{otherApp, "unknown"} {
+				c := getOAuthTokenController(url.Values{"grant_type": {"refresh_token"},
+					"refresh_token": {token}, "client_id": {oauthTestAk}, "client_secret": {oauthTestSk}})
+				c.Post()
+				So(c.Ctx.ResponseWriter.Status, ShouldEqual, http.StatusBadRequest)
+				So(c.Data["json"].(*models.OAuthErrorInfo).Error, ShouldEqual, oauthInvalidGrant)
+			}
+			So(refreshTokenStore, ShouldContainKey, hashToken(otherApp))
+		})
+		Convey("for concurrent reuse", func() {
+			// another request consumes the token between the read and the delete of this one
+			var pgdb *adapter.PgDb
+			deletePatches := ApplyMethod(reflect.TypeOf(pgdb), "DeleteDataCount",
+				func(*adapter.PgDb, interface{}, ...string) (int64, error) {
+					return 0, nil
+				})
+			defer deletePatches.Reset()
+			c := getOAuthTokenController(url.Values{"grant_type": {"refresh_token"},
+				"refresh_token": {refreshToken}, "client_id": {oauthTestAk}, "client_secret": {oauthTestSk}})
+			c.Post()
+			So(c.Ctx.ResponseWriter.Status, ShouldEqual, http.StatusBadRequest)
+			So(c.Data["json"].(*models.OAuthErrorInfo).Error, ShouldEqual, oauthInvalidGrant)
+			// no new refresh token is issued
+			So(refreshTokenStore, ShouldHaveLength, 1)
+		})
+		Convey("for missing refresh token", func() {
+			c := getOAuthTokenController(url.Values{"grant_type": {"refresh_token"},
+				"client_id": {oauthTestAk}, "client_secret": {oauthTestSk}})
+			c.Post()
+			So(c.Ctx.ResponseWriter.Status, ShouldEqual, http.StatusBadRequest)
+			So(c.Data["json"].(*models.OAuthErrorInfo).Error, ShouldEqual, oauthInvalidRequest)
+		})
+	})
+}
+
+func getOAuthTokenController(form url.Values) *TokenController {
+	c := &TokenController{}
+	c.Init(context.NewContext(), "", "", nil)
+	req, err := http.NewRequest("POST", "http://127.0.0.1/mep/token", strings.NewReader(""))
+	if err != nil {
+		log.Error("prepare http request failed")
+	}
+	c.Ctx.Request = req
+	c.Ctx.Request.Header.Set("X-Real-Ip", "127.0.0.1")
+	c.Ctx.Request.Header.Set(util.ContentType, formContentType)
+	c.Ctx.ResponseWriter = &context.Response{}
+	c.Ctx.ResponseWriter.ResponseWriter = httptest.NewRecorder()
+	c.Ctx.Output = context.NewOutput()
+	c.Ctx.Input = context.NewInput()
+	c.Ctx.Output.Reset(c.Ctx)
+	c.Ctx.Input.Reset(c.Ctx)
+	c.Ctx.Input.RequestBody = []byte(form.Encode())
+	return c
+}
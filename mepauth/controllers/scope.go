@@ -0,0 +1,114 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package controllers implements mep auth controller
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"mepauth/adapter"
+	"mepauth/models"
+	"mepauth/util"
+)
+
+var errScopeNotDeclared = errors.New("requested scope is not in the required services")
+
+// Get the required services configured for the app instance
+func getRequiredServices(appInsId string) (string, error) {
+	authInfoRecord := &models.AuthInfoRecord{
+		AppInsId: appInsId,
+	}
+	err := adapter.Db.ReadData(authInfoRecord, appInstanceID)
+	if err != nil && err.Error() != util.PgOkMsg {
+		log.Error("Failed to read required services of app instance " + appInsId)
+		return "", err
+	}
+	return authInfoRecord.RequiredServices, nil
+}
+
+// Parse the required services, * or service-discovery allow all services the same way mepserver does
+func declaredServices(requiredServices string) ([]string, bool, error) {
+	if requiredServices == util.AllServices || strings.Contains(requiredServices, util.ServiceDiscovery) {
+		return nil, true, nil
+	}
+	if requiredServices == "" {
+		return []string{}, false, nil
+	}
+	var services []string
+	err := json.Unmarshal([]byte(requiredServices), &services)
+	if err != nil {
+		return nil, false, err
+	}
+	return services, false, nil
+}
+
+func isServiceDeclared(service string, declared []string) bool {
+	for _, declaredService := range declared {
+		if declaredService == service {
+			return true
+		}
+	}
+	return false
+}
+
+// Grant the services of the token, a requested scope has to be within the required services
+func grantServices(requiredServices string, scope string) ([]string, error) {
+	declared, all, err := declaredServices(requiredServices)
+	if err != nil {
+		log.Error("Failed to parse required services")
+		return nil, err
+	}
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		if all {
+			return []string{util.AllServices}, nil
+		}
+		return declared, nil
+	}
+	granted := make([]string, 0, len(requested))
+	seen := make(map[string]bool, len(requested))
+	for _, service := range requested {
+		if seen[service] {
+			continue
+		}
+		seen[service] = true
+		if !all && !isServiceDeclared(service, declared) {
+			return nil, errScopeNotDeclared
+		}
+		granted = append(granted, service)
+	}
+	return granted, nil
+}
+
+// Narrow previously granted services to the requested scope, a refresh must not widen the grant
+func narrowServices(granted []string, scope string) ([]string, error) {
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return granted, nil
+	}
+	all := isServiceDeclared(util.AllServices, granted)
+	for _, service := range requested {
+		if !all && !isServiceDeclared(service, granted) {
+			return nil, errScopeNotDeclared
+		}
+	}
+	return requested, nil
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGrantServices(t *testing.T) {
+	Convey("grant services", t, func() {
+		Convey("for required services", func() {
+			services, err := grantServices(`["serviceA","serviceB"]`, "")
+			So(err, ShouldBeNil)
+			So(services, ShouldResemble, []string{"serviceA", "serviceB"})
+		})
+		Convey("for no required services", func() {
+			services, err := grantServices("", "")
+			So(err, ShouldBeNil)
+			So(services, ShouldBeEmpty)
+			_, err = grantServices("", "serviceA")
+			So(err, ShouldEqual, errScopeNotDeclared)
+		})
+		Convey("for requested scope", func() {
+			services, err := grantServices(`["serviceA","serviceB"]`, "serviceB serviceB")
+			So(err, ShouldBeNil)
+			So(services, ShouldResemble, []string{"serviceB"})
+			_, err = grantServices(`["serviceA","serviceB"]`, "serviceB serviceC")
+			So(err, ShouldEqual, errScopeNotDeclared)
+		})
+		Convey("for all services", func() {
+			services, err := grantServices("*", "")
+			So(err, ShouldBeNil)
+			So(services, ShouldResemble, []string{"*"})
+			services, err = grantServices(`["service-discovery"]`, "serviceC")
+			So(err, ShouldBeNil)
+			So(services, ShouldResemble, []string{"serviceC"})
+		})
+		Convey("for malformed required services", func() {
+			_, err := grantServices("serviceA", "")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -50,6 +50,11 @@ type TokenController struct {
 // @Param   authorization  header  string  true   "Certification Information"
 // @Param   x-sdk-date     header  string  true   "Signature time, current timestamp, format: YYYYMMDDTHHMMSSZ"
 // @Param   Host           header  string  true   "Consistent with the host field used to generate the authentication information signature"
+// @Param   grant_type     formData  string  false  "OAuth2 grant type, client_credentials or refresh_token"
+// @Param   refresh_token  formData  string  false  "OAuth2 refresh token, for the refresh_token grant"
+// @Param   client_id      formData  string  false  "OAuth2 client id, the AK, when not given by basic auth"
+// @Param   client_secret  formData  string  false  "OAuth2 client secret, the SK, when not given by basic auth"
+// @Param   scope          formData  string  false  "OAuth2 requested scope"
 // @Success 200 ok
 // @Failure 400 bad request
 // @router /token [post]
@@ -61,6 +66,10 @@ func (c *TokenController) Post() {
 		c.handleLoggingForError(clientIp, http.StatusBadRequest, util.ClientIpaddressInvalid)
 		return
 	}
+	if isOAuthTokenRequest(c.Ctx.Request) {
+		c.postOAuthToken(clientIp)
+		return
+	}
 	// Below we first check the formats of the header is correct or not
 	header := c.Ctx.Input.Header(authorization)
 	ak, signHeader, sig := parseAuthHeader(header)
@@ -98,7 +107,13 @@ func (c *TokenController) Post() {
 	}
 	clearAkFromBlockListing(ak)
 
-	tokenInfo := c.getTokenInfo(appInsId, ak)
+	services, err := c.getGrantedServices(appInsId, "")
+	if err != nil {
+		c.writeErrorResponse(internalError, http.StatusInternalServerError)
+		c.logErrResponseMsgWithAk(clientIp, "Granting services failed", ak)
+		return
+	}
+	tokenInfo := c.getTokenInfo(appInsId, ak, services)
 	if tokenInfo == nil {
 		return
 	}
@@ -107,43 +122,66 @@ func (c *TokenController) Post() {
 
 type jwtClaims struct {
 	jwt.StandardClaims
-	ClientIp string `json:"clientip"`
+	ClientIp string   `json:"clientip"`
+	Scope    string   `json:"scope"`
+	Services []string `json:"services"`
+}
+
+// Services granted to the app, limited to the requested scope if any
+func (c *TokenController) getGrantedServices(appInsId string, scope string) ([]string, error) {
+	requiredServices, err := getRequiredServices(appInsId)
+	if err != nil {
+		return nil, err
+	}
+	return grantServices(requiredServices, scope)
 }
 
-func generateJwtToken(appInsId string, clientIp string) (*string, error) {
-	privateKey, err := util.GetPrivateKey()
+func generateJwtToken(appInsId string, clientIp string, services []string) (*string, error) {
+	keyRecord, privateKey, err := getSigningKey()
 	if privateKey == nil || err != nil {
 		return nil, errors.New("failed to get private key")
 	}
+	// Clear the private key
+	defer util.ClearPrivateKey(privateKey)
 
 	mepAuthKey := util.GetAppConfig("mepauth_key")
 	if len(mepAuthKey) == 0 {
 		msg := "mep auth key configuration is not set"
 		log.Error(msg)
-		// Clear the private key
-		privateKeyBits := privateKey.D.Bits()
-		for i := 0; i < len(privateKeyBits); i++ {
-			privateKeyBits[i] = 0
-		}
 		return nil, errors.New(msg)
 	}
+	signingMethod, err := util.GetJwtSigningMethod(keyRecord.Alg)
+	if err != nil {
+		log.Error("Unsupported signing algorithm of jwt key " + keyRecord.Kid)
+		return nil, err
+	}
+	if services == nil {
+		services = []string{}
+	}
+	tokenId, err := newRandomToken(tokenIdSize)
+	if err != nil {
+		log.Error("Failed to generate the token id")
+		return nil, err
+	}
+	now := time.Now()
 	claims := jwtClaims{
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: jwt.At(time.Now().Add(time.Hour * 1)),
+			ExpiresAt: jwt.At(now.Add(time.Duration(util.ExpiresVal) * time.Second)),
+			IssuedAt:  jwt.At(now),
+			ID:        tokenId,
 			Issuer:    mepAuthKey,
 			Subject:   appInsId,
 		},
 		ClientIp: clientIp,
+		Scope:    strings.Join(services, " "),
+		Services: services,
 	}
 
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodRS512, claims)
+	jwtToken := jwt.NewWithClaims(signingMethod, claims)
+	// the api gateway and the verifiers pick the public key by the kid
+	jwtToken.Header["kid"] = keyRecord.Kid
 
 	token, err := jwtToken.SignedString(privateKey)
-	// Clear the private key
-	privateKeyBits := privateKey.D.Bits()
-	for i := 0; i < len(privateKeyBits); i++ {
-		privateKeyBits[i] = 0
-	}
 	if err != nil || token == "" {
 		log.Error("Failed to sign the token for application Instance ID [" + appInsId + "]")
 		return nil, err
@@ -272,23 +310,32 @@ func (c *TokenController) isSignatureValid(ak string, sk []byte, signHeader stri
 	return true
 }
 
-func (c *TokenController) getTokenInfo(appInsId string, ak string) *models.TokenInfo {
+func (c *TokenController) getTokenInfo(appInsId string, ak string, services []string) *models.TokenInfo {
 	clientIp := c.Ctx.Request.Header.Get(xRealIp)
 	if clientIp == "" {
 		clientIp = "UNKNOWN_IP"
 	}
 
-	token, err := generateJwtToken(appInsId, clientIp)
+	token, err := generateJwtToken(appInsId, clientIp, services)
 	if err != nil {
 		c.writeErrorResponse(internalError, http.StatusInternalServerError)
 		c.logErrResponseMsgWithAk(clientIp, "Generation of jwt token failed", ak)
 		return nil
 	}
 
+	refreshToken, err := issueRefreshToken(appInsId, ak, services)
+	if err != nil {
+		c.writeErrorResponse(internalError, http.StatusInternalServerError)
+		c.logErrResponseMsgWithAk(clientIp, "Issuing refresh token failed", ak)
+		return nil
+	}
+
 	tokenInfo := &models.TokenInfo{
-		AccessToken: *token,
-		TokenType:   "Bearer",
-		ExpiresIn:   util.ExpiresVal,
+		AccessToken:  *token,
+		TokenType:    "Bearer",
+		ExpiresIn:    util.ExpiresVal,
+		Scope:        strings.Join(services, " "),
+		RefreshToken: refreshToken,
 	}
 	return tokenInfo
 }
@@ -306,6 +353,8 @@ func (c *TokenController) sendResponseMsg(ak string, tokenInfo *models.TokenInfo
 	c.ServeJSON()
 	bKey := *(*[]byte)(unsafe.Pointer(&tokenInfo.AccessToken))
 	util.ClearByteArray(bKey)
+	bRefreshKey := *(*[]byte)(unsafe.Pointer(&tokenInfo.RefreshToken))
+	util.ClearByteArray(bRefreshKey)
 	log.Info("Response message for ClientIP [" + clientIp + "] ClientAK [" + ak + "]" +
 		" operation [" + c.Ctx.Request.Method + "] resource [" + c.Ctx.Input.URL() + "] Result [Success]")
 }
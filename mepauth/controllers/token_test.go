@@ -17,6 +17,7 @@
 package controllers
 
 import (
+	"crypto"
 	"crypto/rsa"
 	"encoding/hex"
 	"errors"
@@ -61,28 +62,42 @@ func TestGetTokenInfo(t *testing.T) {
 		ak := "QVUJMSUMgS0VZLS0tLS0"
 		token := "jwtToken"
 		c := getController()
+		refreshPatches := ApplyFunc(issueRefreshToken, func(_ string, _ string, _ []string) (string, error) {
+			return "refreshToken", nil
+		})
+		defer refreshPatches.Reset()
 		Convey("Client ip is nil", func() {
 			c.Ctx.Request.Header.Set("X-Real-Ip", "")
-			patches := ApplyFunc(generateJwtToken, func(_ string, _ string) (*string, error) {
+			patches := ApplyFunc(generateJwtToken, func(_ string, _ string, _ []string) (*string, error) {
 				return &token, nil
 			})
 			defer patches.Reset()
-			So(c.getTokenInfo(appInsId, ak), ShouldNotBeNil)
+			So(c.getTokenInfo(appInsId, ak, nil), ShouldNotBeNil)
 		})
 		Convey("for success", func() {
 			c.Ctx.Request.Header.Set("X-Real-Ip", "127.0.0.1")
-			patches := ApplyFunc(generateJwtToken, func(_ string, _ string) (*string, error) {
+			patches := ApplyFunc(generateJwtToken, func(_ string, _ string, _ []string) (*string, error) {
 				return &token, nil
 			})
 			defer patches.Reset()
-			So(c.getTokenInfo(appInsId, ak), ShouldNotBeNil)
+			So(c.getTokenInfo(appInsId, ak, nil), ShouldNotBeNil)
 		})
 		Convey("for fail", func() {
-			patches := ApplyFunc(generateJwtToken, func(_ string, _ string) (*string, error) {
+			patches := ApplyFunc(generateJwtToken, func(_ string, _ string, _ []string) (*string, error) {
 				return nil, errors.New("generate token fail")
 			})
 			defer patches.Reset()
-			So(c.getTokenInfo(appInsId, ak), ShouldBeNil)
+			So(c.getTokenInfo(appInsId, ak, nil), ShouldBeNil)
+		})
+		Convey("for refresh token fail", func() {
+			patches := ApplyFunc(generateJwtToken, func(_ string, _ string, _ []string) (*string, error) {
+				return &token, nil
+			})
+			patches.ApplyFunc(issueRefreshToken, func(_ string, _ string, _ []string) (string, error) {
+				return "", errors.New("db error")
+			})
+			defer patches.Reset()
+			So(c.getTokenInfo(appInsId, ak, nil), ShouldBeNil)
 		})
 	})
 }
@@ -228,6 +243,7 @@ func TestGetAppInsIdSk(t *testing.T) {
 			patches.ApplyFunc(util.GetWorkKey, func() ([]byte, error) {
 				return []byte("validKey"), errors.New("get work key fail")
 			})
+			defer patches.Reset()
 			appInsId, _, ok := getAppInsIdSk("QVUJMSUMgS0VZLS0tLS0")
 			So(appInsId, ShouldEqual, "")
 			So(ok, ShouldBeTrue)
@@ -248,6 +264,7 @@ func TestGetAppInsIdSk(t *testing.T) {
 			patches.ApplyFunc(util.DecryptByAES256GCM, func(_, _, _ []byte) ([]byte, error) {
 				return nil, errors.New("for decrypt fail")
 			})
+			defer patches.Reset()
 			appInsId, _, ok := getAppInsIdSk("QVUJMSUMgS0VZLS0tLS0")
 			So(appInsId, ShouldEqual, "")
 			So(ok, ShouldBeTrue)
@@ -335,25 +352,25 @@ func TestGenerateJwtToken(t *testing.T) {
 	}
 	Convey("generate jwt token", t, func() {
 		Convey("for success", func() {
-			patches := ApplyFunc(util.GetPrivateKey, func() (*rsa.PrivateKey, error) {
-				return priv, nil
+			patches := ApplyFunc(getSigningKey, func() (*models.JwtKeyRecord, crypto.Signer, error) {
+				return &models.JwtKeyRecord{Kid: "kid", Alg: util.RS512}, priv, nil
 			})
 			patches.ApplyMethod(reflect.TypeOf(token), "SignedString", func(_ *jwt.Token, _ interface{}, _ ...jwt.SigningOption) (string, error) {
 				return "token_content", nil
 			})
 
 			defer patches.Reset()
-			token, err := generateJwtToken(appInsId, clientIp)
+			token, err := generateJwtToken(appInsId, clientIp, []string{"serviceA"})
 
 			So(token, ShouldNotEqual, "")
 			So(err, ShouldBeNil)
 		})
 		Convey("for fail", func() {
-			patches := ApplyFunc(util.GetPrivateKey, func() (*rsa.PrivateKey, error) {
-				return nil, errors.New("get private key fail")
+			patches := ApplyFunc(getSigningKey, func() (*models.JwtKeyRecord, crypto.Signer, error) {
+				return nil, nil, errors.New("get private key fail")
 			})
 			defer patches.Reset()
-			token, err := generateJwtToken(appInsId, clientIp)
+			token, err := generateJwtToken(appInsId, clientIp, []string{"serviceA"})
 
 			So(token, ShouldBeNil)
 			So(err.Error(), ShouldEqual, "failed to get private key")
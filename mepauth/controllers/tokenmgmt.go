@@ -0,0 +1,204 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package controllers implements mep auth controller
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"mepauth/models"
+	"mepauth/util"
+)
+
+// @Title Revokes a token
+// @Description revoke an access or refresh token of the client, RFC 7009
+// @Param   Content-Type     header    string  true   "MIME type, fill in application/x-www-form-urlencoded"
+// @Param   token            formData  string  true   "The access or refresh token to revoke"
+// @Param   token_type_hint  formData  string  false  "access_token or refresh_token"
+// @Param   client_id        formData  string  false  "OAuth2 client id, the AK, when not given by basic auth"
+// @Param   client_secret    formData  string  false  "OAuth2 client secret, the SK, when not given by basic auth"
+// @Success 200 ok
+// @Failure 400 bad request
+// @Failure 401 unauthorized
+// @router /token/revoke [post]
+func (c *TokenController) Revoke() {
+	log.Info("Revoke token request received.")
+	client, token, ok := c.parseTokenMgmtRequest()
+	if !ok {
+		return
+	}
+	clientIp := c.Ctx.Request.Header.Get(xRealIp)
+	// invalid tokens and tokens of other clients are ignored, the response is the same
+	if err := revokeToken(client.appInsId, token); err != nil {
+		c.writeOAuthError(http.StatusServiceUnavailable, oauthServerError, internalError, false)
+		c.logErrResponseMsgWithAk(clientIp, "Revoking token failed", client.ak)
+		return
+	}
+	c.Ctx.Output.Header("Cache-Control", "no-store")
+	c.Data["json"] = "Revoke success."
+	c.handleLoggingForSuccess(clientIp, "Revoke success.")
+}
+
+// @Title Introspects a token
+// @Description get the state of an access or refresh token of the client, RFC 7662
+// @Param   Content-Type     header    string  true   "MIME type, fill in application/x-www-form-urlencoded"
+// @Param   token            formData  string  true   "The access or refresh token to introspect"
+// @Param   token_type_hint  formData  string  false  "access_token or refresh_token"
+// @Param   client_id        formData  string  false  "OAuth2 client id, the AK, when not given by basic auth"
+// @Param   client_secret    formData  string  false  "OAuth2 client secret, the SK, when not given by basic auth"
+// @Success 200 ok
+// @Failure 400 bad request
+// @Failure 401 unauthorized
+// @router /token/introspect [post]
+func (c *TokenController) Introspect() {
+	log.Info("Introspect token request received.")
+	client, token, ok := c.parseTokenMgmtRequest()
+	if !ok {
+		return
+	}
+	clientIp := c.Ctx.Request.Header.Get(xRealIp)
+	info, err := introspectToken(client.appInsId, token)
+	if err != nil {
+		c.writeOAuthError(http.StatusInternalServerError, oauthServerError, internalError, false)
+		c.logErrResponseMsgWithAk(clientIp, "Introspecting token failed", client.ak)
+		return
+	}
+	c.Ctx.Output.Header("Cache-Control", "no-store")
+	c.Data["json"] = info
+	c.handleLoggingForSuccess(clientIp, "")
+}
+
+// Validate the source, the form and the client of a revoke or introspect request
+func (c *TokenController) parseTokenMgmtRequest() (*oauthClient, string, bool) {
+	clientIp := c.Ctx.Request.Header.Get(xRealIp)
+	if err := c.validateSrcAddress(clientIp); err != nil {
+		c.handleLoggingForError(clientIp, http.StatusBadRequest, util.ClientIpaddressInvalid)
+		return nil, "", false
+	}
+	c.logReceivedMsg(clientIp)
+	if !isOAuthTokenRequest(c.Ctx.Request) {
+		c.writeOAuthError(http.StatusBadRequest, oauthInvalidRequest, "Form content type is expected.", false)
+		c.logErrResponseMsg(clientIp, "Bad content type")
+		return nil, "", false
+	}
+	form, err := c.parseTokenForm()
+	if err != nil {
+		c.writeOAuthError(http.StatusBadRequest, oauthInvalidRequest, err.Error(), false)
+		c.logErrResponseMsg(clientIp, "Bad token request")
+		return nil, "", false
+	}
+	token := form.Get("token")
+	if token == "" {
+		c.writeOAuthError(http.StatusBadRequest, oauthInvalidRequest, "Missing token.", false)
+		c.logErrResponseMsg(clientIp, "Bad token request")
+		return nil, "", false
+	}
+	client, ok := c.authenticateClient(clientIp, form)
+	if !ok {
+		return nil, "", false
+	}
+	return client, token, true
+}
+
+// Revoke a refresh token or an access token of the app, both types are looked up so the hint is not needed
+func revokeToken(appInsId string, token string) error {
+	record, err := readRefreshToken(token)
+	if err == nil && record.AppInsId == appInsId {
+		return deleteRefreshToken(record)
+	}
+	if err != nil && err != errTokenInvalid {
+		return err
+	}
+	claims, err := parseAccessToken(token)
+	if err != nil || claims.Subject != appInsId {
+		return nil
+	}
+	return denyAccessToken(claims)
+}
+
+// Introspect a refresh token or an access token of the app, tokens of other apps are inactive
+func introspectToken(appInsId string, token string) (*models.IntrospectionInfo, error) {
+	record, err := readRefreshToken(token)
+	if err == nil && record.AppInsId == appInsId {
+		return &models.IntrospectionInfo{
+			Active:    true,
+			Scope:     strings.Join(parseServices(record.Services), " "),
+			ClientId:  record.Ak,
+			TokenType: grantTypeRefreshToken,
+			Exp:       record.ExpiresAt.Unix(),
+			Iat:       record.IssuedAt.Unix(),
+			Sub:       record.AppInsId,
+		}, nil
+	}
+	if err != nil && err != errTokenInvalid {
+		return nil, err
+	}
+	claims, err := parseAccessToken(token)
+	if err != nil || claims.Subject != appInsId {
+		return &models.IntrospectionInfo{Active: false}, nil
+	}
+	denied, err := isAccessTokenDenied(claims)
+	if err != nil {
+		return nil, err
+	}
+	if denied {
+		return &models.IntrospectionInfo{Active: false}, nil
+	}
+	return &models.IntrospectionInfo{
+		Active:    true,
+		Scope:     claims.Scope,
+		TokenType: "Bearer",
+		Exp:       claims.ExpiresAt.Unix(),
+		Iat:       claims.IssuedAt.Unix(),
+		Sub:       claims.Subject,
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+	}, nil
+}
+
+// TokenDenyListController token deny list controller
+type TokenDenyListController struct {
+	BaseController
+}
+
+// @Title Gets the token deny list
+// @Description get the revoked access tokens and apps, for the api gateway to reject their tokens
+// @Success 200 ok
+// @Failure 400 bad request
+// @router /appMng/v1/tokens/denylist [get]
+func (c *TokenDenyListController) Get() {
+	log.Info("Get token deny list request received.")
+	clientIp := c.Ctx.Request.Header.Get(xRealIp)
+	err := c.validateSrcAddress(clientIp)
+	if err != nil {
+		c.handleLoggingForError(clientIp, http.StatusBadRequest, util.ClientIpaddressInvalid)
+		return
+	}
+	c.logReceivedMsg(clientIp)
+
+	denyList, err := getDenyList(time.Now())
+	if err != nil {
+		c.handleLoggingForError(clientIp, http.StatusInternalServerError, "Error while reading token deny list")
+		return
+	}
+	c.Data["json"] = denyList
+	c.handleLoggingForSuccess(clientIp, "")
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"mepauth/models"
+)
+
+func TestRevokeToken(t *testing.T) {
+	patches := patchOAuthClient()
+	defer patches.Reset()
+
+	Convey("revoke token", t, func() {
+		InitAuthInfoList()
+		storePatches := patchTokenStore()
+		defer storePatches.Reset()
+		keyPatches := patchJwtKey()
+		defer keyPatches.Reset()
+
+		Convey("for refresh token", func() {
+			refreshToken, _ := issueRefreshToken(oauthTestAppInsId, oauthTestAk, nil)
+			c := getOAuthTokenController(url.Values{"token": {refreshToken},
+				"token_type_hint": {"refresh_token"}})
+			c.Ctx.Request.SetBasicAuth(oauthTestAk, oauthTestSk)
+			c.Revoke()
+			So(c.Data["json"], ShouldEqual, "Revoke success.")
+			So(refreshTokenStore, ShouldBeEmpty)
+		})
+		Convey("for access token", func() {
+			token, _ := generateJwtToken(oauthTestAppInsId, "127.0.0.1", nil)
+			c := getOAuthTokenController(url.Values{"token": {*token}})
+			c.Ctx.Request.SetBasicAuth(oauthTestAk, oauthTestSk)
+			c.Revoke()
+			So(c.Data["json"], ShouldEqual, "Revoke success.")
+			So(tokenDenyStore, ShouldHaveLength, 1)
+		})
+		Convey("for token of other app", func() {
+			token, _ := generateJwtToken("0bde4782-2c70-4e47-9a4e-0ee3a1a0fd1f", "127.0.0.1", nil)
+			c := getOAuthTokenController(url.Values{"token": {*token}})
+			c.Ctx.Request.SetBasicAuth(oauthTestAk, oauthTestSk)
+			c.Revoke()
+			So(c.Data["json"], ShouldEqual, "Revoke success.")
+			So(tokenDenyStore, ShouldBeEmpty)
+		})
+		Convey("for bad requests", func() {
+			c := getOAuthTokenController(url.Values{"token": {"token"}})
+			c.Ctx.Request.SetBasicAuth(oauthTestAk, "secret")
+			c.Revoke()
+			So(c.Ctx.ResponseWriter.Status, ShouldEqual, http.StatusUnauthorized)
+			So(c.Data["json"].(*models.OAuthErrorInfo).Error, ShouldEqual, oauthInvalidClient)
+
+			c = getOAuthTokenController(url.Values{})
+			c.Ctx.Request.SetBasicAuth(oauthTestAk, oauthTestSk)
+			c.Revoke()
+			So(c.Ctx.ResponseWriter.Status, ShouldEqual, http.StatusBadRequest)
+			So(c.Data["json"].(*models.OAuthErrorInfo).Error, ShouldEqual, oauthInvalidRequest)
+		})
+	})
+}
+
+func TestIntrospectToken(t *testing.T) {
+	patches := patchOAuthClient()
+	defer patches.Reset()
+
+	Convey("introspect token", t, func() {
+		InitAuthInfoList()
+		storePatches := patchTokenStore()
+		defer storePatches.Reset()
+		keyPatches := patchJwtKey()
+		defer keyPatches.Reset()
+
+		introspect := func(token string) *models.IntrospectionInfo {
+			c := getOAuthTokenController(url.Values{"token": {token},
+				"client_id": {oauthTestAk}, "client_secret": {oauthTestSk}})
+			c.Introspect()
+			return c.Data["json"].(*models.IntrospectionInfo)
+		}
+
+		Convey("for access token", func() {
+			token, _ := generateJwtToken(oauthTestAppInsId, "127.0.0.1", []string{"traffic"})
+			info := introspect(*token)
+			So(info.Active, ShouldBeTrue)
+			So(info.Scope, ShouldEqual, "traffic")
+			So(info.Sub, ShouldEqual, oauthTestAppInsId)
+			So(info.Jti, ShouldNotBeEmpty)
+
+			So(revokeAppTokens(oauthTestAppInsId), ShouldBeNil)
+			So(introspect(*token).Active, ShouldBeFalse)
+		})
+		Convey("for refresh token", func() {
+			refreshToken, _ := issueRefreshToken(oauthTestAppInsId, oauthTestAk, []string{"traffic"})
+			info := introspect(refreshToken)
+			So(info.Active, ShouldBeTrue)
+			So(info.TokenType, ShouldEqual, grantTypeRefreshToken)
+			So(info.ClientId, ShouldEqual, oauthTestAk)
+		})
+		Convey("for unknown token", func() {
+			So(introspect("unknown"), ShouldResemble, &models.IntrospectionInfo{Active: false})
+		})
+	})
+}
+
+func TestGetTokenDenyList(t *testing.T) {
+	Convey("get token deny list", t, func() {
+		storePatches := patchTokenStore()
+		defer storePatches.Reset()
+		So(revokeAppTokens(oauthTestAppInsId), ShouldBeNil)
+
+		c := &TokenDenyListController{}
+		c.Init(getOAuthTokenController(nil).Ctx, "", "", nil)
+		c.Get()
+		denyList, ok := c.Data["json"].(*models.DenyListInfo)
+		So(ok, ShouldBeTrue)
+		So(denyList.Jtis, ShouldBeEmpty)
+		So(denyList.Apps, ShouldHaveLength, 1)
+		So(denyList.Apps[0].AppInsId, ShouldEqual, oauthTestAppInsId)
+	})
+}
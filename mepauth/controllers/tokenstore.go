@@ -0,0 +1,268 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package controllers implements mep auth controller
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+	"github.com/dgrijalva/jwt-go/v4"
+	log "github.com/sirupsen/logrus"
+
+	"mepauth/adapter"
+	"mepauth/models"
+	"mepauth/util"
+)
+
+const (
+	refreshTokenRecord = "refresh_token_record"
+	tokenDenyRecord    = "token_deny_record"
+	appRevokeRecord    = "app_revoke_record"
+	tokenIdSize        = 16
+	refreshTokenSize   = 32
+)
+
+var errTokenInvalid = errors.New("token is invalid")
+
+// InitTokenStore starts the periodic purge of expired refresh tokens and deny list records
+func InitTokenStore() {
+	go func() {
+		ticker := time.NewTicker(time.Duration(util.TokenPurgeInterval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			purgeTokenRecords(time.Now())
+		}
+	}()
+}
+
+func purgeTokenRecords(now time.Time) {
+	if _, err := adapter.Db.DeleteDataBefore(refreshTokenRecord, "expires_at", now); err != nil {
+		log.Error("Failed to purge expired refresh tokens: " + err.Error())
+	}
+	if _, err := adapter.Db.DeleteDataBefore(tokenDenyRecord, "expires_at", now); err != nil {
+		log.Error("Failed to purge expired deny list records: " + err.Error())
+	}
+	// refresh tokens issued before the revocation may live that long
+	revokedBefore := now.Add(-time.Duration(util.RefreshExpiresVal) * time.Second)
+	if _, err := adapter.Db.DeleteDataBefore(appRevokeRecord, "revoked_at", revokedBefore); err != nil {
+		log.Error("Failed to purge expired app revoke records: " + err.Error())
+	}
+}
+
+func newRandomToken(size int) (string, error) {
+	token := make([]byte, size)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, orm.ErrNoRows)
+}
+
+// Issue a refresh token for the app, only its hash is stored
+func issueRefreshToken(appInsId string, ak string, services []string) (string, error) {
+	token, err := newRandomToken(refreshTokenSize)
+	if err != nil {
+		return "", err
+	}
+	servicesJson, err := json.Marshal(services)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	record := &models.RefreshTokenRecord{
+		TokenHash: hashToken(token),
+		AppInsId:  appInsId,
+		Ak:        ak,
+		Services:  string(servicesJson),
+		IssuedAt:  now,
+		ExpiresAt: now.Add(time.Duration(util.RefreshExpiresVal) * time.Second),
+	}
+	err = adapter.Db.InsertData(record)
+	if err != nil && err.Error() != util.PgOkMsg {
+		return "", err
+	}
+	return token, nil
+}
+
+// Parse the services stored with a refresh token
+func parseServices(services string) []string {
+	var parsed []string
+	if err := json.Unmarshal([]byte(services), &parsed); err != nil {
+		log.Error("Failed to parse refresh token services")
+		return nil
+	}
+	return parsed
+}
+
+// Read a live refresh token, errTokenInvalid when it is unknown, expired or revoked with its app
+func readRefreshToken(token string) (*models.RefreshTokenRecord, error) {
+	record := &models.RefreshTokenRecord{TokenHash: hashToken(token)}
+	err := adapter.Db.ReadData(record, "token_hash")
+	if isNotFound(err) {
+		return nil, errTokenInvalid
+	}
+	if err != nil && err.Error() != util.PgOkMsg {
+		return nil, err
+	}
+	if !time.Now().Before(record.ExpiresAt) {
+		return nil, errTokenInvalid
+	}
+	revoked, err := isAppRevokedSince(record.AppInsId, record.IssuedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errTokenInvalid
+	}
+	return record, nil
+}
+
+// Consume the refresh token, errTokenInvalid when a concurrent request consumed it first. Only the request whose
+// delete removed the row may issue the new tokens.
+func deleteRefreshToken(record *models.RefreshTokenRecord) error {
+	num, err := adapter.Db.DeleteDataCount(record, "token_hash")
+	if isNotFound(err) {
+		return errTokenInvalid
+	}
+	if err != nil && err.Error() != util.PgOkMsg {
+		return err
+	}
+	if num != 1 {
+		return errTokenInvalid
+	}
+	return nil
+}
+
+// Whether the tokens of the app were revoked at or after the issue time. The times are compared in whole seconds,
+// the precision of the iat claim, both rounded down. A token issued within the second of the revocation is taken as
+// revoked, as it can not be told whether it was issued before or after it.
+func isAppRevokedSince(appInsId string, issuedAt time.Time) (bool, error) {
+	record := &models.AppRevokeRecord{AppInsId: appInsId}
+	err := adapter.Db.ReadData(record, appInstanceID)
+	if isNotFound(err) {
+		return false, nil
+	}
+	if err != nil && err.Error() != util.PgOkMsg {
+		return false, err
+	}
+	return issuedAt.Unix() <= record.RevokedAt.Unix(), nil
+}
+
+// Revoke all tokens issued to the app so far
+func revokeAppTokens(appInsId string) error {
+	record := &models.AppRevokeRecord{
+		AppInsId:  appInsId,
+		RevokedAt: time.Now(),
+	}
+	err := adapter.Db.InsertOrUpdateData(record, appInstanceID)
+	if err != nil && err.Error() != util.PgOkMsg {
+		return err
+	}
+	return nil
+}
+
+// Put the access token on the deny list until it expires
+func denyAccessToken(claims *jwtClaims) error {
+	record := &models.TokenDenyRecord{
+		Jti:       claims.ID,
+		AppInsId:  claims.Subject,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+	err := adapter.Db.InsertOrUpdateData(record, "jti")
+	if err != nil && err.Error() != util.PgOkMsg {
+		return err
+	}
+	return nil
+}
+
+func isAccessTokenDenied(claims *jwtClaims) (bool, error) {
+	record := &models.TokenDenyRecord{Jti: claims.ID}
+	err := adapter.Db.ReadData(record, "jti")
+	if err == nil || err.Error() == util.PgOkMsg {
+		return true, nil
+	}
+	if !isNotFound(err) {
+		return false, err
+	}
+	return isAppRevokedSince(claims.Subject, claims.IssuedAt.Time)
+}
+
+// Parse and verify an access token issued by mepauth, it is verified by the key set key of its kid
+func parseAccessToken(token string) (*jwtClaims, error) {
+	claims := &jwtClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, ok := t.Header["kid"].(string)
+		if !ok {
+			return nil, errTokenInvalid
+		}
+		return getVerificationKey(kid, t.Method.Alg())
+	})
+	if err != nil {
+		return nil, errTokenInvalid
+	}
+	if claims.ID == "" || claims.IssuedAt == nil || claims.ExpiresAt == nil ||
+		claims.Issuer != util.GetAppConfig("mepauth_key") {
+		return nil, errTokenInvalid
+	}
+	return claims, nil
+}
+
+// Get the revoked tokens which have not expired yet
+func getDenyList(now time.Time) (*models.DenyListInfo, error) {
+	var denied []*models.TokenDenyRecord
+	if _, err := adapter.Db.ReadAllData(tokenDenyRecord, &denied); err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	var revoked []*models.AppRevokeRecord
+	if _, err := adapter.Db.ReadAllData(appRevokeRecord, &revoked); err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	denyList := &models.DenyListInfo{
+		Jtis: make([]string, 0, len(denied)),
+		Apps: make([]models.AppRevokeInfo, 0, len(revoked)),
+	}
+	for _, record := range denied {
+		if now.Before(record.ExpiresAt) {
+			denyList.Jtis = append(denyList.Jtis, record.Jti)
+		}
+	}
+	// access tokens issued before this are expired anyway
+	issuedAfter := now.Add(-time.Duration(util.ExpiresVal) * time.Second)
+	for _, record := range revoked {
+		if record.RevokedAt.After(issuedAfter) {
+			denyList.Apps = append(denyList.Apps, models.AppRevokeInfo{
+				AppInsId:  record.AppInsId,
+				RevokedAt: record.RevokedAt.Unix(),
+			})
+		}
+	}
+	return denyList, nil
+}
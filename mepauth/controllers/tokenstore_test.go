@@ -0,0 +1,263 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/agiledragon/gomonkey"
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"mepauth/adapter"
+	"mepauth/models"
+	"mepauth/util"
+)
+
+const tokenStoreAppInsId = "5abe4782-2c70-4e47-9a4e-0ee3a1a0fd1f"
+
+var (
+	refreshTokenStore map[string]models.RefreshTokenRecord
+	tokenDenyStore    map[string]models.TokenDenyRecord
+	appRevokeStore    map[string]models.AppRevokeRecord
+	jwtKeyStore       map[string]models.JwtKeyRecord
+)
+
+// Work key of the tests, the callers clear the returned copy
+const testWorkKey = "0123456789abcdef0123456789abcdef"
+
+func patchTokenStore() *gomonkey.Patches {
+	refreshTokenStore = make(map[string]models.RefreshTokenRecord)
+	tokenDenyStore = make(map[string]models.TokenDenyRecord)
+	appRevokeStore = make(map[string]models.AppRevokeRecord)
+	jwtKeyStore = make(map[string]models.JwtKeyRecord)
+	adapter.Db = &adapter.PgDb{}
+	var pgdb *adapter.PgDb
+	patches := gomonkey.ApplyMethod(reflect.TypeOf(pgdb), "ReadData",
+		func(_ *adapter.PgDb, data interface{}, _ ...string) error {
+			var ok bool
+			switch record := data.(type) {
+			case *models.RefreshTokenRecord:
+				*record, ok = refreshTokenStore[record.TokenHash]
+			case *models.TokenDenyRecord:
+				*record, ok = tokenDenyStore[record.Jti]
+			case *models.AppRevokeRecord:
+				*record, ok = appRevokeStore[record.AppInsId]
+			}
+			if !ok {
+				return orm.ErrNoRows
+			}
+			return nil
+		})
+	patches.ApplyMethod(reflect.TypeOf(pgdb), "InsertData",
+		func(_ *adapter.PgDb, data interface{}) error {
+			switch record := data.(type) {
+			case *models.RefreshTokenRecord:
+				refreshTokenStore[record.TokenHash] = *record
+			case *models.JwtKeyRecord:
+				jwtKeyStore[record.Kid] = *record
+			}
+			return nil
+		})
+	patches.ApplyMethod(reflect.TypeOf(pgdb), "InsertOrUpdateData",
+		func(_ *adapter.PgDb, data interface{}, _ ...string) error {
+			switch record := data.(type) {
+			case *models.TokenDenyRecord:
+				tokenDenyStore[record.Jti] = *record
+			case *models.AppRevokeRecord:
+				appRevokeStore[record.AppInsId] = *record
+			case *models.JwtKeyRecord:
+				jwtKeyStore[record.Kid] = *record
+			}
+			return nil
+		})
+	// block list records of the db block list are not kept
+	patches.ApplyMethod(reflect.TypeOf(pgdb), "UpdateDataLocked",
+		func(_ *adapter.PgDb, _ interface{}, _ func(bool) bool, _ ...string) error {
+			return nil
+		})
+	patches.ApplyMethod(reflect.TypeOf(pgdb), "DeleteDataCount",
+		func(_ *adapter.PgDb, data interface{}, _ ...string) (int64, error) {
+			record, ok := data.(*models.RefreshTokenRecord)
+			if !ok {
+				return 0, nil
+			}
+			if _, ok = refreshTokenStore[record.TokenHash]; !ok {
+				return 0, nil
+			}
+			delete(refreshTokenStore, record.TokenHash)
+			return 1, nil
+		})
+	patches.ApplyMethod(reflect.TypeOf(pgdb), "DeleteData",
+		func(_ *adapter.PgDb, data interface{}, _ ...string) error {
+			switch record := data.(type) {
+			case *models.JwtKeyRecord:
+				delete(jwtKeyStore, record.Kid)
+			}
+			return nil
+		})
+	patches.ApplyMethod(reflect.TypeOf(pgdb), "ReadAllData",
+		func(_ *adapter.PgDb, _ string, container interface{}) (int64, error) {
+			switch records := container.(type) {
+			case *[]*models.TokenDenyRecord:
+				for _, stored := range tokenDenyStore {
+					record := stored
+					*records = append(*records, &record)
+				}
+			case *[]*models.AppRevokeRecord:
+				for _, stored := range appRevokeStore {
+					record := stored
+					*records = append(*records, &record)
+				}
+			case *[]*models.JwtKeyRecord:
+				for _, stored := range jwtKeyStore {
+					record := stored
+					*records = append(*records, &record)
+				}
+			}
+			return 0, nil
+		})
+	return patches
+}
+
+// Sign and verify the access tokens with a new ES256 key set, the token store has to be patched first
+func patchJwtKey() *gomonkey.Patches {
+	patches := gomonkey.ApplyFunc(util.GetWorkKey, func() ([]byte, error) {
+		return []byte(testWorkKey), nil
+	})
+	key, _ := util.GenerateJwtKey(util.ES256)
+	record, _ := newJwtKeyRecord(util.ES256, key)
+	jwtKeyStore[record.Kid] = *record
+	jwtKeys.keys = []*models.JwtKeyRecord{record}
+	beego.AppConfig.Set("mepauth_key", "mepauth")
+	return patches
+}
+
+func TestRefreshTokenStore(t *testing.T) {
+	Convey("refresh token store", t, func() {
+		patches := patchTokenStore()
+		defer patches.Reset()
+
+		token, err := issueRefreshToken(tokenStoreAppInsId, "ak", []string{"app_support"})
+		So(err, ShouldBeNil)
+		So(refreshTokenStore, ShouldNotContainKey, token)
+
+		Convey("for reading", func() {
+			record, err := readRefreshToken(token)
+			So(err, ShouldBeNil)
+			So(record.AppInsId, ShouldEqual, tokenStoreAppInsId)
+			So(parseServices(record.Services), ShouldResemble, []string{"app_support"})
+			_, err = readRefreshToken("unknown")
+			So(err, ShouldEqual, errTokenInvalid)
+		})
+		Convey("for expiry", func() {
+			record := refreshTokenStore[hashToken(token)]
+			record.ExpiresAt = time.Now()
+			refreshTokenStore[record.TokenHash] = record
+			_, err := readRefreshToken(token)
+			So(err, ShouldEqual, errTokenInvalid)
+		})
+		Convey("for app revocation", func() {
+			So(revokeAppTokens(tokenStoreAppInsId), ShouldBeNil)
+			_, err := readRefreshToken(token)
+			So(err, ShouldEqual, errTokenInvalid)
+			// tokens issued in the seconds after the revocation are valid
+			record := appRevokeStore[tokenStoreAppInsId]
+			record.RevokedAt = time.Now().Add(-time.Second)
+			appRevokeStore[tokenStoreAppInsId] = record
+			reissued, err := issueRefreshToken(tokenStoreAppInsId, "ak", nil)
+			So(err, ShouldBeNil)
+			_, err = readRefreshToken(reissued)
+			So(err, ShouldBeNil)
+		})
+		Convey("for revocation in the same second", func() {
+			revokedAt := time.Unix(time.Now().Unix(), int64(500*time.Millisecond))
+			appRevokeStore[tokenStoreAppInsId] = models.AppRevokeRecord{AppInsId: tokenStoreAppInsId,
+				RevokedAt: revokedAt}
+			for _, issuedAt := range []time.Time{revokedAt.Add(-400 * time.Millisecond),
+				revokedAt.Add(400 * time.Millisecond)} {
+				revoked, err := isAppRevokedSince(tokenStoreAppInsId, issuedAt)
+				So(err, ShouldBeNil)
+				So(revoked, ShouldBeTrue)
+			}
+			revoked, err := isAppRevokedSince(tokenStoreAppInsId, revokedAt.Add(600*time.Millisecond))
+			So(err, ShouldBeNil)
+			So(revoked, ShouldBeFalse)
+		})
+		Convey("for deletion", func() {
+			record, _ := readRefreshToken(token)
+			So(deleteRefreshToken(record), ShouldBeNil)
+			_, err := readRefreshToken(token)
+			So(err, ShouldEqual, errTokenInvalid)
+			// a refresh token is consumed only once
+			So(deleteRefreshToken(record), ShouldEqual, errTokenInvalid)
+		})
+	})
+}
+
+func TestAccessTokenRevocation(t *testing.T) {
+	Convey("access token revocation", t, func() {
+		patches := patchTokenStore()
+		defer patches.Reset()
+		keyPatches := patchJwtKey()
+		defer keyPatches.Reset()
+
+		token, err := generateJwtToken(tokenStoreAppInsId, "127.0.0.1", []string{"app_support"})
+		So(err, ShouldBeNil)
+		claims, err := parseAccessToken(*token)
+		So(err, ShouldBeNil)
+		So(claims.ID, ShouldNotBeEmpty)
+		So(claims.Subject, ShouldEqual, tokenStoreAppInsId)
+		denied, err := isAccessTokenDenied(claims)
+		So(err, ShouldBeNil)
+		So(denied, ShouldBeFalse)
+
+		Convey("for bad tokens", func() {
+			_, err := parseAccessToken("bad.token.value")
+			So(err, ShouldEqual, errTokenInvalid)
+			beego.AppConfig.Set("mepauth_key", "other")
+			defer beego.AppConfig.Set("mepauth_key", "mepauth")
+			_, err = parseAccessToken(*token)
+			So(err, ShouldEqual, errTokenInvalid)
+		})
+		Convey("for token revocation", func() {
+			So(denyAccessToken(claims), ShouldBeNil)
+			denied, err := isAccessTokenDenied(claims)
+			So(err, ShouldBeNil)
+			So(denied, ShouldBeTrue)
+			denyList, err := getDenyList(time.Now())
+			So(err, ShouldBeNil)
+			So(denyList.Jtis, ShouldResemble, []string{claims.ID})
+			So(denyList.Apps, ShouldBeEmpty)
+			denyList, _ = getDenyList(time.Now().Add(time.Duration(util.ExpiresVal) * time.Second))
+			So(denyList.Jtis, ShouldBeEmpty)
+		})
+		Convey("for app revocation", func() {
+			So(revokeAppTokens(tokenStoreAppInsId), ShouldBeNil)
+			denied, err := isAccessTokenDenied(claims)
+			So(err, ShouldBeNil)
+			So(denied, ShouldBeTrue)
+			denyList, err := getDenyList(time.Now())
+			So(err, ShouldBeNil)
+			So(denyList.Apps, ShouldHaveLength, 1)
+			So(denyList.Apps[0].AppInsId, ShouldEqual, tokenStoreAppInsId)
+		})
+	})
+}
@@ -125,7 +125,18 @@ func main() {
 		beego.BeeApp.Server.TLSConfig = tlsConf
 	}
 
+	initializer := newApiGwInitializer()
+	if initializer == nil {
+		return
+	}
+	err = controllers.InitJwtKeySet(initializer)
+	if err != nil {
+		log.Error("Failed to initialize jwt signing keys")
+		return
+	}
+
 	controllers.InitAuthInfoList()
+	controllers.InitTokenStore()
 	setSwaggerConfig()
 	beego.ErrorController(&controllers.ErrorController{})
 	beego.Run()
@@ -151,17 +162,11 @@ func doInitialization(trustedNetworks *[]byte) bool {
 		return false
 	}
 
-	var config *tls.Config
-	if strings.EqualFold(os.Getenv("SSL_ENABLED"), "true") {
-		config, err = util.TLSConfig("apigw_cacert")
-		if err != nil {
-			log.Error("Failed to add TLS configurations during API gateway initialization")
-			return false
-		}
+	initializer := newApiGwInitializer()
+	if initializer == nil {
+		return false
 	}
 
-	initializer := &apiGwInitializer{tlsConfig: config}
-
 	err = initializer.InitAPIGateway(trustedNetworks)
 	if err != nil {
 		log.Error("Failed to initialize API gateway.")
@@ -170,3 +175,17 @@ func doInitialization(trustedNetworks *[]byte) bool {
 
 	return true
 }
+
+// Create the api gateway initializer, nil if the TLS configuration fails
+func newApiGwInitializer() *apiGwInitializer {
+	var config *tls.Config
+	if strings.EqualFold(os.Getenv("SSL_ENABLED"), "true") {
+		var err error
+		config, err = util.TLSConfig("apigw_cacert")
+		if err != nil {
+			log.Error("Failed to add TLS configurations during API gateway initialization")
+			return nil
+		}
+	}
+	return &apiGwInitializer{tlsConfig: config}
+}
@@ -228,6 +228,9 @@ func TestMainFunc(t *testing.T) {
 			patches.ApplyFunc(util.TLSConfig, func(crtName string) (*tls.Config, error) {
 				return nil, nil
 			})
+			patches.ApplyFunc(controllers.InitJwtKeySet, func(publisher controllers.JwtKeyPublisher) error {
+				return nil
+			})
 			patches.ApplyFunc(beego.Run, func(params ...string) {
 				return
 			})
@@ -24,7 +24,7 @@ import (
 )
 
 func init() {
-	orm.RegisterModel(new(AuthInfoRecord))
+	orm.RegisterModel(new(AuthInfoRecord), new(AkBlockRecord))
 }
 
 // AuthInfoRecord authentication information record data structure
@@ -37,19 +37,27 @@ type AuthInfoRecord struct {
 	RequiredServices string `json:"required_services"`
 }
 
-// AkSessionInfo AK session information data structure
-type AkSessionInfo struct {
-	ClearTimer      *time.Timer
-	State           string
-	Ak              string
-	ValidateCounter int64
+// AkBlockRecord AK validation and block list record data structure
+type AkBlockRecord struct {
+	Ak              string    `orm:"pk" json:"ak"`
+	State           string    `json:"state"`
+	ValidateCounter int64     `json:"validate_counter"`
+	ExpiresAt       time.Time `orm:"type(datetime)" json:"expires_at"`
 }
 
 // TokenInfo token information data structure
 type TokenInfo struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
-	ExpiresIn   uint32 `json:"expires_in"`
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    uint32 `json:"expires_in"`
+	Scope        string `json:"scope,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// OAuthErrorInfo OAuth2 token error response data structure
+type OAuthErrorInfo struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
 }
 
 // AuthInfo authentication information data structure
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package model contains mep auth data model
+package models
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+)
+
+func init() {
+	orm.RegisterModel(new(JwtKeyRecord))
+}
+
+// JwtKeyRecord jwt signing key record data structure, the private key is encrypted by the work key
+type JwtKeyRecord struct {
+	Kid        string    `orm:"pk" json:"kid"`
+	Alg        string    `json:"alg"`
+	PublicKey  string    `orm:"type(text)" json:"public_key"`
+	PrivateKey string    `orm:"type(text)" json:"private_key"`
+	Nonce      string    `json:"nonce"`
+	CreatedAt  time.Time `orm:"type(datetime)" json:"created_at"`
+}
+
+// JwkSet json web key set data structure, RFC 7517
+type JwkSet struct {
+	Keys []Jwk `json:"keys"`
+}
+
+// Jwk json web key data structure, RFC 7517 and RFC 8037
+type Jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JwtKeyInfo jwt signing key information data structure
+type JwtKeyInfo struct {
+	Kid       string `json:"kid"`
+	Alg       string `json:"alg"`
+	CreatedAt int64  `json:"createdAt"`
+	RetiresAt int64  `json:"retiresAt,omitempty"`
+}
+
+// JwtCredentialInfo api gateway jwt credential data structure
+type JwtCredentialInfo struct {
+	Algorithm    string `json:"algorithm"`
+	Key          string `json:"key"`
+	RsaPublicKey string `json:"rsa_public_key"`
+}
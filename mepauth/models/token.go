@@ -0,0 +1,77 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package model contains mep auth data model
+package models
+
+import (
+	"time"
+
+	"github.com/astaxie/beego/orm"
+)
+
+func init() {
+	orm.RegisterModel(new(RefreshTokenRecord), new(TokenDenyRecord), new(AppRevokeRecord))
+}
+
+// RefreshTokenRecord refresh token record data structure, only the hash of the token is stored
+type RefreshTokenRecord struct {
+	TokenHash string    `orm:"pk" json:"token_hash"`
+	AppInsId  string    `json:"app_ins_id"`
+	Ak        string    `json:"ak"`
+	Services  string    `json:"services"`
+	IssuedAt  time.Time `orm:"type(datetime)" json:"issued_at"`
+	ExpiresAt time.Time `orm:"type(datetime)" json:"expires_at"`
+}
+
+// TokenDenyRecord revoked access token record data structure, kept until the token expires
+type TokenDenyRecord struct {
+	Jti       string    `orm:"pk" json:"jti"`
+	AppInsId  string    `json:"app_ins_id"`
+	ExpiresAt time.Time `orm:"type(datetime)" json:"expires_at"`
+}
+
+// AppRevokeRecord app token revocation record data structure, tokens of the app issued until RevokedAt are
+// revoked
+type AppRevokeRecord struct {
+	AppInsId  string    `orm:"pk" json:"app_ins_id"`
+	RevokedAt time.Time `orm:"type(datetime)" json:"revoked_at"`
+}
+
+// DenyListInfo token deny list data structure for the api gateway
+type DenyListInfo struct {
+	Jtis []string        `json:"jtis"`
+	Apps []AppRevokeInfo `json:"apps"`
+}
+
+// AppRevokeInfo app token revocation data structure
+type AppRevokeInfo struct {
+	AppInsId  string `json:"appInsId"`
+	RevokedAt int64  `json:"revokedAt"`
+}
+
+// IntrospectionInfo token introspection response data structure, RFC 7662
+type IntrospectionInfo struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientId  string `json:"client_id,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Iss       string `json:"iss,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+}
@@ -25,6 +25,9 @@ import (
 const (
 	confController  = "mepauth/controllers:ConfController"
 	tokenController = "mepauth/controllers:TokenController"
+	blockController = "mepauth/controllers:BlockListController"
+	denyController  = "mepauth/controllers:TokenDenyListController"
+	keyController   = "mepauth/controllers:JwtKeyController"
 )
 
 const (
@@ -40,7 +43,15 @@ const (
 	appManagePrefix     string = "/appMng/v1"
 	AuthTokenPath              = rootPath + authTokenPrefix
 	AppManagePath              = rootPath + appManagePrefix
+	TokenDenyListPath          = rootPath + denyListRoute
+	JwksPath                   = "/.well-known/jwks.json"
 	confControllerRoute        = appManagePrefix + "/applications/:applicationId/confs"
+	denyListRoute              = appManagePrefix + "/tokens/denylist"
+	keysRoute                  = appManagePrefix + "/keys"
+	keyRotateRoute             = keysRoute + "/rotate"
+	blockListRoute             = appManagePrefix + "/blocklist"
+	revokeRoute                = authTokenPrefix + "/revoke"
+	introspectRoute            = authTokenPrefix + "/introspect"
 )
 
 func init() {
@@ -77,4 +88,63 @@ func init() {
 			MethodParams:     param.Make(),
 			Filters:          nil,
 			Params:           nil})
+	beego.GlobalControllerRouter[tokenController] = append(beego.GlobalControllerRouter[tokenController],
+		beego.ControllerComments{
+			Method:           "Revoke",
+			Router:           revokeRoute,
+			AllowHTTPMethods: []string{post},
+			MethodParams:     param.Make(),
+			Filters:          nil,
+			Params:           nil})
+	beego.GlobalControllerRouter[tokenController] = append(beego.GlobalControllerRouter[tokenController],
+		beego.ControllerComments{
+			Method:           "Introspect",
+			Router:           introspectRoute,
+			AllowHTTPMethods: []string{post},
+			MethodParams:     param.Make(),
+			Filters:          nil,
+			Params:           nil})
+
+	beego.GlobalControllerRouter[blockController] = append(beego.GlobalControllerRouter[blockController],
+		beego.ControllerComments{
+			Method:           "Get",
+			Router:           blockListRoute,
+			AllowHTTPMethods: []string{get},
+			MethodParams:     param.Make(),
+			Filters:          nil,
+			Params:           nil})
+	beego.GlobalControllerRouter[blockController] = append(beego.GlobalControllerRouter[blockController],
+		beego.ControllerComments{
+			Method:           "Delete",
+			Router:           blockListRoute,
+			AllowHTTPMethods: []string{deleteOp},
+			MethodParams:     param.Make(),
+			Filters:          nil,
+			Params:           nil})
+
+	beego.GlobalControllerRouter[denyController] = append(beego.GlobalControllerRouter[denyController],
+		beego.ControllerComments{
+			Method:           "Get",
+			Router:           denyListRoute,
+			AllowHTTPMethods: []string{get},
+			MethodParams:     param.Make(),
+			Filters:          nil,
+			Params:           nil})
+
+	beego.GlobalControllerRouter[keyController] = append(beego.GlobalControllerRouter[keyController],
+		beego.ControllerComments{
+			Method:           "Get",
+			Router:           keysRoute,
+			AllowHTTPMethods: []string{get},
+			MethodParams:     param.Make(),
+			Filters:          nil,
+			Params:           nil})
+	beego.GlobalControllerRouter[keyController] = append(beego.GlobalControllerRouter[keyController],
+		beego.ControllerComments{
+			Method:           "Rotate",
+			Router:           keyRotateRoute,
+			AllowHTTPMethods: []string{post},
+			MethodParams:     param.Make(),
+			Filters:          nil,
+			Params:           nil})
 }
@@ -33,10 +33,15 @@ func init() {
 	beego.Get("/health", func(ctx *context.Context) {
 		ctx.Output.Context.ResponseWriter.ResponseWriter.Write([]byte("ok"))
 	})
+	// the key set is served at the well-known location, outside of the mep namespace
+	beego.Router(JwksPath, &controllers.JwksController{}, "get:Get")
 	ns := beego.NewNamespace("/mep/",
 		beego.NSInclude(
 			&controllers.ConfController{},
 			&controllers.TokenController{},
+			&controllers.BlockListController{},
+			&controllers.TokenDenyListController{},
+			&controllers.JwtKeyController{},
 		),
 	)
 	beego.AddNamespace(ns)
@@ -25,6 +25,7 @@ const (
 	akRegex                string = `^[\w+/=]{20}$`
 	skRegex                string = `^[\w+/=]{64}$`
 	AuthHeaderRegex        string = `^SDK-HMAC-SHA256 Access=([\w=+/]{20}), SignedHeaders=([^, ]{28}), Signature=([^, ]{64})$`
+	ScopeRegex             string = `^[\x21\x23-\x5b\x5d-\x7e]+( [\x21\x23-\x5b\x5d-\x7e]+)*$`
 	ValidationCounter      int64  = 3
 	ValidateListClearTimer int64  = 300
 	BlockListClearTimer    int64  = 900
@@ -42,6 +43,19 @@ const (
 	BaseVal                       = 10
 	MaxMatchVarSize               = 3
 	ExpiresVal                    = 3600
+	RefreshExpiresVal             = 86400
+	TokenPurgeInterval            = 600
+	JwtKeyCheckInterval           = 300
+	JwtKeyRotationInterval        = 2592000
+	JwtKeyOverlap                 = 7200
+	RsaKeyBits                    = 4096
+)
+
+// JWT signing algorithms
+const (
+	RS512 = "RS512"
+	ES256 = "ES256"
+	EdDSA = "EdDSA"
 )
 
 // End point related constants
@@ -50,6 +64,8 @@ const (
 	MepServerServiceMgmt        = "/mep/mec_service_mgmt"
 	MepServerAppSupport         = "/mep/mec_app_support"
 	MepauthName                 = "mepauth"
+	AllServices                 = "*"
+	ServiceDiscovery            = "service-discovery"
 	ApigwHost            string = "apigw_host"
 	ApigwPort            string = "apigw_port"
 	UrlApplicationId     string = ":applicationId"
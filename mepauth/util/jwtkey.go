@@ -0,0 +1,124 @@
+/*
+ * Copyright 2020 Huawei Technologies Co., Ltd.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package util implements mep auth utility functions and contain constants
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+
+	"github.com/dgrijalva/jwt-go/v4"
+)
+
+// SigningMethodEdDSA signs tokens with Ed25519 keys, RFC 8037
+var SigningMethodEdDSA = &signingMethodEdDSA{}
+
+type signingMethodEdDSA struct{}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+// Alg implements jwt.SigningMethod
+func (m *signingMethodEdDSA) Alg() string {
+	return EdDSA
+}
+
+// Sign implements jwt.SigningMethod
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok || len(privateKey) != ed25519.PrivateKeySize {
+		return "", jwt.NewInvalidKeyTypeError("ed25519.PrivateKey", key)
+	}
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+// Verify implements jwt.SigningMethod
+func (m *signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok || len(publicKey) != ed25519.PublicKeySize {
+		return jwt.NewInvalidKeyTypeError("ed25519.PublicKey", key)
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+// GetJwtSigningMethod gets the signing method of a supported jwt algorithm
+func GetJwtSigningMethod(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case RS512:
+		return jwt.SigningMethodRS512, nil
+	case ES256:
+		return jwt.SigningMethodES256, nil
+	case EdDSA:
+		return SigningMethodEdDSA, nil
+	default:
+		return nil, errors.New("unsupported jwt signing algorithm " + alg)
+	}
+}
+
+// GenerateJwtKey generates a private key for a supported jwt algorithm
+func GenerateJwtKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case RS512:
+		return rsa.GenerateKey(rand.Reader, RsaKeyBits)
+	case ES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case EdDSA:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	default:
+		return nil, errors.New("unsupported jwt signing algorithm " + alg)
+	}
+}
+
+// ClearPrivateKey clears the private part of a jwt key
+func ClearPrivateKey(key crypto.Signer) {
+	switch privateKey := key.(type) {
+	case *rsa.PrivateKey:
+		privateKeyBits := privateKey.D.Bits()
+		for i := 0; i < len(privateKeyBits); i++ {
+			privateKeyBits[i] = 0
+		}
+		for _, prime := range privateKey.Primes {
+			primeBits := prime.Bits()
+			for i := 0; i < len(primeBits); i++ {
+				primeBits[i] = 0
+			}
+		}
+	case *ecdsa.PrivateKey:
+		privateKeyBits := privateKey.D.Bits()
+		for i := 0; i < len(privateKeyBits); i++ {
+			privateKeyBits[i] = 0
+		}
+	case ed25519.PrivateKey:
+		ClearByteArray(privateKey)
+	}
+}
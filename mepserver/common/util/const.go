@@ -159,6 +159,12 @@ const ComponentContent = "j7k0UwOJSsIfi3dzainoBdkcpJJJOJlzd2oBwMQxXdaZ3oCswITWUy
 const ErrorRequestBodyMessage = "request body invalid"
 const XRealIp = "X-Real-Ip"
 
+// AppServicesHeader services the app token is scoped to, set by the api gateway from the token
+const AppServicesHeader = "X-AppServices"
+
+// AllServices scope that does not restrict the services
+const AllServices = "*"
+
 // MaxFQDNLength As per RFC-1035 section-2.3.4, the maximum length of full FQDN name is 255 octets including
 // one length and one null terminating character. Hence it is limited as 253.
 const MaxFQDNLength = 253
@@ -815,6 +815,24 @@ func ReadMepAuthEndpoint() (string, error) {
 	return fmt.Sprintf(MepAuthBaseUrlFormat, httpProtocol, mepAuthIp, mepAuthPort), nil
 }
 
+// GetAppServices get the services the app token is scoped to, scoped is false when the request is not
+// restricted, e.g. tokens issued without a scope
+func GetAppServices(r *http.Request) (services []string, scoped bool) {
+	header := r.Header.Get(AppServicesHeader)
+	if header == "" {
+		return nil, false
+	}
+	err := json.Unmarshal([]byte(header), &services)
+	if err != nil {
+		log.Error("Parse app services header failed.", err)
+		return []string{}, true
+	}
+	if InArray(AllServices, services) {
+		return nil, false
+	}
+	return services, true
+}
+
 // InArray whether the element exists in array
 func InArray(value string, array []string) bool {
 	for _, v := range array {
@@ -1334,6 +1334,136 @@ func TestAppSubscribePost(t *testing.T) {
 	mockWriter.AssertExpectations(t)
 }
 
+// Post App service availability Notification within the services the app token is scoped to
+func TestAppSubscribePostScoped(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf(panicFormatString, r)
+		}
+	}()
+
+	service := Mp1Service{}
+	createSubscription := models.SerAvailabilityNotificationSubscription{
+		SubscriptionType:  "SerAvailabilityNotificationSubscription",
+		CallbackReference: callBackRef,
+		FilteringCriteria: models.FilteringCriteria{
+			SerInstanceIds: []string{
+				"f7e898d1c9ea9edd8a41295fc55c2373",
+			},
+			SerNames: []string{
+				"FaceRegService5",
+			},
+		},
+	}
+	createSubscriptionBytes, _ := json.Marshal(createSubscription)
+	var resp = &pb.GetOneInstanceResponse{
+		Response: &pb.Response{Code: pb.Response_SUCCESS},
+		Instance: &pb.MicroServiceInstance{Properties: map[string]string{"serName": "FaceRegService5"}},
+	}
+	n := &srv.InstanceService{}
+	patch1 := gomonkey.ApplyMethod(reflect.TypeOf(n), "GetOneInstance", func(*srv.InstanceService, context.Context, *pb.GetOneInstanceRequest) (*pb.GetOneInstanceResponse, error) {
+		return resp, nil
+	})
+	defer patch1.Reset()
+	postRequest, _ := http.NewRequest("POST",
+		fmt.Sprintf(postSubscribeUrl, defaultAppInstanceId),
+		bytes.NewReader(createSubscriptionBytes))
+	postRequest.URL.RawQuery = fmt.Sprintf(appInstanceQueryFormat, defaultAppInstanceId)
+	postRequest.Header.Set(appInstanceIdHeader, defaultAppInstanceId)
+	postRequest.Header.Set(util.AppServicesHeader, `["FaceRegService5"]`)
+
+	// Mock the response writer
+	mockWriter := &mockHttpWriterWithoutWrite{}
+	responseHeader := http.Header{} // Create http response header
+	mockWriter.On("Header").Return(responseHeader)
+	mockWriter.On("Write").Return(0, nil)
+	mockWriter.On("WriteHeader", 201)
+
+	service.URLPatterns()[0].Func(mockWriter, postRequest)
+
+	assert.Equal(t, "201", responseHeader.Get(responseStatusHeader),
+		responseCheckFor201)
+	mockWriter.AssertExpectations(t)
+}
+
+// Post App service availability Notification for a service the app token is not scoped to
+func TestAppSubscribePostOutOfScope(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf(panicFormatString, r)
+		}
+	}()
+
+	service := Mp1Service{}
+	createSubscription := models.SerAvailabilityNotificationSubscription{
+		SubscriptionType:  "SerAvailabilityNotificationSubscription",
+		CallbackReference: callBackRef,
+		FilteringCriteria: models.FilteringCriteria{
+			SerNames: []string{
+				"FaceRegService6",
+			},
+		},
+	}
+	createSubscriptionBytes, _ := json.Marshal(createSubscription)
+	postRequest, _ := http.NewRequest("POST",
+		fmt.Sprintf(postSubscribeUrl, defaultAppInstanceId),
+		bytes.NewReader(createSubscriptionBytes))
+	postRequest.URL.RawQuery = fmt.Sprintf(appInstanceQueryFormat, defaultAppInstanceId)
+	postRequest.Header.Set(appInstanceIdHeader, defaultAppInstanceId)
+	postRequest.Header.Set(util.AppServicesHeader, `["FaceRegService5"]`)
+
+	// Mock the response writer
+	mockWriter := &mockHttpWriterWithoutWrite{}
+	responseHeader := http.Header{} // Create http response header
+	mockWriter.On("Header").Return(responseHeader)
+	mockWriter.On("Write").Return(0, nil)
+	mockWriter.On("WriteHeader", 401)
+
+	service.URLPatterns()[0].Func(mockWriter, postRequest)
+
+	assert.Equal(t, "401", responseHeader.Get(responseStatusHeader),
+		"Response status code must be 401 Unauthorized")
+	mockWriter.AssertExpectations(t)
+}
+
+// Post App service availability Notification without filter is narrowed to the app token scope
+func TestAppSubscribePostScopedNoFilter(t *testing.T) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Errorf(panicFormatString, r)
+		}
+	}()
+
+	service := Mp1Service{}
+	createSubscription := models.SerAvailabilityNotificationSubscription{
+		SubscriptionType:  "SerAvailabilityNotificationSubscription",
+		CallbackReference: callBackRef,
+	}
+	createSubscriptionBytes, _ := json.Marshal(createSubscription)
+	postRequest, _ := http.NewRequest("POST",
+		fmt.Sprintf(postSubscribeUrl, defaultAppInstanceId),
+		bytes.NewReader(createSubscriptionBytes))
+	postRequest.URL.RawQuery = fmt.Sprintf(appInstanceQueryFormat, defaultAppInstanceId)
+	postRequest.Header.Set(appInstanceIdHeader, defaultAppInstanceId)
+	postRequest.Header.Set(util.AppServicesHeader, `["FaceRegService5"]`)
+
+	// Mock the response writer
+	mockWriter := &mockHttpWriterWithoutWrite{}
+	responseHeader := http.Header{} // Create http response header
+	mockWriter.On("Header").Return(responseHeader)
+	mockWriter.On("Write").Return(0, nil)
+	mockWriter.On("WriteHeader", 201)
+
+	service.URLPatterns()[0].Func(mockWriter, postRequest)
+
+	assert.Equal(t, "201", responseHeader.Get(responseStatusHeader),
+		responseCheckFor201)
+	notification := models.SerAvailabilityNotificationSubscription{}
+	_ = json.Unmarshal(mockWriter.response, &notification)
+	assert.Equal(t, []string{"FaceRegService5"}, notification.FilteringCriteria.SerNames)
+	mockWriter.AssertExpectations(t)
+}
+
 // Post App service availability Notification With invalid json body
 func TestAppSubscribePostWrongJsonBody(t *testing.T) {
 	defer func() {
@@ -3410,6 +3540,25 @@ func TestMp1CvtSrvAuthenDiscover(t *testing.T) {
 	assert.Equal(t, []*models.ServiceInfo([]*models.ServiceInfo{}), serviceInfos)
 }
 
+// Test scoped discover
+func TestMp1CvtSrvScopedDiscover(t *testing.T) {
+	response := pb.FindInstancesResponse{}
+	for _, serName := range []string{"FaceRegService5", "FaceRegService6"} {
+		response.Instances = append(response.Instances, &pb.MicroServiceInstance{
+			InstanceId: defCapabilityId[len(defCapabilityId)/2:],
+			ServiceId:  defCapabilityId[:len(defCapabilityId)/2],
+			Properties: map[string]string{"serName": serName},
+		})
+	}
+
+	_, serviceInfos := Mp1CvtSrvScopedDiscover(&response, []string{"FaceRegService6"})
+	assert.Equal(t, 1, len(serviceInfos))
+	assert.Equal(t, "FaceRegService6", serviceInfos[0].SerName)
+
+	_, serviceInfos = Mp1CvtSrvScopedDiscover(&response, []string{})
+	assert.Equal(t, 0, len(serviceInfos))
+}
+
 // Query dns rules request in mp1 interface
 func TestGetTransportInfoGetRecordFailed(t *testing.T) {
 	defer func() {
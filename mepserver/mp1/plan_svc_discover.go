@@ -190,6 +190,7 @@ func (t *DiscoverService) OnRequest(data string) workspace.TaskCode {
 
 type ToStrDiscover struct {
 	workspace.TaskBase
+	R          *http.Request   `json:"r,in"`
 	CoreRsp    interface{}     `json:"coreRsp,in"`
 	InstanceId string          `json:"instanceId,in"`
 	Flag       bool            `json:"flag,in"`
@@ -206,7 +207,12 @@ func (t *ToStrDiscover) OnRequest(data string) workspace.TaskCode {
 		return workspace.TaskFinish
 	}
 	if t.Flag {
-		t.HttpErrInf, t.HttpRsp = Mp1CvtSrvAuthenDiscover(value, t.InstanceId)
+		// the token scope is derived from the required services, so no need to query mepauth again
+		if services, scoped := meputil.GetAppServices(t.R); scoped {
+			t.HttpErrInf, t.HttpRsp = Mp1CvtSrvScopedDiscover(value, services)
+		} else {
+			t.HttpErrInf, t.HttpRsp = Mp1CvtSrvAuthenDiscover(value, t.InstanceId)
+		}
 	} else {
 		t.HttpErrInf, t.HttpRsp = Mp1CvtSrvDiscover(value)
 	}
@@ -301,6 +307,22 @@ func Mp1CvtSrvAuthenDiscover(findInsResp *proto.FindInstancesResponse, appInsId
 		}
 	}
 
+	return resp, filterSrvDiscover(findInsResp, isAllService, reqServices)
+}
+
+// Mp1CvtSrvScopedDiscover mp1 cvt service discover limited to the services the app token is scoped to
+func Mp1CvtSrvScopedDiscover(findInsResp *proto.FindInstancesResponse, services []string) (*proto.Response,
+	[]*models.ServiceInfo) {
+	resp := findInsResp.Response
+	if resp != nil && resp.GetCode() != proto.Response_SUCCESS {
+		return resp, nil
+	}
+	return resp, filterSrvDiscover(findInsResp, false, services)
+}
+
+func filterSrvDiscover(findInsResp *proto.FindInstancesResponse, isAllService bool,
+	reqServices []string) []*models.ServiceInfo {
+	serviceInfos := make([]*models.ServiceInfo, 0, len(findInsResp.Instances))
 	for _, ins := range findInsResp.Instances {
 		serviceInfo := &models.ServiceInfo{}
 		serviceInfo.FromServiceInstance(ins)
@@ -309,6 +331,5 @@ func Mp1CvtSrvAuthenDiscover(findInsResp *proto.FindInstancesResponse, appInsId
 			serviceInfos = append(serviceInfos, serviceInfo)
 		}
 	}
-	return resp, serviceInfos
-
+	return serviceInfos
 }
@@ -65,6 +65,11 @@ func (t *SubscribeIst) OnRequest(data string) workspace.TaskCode {
 	if mp1SubscribeInfo == nil {
 		return workspace.TaskFinish
 	}
+	if err := t.checkSubscribeScope(mp1SubscribeInfo); err != nil {
+		log.Error("Subscription is out of the app service scope.", nil)
+		t.SetFirstErrorCode(util.AuthorizationValidateErr, err.Error())
+		return workspace.TaskFinish
+	}
 
 	subscribeJSON, err := json.Marshal(mp1SubscribeInfo)
 	if err != nil {
@@ -195,7 +200,59 @@ func (t *SubscribeIst) checkSubscribeSerInstanceExist(sub interface{}) error {
 	return nil
 }
 
+// checkSubscribeScope limits service availability subscriptions to the services the app token is scoped to,
+// subscriptions without service filter are narrowed to those services
+func (t *SubscribeIst) checkSubscribeScope(sub interface{}) error {
+	serAvl, ok := sub.(*models.SerAvailabilityNotificationSubscription)
+	if !ok {
+		return nil
+	}
+	services, scoped := util.GetAppServices(t.R)
+	if !scoped {
+		return nil
+	}
+	if len(services) == 0 {
+		return errors.New("app has no required services to subscribe")
+	}
+	filter := &serAvl.FilteringCriteria
+	if len(filter.SerCategories) != 0 {
+		return errors.New("subscribe by service category is not allowed for scoped app")
+	}
+	for _, serName := range filter.SerNames {
+		if !util.InArray(serName, services) {
+			return fmt.Errorf("service %s is not in the app required services", serName)
+		}
+	}
+	for _, serInstanceId := range filter.SerInstanceIds {
+		serName, err := getSerInstanceName(t.R, serInstanceId)
+		if err != nil {
+			return err
+		}
+		if !util.InArray(serName, services) {
+			return fmt.Errorf("service instance %s is not in the app required services", serInstanceId)
+		}
+	}
+	if len(filter.SerNames) == 0 && len(filter.SerInstanceIds) == 0 {
+		filter.SerNames = services
+	}
+	return nil
+}
+
+func getSerInstanceName(r *http.Request, serInstanceId string) (string, error) {
+	instance, err := getSerInstance(r, serInstanceId)
+	if err != nil {
+		return "", err
+	}
+	return instance.Properties["serName"], nil
+}
+
 func checkSerInstanceExist(r *http.Request, serInstanceId string) error {
+	_, err := getSerInstance(r, serInstanceId)
+	return err
+}
+
+// getSerInstance get the service instance subscribed to by its id, the service id followed by the instance id
+func getSerInstance(r *http.Request, serInstanceId string) (*proto.MicroServiceInstance, error) {
 	query, ids := util.GetHTTPTags(r)
 	serviceId := serInstanceId[:len(serInstanceId)/2]
 	instanceId := serInstanceId[len(serInstanceId)/2:]
@@ -208,19 +265,19 @@ func checkSerInstanceExist(r *http.Request, serInstanceId string) error {
 	ctx := scutil.SetTargetDomainProject(r.Context(), r.Header.Get("X-Domain-Name"), query.Get(":project"))
 	resp, errGetOneInstance := core.InstanceAPI.GetOneInstance(ctx, req)
 	if errGetOneInstance != nil {
-		return errGetOneInstance
-	}
-	if resp != nil {
-		respCode := resp.Response.GetCode()
-		if respCode == proto.Response_SUCCESS {
-			return nil
-		} else if respCode == scerr.ErrInstanceNotExists || respCode == scerr.ErrServiceNotExists {
-			return fmt.Errorf("subscribe service instance id no exist")
-		} else {
-			return fmt.Errorf("unexpected error")
-		}
+		return nil, errGetOneInstance
 	}
-	return nil
+	if resp == nil {
+		return nil, fmt.Errorf("unexpected error")
+	}
+	respCode := resp.Response.GetCode()
+	if respCode == scerr.ErrInstanceNotExists || respCode == scerr.ErrServiceNotExists ||
+		(respCode == proto.Response_SUCCESS && resp.Instance == nil) {
+		return nil, fmt.Errorf("subscribe service instance id no exist")
+	} else if respCode != proto.Response_SUCCESS {
+		return nil, fmt.Errorf("unexpected error")
+	}
+	return resp.Instance, nil
 }
 
 // AppSubscribeLimit steps to check application subscription limit